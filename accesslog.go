@@ -0,0 +1,153 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// redacted replaces the values of redacted fields in the access log.
+const redacted = "REDACTED"
+
+type optionAccessLog struct {
+	headers []string
+}
+
+func (o optionAccessLog) Apply(mux *treeMux) {
+	mux.accessLog = true
+	mux.accessLogHeaders = o.headers
+}
+
+func (o optionAccessLog) private() {}
+
+// OptionAccessLog logs every request at info level, with its method, path,
+// query, route pattern, response status, response size and duration. The
+// values of the given request headers are logged as well.
+func OptionAccessLog(headers ...string) Option {
+	return optionAccessLog{headers}
+}
+
+type withLogSampling struct {
+	value float64
+}
+
+func (o withLogSampling) Apply(rt *route) {
+	rt.logSampling = o.value
+	rt.hasLogSampling = true
+}
+
+func (o withLogSampling) private() {}
+
+// WithLogSampling only writes access log entries for a sample (rate between 0
+// and 1) of the route's requests. Requests resulting in a server error are
+// always logged.
+func WithLogSampling(rate float64) RouteOption {
+	return withLogSampling{rate}
+}
+
+// LogRedaction lists request fields whose values are replaced with
+// "REDACTED" in the access log.
+type LogRedaction struct {
+	// Query holds the names of query parameters to redact.
+	Query []string
+	// Headers holds the names of request headers to redact.
+	Headers []string
+}
+
+type withLogRedaction struct {
+	value LogRedaction
+}
+
+func (o withLogRedaction) Apply(rt *route) {
+	rt.logRedaction.Query = append(rt.logRedaction.Query, o.value.Query...)
+	rt.logRedaction.Headers = append(rt.logRedaction.Headers, o.value.Headers...)
+}
+
+func (o withLogRedaction) private() {}
+
+// WithLogRedaction keeps sensitive values, like tokens, for the route out of
+// the access log.
+func WithLogRedaction(r LogRedaction) RouteOption {
+	return withLogRedaction{r}
+}
+
+// redactQuery returns the raw query with the values of the given parameters
+// replaced.
+func redactQuery(raw string, names []string) string {
+	if len(names) == 0 || raw == "" {
+		return raw
+	}
+	q, err := url.ParseQuery(raw)
+	if err != nil {
+		return redacted
+	}
+	for _, n := range names {
+		if vs, ok := q[n]; ok {
+			for i := range vs {
+				vs[i] = redacted
+			}
+		}
+	}
+	return q.Encode()
+}
+
+func redactHeader(name string, names []string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// accessLogger wraps the handler so that its requests are logged. The route
+// is nil for unmatched requests.
+func (t *treeMux) accessLogger(h http.Handler, rt *route) http.Handler {
+	pattern, logf := "", t.logf
+	var sampling float64
+	var hasSampling bool
+	var redaction LogRedaction
+	if rt != nil {
+		pattern, logf = rt.pattern, rt.logf
+		sampling, hasSampling = rt.logSampling, rt.hasLogSampling
+		redaction = rt.logRedaction
+	}
+	headers := t.accessLogHeaders
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := t.clock.Now()
+		rw := newResponseWriter(w)
+		h.ServeHTTP(rw, r)
+		status := rw.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if hasSampling && status < 500 && rand.Float64() >= sampling {
+			return
+		}
+		kvs := []interface{}{
+			"method", r.Method,
+			"path", r.URL.Path,
+		}
+		if r.URL.RawQuery != "" {
+			kvs = append(kvs, "query", redactQuery(r.URL.RawQuery, redaction.Query))
+		}
+		for _, name := range headers {
+			v := r.Header.Get(name)
+			if v != "" && redactHeader(name, redaction.Headers) {
+				v = redacted
+			}
+			kvs = append(kvs, "header."+http.CanonicalHeaderKey(name), v)
+		}
+		kvs = append(kvs,
+			"pattern", pattern,
+			"status", status,
+			"bytes", rw.written,
+			"duration", t.clock.Now().Sub(start))
+		logf(LogInfo, "request", kvs...)
+	})
+}
@@ -0,0 +1,173 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// captureLog returns a Logger that collects lines in StdLogger format.
+func captureLog(lines *[]string) Logger {
+	return LoggerFunc(func(level LogLevel, msg string, kvs ...interface{}) {
+		buf := &bytes.Buffer{}
+		StdLogger(log.New(buf, "", 0)).Log(level, msg, kvs...)
+		*lines = append(*lines, strings.TrimSpace(buf.String()))
+	})
+}
+
+func TestOptionAccessLog(t *testing.T) {
+	cases := []struct {
+		name    string
+		options []Option
+		path    string
+		want    []string
+	}{
+		{
+			name:    "disabled",
+			options: nil,
+			path:    "/foo",
+			want:    nil,
+		},
+		{
+			name:    "matched",
+			options: []Option{OptionAccessLog()},
+			path:    "/foo",
+			want:    []string{"INFO: request method=GET path=/foo pattern=/foo status=200 bytes=3 duration="},
+		},
+		{
+			name:    "not found",
+			options: []Option{OptionAccessLog()},
+			path:    "/baz",
+			want:    []string{"INFO: request method=GET path=/baz pattern= status=404 bytes=19 duration="},
+		},
+		{
+			name:    "route level",
+			options: []Option{OptionAccessLog()},
+			path:    "/quiet",
+			want:    nil,
+		},
+		{
+			name:    "debug",
+			options: []Option{OptionDebug()},
+			path:    "/foo",
+			want:    []string{"DEBUG: matched route pattern=/foo path=/foo"},
+		},
+		{
+			name:    "debug route level",
+			options: []Option{OptionDebug()},
+			path:    "/quiet",
+			want:    nil,
+		},
+		{
+			name:    "debug not found",
+			options: []Option{OptionDebug()},
+			path:    "/baz",
+			want:    []string{"DEBUG: no route matched path=/baz"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var got []string
+			tr := NewTreeMux(append(c.options, OptionLogger(captureLog(&got)))...)
+			tr.HandleFunc("/foo", bodyHandler("foo"))
+			tr.HandleFunc("/quiet", bodyHandler("quiet"), WithLogLevel(LogWarning))
+
+			tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, c.path, nil))
+
+			if len(got) != len(c.want) {
+				t.Fatalf("expected %v, got %v", c.want, got)
+			}
+			for i := range c.want {
+				if !strings.HasPrefix(got[i], c.want[i]) {
+					t.Errorf("expected %q, got %q", c.want[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWithLogSampling(t *testing.T) {
+	cases := []struct {
+		name    string
+		path    string
+		rate    float64
+		wantLog bool
+	}{
+		{"none", "/ok", 0, false},
+		{"all", "/ok", 1, true},
+		{"server error", "/fail", 0, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var got []string
+			tr := NewTreeMux(OptionAccessLog(), OptionLogger(captureLog(&got)))
+			tr.HandleFunc("/ok", bodyHandler("ok"), WithLogSampling(c.rate))
+			tr.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadGateway)
+			}, WithLogSampling(c.rate))
+
+			tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, c.path, nil))
+
+			if hasLog := len(got) > 0; hasLog != c.wantLog {
+				t.Errorf("expected log %v, got %v", c.wantLog, got)
+			}
+		})
+	}
+}
+
+func TestWithLogRedaction(t *testing.T) {
+	cases := []struct {
+		name   string
+		target string
+		header string
+		want   string
+	}{
+		{
+			name:   "untouched",
+			target: "/public?token=abc&b=c",
+			header: "Bearer abc",
+			want:   "INFO: request method=GET path=/public query=token=abc&b=c header.Authorization=Bearer abc header.X-Trace=t1 pattern=/public ",
+		},
+		{
+			name:   "redacted",
+			target: "/auth?token=abc&token=def&b=c",
+			header: "Bearer abc",
+			want:   "INFO: request method=GET path=/auth query=b=c&token=REDACTED&token=REDACTED header.Authorization=REDACTED header.X-Trace=t1 pattern=/auth ",
+		},
+		{
+			name:   "absent",
+			target: "/auth",
+			header: "",
+			want:   "INFO: request method=GET path=/auth header.Authorization= header.X-Trace=t1 pattern=/auth ",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var got []string
+			tr := NewTreeMux(OptionAccessLog("authorization", "X-Trace"), OptionLogger(captureLog(&got)))
+			tr.HandleFunc("/public", bodyHandler("public"))
+			tr.HandleFunc("/auth", bodyHandler("auth"), WithLogRedaction(LogRedaction{
+				Query:   []string{"token"},
+				Headers: []string{"Authorization"},
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, c.target, nil)
+			if c.header != "" {
+				r.Header.Set("Authorization", c.header)
+			}
+			r.Header.Set("X-Trace", "t1")
+			tr.ServeHTTP(httptest.NewRecorder(), r)
+
+			if len(got) != 1 || !strings.HasPrefix(got[0], c.want) {
+				t.Errorf("expected %q, got %q", c.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+)
+
+// ACMEManager is the part of autocert.Manager (package
+// golang.org/x/crypto/acme/autocert) used by ACME.
+type ACMEManager interface {
+	HTTPHandler(fallback http.Handler) http.Handler
+}
+
+func (t *treeMux) ACME(manager ACMEManager) {
+	h := manager.HTTPHandler(t.errorHandler(http.StatusNotFound))
+	t.Handle(acmeChallengePath+"*", h)
+}
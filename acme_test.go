@@ -0,0 +1,60 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeACMEManager responds to challenges for a single token, like
+// autocert.Manager.
+type fakeACMEManager struct {
+	token string
+}
+
+func (m fakeACMEManager) HTTPHandler(fallback http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.TrimPrefix(r.URL.Path, acmeChallengePath) != m.token {
+			fallback.ServeHTTP(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("key-authorization"))
+	})
+}
+
+func TestTreeMux_ACME(t *testing.T) {
+	tr := NewTreeMux(OptionRedirectHTTP(""))
+	tr.ACME(fakeACMEManager{"token"})
+	tr.Mount("/", bodyHandler("app"), WithGuard(func(*http.Request) bool { return false }))
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		want   int
+		body   string
+	}{
+		{"challenge", http.MethodGet, "/.well-known/acme-challenge/token", http.StatusOK, "key-authorization"},
+		{"unknown token", http.MethodGet, "/.well-known/acme-challenge/other", http.StatusNotFound, "404 page not found\n"},
+		{"deeper path", http.MethodGet, "/.well-known/acme-challenge/token/x", http.StatusForbidden, "403 forbidden\n"},
+		{"other path", http.MethodGet, "/foo", http.StatusMovedPermanently, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest(c.method, "http://example.com"+c.path, nil)
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, r)
+			if w.Code != c.want {
+				t.Errorf("expected %d, got %d", c.want, w.Code)
+			}
+			if c.body != "" && w.Body.String() != c.body {
+				t.Errorf("expected %q, got %q", c.body, w.Body.String())
+			}
+		})
+	}
+}
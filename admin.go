@@ -0,0 +1,155 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+type routeInfo struct {
+	Pattern     string            `json:"pattern"`
+	Host        string            `json:"host,omitempty"`
+	Name        string            `json:"name,omitempty"`
+	Conditional bool              `json:"conditional,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+// routeInfos returns a description of all routes, sorted by pattern.
+func (t *treeMux) routeInfos() []routeInfo {
+	var xs []routeInfo
+	for _, rt := range t.routes() {
+		ri := routeInfo{Pattern: rt.pattern, Host: rt.host, Name: rt.name, Conditional: rt.conditional()}
+		if len(rt.metadata) > 0 {
+			ri.Metadata = make(map[string]string, len(rt.metadata))
+			for k, v := range rt.metadata {
+				ri.Metadata[k] = fmt.Sprint(v)
+			}
+		}
+		xs = append(xs, ri)
+	}
+	return xs
+}
+
+// writeMetrics writes the mux's metrics in the Prometheus text exposition
+// format, or the OpenMetrics format, which adds exemplars.
+func (t *treeMux) writeMetrics(w io.Writer, openMetrics bool) error {
+	if t.sloTracker != nil {
+		if err := t.sloTracker.WriteMetrics(w); err != nil {
+			return err
+		}
+	}
+	if t.latency != nil {
+		if err := t.latency.write(w, openMetrics); err != nil {
+			return err
+		}
+	}
+	if t.notFoundStats != nil {
+		counts := t.notFoundStats.Counts()
+		prefixes := make([]string, 0, len(counts))
+		for p := range counts {
+			prefixes = append(prefixes, p)
+		}
+		sort.Strings(prefixes)
+		const name = "treemux_not_found_total"
+		family := name
+		if openMetrics {
+			// OpenMetrics names counters without the suffix.
+			family = strings.TrimSuffix(name, "_total")
+		}
+		if _, err := fmt.Fprintf(w, "# HELP %s Unmatched requests by deepest matching prefix.\n# TYPE %s counter\n", family, family); err != nil {
+			return err
+		}
+		for _, p := range prefixes {
+			if _, err := fmt.Fprintf(w, "%s{prefix=%q} %d\n", name, p, counts[p]); err != nil {
+				return err
+			}
+		}
+	}
+	if t.paramStats != nil {
+		card := t.paramStats.Cardinality()
+		patterns := make([]string, 0, len(card))
+		for p := range card {
+			patterns = append(patterns, p)
+		}
+		sort.Strings(patterns)
+		const name = "treemux_param_cardinality"
+		if _, err := fmt.Fprintf(w, "# HELP %s Estimated distinct values per route wildcard.\n# TYPE %s gauge\n", name, name); err != nil {
+			return err
+		}
+		for _, p := range patterns {
+			params := make([]string, 0, len(card[p]))
+			for k := range card[p] {
+				params = append(params, k)
+			}
+			sort.Strings(params)
+			for _, k := range params {
+				if _, err := fmt.Fprintf(w, "%s{pattern=%q,param=%q} %d\n", name, p, k, card[p][k]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if openMetrics {
+		_, err := io.WriteString(w, "# EOF\n")
+		return err
+	}
+	return nil
+}
+
+func (t *treeMux) Admin(options ...Option) TreeMux {
+	a := NewTreeMux(append([]Option{OptionLogger(t.logger)}, options...)...)
+	a.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if t.isDraining() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte("shutting down"))
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	})
+	a.HandleFunc("/routes", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set(HeaderRouteTableHash, t.SnapshotHash())
+		_ = json.NewEncoder(w).Encode(t.routeInfos())
+	})
+	a.HandleFunc("/debug/routes", func(w http.ResponseWriter, r *http.Request) {
+		snapshot := t.Snapshot()
+		sum := sha256.Sum256(snapshot)
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set(HeaderRouteTableHash, hex.EncodeToString(sum[:]))
+		_, _ = w.Write(snapshot)
+	})
+	a.HandleFunc("/debug/routes/hash", func(w http.ResponseWriter, r *http.Request) {
+		hash := t.SnapshotHash()
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set(HeaderRouteTableHash, hash)
+		_, _ = w.Write([]byte(hash + "\n"))
+	})
+	a.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text") {
+			w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+			_ = t.writeMetrics(w, true)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		_ = t.writeMetrics(w, false)
+	})
+	if t.sloTracker != nil {
+		a.Handle("/debug/slo", t.sloTracker)
+	}
+	if t.notFoundStats != nil {
+		a.Handle("/debug/notfound", t.notFoundStats)
+	}
+	if t.paramStats != nil {
+		a.Handle("/debug/params", t.paramStats)
+	}
+	return a
+}
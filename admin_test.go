@@ -0,0 +1,93 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTreeMux_Admin(t *testing.T) {
+	stats := NewNotFoundStats()
+	tr := NewTreeMux(OptionNotFoundStats(stats), OptionSLOTracker(NewSLOTracker()))
+	tr.HandleFunc("/foo", bodyHandler("foo"), WithName("foo"), WithSLO(SLO{Latency: time.Second}))
+	tr.HandleFunc("/foo", bodyHandler("foo"), WithPredicate(hasHeader("X-Foo")), WithMetadata("team", "a"))
+	tr.Mount("/static", bodyHandler("static"))
+	tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/foo", nil))
+	tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/bar", nil))
+
+	admin := tr.Admin()
+
+	cases := []struct {
+		path       string
+		wantStatus int
+		wantBody   []string
+	}{
+		{"/healthz", 200, []string{"ok"}},
+		{"/routes", 200, []string{`[{"pattern":"/foo","conditional":true,"metadata":{"team":"a"}},{"pattern":"/foo","name":"foo"},{"pattern":"/static/**"}]`}},
+		{"/metrics", 200, []string{
+			`treemux_slo_requests{pattern="/foo"} 1`,
+			"# TYPE treemux_not_found_total counter\n",
+			`treemux_not_found_total{prefix="/"} 1`,
+		}},
+		{"/debug/routes", 200, []string{"/foo name=foo\n/static/**\n"}},
+		{"/debug/routes/hash", 200, []string{tr.SnapshotHash() + "\n"}},
+		{"/debug/slo", 200, []string{`"pattern":"/foo"`}},
+		{"/debug/notfound", 200, []string{`{"/":1}`}},
+		{"/foo", 404, nil},
+	}
+	for _, c := range cases {
+		t.Run(c.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			admin.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+			if w.Code != c.wantStatus {
+				t.Errorf("expected status %d, got %d", c.wantStatus, w.Code)
+			}
+			if strings.HasPrefix(c.path, "/debug/routes") || c.path == "/routes" {
+				if got := w.Header().Get(HeaderRouteTableHash); got != tr.SnapshotHash() {
+					t.Errorf("expected hash header %s, got %q", tr.SnapshotHash(), got)
+				}
+			}
+			for _, s := range c.wantBody {
+				if !strings.Contains(w.Body.String(), s) {
+					t.Errorf("expected body to contain %q, got %q", s, w.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestTreeMux_Admin_Minimal(t *testing.T) {
+	admin := NewTreeMux().Admin()
+	for _, p := range []string{"/debug/slo", "/debug/notfound"} {
+		w := httptest.NewRecorder()
+		admin.ServeHTTP(w, httptest.NewRequest(http.MethodGet, p, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected %s to be not found, got %d", p, w.Code)
+		}
+	}
+	w := httptest.NewRecorder()
+	admin.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Body.Len() != 0 {
+		t.Errorf("expected no metrics, got %q", w.Body.String())
+	}
+}
+
+func TestTreeMux_Admin_Shutdown(t *testing.T) {
+	tr := NewTreeMux()
+	admin := tr.Admin()
+	if err := tr.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w := httptest.NewRecorder()
+	admin.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+}
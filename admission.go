@@ -0,0 +1,218 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Admission configures admission control: a limit on the number of requests
+// handled concurrently, with excess requests queued fairly per route.
+//
+// When requests have to wait, free slots are shared between routes in
+// proportion to their weight (see WithPriority). Routes that breach their
+// service level objectives (see WithSLO) have their weight multiplied by
+// BreachingWeight, protecting healthy routes from their load.
+type Admission struct {
+	// MaxInFlight is the maximum number of requests handled concurrently. It
+	// must be positive.
+	MaxInFlight int
+	// MaxWait is how long a request may wait for a slot before it is shed.
+	// Defaults to one second.
+	MaxWait time.Duration
+	// BreachingWeight is the factor applied to the weight of routes that are
+	// breaching their objectives. With zero, their requests are shed
+	// immediately when no slot is available.
+	BreachingWeight float64
+}
+
+var errShed = errors.New("request shed")
+
+type admissionWaiter struct {
+	ch  chan struct{}
+	seq uint64
+}
+
+type admissionQueue struct {
+	weight  float64
+	vtime   float64
+	waiters []admissionWaiter
+}
+
+// admission implements weighted fair queueing over routes.
+type admission struct {
+	cfg     Admission
+	tracker *SLOTracker
+	clock   Clock
+
+	mux      sync.Mutex
+	inFlight int
+	vclock   float64
+	seq      uint64
+	queues   map[string]*admissionQueue
+}
+
+func newAdmission(cfg Admission) *admission {
+	if cfg.MaxWait <= 0 {
+		cfg.MaxWait = time.Second
+	}
+	return &admission{cfg: cfg, clock: realClock{}, queues: make(map[string]*admissionQueue)}
+}
+
+// weight returns the current weight of a route.
+func (a *admission) weight(pattern string, base float64) float64 {
+	if a.tracker != nil {
+		if s, ok := a.tracker.Status(pattern); ok && !s.Compliant {
+			return base * a.cfg.BreachingWeight
+		}
+	}
+	return base
+}
+
+// acquire waits for a slot. It returns an error when the request is shed.
+func (a *admission) acquire(r *http.Request, pattern string, base float64) error {
+	a.mux.Lock()
+	if a.inFlight < a.cfg.MaxInFlight && a.waiting() == 0 {
+		a.inFlight += 1
+		a.mux.Unlock()
+		return nil
+	}
+	weight := a.weight(pattern, base)
+	if weight <= 0 {
+		a.mux.Unlock()
+		return errShed
+	}
+	q, ok := a.queues[pattern]
+	if !ok {
+		q = &admissionQueue{}
+		a.queues[pattern] = q
+	}
+	if len(q.waiters) == 0 && q.vtime < a.vclock {
+		// an idle route does not get to catch up on its share
+		q.vtime = a.vclock
+	}
+	q.weight = weight
+	ch := make(chan struct{})
+	a.seq += 1
+	q.waiters = append(q.waiters, admissionWaiter{ch, a.seq})
+	a.mux.Unlock()
+
+	timer := a.clock.NewTimer(a.cfg.MaxWait)
+	defer timer.Stop()
+	select {
+	case <-ch:
+		return nil
+	case <-timer.C():
+	case <-r.Context().Done():
+	}
+
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	for i, wt := range q.waiters {
+		if wt.ch == ch {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			return errShed
+		}
+	}
+	// the slot was handed over while giving up
+	a.releaseLocked()
+	return errShed
+}
+
+func (a *admission) waiting() int {
+	n := 0
+	for _, q := range a.queues {
+		n += len(q.waiters)
+	}
+	return n
+}
+
+// release hands the slot over to the next waiting request, if any.
+func (a *admission) release() {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	a.releaseLocked()
+}
+
+func (a *admission) releaseLocked() {
+	var next *admissionQueue
+	for _, q := range a.queues {
+		if len(q.waiters) == 0 {
+			continue
+		}
+		// ties are broken by arrival
+		if next == nil || q.vtime < next.vtime || q.vtime == next.vtime && q.waiters[0].seq < next.waiters[0].seq {
+			next = q
+		}
+	}
+	if next == nil {
+		a.inFlight -= 1
+		return
+	}
+	wt := next.waiters[0]
+	next.waiters = next.waiters[1:]
+	a.vclock = next.vtime
+	next.vtime += 1 / next.weight
+	close(wt.ch)
+}
+
+type optionAdmission struct {
+	value Admission
+}
+
+func (o optionAdmission) Apply(mux *treeMux) {
+	mux.admission = newAdmission(o.value)
+}
+
+func (o optionAdmission) private() {}
+
+// OptionAdmission enables admission control. Shed requests get a 503
+// response. It uses the objectives tracked by OptionSLOTracker, if set. It
+// panics when MaxInFlight is not positive.
+func OptionAdmission(cfg Admission) Option {
+	if cfg.MaxInFlight <= 0 {
+		panic(fmt.Sprintf("invalid admission limit %d", cfg.MaxInFlight))
+	}
+	return optionAdmission{cfg}
+}
+
+type withPriority struct {
+	value float64
+}
+
+func (o withPriority) Apply(rt *route) {
+	rt.priority = o.value
+}
+
+func (o withPriority) private() {}
+
+// WithPriority sets the weight of the route for admission control. The
+// default weight is 1.
+func WithPriority(weight float64) RouteOption {
+	return withPriority{weight}
+}
+
+// admit wraps the handler with admission control.
+func admit(h http.Handler, pattern string, weight float64, a *admission, fail errorFunc) http.Handler {
+	if a == nil {
+		return h
+	}
+	if weight == 0 {
+		weight = 1
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := a.acquire(r, pattern, weight); err != nil {
+			w.Header().Set("Retry-After", "1")
+			fail(w, r, http.StatusServiceUnavailable)
+			return
+		}
+		defer a.release()
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,136 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAdmission_FairQueueing(t *testing.T) {
+	a := newAdmission(Admission{MaxInFlight: 1, MaxWait: time.Second})
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if err := a.acquire(r, "/busy", 1); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	var mux sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	queued := 0
+	enqueue := func(pattern string, weight float64) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := a.acquire(r, pattern, weight); err != nil {
+				t.Errorf("unexpected error %v", err)
+				return
+			}
+			mux.Lock()
+			order = append(order, pattern)
+			mux.Unlock()
+			a.release()
+		}()
+		queued += 1
+		for {
+			a.mux.Lock()
+			n := a.waiting()
+			a.mux.Unlock()
+			if n == queued {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		enqueue("/busy", 1)
+	}
+	enqueue("/quiet", 1)
+
+	a.release()
+	wg.Wait()
+
+	// the quiet route does not have to wait for the busy one's backlog
+	want := []string{"/busy", "/quiet", "/busy", "/busy"}
+	for i := range want {
+		if i >= len(order) || order[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, order)
+		}
+	}
+}
+
+func TestOptionAdmission(t *testing.T) {
+	tracker := NewSLOTracker()
+	tr := NewTreeMux(
+		OptionSLOTracker(tracker),
+		OptionAdmission(Admission{MaxInFlight: 1, MaxWait: 10 * time.Millisecond}),
+	)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	tr.HandleFunc("/hold", func(http.ResponseWriter, *http.Request) {
+		close(started)
+		<-release
+	})
+	tr.HandleFunc("/broken", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}, WithSLO(SLO{Latency: time.Second, AvailabilityTarget: .9}))
+	tr.Handle("/healthy", bodyHandler("ok"))
+
+	// make /broken breach its objectives
+	tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/broken", nil))
+	if s, _ := tracker.Status("/broken"); s.Compliant {
+		t.Fatalf("expected /broken to breach its objectives")
+	}
+
+	go tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/hold", nil))
+	<-started
+
+	// breaching routes are shed immediately when saturated
+	start := time.Now()
+	w := httptest.NewRecorder()
+	tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/broken", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Errorf("expected 503 with Retry-After, got %v", w.Code)
+	}
+	if time.Since(start) >= 10*time.Millisecond {
+		t.Errorf("expected breaching route to be shed without waiting")
+	}
+
+	// healthy routes wait for a slot
+	done := make(chan int)
+	go func() {
+		w := httptest.NewRecorder()
+		tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthy", nil))
+		done <- w.Code
+	}()
+	time.Sleep(2 * time.Millisecond)
+	close(release)
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("expected healthy route to be admitted, got %v", code)
+	}
+}
+
+func TestOptionAdmission_invalid(t *testing.T) {
+	cases := []struct {
+		name string
+		cfg  Admission
+	}{
+		{"zero value", Admission{}},
+		{"negative", Admission{MaxInFlight: -1}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic")
+				}
+			}()
+			OptionAdmission(c.cfg)
+		})
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NotFoundAnalyzer classifies unmatched requests, for instance to detect
+// vulnerability scanners.
+type NotFoundAnalyzer interface {
+	// Analyze is called for (sampled) unmatched requests. It reports whether
+	// the client should be banned. It must be safe for concurrent use.
+	Analyze(r *http.Request) bool
+}
+
+// NotFoundAnalyzerFunc is an adapter to allow the use of ordinary functions
+// as a NotFoundAnalyzer.
+type NotFoundAnalyzerFunc func(r *http.Request) bool
+
+func (f NotFoundAnalyzerFunc) Analyze(r *http.Request) bool {
+	return f(r)
+}
+
+// NotFoundAnalysis configures the analysis of unmatched requests.
+type NotFoundAnalysis struct {
+	// Analyzer classifies the requests. It must be set.
+	Analyzer NotFoundAnalyzer
+	// SampleRate is the fraction of unmatched requests analysed. Zero means
+	// all of them.
+	SampleRate float64
+	// Ban is called for requests of clients that should be banned, for
+	// instance to feed a firewall. Optional.
+	Ban func(r *http.Request)
+}
+
+// analyze feeds the unmatched request to the analyzer.
+func (a *NotFoundAnalysis) analyze(r *http.Request) {
+	if a.SampleRate > 0 && rand.Float64() >= a.SampleRate {
+		return
+	}
+	if a.Analyzer.Analyze(r) && a.Ban != nil {
+		a.Ban(r)
+	}
+}
+
+type optionNotFoundAnalysis struct {
+	value NotFoundAnalysis
+}
+
+func (o optionNotFoundAnalysis) Apply(mux *treeMux) {
+	mux.notFoundAnalysis = &o.value
+}
+
+func (o optionNotFoundAnalysis) private() {}
+
+// OptionNotFoundAnalysis feeds unmatched requests to an analyzer, which can
+// have clients banned. The analysis happens before the not found response
+// is written, so analyzers should be fast. It panics when no analyzer is set.
+func OptionNotFoundAnalysis(cfg NotFoundAnalysis) Option {
+	if cfg.Analyzer == nil {
+		panic("invalid not found analysis: no analyzer")
+	}
+	return optionNotFoundAnalysis{cfg}
+}
+
+// scannerProbes are path elements commonly requested by vulnerability
+// scanners.
+var scannerProbes = []string{
+	".env", ".git", ".aws", ".ssh", ".htaccess", ".ds_store",
+	"wp-admin", "wp-login.php", "wp-content", "xmlrpc.php",
+	"phpmyadmin", "cgi-bin", "actuator", "server-status",
+}
+
+// ScannerDetector is a NotFoundAnalyzer that bans clients requesting well-
+// known scanner probes (like "/.env" or "/wp-login.php"), or making too many
+// unmatched requests in a window. Clients are identified by ClientIP.
+type ScannerDetector struct {
+	threshold int
+	window    time.Duration
+
+	mux     sync.Mutex
+	counts  map[string]int
+	resetAt time.Time
+}
+
+// NewScannerDetector creates a ScannerDetector that bans clients with more
+// than threshold unmatched requests per window. With a threshold of zero,
+// only probes are detected.
+func NewScannerDetector(threshold int, window time.Duration) *ScannerDetector {
+	return &ScannerDetector{threshold: threshold, window: window, counts: make(map[string]int)}
+}
+
+func (d *ScannerDetector) Analyze(r *http.Request) bool {
+	for _, x := range strings.Split(strings.ToLower(r.URL.Path), "/") {
+		for _, p := range scannerProbes {
+			if x == p {
+				return true
+			}
+		}
+	}
+	if d.threshold <= 0 {
+		return false
+	}
+	now := requestClock(r).Now()
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	if !now.Before(d.resetAt) {
+		d.counts = make(map[string]int)
+		d.resetAt = now.Add(d.window)
+	}
+	ip := ClientIP(r)
+	d.counts[ip] += 1
+	return d.counts[ip] > d.threshold
+}
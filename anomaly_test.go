@@ -0,0 +1,54 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestScannerDetector(t *testing.T) {
+	cases := []struct {
+		name      string
+		threshold int
+		paths     []string
+		want      []bool
+	}{
+		{"probe", 0, []string{"/.env", "/foo/WP-LOGIN.PHP", "/foo"}, []bool{true, true, false}},
+		{"threshold", 2, []string{"/a", "/b", "/c"}, []bool{false, false, true}},
+		{"no threshold", 0, []string{"/a", "/b", "/c"}, []bool{false, false, false}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := NewScannerDetector(c.threshold, time.Minute)
+			for i, p := range c.paths {
+				if got := d.Analyze(httptest.NewRequest(http.MethodGet, p, nil)); got != c.want[i] {
+					t.Errorf("%s: expected %v, got %v", p, c.want[i], got)
+				}
+			}
+		})
+	}
+}
+
+func TestOptionNotFoundAnalysis(t *testing.T) {
+	var banned []string
+	tr := NewTreeMux(OptionNotFoundAnalysis(NotFoundAnalysis{
+		Analyzer: NewScannerDetector(0, time.Minute),
+		Ban: func(r *http.Request) {
+			banned = append(banned, r.URL.Path)
+		},
+	}))
+	tr.HandleFunc("/foo", bodyHandler("foo"))
+	tr.HandleFunc("/.env", bodyHandler("env"))
+
+	for _, p := range []string{"/foo", "/.env", "/bar", "/.git/config"} {
+		tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, p, nil))
+	}
+	if len(banned) != 1 || banned[0] != "/.git/config" {
+		t.Errorf("expected [/.git/config], got %v", banned)
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// RegistrationError describes a route that could not be registered.
+type RegistrationError struct {
+	// Pattern identifies the route, as far as it is known.
+	Pattern string
+	Err     error
+}
+
+func (e RegistrationError) Error() string {
+	if e.Pattern == "" {
+		return e.Err.Error()
+	}
+	return e.Pattern + ": " + e.Err.Error()
+}
+
+func (e RegistrationError) Unwrap() error {
+	return e.Err
+}
+
+// RegistrationErrors lists all failures of a bulk registration, like
+// ImportKubernetes.
+type RegistrationErrors []RegistrationError
+
+func (es RegistrationErrors) Error() string {
+	if len(es) == 1 {
+		return es[0].Error()
+	}
+	xs := make([]string, len(es))
+	for i, e := range es {
+		xs[i] = e.Error()
+	}
+	return fmt.Sprintf("%d registration errors: %s", len(es), strings.Join(xs, "; "))
+}
+
+func (es RegistrationErrors) Unwrap() []error {
+	xs := make([]error, len(es))
+	for i, e := range es {
+		xs[i] = e
+	}
+	return xs
+}
+
+func (t *treeMux) TryHandle(path string, handler http.Handler, options ...RouteOption) (err error) {
+	// Patterns are validated before anything is registered, so recovering
+	// does not leave the mux half-configured.
+	defer func() {
+		if v := recover(); v != nil {
+			e, ok := v.(error)
+			if !ok {
+				e = fmt.Errorf("%v", v)
+			}
+			err = RegistrationError{Pattern: path, Err: e}
+		}
+	}()
+	t.Handle(path, handler, options...)
+	return nil
+}
+
+// batch collects the registrations of a bulk registration. They are only
+// applied when none of them failed, so a faulty configuration does not leave
+// the mux half-configured.
+type batch struct {
+	t    *treeMux
+	regs []func()
+	errs RegistrationErrors
+}
+
+func (b *batch) handle(path string, h http.Handler, options []RouteOption) {
+	if err := b.check(path, false); err != nil {
+		b.fail(path, err)
+		return
+	}
+	b.regs = append(b.regs, func() {
+		b.t.Handle(path, h, options...)
+	})
+}
+
+// handleExact registers a route for a path that is to be matched literally,
+// as imported configurations do. Paths the mux would not match literally are
+// rejected.
+func (b *batch) handleExact(path string, h http.Handler, options []RouteOption) {
+	if err := checkLiteral(path); err != nil {
+		b.fail(path, err)
+		return
+	}
+	b.handle(path, h, options)
+}
+
+// checkLiteral returns an error when the path cannot be registered as an
+// exact match: when it ends with a slash, or has elements that would be taken
+// for wildcards, path parameters or fragments.
+func checkLiteral(path string) error {
+	if strings.HasSuffix(path, "/") {
+		return fmt.Errorf("exact match on a path ending with a slash is not supported")
+	}
+	for _, x := range strings.Split(path, "/") {
+		if strings.ContainsAny(x, "*{") || strings.HasPrefix(x, ":") {
+			return fmt.Errorf("exact match on path element '%s' is not supported", x)
+		}
+	}
+	return nil
+}
+
+func (b *batch) handlePrefix(path string, h http.Handler, options []RouteOption) {
+	if err := b.check(path, true); err != nil {
+		b.fail(path, err)
+		return
+	}
+	b.regs = append(b.regs, func() {
+		b.t.handlePrefix(path, h, options...)
+	})
+}
+
+// check validates the path like Handle (or handlePrefix) would, so that the
+// registrations do not fail once they are applied.
+func (b *batch) check(path string, prefix bool) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("%v", v)
+		}
+	}()
+	path = normalisePattern(b.t.expandFragments(path))
+	if prefix {
+		// prefixes are matched literally
+		return nil
+	}
+	if b.t.strict {
+		if err := checkCatchAll(path); err != nil {
+			return err
+		}
+	}
+	if _, _, ok := parseCatchAll(path); ok {
+		return nil
+	}
+	pattern, _ := parsePathParams(path)
+	return checkPattern(pattern)
+}
+
+func (b *batch) fail(pattern string, err error) {
+	b.errs = append(b.errs, RegistrationError{Pattern: pattern, Err: err})
+}
+
+// commit applies the registrations, or returns all errors.
+func (b *batch) commit() error {
+	if len(b.errs) > 0 {
+		return b.errs
+	}
+	for _, reg := range b.regs {
+		reg()
+	}
+	return nil
+}
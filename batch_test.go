@@ -0,0 +1,189 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRegistrationErrors_Error(t *testing.T) {
+	cause := errors.New("bad")
+	cases := []struct {
+		name string
+		errs RegistrationErrors
+		want string
+	}{
+		{"single", RegistrationErrors{{Pattern: "/foo", Err: cause}}, "/foo: bad"},
+		{"no pattern", RegistrationErrors{{Err: cause}}, "bad"},
+		{
+			"multiple",
+			RegistrationErrors{{Pattern: "/foo", Err: cause}, {Pattern: "/bar", Err: cause}},
+			"2 registration errors: /foo: bad; /bar: bad",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.errs.Error(); got != c.want {
+				t.Errorf("expected %q, got %q", c.want, got)
+			}
+		})
+	}
+}
+
+func TestBulkRegistrationErrors(t *testing.T) {
+	backends := map[string]http.Handler{"foo": bodyHandler("foo")}
+	cases := []struct {
+		name     string
+		register func(tr TreeMux) error
+		want     []string
+	}{
+		{
+			"kubernetes",
+			func(tr TreeMux) error {
+				return tr.ImportKubernetes([]byte(`{"kind": "List", "items": [
+  {"kind": "HTTPRoute", "spec": {"rules": [
+    {"matches": [{"path": {"type": "Exact", "value": "/ok"}}], "backendRefs": [{"name": "foo"}]},
+    {"matches": [{"path": {"type": "Exact", "value": "/a"}}], "backendRefs": [{"name": "bar"}]},
+    {"matches": [{"path": {"type": "Weird", "value": "/b"}}], "backendRefs": [{"name": "foo"}]}
+  ]}},
+  {"kind": "Ingress", "spec": {"rules": [{"http": {"paths": [
+    {"path": "/c", "pathType": "Exact", "backend": {"service": {"name": "baz"}}}
+  ]}}]}},
+  {"kind": "Service"}
+]}`), backends)
+			},
+			[]string{"/a", "/b", "/c", ""},
+		},
+		{
+			"envoy",
+			func(tr TreeMux) error {
+				return tr.ImportEnvoy([]byte(`{"virtual_hosts": [{"domains": ["*"], "routes": [
+  {"match": {"path": "/ok"}, "route": {"cluster": "foo"}},
+  {"match": {"path": "/a"}, "route": {"cluster": "bar"}},
+  {"match": {"prefix": "/b"}},
+  {"match": {"safe_regex": {"regex": "("}}, "route": {"cluster": "foo"}}
+]}]}`), backends)
+			},
+			[]string{"/a", "/b", "("},
+		},
+		{
+			"kubernetes trailing slash",
+			func(tr TreeMux) error {
+				return tr.ImportKubernetes([]byte(`{"kind": "HTTPRoute", "spec": {"rules": [
+  {"matches": [{"path": {"type": "PathPrefix", "value": "/api"}}], "backendRefs": [{"name": "foo"}]},
+  {"matches": [{"path": {"type": "Exact", "value": "/health/"}}], "backendRefs": [{"name": "foo"}]},
+  {"matches": [{"path": {"type": "Exact", "value": "/"}}], "backendRefs": [{"name": "foo"}]}
+]}}`), backends)
+			},
+			[]string{"/health/", "/"},
+		},
+		{
+			"ingress trailing slash",
+			func(tr TreeMux) error {
+				return tr.ImportKubernetes([]byte(`{"kind": "Ingress", "spec": {"rules": [{"http": {"paths": [
+  {"path": "/api", "pathType": "Prefix", "backend": {"service": {"name": "foo"}}},
+  {"path": "/health/", "pathType": "Exact", "backend": {"service": {"name": "foo"}}},
+  {"path": "/", "pathType": "Exact", "backend": {"service": {"name": "foo"}}}
+]}}]}}`), backends)
+			},
+			[]string{"/health/", "/"},
+		},
+		{
+			"envoy trailing slash",
+			func(tr TreeMux) error {
+				return tr.ImportEnvoy([]byte(`{"virtual_hosts": [{"domains": ["*"], "routes": [
+  {"match": {"prefix": "/api"}, "route": {"cluster": "foo"}},
+  {"match": {"path": "/health/"}, "route": {"cluster": "foo"}},
+  {"match": {"path": "/"}, "route": {"cluster": "foo"}}
+]}]}`), backends)
+			},
+			[]string{"/health/", "/"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tr := NewTreeMux()
+			err := c.register(tr)
+			var errs RegistrationErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("expected RegistrationErrors, got %v", err)
+			}
+			var got []string
+			for _, e := range errs {
+				got = append(got, e.Pattern)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("expected patterns %v, got %v", c.want, got)
+			}
+			if s := tr.Snapshot(); len(s) > 0 {
+				t.Errorf("expected no routes, got:\n%s", s)
+			}
+		})
+	}
+}
+
+func TestTreeMux_TryHandle(t *testing.T) {
+	cases := []struct {
+		name    string
+		path    string
+		wantErr string
+	}{
+		{"valid", "/foo/{id}", ""},
+		{"catch-all", "/static/{path...}", ""},
+		{"trailing slash", "/foo/", "/foo/: path cannot end with slash"},
+		{"empty", "", "path cannot end with slash"},
+		{"root", "/", "/: path cannot end with slash"},
+		{"duplicate param", "/foo/{id}/{id}", `/foo/{id}/{id}: invalid path parameter "{id}" in /foo/{id}/{id}`},
+		{"undefined fragment", "/{@foo}", "/{@foo}: undefined fragment {@foo}"},
+		{"conflict", "/bar", "/bar: pattern /bar conflicts with existing pattern /bar"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tr := NewTreeMux(OptionStrictRegistration())
+			tr.HandleFunc("/bar", bodyHandler("bar"))
+			err := tr.TryHandle(c.path, bodyHandler("foo"))
+			if err == nil && c.wantErr != "" || err != nil && err.Error() != c.wantErr {
+				t.Errorf("expected error %q, got %v", c.wantErr, err)
+			}
+			if _, ok := err.(RegistrationError); err != nil && !ok {
+				t.Errorf("expected RegistrationError, got %T", err)
+			}
+			// a failed registration must not leave the mux locked
+			done := make(chan string)
+			go func() {
+				tr.HandleFunc("/baz/{id}", bodyHandler("baz"))
+				w := httptest.NewRecorder()
+				tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/baz/1", nil))
+				done <- w.Body.String()
+			}()
+			select {
+			case body := <-done:
+				if body != "baz" {
+					t.Errorf("expected %q, got %q", "baz", body)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("mux is deadlocked")
+			}
+
+			var n int
+			tr.Walk(func(string, http.Handler) bool {
+				n += 1
+				return true
+			})
+			want := 3
+			if c.wantErr != "" {
+				want = 2
+			}
+			if n != want {
+				t.Errorf("expected %d routes, got %d", want, n)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net"
+	"net/http"
+	"net/http/cgi"
+	"strconv"
+	"strings"
+)
+
+func (t *treeMux) HandleCGI(path string, handler *cgi.Handler, options ...RouteOption) {
+	h := *handler
+	h.Root = strings.TrimSuffix(normalisePattern(path), "/")
+	if h.PathLocationHandler == nil {
+		h.PathLocationHandler = t
+	}
+	t.handlePrefix(path, &h, options...)
+}
+
+func (t *treeMux) HandleFastCGI(path string, handler *FastCGI, options ...RouteOption) {
+	h := *handler
+	h.root = strings.TrimSuffix(normalisePattern(path), "/")
+	h.fail = t.writeError
+	t.handlePrefix(path, &h, options...)
+}
+
+// cgiEnv returns the CGI meta-variables (RFC 3875) for the request. The root
+// is the path prefix the script is mounted at; the rest of the path is the
+// PATH_INFO.
+func cgiEnv(r *http.Request, root string) map[string]string {
+	env := map[string]string{
+		"GATEWAY_INTERFACE": "CGI/1.1",
+		"SERVER_SOFTWARE":   "go-treemux",
+		"SERVER_PROTOCOL":   r.Proto,
+		"REQUEST_METHOD":    r.Method,
+		"REQUEST_URI":       r.URL.RequestURI(),
+		"QUERY_STRING":      r.URL.RawQuery,
+		"SCRIPT_NAME":       root,
+		"PATH_INFO":         strings.TrimPrefix(r.URL.Path, root),
+		"HTTP_HOST":         r.Host,
+	}
+
+	host, port, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+		port = "80"
+		if r.TLS != nil {
+			port = "443"
+		}
+	}
+	env["SERVER_NAME"] = host
+	env["SERVER_PORT"] = port
+	if r.TLS != nil {
+		env["HTTPS"] = "on"
+	}
+	if ip, p, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		env["REMOTE_ADDR"] = ip
+		env["REMOTE_HOST"] = ip
+		env["REMOTE_PORT"] = p
+	}
+
+	for k, vs := range r.Header {
+		k = strings.ToUpper(strings.ReplaceAll(k, "-", "_"))
+		if k == "PROXY" {
+			// Guards against "httpoxy" (CVE-2016-5385).
+			continue
+		}
+		sep := ", "
+		if k == "COOKIE" {
+			sep = "; "
+		}
+		env["HTTP_"+k] = strings.Join(vs, sep)
+	}
+	if r.ContentLength > 0 {
+		env["CONTENT_LENGTH"] = strconv.FormatInt(r.ContentLength, 10)
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		env["CONTENT_TYPE"] = ct
+	}
+	return env
+}
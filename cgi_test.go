@@ -0,0 +1,98 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestCGIEnv(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "https://example.com:8443/cgi-bin/app/users/1?a=b", nil)
+	r.TLS = &tls.ConnectionState{}
+	r.RemoteAddr = "10.0.0.1:5000"
+	r.ContentLength = 12
+	r.Header.Set("Content-Type", "text/plain")
+	r.Header.Add("Cookie", "a=1")
+	r.Header.Add("Cookie", "b=2")
+	r.Header.Set("Proxy", "evil")
+	r.Header.Set("X-Foo", "bar")
+
+	want := map[string]string{
+		"GATEWAY_INTERFACE": "CGI/1.1",
+		"SERVER_SOFTWARE":   "go-treemux",
+		"SERVER_PROTOCOL":   "HTTP/1.1",
+		"REQUEST_METHOD":    "POST",
+		"REQUEST_URI":       "/cgi-bin/app/users/1?a=b",
+		"QUERY_STRING":      "a=b",
+		"SCRIPT_NAME":       "/cgi-bin/app",
+		"PATH_INFO":         "/users/1",
+		"HTTP_HOST":         "example.com:8443",
+		"SERVER_NAME":       "example.com",
+		"SERVER_PORT":       "8443",
+		"HTTPS":             "on",
+		"REMOTE_ADDR":       "10.0.0.1",
+		"REMOTE_HOST":       "10.0.0.1",
+		"REMOTE_PORT":       "5000",
+		"HTTP_CONTENT_TYPE": "text/plain",
+		"HTTP_COOKIE":       "a=1; b=2",
+		"HTTP_X_FOO":        "bar",
+		"CONTENT_LENGTH":    "12",
+		"CONTENT_TYPE":      "text/plain",
+	}
+	if got := cgiEnv(r, "/cgi-bin/app"); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+// TestCGIHelperProcess is run as CGI script by TestTreeMux_HandleCGI.
+func TestCGIHelperProcess(t *testing.T) {
+	if os.Getenv("TREEMUX_CGI_HELPER") != "1" {
+		return
+	}
+	_ = cgi.Serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if os.Getenv("PATH_INFO") == "/redirect" {
+			w.Header().Set("Location", "/internal")
+			return
+		}
+		_, _ = fmt.Fprintf(w, "%s %s", os.Getenv("SCRIPT_NAME"), os.Getenv("PATH_INFO"))
+	}))
+	os.Exit(0)
+}
+
+func TestTreeMux_HandleCGI(t *testing.T) {
+	tr := NewTreeMux()
+	tr.HandleCGI("/cgi-bin/app", &cgi.Handler{
+		Path: os.Args[0],
+		Args: []string{"-test.run=TestCGIHelperProcess"},
+		Env:  []string{"TREEMUX_CGI_HELPER=1"},
+	})
+	tr.HandleFunc("/internal", bodyHandler("internal"))
+
+	cases := []struct {
+		name   string
+		target string
+		want   string
+	}{
+		{"root", "/cgi-bin/app", "/cgi-bin/app "},
+		{"path info", "/cgi-bin/app/users/1", "/cgi-bin/app /users/1"},
+		{"internal redirect", "/cgi-bin/app/redirect", "internal"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.target, nil))
+			if got := w.Body.String(); got != c.want {
+				t.Errorf("expected %q, got %q", c.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+)
+
+type chain []TreeMux
+
+// Chain returns a handler that tries the muxes in order and lets the first one
+// with a matching route serve the request. This allows for layered route
+// tables, like overrides before generated routes before defaults. The muxes
+// are consulted with Handler, so a miss does not produce a response; when
+// none of them matches, the last mux serves its not found response.
+func Chain(muxes ...TreeMux) http.Handler {
+	if len(muxes) == 0 {
+		panic("chain needs at least one mux")
+	}
+	return chain(muxes)
+}
+
+func (c chain) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for _, m := range c[:len(c)-1] {
+		if _, pattern := m.Handler(r); pattern != "" {
+			m.ServeHTTP(w, r)
+			return
+		}
+	}
+	c[len(c)-1].ServeHTTP(w, r)
+}
@@ -0,0 +1,52 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestChain(t *testing.T) {
+	overrides := NewTreeMux()
+	overrides.HandleFunc("/foo/bar", bodyHandler("override"))
+	generated := NewTreeMux()
+	generated.HandleFunc("/foo/*", bodyHandler("generated"))
+	generated.HandleFunc("/qux", bodyHandler("qux"), WithMethods(http.MethodPost))
+	defaults := NewTreeMux(OptionNotFound(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte("default not found"))
+	}))
+	defaults.HandleFunc("/foo/*", bodyHandler("default"))
+	defaults.HandleFunc("/bar", bodyHandler("bar"))
+	h := Chain(overrides, generated, defaults)
+
+	cases := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"override", http.MethodGet, "/foo/bar", http.StatusOK, "override"},
+		{"generated", http.MethodGet, "/foo/baz", http.StatusOK, "generated"},
+		{"default", http.MethodGet, "/bar", http.StatusOK, "bar"},
+		{"method not allowed", http.MethodGet, "/qux", http.StatusMethodNotAllowed, "405 method not allowed\n"},
+		{"not found", http.MethodGet, "/baz", http.StatusNotFound, "default not found"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(c.method, c.path, nil))
+			if w.Code != c.wantStatus {
+				t.Errorf("expected %d, got %d", c.wantStatus, w.Code)
+			}
+			if got := w.Body.String(); got != c.wantBody {
+				t.Errorf("expected %q, got %q", c.wantBody, got)
+			}
+		})
+	}
+}
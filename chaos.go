@@ -0,0 +1,126 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Fault describes the failures to inject into requests on a route.
+type Fault struct {
+	// Latency is added before the handler is called.
+	Latency time.Duration
+	// ErrorRate is the fraction (0 to 1) of requests that get an error
+	// response instead of reaching the handler.
+	ErrorRate float64
+	// ErrorStatus is the status of error responses, defaults to 500.
+	ErrorStatus int
+	// ResetRate is the fraction (0 to 1) of requests whose connection is
+	// dropped without a response.
+	ResetRate float64
+}
+
+// ChaosInjector injects faults into requests, for resilience testing. Faults
+// are configured per route pattern and can be changed while serving.
+type ChaosInjector struct {
+	mux     sync.RWMutex
+	enabled bool
+	faults  map[string]Fault
+	random  func() float64
+}
+
+// NewChaosInjector creates a new, enabled, fault injector without any faults.
+func NewChaosInjector() *ChaosInjector {
+	return &ChaosInjector{enabled: true, faults: make(map[string]Fault), random: rand.Float64}
+}
+
+// Set configures the faults for the route with the given pattern, as it was
+// registered ("/orders/{id}" and "/orders/*" are the same route).
+func (c *ChaosInjector) Set(pattern string, f Fault) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.faults[canonicalPattern(pattern)] = f
+}
+
+// Clear removes the faults for the route with the given pattern.
+func (c *ChaosInjector) Clear(pattern string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	delete(c.faults, canonicalPattern(pattern))
+}
+
+// SetEnabled turns fault injection on or off, without losing the
+// configuration.
+func (c *ChaosInjector) SetEnabled(enabled bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.enabled = enabled
+}
+
+func (c *ChaosInjector) fault(pattern string) (Fault, bool) {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	if !c.enabled {
+		return Fault{}, false
+	}
+	f, ok := c.faults[pattern]
+	return f, ok
+}
+
+type optionChaos struct {
+	value *ChaosInjector
+}
+
+func (o optionChaos) Apply(mux *treeMux) {
+	mux.chaos = o.value
+}
+
+func (o optionChaos) private() {}
+
+// OptionChaos enables fault injection with the given injector. Not meant for
+// production use.
+func OptionChaos(c *ChaosInjector) Option {
+	return optionChaos{c}
+}
+
+// inject wraps the handler so that the faults configured for the pattern are
+// applied to its requests.
+func inject(h http.Handler, pattern string, c *ChaosInjector, clock Clock) http.Handler {
+	if c == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, ok := c.fault(pattern)
+		if !ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if f.Latency > 0 {
+			timer := clock.NewTimer(f.Latency)
+			select {
+			case <-timer.C():
+			case <-r.Context().Done():
+				timer.Stop()
+				return
+			}
+		}
+		if f.ResetRate > 0 && c.random() < f.ResetRate {
+			// makes the server drop the connection
+			panic(http.ErrAbortHandler)
+		}
+		if f.ErrorRate > 0 && c.random() < f.ErrorRate {
+			status := f.ErrorStatus
+			if status == 0 {
+				status = http.StatusInternalServerError
+			}
+			http.Error(w, "injected fault", status)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,91 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOptionChaos(t *testing.T) {
+	cases := []struct {
+		name      string
+		fault     *Fault
+		disabled  bool
+		random    float64
+		wantCode  int
+		wantPanic bool
+		minDelay  time.Duration
+	}{
+		{"no fault", nil, false, 0, 200, false, 0},
+		{"latency", &Fault{Latency: 20 * time.Millisecond}, false, 0, 200, false, 20 * time.Millisecond},
+		{"error", &Fault{ErrorRate: .5, ErrorStatus: 502}, false, .2, 502, false, 0},
+		{"error not drawn", &Fault{ErrorRate: .5}, false, .7, 200, false, 0},
+		{"default error status", &Fault{ErrorRate: 1}, false, .2, 500, false, 0},
+		{"reset", &Fault{ResetRate: .5}, false, .2, 0, true, 0},
+		{"disabled", &Fault{ErrorRate: 1}, true, .2, 200, false, 0},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ci := NewChaosInjector()
+			ci.random = func() float64 { return c.random }
+			tr := NewTreeMux(OptionChaos(ci))
+			tr.Handle("/foo/*", bodyHandler("ok"))
+			if c.fault != nil {
+				ci.Set("foo/*", *c.fault)
+			}
+			ci.SetEnabled(!c.disabled)
+
+			defer func() {
+				if r := recover(); (r == http.ErrAbortHandler) != c.wantPanic {
+					t.Errorf("expected abort %v, got %v", c.wantPanic, r)
+				}
+			}()
+			start := time.Now()
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/foo/bar", nil))
+			if w.Code != c.wantCode {
+				t.Errorf("expected %v, got %v", c.wantCode, w.Code)
+			}
+			if d := time.Since(start); d < c.minDelay {
+				t.Errorf("expected delay of at least %v, got %v", c.minDelay, d)
+			}
+		})
+	}
+}
+
+func TestChaosInjector_Set(t *testing.T) {
+	cases := []struct {
+		name     string
+		route    string
+		set      string
+		path     string
+		wantCode int
+	}{
+		{"named param", "/orders/{id}", "/orders/{id}", "/orders/42", 503},
+		{"named param set as wildcard", "/orders/{id}", "/orders/*", "/orders/42", 503},
+		{"colon param", "/orders/:id", "/orders/{order}", "/orders/42", 503},
+		{"constrained param", "/orders/{id:[0-9]+}", "/orders/{id:[0-9]+}", "/orders/42", 503},
+		{"catch-all", "/files/{path...}", "/files/**", "/files/a/b", 503},
+		{"other route", "/orders/{id}", "/orders/{id}/items", "/orders/42", 200},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ci := NewChaosInjector()
+			ci.random = func() float64 { return 0 }
+			tr := NewTreeMux(OptionChaos(ci))
+			tr.Handle(c.route, bodyHandler("ok"))
+			ci.Set(c.set, Fault{ErrorRate: 1, ErrorStatus: 503})
+
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+			if w.Code != c.wantCode {
+				t.Errorf("expected %v, got %v", c.wantCode, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type withName struct {
+	value string
+}
+
+func (o withName) Apply(rt *route) {
+	rt.name = o.value
+}
+
+func (o withName) private() {}
+
+// WithName names the route, so it can be referred to without repeating its
+// pattern (see Client).
+func WithName(name string) RouteOption {
+	return withName{name}
+}
+
+// expand substitutes the pattern's wildcards with the given values, in order.
+// The values are path escaped; the value for a final catch-all can have
+// multiple path elements. Values for constrained wildcards must match their
+// expression.
+func expand(pattern string, values []string) (string, error) {
+	ps := strings.Split(strings.TrimPrefix(pattern, "/"), "/")
+	i := 0
+	for j, p := range ps {
+		rest := p == "**" && j == len(ps)-1
+		if !isWildcard(p) && !rest {
+			continue
+		}
+		if i == len(values) {
+			return "", fmt.Errorf("too few values for pattern '%s'", pattern)
+		}
+		if strings.HasPrefix(p, constrainedPrefix) && !matchConstraint(p, values[i]) {
+			return "", fmt.Errorf("value '%s' does not match '%s' in pattern '%s'", values[i], p, pattern)
+		}
+		if rest {
+			xs := strings.Split(values[i], "/")
+			for k := range xs {
+				xs[k] = url.PathEscape(xs[k])
+			}
+			ps[j] = strings.Join(xs, "/")
+		} else {
+			ps[j] = url.PathEscape(values[i])
+		}
+		i += 1
+	}
+	if i != len(values) {
+		return "", fmt.Errorf("too many values for pattern '%s'", pattern)
+	}
+	return "/" + strings.Join(ps, "/"), nil
+}
+
+// Client makes requests to the named routes of a mux, building their URLs
+// from the route patterns. This keeps server and client paths from drifting
+// apart.
+type Client struct {
+	// BaseURL is prepended to the request paths, i.e.
+	// "https://api.example.com".
+	BaseURL string
+	// HTTPClient is used to send requests, defaults to http.DefaultClient.
+	HTTPClient *http.Client
+
+	mux *treeMux
+}
+
+func (t *treeMux) URL(pattern string, params ...string) (string, error) {
+	t.routesMux.RLock()
+	p, ok := t.names[pattern]
+	t.routesMux.RUnlock()
+	if !ok {
+		var err error
+		if p, err = t.registeredPattern(pattern); err != nil {
+			return "", err
+		}
+	}
+	return expand(p, params)
+}
+
+// registeredPattern returns the pattern as registered, or an error when it is
+// invalid or there is no route for it.
+func (t *treeMux) registeredPattern(pattern string) (p string, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("%v", v)
+		}
+	}()
+	path := normalisePattern(t.expandFragments(pattern))
+	t.routesMux.RLock()
+	defer t.routesMux.RUnlock()
+	if prefix, _, catchAll := parseCatchAll(path); catchAll {
+		if _, ok := t.prefixes[prefix]; ok {
+			return prefix + "/**", nil
+		}
+	} else if p, _ = parsePathParams(path); t.endpoints[p] != nil {
+		return p, nil
+	}
+	return "", fmt.Errorf("no route for pattern '%s'", pattern)
+}
+
+func (t *treeMux) Client(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/"), mux: t}
+}
+
+// NewRequest creates a request for the named route. The params are the values
+// for the route's wildcards, in order.
+func (c *Client) NewRequest(ctx context.Context, method, name string, params []string, body io.Reader) (*http.Request, error) {
+	c.mux.routesMux.RLock()
+	pattern, ok := c.mux.names[name]
+	c.mux.routesMux.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown route name '%s'", name)
+	}
+	path, err := expand(pattern, params)
+	if err != nil {
+		return nil, err
+	}
+	return http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+}
+
+// Do sends a request to the named route. It is a GET request without body, or
+// a POST request when there is one. Use NewRequest for other methods.
+func (c *Client) Do(ctx context.Context, name string, params []string, body io.Reader) (*http.Response, error) {
+	method := http.MethodGet
+	if body != nil {
+		method = http.MethodPost
+	}
+	r, err := c.NewRequest(ctx, method, name, params, body)
+	if err != nil {
+		return nil, err
+	}
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	return hc.Do(r)
+}
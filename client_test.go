@@ -0,0 +1,133 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	cases := []struct {
+		name    string
+		pattern string
+		values  []string
+		want    string
+		wantErr bool
+	}{
+		{"static", "/foo/bar", nil, "/foo/bar", false},
+		{"wildcards", "/countries/*/cities/*", []string{"france", "lille"}, "/countries/france/cities/lille", false},
+		{"escaped", "/files/*", []string{"a b/c"}, "/files/a%20b%2Fc", false},
+		{"too few", "/countries/*/cities/*", []string{"france"}, "", true},
+		{"too many", "/countries/*", []string{"france", "lille"}, "", true},
+		{"catch-all", "/static/**", []string{"css/a b.css"}, "/static/css/a%20b.css", false},
+		{"constrained", "/orders/*:[0-9]+", []string{"42"}, "/orders/42", false},
+		{"constrained mismatch", "/orders/*:[0-9]+", []string{"abc"}, "", true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := expand(c.pattern, c.values)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("expected error %v, got %v", c.wantErr, err)
+			}
+			if got != c.want {
+				t.Errorf("expected %q, got %q", c.want, got)
+			}
+		})
+	}
+}
+
+func TestTreeMux_URL(t *testing.T) {
+	h := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	mux := NewTreeMux()
+	mux.Handle("/orders/{id}/items/{item}", h, WithName("item"))
+	mux.Handle("/users/{id:[0-9]+}", h)
+	mux.Handle("/static/**", h)
+
+	cases := []struct {
+		name    string
+		pattern string
+		params  []string
+		want    string
+		wantErr bool
+	}{
+		{"by name", "item", []string{"42", "7"}, "/orders/42/items/7", false},
+		{"by pattern", "/orders/{id}/items/{item}", []string{"42", "7"}, "/orders/42/items/7", false},
+		{"plain wildcards", "/orders/*/items/*", []string{"42", "7"}, "/orders/42/items/7", false},
+		{"constrained", "/users/{id:[0-9]+}", []string{"42"}, "/users/42", false},
+		{"constrained mismatch", "/users/{id:[0-9]+}", []string{"bob"}, "", true},
+		{"catch-all", "/static/**", []string{"js/app.js"}, "/static/js/app.js", false},
+		{"too few", "item", []string{"42"}, "", true},
+		{"unknown name", "nope", nil, "", true},
+		{"unregistered", "/orders/{id}", []string{"42"}, "", true},
+		{"invalid", "/static/**/x", nil, "", true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := mux.URL(c.pattern, c.params...)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("expected error %v, got %v", c.wantErr, err)
+			}
+			if got != c.want {
+				t.Errorf("expected %q, got %q", c.want, got)
+			}
+		})
+	}
+}
+
+func TestClient_Do(t *testing.T) {
+	tr := NewTreeMux()
+	tr.HandleFunc("/countries/*/cities/*", func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		_, _ = w.Write([]byte(r.Method + " " + r.URL.Path + " " + string(b)))
+	}, WithName("city"))
+	srv := httptest.NewServer(tr)
+	defer srv.Close()
+
+	cases := []struct {
+		name    string
+		route   string
+		params  []string
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{"get", "city", []string{"france", "lille"}, "", "GET /countries/france/cities/lille ", false},
+		{"post", "city", []string{"belgium", "wommelgem"}, "hi", "POST /countries/belgium/cities/wommelgem hi", false},
+		{"unknown", "country", nil, "", "", true},
+		{"bad params", "city", []string{"france"}, "", "", true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cl := tr.Client(srv.URL + "/")
+			var body *strings.Reader
+			if c.body != "" {
+				body = strings.NewReader(c.body)
+			}
+			var resp *http.Response
+			var err error
+			if body != nil {
+				resp, err = cl.Do(context.Background(), c.route, c.params, body)
+			} else {
+				resp, err = cl.Do(context.Background(), c.route, c.params, nil)
+			}
+			if (err != nil) != c.wantErr {
+				t.Fatalf("expected error %v, got %v", c.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			defer resp.Body.Close()
+			b, _ := ioutil.ReadAll(resp.Body)
+			if got := string(b); got != c.want {
+				t.Errorf("expected %q, got %q", c.want, got)
+			}
+		})
+	}
+}
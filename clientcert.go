@@ -0,0 +1,72 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"crypto/x509"
+	"net/http"
+)
+
+// ClientCertificate returns the verified client certificate of a mutual TLS
+// connection, or nil if there is none. Certificates that were presented but
+// not verified (i.e. with tls.RequestClientCert) are ignored.
+func ClientCertificate(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return r.TLS.VerifiedChains[0][0]
+}
+
+// HasClientCertificate is a Predicate that holds when the request carries a
+// verified client certificate.
+func HasClientCertificate(r *http.Request) bool {
+	return ClientCertificate(r) != nil
+}
+
+// ClientCertDNSName returns a Predicate that holds when the verified client
+// certificate has one of the given DNS subject alternative names.
+func ClientCertDNSName(names ...string) Predicate {
+	return clientCertPredicate(func(c *x509.Certificate) []string {
+		return c.DNSNames
+	}, names)
+}
+
+// ClientCertURI returns a Predicate that holds when the verified client
+// certificate has one of the given URI subject alternative names (i.e. SPIFFE
+// IDs).
+func ClientCertURI(uris ...string) Predicate {
+	return clientCertPredicate(func(c *x509.Certificate) []string {
+		xs := make([]string, len(c.URIs))
+		for i, u := range c.URIs {
+			xs[i] = u.String()
+		}
+		return xs
+	}, uris)
+}
+
+// ClientCertOU returns a Predicate that holds when the subject of the verified
+// client certificate is part of one of the given organisational units.
+func ClientCertOU(units ...string) Predicate {
+	return clientCertPredicate(func(c *x509.Certificate) []string {
+		return c.Subject.OrganizationalUnit
+	}, units)
+}
+
+func clientCertPredicate(attr func(*x509.Certificate) []string, want []string) Predicate {
+	return func(r *http.Request) bool {
+		c := ClientCertificate(r)
+		if c == nil {
+			return false
+		}
+		for _, a := range attr(c) {
+			for _, w := range want {
+				if a == w {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
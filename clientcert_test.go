@@ -0,0 +1,53 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestClientCertPredicates(t *testing.T) {
+	spiffe, _ := url.Parse("spiffe://example.org/billing")
+	cert := &x509.Certificate{
+		Subject:  pkix.Name{OrganizationalUnit: []string{"payments"}},
+		DNSNames: []string{"billing.internal"},
+		URIs:     []*url.URL{spiffe},
+	}
+	verified := &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	unverified := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+
+	cases := []struct {
+		name  string
+		state *tls.ConnectionState
+		pred  Predicate
+		want  bool
+	}{
+		{"plaintext", nil, HasClientCertificate, false},
+		{"unverified", unverified, HasClientCertificate, false},
+		{"verified", verified, HasClientCertificate, true},
+		{"dns name", verified, ClientCertDNSName("other", "billing.internal"), true},
+		{"unknown dns name", verified, ClientCertDNSName("other"), false},
+		{"unverified dns name", unverified, ClientCertDNSName("billing.internal"), false},
+		{"uri", verified, ClientCertURI("spiffe://example.org/billing"), true},
+		{"unknown uri", verified, ClientCertURI("spiffe://example.org/other"), false},
+		{"organisational unit", verified, ClientCertOU("payments"), true},
+		{"unknown organisational unit", verified, ClientCertOU("sales"), false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.TLS = c.state
+			if got := c.pred(r); got != c.want {
+				t.Errorf("expected %v, got %v", c.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// ClientGoneFunc is called when a client disconnects before the handler has
+// written a response.
+type ClientGoneFunc func(r *http.Request, pattern string, elapsed time.Duration)
+
+type optionOnClientGone struct {
+	value ClientGoneFunc
+}
+
+func (o optionOnClientGone) Apply(mux *treeMux) {
+	mux.onClientGone = o.value
+}
+
+func (o optionOnClientGone) private() {}
+
+// OptionOnClientGone sets a function that is called when the request context
+// is cancelled before the handler starts writing the response. It is called
+// from a separate goroutine, while the handler may still be running.
+//
+// Handy for spotting routes whose latency exceeds the patience of clients.
+func OptionOnClientGone(fn ClientGoneFunc) Option {
+	return optionOnClientGone{fn}
+}
+
+// observeClientGone wraps the handler so that fn is called when the client
+// goes away before a response is written.
+func observeClientGone(h http.Handler, pattern string, fn ClientGoneFunc, clock Clock) http.Handler {
+	if fn == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := clock.Now()
+		rw := newResponseWriter(w)
+		var finished int32
+		done := make(chan struct{})
+		go func() {
+			select {
+			case <-r.Context().Done():
+				if atomic.LoadInt32(&finished) == 0 && !rw.hasStarted() && r.Context().Err() == context.Canceled {
+					fn(r, pattern, clock.Now().Sub(start))
+				}
+			case <-done:
+			}
+		}()
+		h.ServeHTTP(rw, r)
+		atomic.StoreInt32(&finished, 1)
+		close(done)
+	})
+}
@@ -0,0 +1,58 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOptionOnClientGone(t *testing.T) {
+	cases := []struct {
+		name        string
+		writeFirst  bool
+		cancel      bool
+		wantPattern string
+	}{
+		{"client gone", false, true, "/slow/*"},
+		{"client gone after write", true, true, ""},
+		{"client stayed", false, false, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			gone := make(chan string, 1)
+			tr := NewTreeMux(OptionOnClientGone(func(r *http.Request, pattern string, elapsed time.Duration) {
+				gone <- pattern
+			}))
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			tr.HandleFunc("/slow/*", func(w http.ResponseWriter, r *http.Request) {
+				if c.writeFirst {
+					w.WriteHeader(http.StatusAccepted)
+				}
+				if c.cancel {
+					cancel()
+					<-r.Context().Done()
+					time.Sleep(10 * time.Millisecond)
+				}
+			})
+
+			r := httptest.NewRequest(http.MethodGet, "/slow/foo", nil).WithContext(ctx)
+			tr.ServeHTTP(httptest.NewRecorder(), r)
+
+			var got string
+			select {
+			case got = <-gone:
+			case <-time.After(20 * time.Millisecond):
+			}
+			if got != c.wantPattern {
+				t.Errorf("expected %q, got %q", c.wantPattern, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Clock tells the time and makes timers for all time-dependent parts of the
+// mux: rate limits, quotas, SLO windows, panic budgets, signature timestamps,
+// scanner detection windows, the CORS decision cache, usage flushing, time
+// limits, watchdogs, admission waits, tarpits, chaos latency, job backoff and
+// the durations and timestamps reported to logs, metrics and hooks.
+// Substitute a FakeClock using OptionClock to test these deterministically.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// NewTimer creates a timer that fires once the duration has elapsed.
+	NewTimer(d time.Duration) Timer
+	// AfterFunc calls f in its own goroutine once the duration has elapsed.
+	// The timer's channel is not used.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a single-use timer created by a Clock.
+type Timer interface {
+	// C returns the channel on which the time is delivered when the timer
+	// fires.
+	C() <-chan time.Time
+	// Stop prevents the timer from firing. It returns false if the timer
+	// had already fired or been stopped.
+	Stop() bool
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return realTimer{time.AfterFunc(d, f)}
+}
+
+type realTimer struct {
+	t *time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t realTimer) Stop() bool {
+	return t.t.Stop()
+}
+
+type optionClock struct {
+	value Clock
+}
+
+func (o optionClock) Apply(mux *treeMux) {
+	mux.clock = o.value
+}
+
+func (o optionClock) private() {}
+
+// OptionClock sets the clock used by all time-dependent parts of the mux (see
+// Clock). Defaults to the system clock. Stores passed with
+// OptionRateLimitStore or OptionQuotaStore get their windows from it, but
+// expire counters on their own time.
+func OptionClock(c Clock) Option {
+	return optionClock{c}
+}
+
+// requestClock returns the clock of the mux serving the request, or the
+// system clock when it is not served by a mux.
+func requestClock(r *http.Request) Clock {
+	if c, ok := r.Context().Value(clockKey).(Clock); ok {
+		return c
+	}
+	if rt := routeFromContext(r); rt != nil && rt.clock != nil {
+		return rt.clock
+	}
+	return realClock{}
+}
+
+// withClock makes the clock available to code that only gets the request,
+// like not found analyzers.
+func withClock(r *http.Request, c Clock) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), clockKey, c))
+}
+
+// withDeadline returns a context that is done when the deadline on the clock
+// has passed.
+func withDeadline(ctx context.Context, c Clock, deadline time.Time) (context.Context, context.CancelFunc) {
+	if _, ok := c.(realClock); ok {
+		return context.WithDeadline(ctx, deadline)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	dc := &clockContext{Context: ctx, deadline: deadline}
+	timer := c.AfterFunc(deadline.Sub(c.Now()), func() {
+		atomic.StoreInt32(&dc.expired, 1)
+		cancel()
+	})
+	return dc, func() {
+		timer.Stop()
+		cancel()
+	}
+}
+
+// clockContext is a context with a deadline on a Clock other than the
+// system clock.
+type clockContext struct {
+	context.Context
+	deadline time.Time
+	expired  int32
+}
+
+func (c *clockContext) Deadline() (time.Time, bool) {
+	if d, ok := c.Context.Deadline(); ok && d.Before(c.deadline) {
+		return d, true
+	}
+	return c.deadline, true
+}
+
+func (c *clockContext) Err() error {
+	err := c.Context.Err()
+	if err != nil && atomic.LoadInt32(&c.expired) == 1 {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+// FakeClock is a Clock that only moves when told to. Its timers fire when
+// the clock is advanced past their deadline.
+//
+//	clock := NewFakeClock(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+//	mux := NewTreeMux(OptionClock(clock))
+//	...
+//	clock.Advance(time.Minute)
+type FakeClock struct {
+	mux    sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFakeClock creates a FakeClock set to the given time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	t := &fakeTimer{clock: c, at: c.now.Add(d), ch: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.ch <- c.now
+		return t
+	}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// AfterFunc calls f once the clock has been advanced by d. Unlike with the
+// system clock, f is called before Advance returns.
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mux.Lock()
+	t := &fakeTimer{clock: c, at: c.now.Add(d), fn: f}
+	if d > 0 {
+		c.timers = append(c.timers, t)
+	}
+	c.mux.Unlock()
+	if d <= 0 {
+		f()
+	}
+	return t
+}
+
+// Advance moves the clock forward and fires the timers that are due, in
+// order of their deadline.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mux.Lock()
+	c.now = c.now.Add(d)
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		return c.timers[i].at.Before(c.timers[j].at)
+	})
+	var fns []func()
+	i := 0
+	for ; i < len(c.timers) && !c.timers[i].at.After(c.now); i += 1 {
+		if t := c.timers[i]; t.fn != nil {
+			fns = append(fns, t.fn)
+		} else {
+			t.ch <- c.now
+		}
+	}
+	c.timers = append(c.timers[:0], c.timers[i:]...)
+	c.mux.Unlock()
+	// functions can use the clock
+	for _, f := range fns {
+		f()
+	}
+}
+
+// Timers returns the number of timers waiting to fire. Tests can use it to
+// wait until a request is blocked on the clock before advancing it.
+func (c *FakeClock) Timers() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return len(c.timers)
+}
+
+type fakeTimer struct {
+	clock *FakeClock
+	at    time.Time
+	ch    chan time.Time
+	fn    func()
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	for i, x := range c.timers {
+		if x == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
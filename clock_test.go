@@ -0,0 +1,210 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFakeClock(start)
+	early := c.NewTimer(time.Second)
+	late := c.NewTimer(time.Minute)
+	stopped := c.NewTimer(time.Second)
+	if !stopped.Stop() {
+		t.Errorf("expected stop to succeed")
+	}
+	if c.Timers() != 2 {
+		t.Fatalf("expected 2 waiting timers, got %d", c.Timers())
+	}
+
+	c.Advance(2 * time.Second)
+	if got := c.Now(); !got.Equal(start.Add(2 * time.Second)) {
+		t.Errorf("expected %s, got %s", start.Add(2*time.Second), got)
+	}
+	select {
+	case <-early.C():
+	default:
+		t.Errorf("expected early timer to have fired")
+	}
+	select {
+	case <-late.C():
+		t.Errorf("expected late timer to be waiting")
+	case <-stopped.C():
+		t.Errorf("expected stopped timer not to fire")
+	default:
+	}
+	if early.Stop() {
+		t.Errorf("expected stop of fired timer to fail")
+	}
+
+	c.Advance(time.Minute)
+	select {
+	case <-late.C():
+	default:
+		t.Errorf("expected late timer to have fired")
+	}
+	if c.Timers() != 0 {
+		t.Errorf("expected no waiting timers, got %d", c.Timers())
+	}
+}
+
+func TestOptionClock(t *testing.T) {
+	t.Run("rate limit", func(t *testing.T) {
+		c := NewFakeClock(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+		tr := NewTreeMux(OptionClock(c))
+		tr.Handle("/login", bodyHandler("ok"), WithRateLimit(RateLimit{Limit: 1, Period: time.Minute}))
+
+		steps := []struct {
+			advance time.Duration
+			status  int
+			retry   string
+		}{
+			{0, http.StatusOK, ""},
+			{0, http.StatusTooManyRequests, "60"},
+			{45 * time.Second, http.StatusTooManyRequests, "15"},
+			{15 * time.Second, http.StatusOK, ""},
+		}
+		for i, s := range steps {
+			c.Advance(s.advance)
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/login", nil))
+			if w.Code != s.status {
+				t.Errorf("step %d: expected %d, got %d", i, s.status, w.Code)
+			}
+			if got := w.Header().Get("Retry-After"); got != s.retry {
+				t.Errorf("step %d: expected Retry-After %q, got %q", i, s.retry, got)
+			}
+		}
+	})
+	t.Run("tarpit", func(t *testing.T) {
+		c := NewFakeClock(time.Now())
+		tr := NewTreeMux(OptionClock(c))
+		tr.HandleFunc("/foo", bodyHandler("foo"), WithTarpit(time.Hour, time.Hour))
+
+		w := httptest.NewRecorder()
+		done := make(chan struct{})
+		go func() {
+			tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/foo", nil))
+			close(done)
+		}()
+		for c.Timers() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		select {
+		case <-done:
+			t.Fatalf("expected request to be held")
+		default:
+		}
+		c.Advance(time.Hour)
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("expected request to be released")
+		}
+		if body := w.Body.String(); body != "foo" {
+			t.Errorf("expected %q, got %q", "foo", body)
+		}
+	})
+	t.Run("slo window", func(t *testing.T) {
+		c := NewFakeClock(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+		slos := NewSLOTracker()
+		tr := NewTreeMux(OptionClock(c), OptionSLOTracker(slos))
+		tr.HandleFunc("/foo", func(w http.ResponseWriter, r *http.Request) {
+			c.Advance(time.Second)
+		}, WithSLO(SLO{Latency: 100 * time.Millisecond, LatencyTarget: .9, Window: time.Minute}))
+
+		tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/foo", nil))
+		st, _ := slos.Status("/foo")
+		if st.Requests != 1 || st.Compliant {
+			t.Errorf("expected 1 slow request, got %+v", st)
+		}
+		c.Advance(2 * time.Minute)
+		st, _ = slos.Status("/foo")
+		if st.Requests != 0 || !st.Compliant {
+			t.Errorf("expected empty window, got %+v", st)
+		}
+	})
+	t.Run("panic budget", func(t *testing.T) {
+		log.SetOutput(ioutil.Discard)
+		defer log.SetOutput(os.Stderr)
+
+		c := NewFakeClock(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+		tr := NewTreeMux(OptionClock(c), OptionRecovery(nil),
+			OptionPanicBudget(PanicBudget{Threshold: 1, Window: time.Minute}))
+		tr.HandleFunc("/foo", func(w http.ResponseWriter, r *http.Request) {
+			panic("oops")
+		})
+
+		steps := []struct {
+			advance time.Duration
+			status  int
+		}{
+			{0, http.StatusInternalServerError},
+			{0, http.StatusServiceUnavailable},
+			{59 * time.Second, http.StatusServiceUnavailable},
+			{time.Second, http.StatusInternalServerError},
+		}
+		for i, s := range steps {
+			c.Advance(s.advance)
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/foo", nil))
+			if w.Code != s.status {
+				t.Errorf("step %d: expected %d, got %d", i, s.status, w.Code)
+			}
+		}
+	})
+	t.Run("time limit", func(t *testing.T) {
+		c := NewFakeClock(time.Now())
+		tr := NewTreeMux(OptionClock(c))
+		tr.HandleFunc("/foo", func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}, WithTimeout(time.Minute))
+
+		w := httptest.NewRecorder()
+		done := make(chan struct{})
+		go func() {
+			tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/foo", nil))
+			close(done)
+		}()
+		for c.Timers() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		c.Advance(time.Minute)
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("expected request to time out")
+		}
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("expected %d, got %d", http.StatusServiceUnavailable, w.Code)
+		}
+	})
+}
+
+func TestFakeClock_AfterFunc(t *testing.T) {
+	c := NewFakeClock(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+	var fired []string
+	c.AfterFunc(time.Minute, func() { fired = append(fired, "late") })
+	c.AfterFunc(time.Second, func() { fired = append(fired, "early") })
+	stopped := c.AfterFunc(time.Second, func() { fired = append(fired, "stopped") })
+	stopped.Stop()
+
+	c.Advance(time.Second)
+	if len(fired) != 1 || fired[0] != "early" {
+		t.Errorf("expected [early], got %v", fired)
+	}
+	c.Advance(time.Minute)
+	if len(fired) != 2 || fired[1] != "late" {
+		t.Errorf("expected [early late], got %v", fired)
+	}
+}
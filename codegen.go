@@ -0,0 +1,173 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// GenerateRoutes writes Go source declaring constants for the mux's named
+// routes (see WithName) to w. For every route it declares the name and the
+// pattern, and for routes with wildcards a params struct that builds paths.
+// Call it from a small program invoked by go:generate, to have references to
+// routes checked by the compiler:
+//
+//	//go:generate go run ./cmd/routegen -o routes/routes.go
+func (t *treeMux) GenerateRoutes(w io.Writer, pkg string) error {
+	names := make([]string, 0, len(t.names))
+	for n := range t.names {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	b := &bytes.Buffer{}
+	b.WriteString("// Code generated by treemux. DO NOT EDIT.\n\n")
+	fmt.Fprintf(b, "package %s\n\n", pkg)
+	for _, n := range names {
+		if hasWildcard(t.names[n]) {
+			b.WriteString("import \"net/url\"\n\n")
+			break
+		}
+	}
+	declared := make(map[string]string)
+	for _, n := range names {
+		id := identifier(n)
+		if id == "" {
+			return fmt.Errorf("cannot derive identifier from route name '%s'", n)
+		}
+		decls := []string{id, id + "Pattern"}
+		if hasWildcard(t.names[n]) {
+			decls = append(decls, id+"Params")
+		}
+		for _, d := range decls {
+			if other, ok := declared[d]; ok {
+				return fmt.Errorf("route names '%s' and '%s' both declare %s", other, n, d)
+			}
+			declared[d] = n
+		}
+		genRoute(b, id, n, t.names[n])
+	}
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return fmt.Errorf("could not format generated source: %w", err)
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+func genRoute(b *bytes.Buffer, id, name, pattern string) {
+	fmt.Fprintf(b, "// %s is the name of the route with pattern %q.\n", id, pattern)
+	fmt.Fprintf(b, "const %s = %q\n\n", id, name)
+	fmt.Fprintf(b, "// %sPattern is the pattern of route %q.\n", id, name)
+	fmt.Fprintf(b, "const %sPattern = %q\n\n", id, pattern)
+	if !hasWildcard(pattern) {
+		return
+	}
+
+	ps := strings.Split(strings.TrimPrefix(pattern, "/"), "/")
+	fields := paramFields(ps)
+	fmt.Fprintf(b, "// %sParams holds the wildcard values of route %q.\n", id, name)
+	fmt.Fprintf(b, "type %sParams struct {\n", id)
+	for _, f := range fields {
+		fmt.Fprintf(b, "%s string\n", f)
+	}
+	b.WriteString("}\n\n")
+
+	fmt.Fprintf(b, "// Path returns the path of route %q for the params.\n", name)
+	fmt.Fprintf(b, "func (p %sParams) Path() string {\n", id)
+	b.WriteString("return ")
+	static := ""
+	i := 0
+	for _, p := range ps {
+		static += "/"
+		if !isWildcard(p) {
+			static += p
+			continue
+		}
+		fmt.Fprintf(b, "%q + url.PathEscape(p.%s) + ", static, fields[i])
+		static = ""
+		i += 1
+	}
+	if static == "" {
+		b.Truncate(b.Len() - len(" + "))
+	} else {
+		fmt.Fprintf(b, "%q", static)
+	}
+	b.WriteString("\n}\n\n")
+
+	b.WriteString("// Values returns the params in pattern order, for use with Client.\n")
+	fmt.Fprintf(b, "func (p %sParams) Values() []string {\n", id)
+	b.WriteString("return []string{")
+	for i, f := range fields {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(b, "p.%s", f)
+	}
+	b.WriteString("}\n}\n\n")
+}
+
+func hasWildcard(pattern string) bool {
+	for _, p := range strings.Split(pattern, "/") {
+		if isWildcard(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// paramFields returns a field name for every wildcard in the path elements.
+// Wildcards are named after the element before them (i.e. "Countries" for
+// "/countries/*"), falling back to their position when that name is taken or
+// would clash with the methods of the params struct.
+func paramFields(ps []string) []string {
+	var fields []string
+	seen := map[string]bool{"Path": true, "Values": true}
+	for i, p := range ps {
+		if !isWildcard(p) {
+			continue
+		}
+		f := ""
+		if i > 0 && !isWildcard(ps[i-1]) {
+			f = identifier(ps[i-1])
+		}
+		for j := len(fields); f == "" || seen[f]; j++ {
+			f = fmt.Sprintf("Param%d", j)
+		}
+		seen[f] = true
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// identifier converts a name like "user-detail" into an exported Go
+// identifier like "UserDetail". It returns an empty string when that is not
+// possible.
+func identifier(name string) string {
+	b := &strings.Builder{}
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if b.Len() == 0 && !unicode.IsLetter(r) {
+			return ""
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
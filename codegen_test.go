@@ -0,0 +1,182 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGenerateRoutes(t *testing.T) {
+	tr := NewTreeMux()
+	tr.HandleFunc("/countries/*/cities/*", bodyHandler("city"), WithName("city-detail"))
+	tr.HandleFunc("/health", bodyHandler("ok"), WithName("health"))
+	tr.HandleFunc("/*/*/edit", bodyHandler("pair"), WithName("pair"))
+	tr.HandleFunc("/anonymous", bodyHandler("anonymous"))
+
+	buf := &bytes.Buffer{}
+	if err := tr.GenerateRoutes(buf, "routes"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `// Code generated by treemux. DO NOT EDIT.
+
+package routes
+
+import "net/url"
+
+// CityDetail is the name of the route with pattern "/countries/*/cities/*".
+const CityDetail = "city-detail"
+
+// CityDetailPattern is the pattern of route "city-detail".
+const CityDetailPattern = "/countries/*/cities/*"
+
+// CityDetailParams holds the wildcard values of route "city-detail".
+type CityDetailParams struct {
+	Countries string
+	Cities    string
+}
+
+// Path returns the path of route "city-detail" for the params.
+func (p CityDetailParams) Path() string {
+	return "/countries/" + url.PathEscape(p.Countries) + "/cities/" + url.PathEscape(p.Cities)
+}
+
+// Values returns the params in pattern order, for use with Client.
+func (p CityDetailParams) Values() []string {
+	return []string{p.Countries, p.Cities}
+}
+
+// Health is the name of the route with pattern "/health".
+const Health = "health"
+
+// HealthPattern is the pattern of route "health".
+const HealthPattern = "/health"
+
+// Pair is the name of the route with pattern "/*/*/edit".
+const Pair = "pair"
+
+// PairPattern is the pattern of route "pair".
+const PairPattern = "/*/*/edit"
+
+// PairParams holds the wildcard values of route "pair".
+type PairParams struct {
+	Param0 string
+	Param1 string
+}
+
+// Path returns the path of route "pair" for the params.
+func (p PairParams) Path() string {
+	return "/" + url.PathEscape(p.Param0) + "/" + url.PathEscape(p.Param1) + "/edit"
+}
+
+// Values returns the params in pattern order, for use with Client.
+func (p PairParams) Values() []string {
+	return []string{p.Param0, p.Param1}
+}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestGenerateRoutes_BadName(t *testing.T) {
+	tr := NewTreeMux()
+	tr.HandleFunc("/foo", bodyHandler("foo"), WithName("1foo"))
+	if err := tr.GenerateRoutes(&bytes.Buffer{}, "routes"); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestGenerateRoutes_Collision(t *testing.T) {
+	cases := []struct {
+		name  string
+		names []string
+	}{
+		{"same identifier", []string{"user-detail", "user_detail"}},
+		{"pattern constant", []string{"foo", "foo-pattern"}},
+		{"params type", []string{"foo", "foo-params"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tr := NewTreeMux()
+			for i, n := range c.names {
+				tr.HandleFunc(fmt.Sprintf("/foo%d/*", i), bodyHandler("foo"), WithName(n))
+			}
+			if err := tr.GenerateRoutes(&bytes.Buffer{}, "routes"); err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
+
+func TestGenerateRoutes_Compiles(t *testing.T) {
+	tr := NewTreeMux()
+	tr.HandleFunc("/path/*/values/*", bodyHandler("x"), WithName("path"))
+	tr.HandleFunc("/param0/*/*", bodyHandler("x"), WithName("param"))
+	b := &bytes.Buffer{}
+	if err := tr.GenerateRoutes(b, "routes"); err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "routes.go", b.Bytes(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("routes", fset, []*ast.File{f}, nil); err != nil {
+		t.Errorf("generated code does not compile: %s\n%s", err, b)
+	}
+}
+
+func TestParamFields(t *testing.T) {
+	cases := []struct {
+		pattern string
+		want    []string
+	}{
+		{"/countries/*/cities/*", []string{"Countries", "Cities"}},
+		{"/path/*", []string{"Param0"}},
+		{"/values/*/foo/*", []string{"Param0", "Foo"}},
+		{"/*/*", []string{"Param0", "Param1"}},
+		{"/param1/*/*", []string{"Param1", "Param2"}},
+		{"/foo/*/foo/*", []string{"Foo", "Param1"}},
+	}
+	for _, c := range cases {
+		t.Run(c.pattern, func(t *testing.T) {
+			got := paramFields(strings.Split(strings.TrimPrefix(c.pattern, "/"), "/"))
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("expected %v, got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestIdentifier(t *testing.T) {
+	cases := []struct {
+		name string
+		want string
+	}{
+		{"user-detail", "UserDetail"},
+		{"user_detail", "UserDetail"},
+		{"users.v2", "UsersV2"},
+		{"Foo", "Foo"},
+		{"2fa", ""},
+		{"", ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := identifier(c.name); got != c.want {
+				t.Errorf("expected %q, got %q", c.want, got)
+			}
+		})
+	}
+}
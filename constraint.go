@@ -0,0 +1,49 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// constrainedPrefix starts pattern elements that are wildcards restricted by
+// a regular expression, like "*:[0-9]+" for "{id:[0-9]+}".
+const constrainedPrefix = wildcard + ":"
+
+// constraints caches the compiled expressions of constrained wildcards.
+var constraints sync.Map
+
+// isWildcard reports whether the pattern element is a wildcard, constrained
+// or not.
+func isWildcard(x string) bool {
+	return x == wildcard || strings.HasPrefix(x, constrainedPrefix)
+}
+
+// compileConstraint returns the expression of a constrained wildcard element,
+// anchored to match the whole path element.
+func compileConstraint(key string) (*regexp.Regexp, error) {
+	if re, ok := constraints.Load(key); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile("^(?:" + key[len(constrainedPrefix):] + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression in %q: %w", key, err)
+	}
+	constraints.Store(key, re)
+	return re, nil
+}
+
+// matchConstraint reports whether the key is a constrained wildcard that
+// matches the path element.
+func matchConstraint(key, x string) bool {
+	if len(key) <= len(constrainedPrefix) || key[0] != '*' || key[1] != ':' {
+		return false
+	}
+	re, err := compileConstraint(key)
+	return err == nil && re.MatchString(x)
+}
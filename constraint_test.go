@@ -0,0 +1,72 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMatchConstraint(t *testing.T) {
+	cases := []struct {
+		key  string
+		x    string
+		want bool
+	}{
+		{"*:[0-9]+", "123", true},
+		{"*:[0-9]+", "12a", false},
+		{"*:[0-9]+", "", false},
+		{"*:a|b", "ab", false},
+		{"*:a|b", "b", true},
+		{"*", "foo", false},
+		{"foo", "foo", false},
+		{"*:(", "(", false},
+	}
+	for _, c := range cases {
+		if got := matchConstraint(c.key, c.x); got != c.want {
+			t.Errorf("%s %s: expected %v, got %v", c.key, c.x, c.want, got)
+		}
+	}
+}
+
+func TestTreeMux_constrainedWildcard(t *testing.T) {
+	tr := NewTreeMux()
+	tr.HandleFunc("/orders/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, "order %s", PathParam(r, "id"))
+	})
+	tr.HandleFunc("/orders/{slug:[a-z]+-[a-z]+}", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, "slug %s", PathParam(r, "slug"))
+	})
+	tr.HandleFunc("/orders/export", bodyHandler("export"))
+	tr.HandleFunc("/orders/{id:[0-9]+}/items", bodyHandler("items"))
+
+	cases := []struct {
+		path    string
+		want    string
+		pattern string
+	}{
+		{"/orders/42", "order 42", "/orders/*:[0-9]+"},
+		{"/orders/export", "export", "/orders/export"},
+		{"/orders/big-one", "slug big-one", "/orders/*:[a-z]+-[a-z]+"},
+		{"/orders/42/items", "items", "/orders/*:[0-9]+/items"},
+		{"/orders/4x2", "404 page not found\n", ""},
+		{"/orders/export/items", "404 page not found\n", ""},
+	}
+	for _, c := range cases {
+		t.Run(c.path, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, c.path, nil)
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, r)
+			if got := w.Body.String(); got != c.want {
+				t.Errorf("expected %q, got %q", c.want, got)
+			}
+			if _, pattern := tr.Handler(r); pattern != c.pattern {
+				t.Errorf("expected pattern %q, got %q", c.pattern, pattern)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"context"
+	"net/http"
+)
+
+type contextKey int
+
+const (
+	routeKey contextKey = iota
+	deadlineKey
+	routerTraceKey
+	warmupKey
+	matrixKey
+	queryParamsKey
+	errorDetailKey
+	pathParamsKey
+	matchInfoKey
+	clockKey
+)
+
+// withRoute wraps the handler so that the matched route is available from the
+// request context.
+func withRoute(h http.Handler, rt *route) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), routeKey, rt)
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// routeFromContext returns the route that matched the request, or nil if the
+// request was not dispatched by a TreeMux.
+func routeFromContext(r *http.Request) *route {
+	rt, _ := r.Context().Value(routeKey).(*route)
+	return rt
+}
@@ -0,0 +1,155 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxCORSDecisions bounds the number of cached CORS decisions.
+const maxCORSDecisions = 4096
+
+// CORS configures the handling of cross-origin requests.
+//
+// Decisions are cached per origin, route pattern and method for MaxAge, so
+// repeated preflight requests (and the actual requests following them) are
+// answered without calling Allow again.
+type CORS struct {
+	// Allow decides whether the origin may send requests with the method to
+	// the route with the pattern, and which request headers it may use. It
+	// must be set.
+	Allow func(origin, pattern, method string) (headers []string, ok bool)
+	// AllowCredentials lets browsers include credentials in the requests.
+	AllowCredentials bool
+	// MaxAge is how long browsers and the mux may cache a decision. Defaults
+	// to five seconds.
+	MaxAge time.Duration
+}
+
+type corsDecision struct {
+	headers string
+	ok      bool
+	expires time.Time
+}
+
+type cors struct {
+	cfg   CORS
+	clock Clock
+
+	mux       sync.Mutex
+	decisions map[string]corsDecision
+}
+
+func newCORS(cfg CORS) *cors {
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = 5 * time.Second
+	}
+	return &cors{cfg: cfg, clock: realClock{}, decisions: make(map[string]corsDecision)}
+}
+
+// decide returns the decision for the origin, pattern and method, consulting
+// the policy only if there is no cached decision.
+func (c *cors) decide(origin, pattern, method string) corsDecision {
+	key := origin + "\x00" + pattern + "\x00" + method
+	now := c.clock.Now()
+	c.mux.Lock()
+	d, ok := c.decisions[key]
+	c.mux.Unlock()
+	if ok && now.Before(d.expires) {
+		return d
+	}
+
+	headers, allowed := c.cfg.Allow(origin, pattern, method)
+	d = corsDecision{headers: strings.Join(headers, ", "), ok: allowed, expires: now.Add(c.cfg.MaxAge)}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if len(c.decisions) >= maxCORSDecisions {
+		for k, x := range c.decisions {
+			if !now.Before(x.expires) {
+				delete(c.decisions, k)
+			}
+		}
+		if len(c.decisions) >= maxCORSDecisions {
+			c.decisions = make(map[string]corsDecision)
+		}
+	}
+	c.decisions[key] = d
+	return d
+}
+
+// serve adds the CORS headers for cross-origin requests. It answers preflight
+// requests itself and reports whether it did so.
+func (c *cors) serve(t *treeMux, w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	method := r.Method
+	preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+	if preflight {
+		method = r.Header.Get("Access-Control-Request-Method")
+		r2 := r.Clone(r.Context())
+		r2.Method = method
+		r = r2
+	}
+	rt, _ := t.match(r, nil)
+	if rt == nil || rt.handler == nil {
+		// Not found or method not allowed; the mux will respond.
+		return false
+	}
+	h := w.Header()
+	if preflight {
+		h.Add("Vary", "Origin")
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+	} else {
+		h.Add("Vary", "Origin")
+	}
+	d := c.decide(origin, rt.pattern, method)
+	if !d.ok {
+		if preflight {
+			t.writeError(w, r, http.StatusForbidden)
+		}
+		return preflight
+	}
+	h.Set("Access-Control-Allow-Origin", origin)
+	if c.cfg.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if preflight {
+		h.Set("Access-Control-Allow-Methods", method)
+		if d.headers != "" {
+			h.Set("Access-Control-Allow-Headers", d.headers)
+		}
+		h.Set("Access-Control-Max-Age", strconv.Itoa(int(c.cfg.MaxAge/time.Second)))
+		w.WriteHeader(http.StatusNoContent)
+	}
+	return preflight
+}
+
+type optionCORS struct {
+	value *cors
+}
+
+func (o optionCORS) Apply(mux *treeMux) {
+	mux.cors = o.value
+}
+
+func (o optionCORS) private() {}
+
+// OptionCORS makes the mux handle cross-origin requests. Preflight requests
+// are answered for routes that accept the requested method, with a 403 when
+// the policy denies it. Other requests from allowed origins get the
+// Access-Control-Allow-Origin header. It panics when cfg.Allow is nil.
+func OptionCORS(cfg CORS) Option {
+	if cfg.Allow == nil {
+		panic("invalid CORS configuration: no policy")
+	}
+	return optionCORS{newCORS(cfg)}
+}
@@ -0,0 +1,85 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOptionCORS(t *testing.T) {
+	calls := 0
+	tr := NewTreeMux(OptionCORS(CORS{
+		Allow: func(origin, pattern, method string) ([]string, bool) {
+			calls += 1
+			return []string{"Content-Type"}, origin == "https://example.com" && pattern == "/api/*"
+		},
+		MaxAge: time.Minute,
+	}))
+	tr.HandleMethod(http.MethodPut, "/api/*", bodyHandler("put"))
+	tr.HandleFunc("/other", bodyHandler("other"))
+
+	cases := []struct {
+		name      string
+		method    string
+		path      string
+		origin    string
+		requested string
+		want      int
+		allow     string
+		calls     int
+	}{
+		{"preflight", http.MethodOptions, "/api/x", "https://example.com", http.MethodPut, http.StatusNoContent, "https://example.com", 1},
+		{"cached preflight", http.MethodOptions, "/api/y", "https://example.com", http.MethodPut, http.StatusNoContent, "https://example.com", 1},
+		{"actual request", http.MethodPut, "/api/x", "https://example.com", "", http.StatusOK, "https://example.com", 1},
+		{"other origin", http.MethodOptions, "/api/x", "https://evil.com", http.MethodPut, http.StatusForbidden, "", 2},
+		{"cached denial", http.MethodOptions, "/api/x", "https://evil.com", http.MethodPut, http.StatusForbidden, "", 2},
+		{"other route", http.MethodGet, "/other", "https://example.com", "", http.StatusOK, "", 3},
+		{"method not allowed", http.MethodOptions, "/api/x", "https://example.com", http.MethodDelete, http.StatusMethodNotAllowed, "", 3},
+		{"not found", http.MethodOptions, "/foo", "https://example.com", http.MethodPut, http.StatusNotFound, "", 3},
+		{"same origin", http.MethodPut, "/api/x", "", "", http.StatusOK, "", 3},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest(c.method, c.path, nil)
+			if c.origin != "" {
+				r.Header.Set("Origin", c.origin)
+			}
+			if c.requested != "" {
+				r.Header.Set("Access-Control-Request-Method", c.requested)
+			}
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, r)
+			if w.Code != c.want {
+				t.Errorf("expected %d, got %d", c.want, w.Code)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != c.allow {
+				t.Errorf("expected origin %q, got %q", c.allow, got)
+			}
+			if calls != c.calls {
+				t.Errorf("expected %d policy calls, got %d", c.calls, calls)
+			}
+			if c.want == http.StatusNoContent {
+				if got := w.Header().Get("Access-Control-Max-Age"); got != "60" {
+					t.Errorf("expected max age 60, got %q", got)
+				}
+				if got := w.Header().Get("Access-Control-Allow-Headers"); got != "Content-Type" {
+					t.Errorf("expected allowed headers, got %q", got)
+				}
+			}
+		})
+	}
+}
+
+func TestOptionCORS_invalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic")
+		}
+	}()
+	OptionCORS(CORS{})
+}
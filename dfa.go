@@ -0,0 +1,319 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"encoding/binary"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DFAMatcher is a Matcher that compiles all patterns into a single byte-level
+// deterministic automaton. Matching then takes time proportional to the length
+// of the path, regardless of the number of routes. It matches exactly like the
+// default wildcard trie, but does not support wildcards with a regular
+// expression.
+//
+// The automaton is built by Compile, or on the first lookup after a pattern
+// was added. Compilation takes time and memory proportional to the number of
+// distinct pattern bytes times the number of automaton states, so it is best
+// done once, before serving (see Warmup).
+type DFAMatcher struct {
+	mux      sync.Mutex
+	nodes    []dfaNode
+	compiled atomic.Value
+}
+
+// dfaNode is a pattern element. Like in the wildcard trie, the patterns form a
+// tree with children in order of insertion.
+type dfaNode struct {
+	key      string
+	pattern  string
+	value    interface{}
+	children []int
+}
+
+// NewDFAMatcher creates a new, empty DFAMatcher.
+func NewDFAMatcher() *DFAMatcher {
+	m := &DFAMatcher{nodes: []dfaNode{{}}}
+	m.compiled.Store((*dfa)(nil))
+	return m
+}
+
+func (m *DFAMatcher) Add(pattern string, v interface{}) {
+	xs := strings.Split(pattern, "/")
+	idx := 0
+	if xs[0] == "" {
+		idx = 1
+	}
+	if len(xs) > 1 && xs[len(xs)-1] == "" {
+		panic("path cannot end with slash")
+	}
+	for _, x := range xs {
+		if strings.HasPrefix(x, constrainedPrefix) {
+			panic("regular expression elements are not supported")
+		}
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	n := 0
+	for i := idx; i < len(xs); i++ {
+		n = m.child(n, xs[i], "/"+strings.Join(xs[idx:i+1], "/"))
+	}
+	m.nodes[n].value = v
+	m.compiled.Store((*dfa)(nil))
+}
+
+// child returns the child of node n with the given key, adding it when it
+// does not exist yet.
+func (m *DFAMatcher) child(n int, key, pattern string) int {
+	for _, c := range m.nodes[n].children {
+		if m.nodes[c].key == key {
+			return c
+		}
+	}
+	m.nodes = append(m.nodes, dfaNode{key: key, pattern: pattern})
+	c := len(m.nodes) - 1
+	m.nodes[n].children = append(m.nodes[n].children, c)
+	return c
+}
+
+// Compile builds the automaton, if it is not up-to-date already.
+func (m *DFAMatcher) Compile() {
+	m.automaton()
+}
+
+func (m *DFAMatcher) automaton() *dfa {
+	if d := m.compiled.Load().(*dfa); d != nil {
+		return d
+	}
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if d := m.compiled.Load().(*dfa); d != nil {
+		return d
+	}
+	d := compileDFA(m.nodes)
+	m.compiled.Store(d)
+	return d
+}
+
+// Trace returns the value and pattern matching the path. The automaton does
+// not backtrack; the trace records the number of path elements and the
+// number of bytes inspected.
+func (m *DFAMatcher) Trace(path string, mt *MatchTrace) (interface{}, string) {
+	d := m.automaton()
+	if path != "" && path[0] != '/' {
+		path = "/" + path
+	}
+	if mt != nil {
+		mt.Segments = strings.Count(path, "/") + 1
+	}
+	s := int32(0)
+	for i := 0; i < len(path); i++ {
+		if mt.Step() {
+			return nil, ""
+		}
+		if s = d.next(s, path[i]); s < 0 {
+			return nil, ""
+		}
+	}
+	n := d.states[s].best
+	if n < 0 {
+		return nil, ""
+	}
+	return d.nodes[n].value, d.nodes[n].pattern
+}
+
+// Prefix returns the pattern of the deepest node that matches the start of the
+// path.
+func (m *DFAMatcher) Prefix(path string) string {
+	d := m.automaton()
+	if path != "" && path[0] != '/' {
+		path = "/" + path
+	}
+	pattern := ""
+	s := int32(0)
+	for i := 0; i < len(path); i++ {
+		if path[i] == '/' {
+			if n := d.states[s].best; n >= 0 {
+				pattern = d.nodes[n].pattern
+			}
+		}
+		if s = d.next(s, path[i]); s < 0 {
+			return pattern
+		}
+	}
+	if n := d.states[s].best; n >= 0 {
+		pattern = d.nodes[n].pattern
+	}
+	return pattern
+}
+
+// dfa is a compiled automaton. Bytes are mapped to equivalence classes first:
+// the separator, every byte that appears in a pattern and all other bytes.
+type dfa struct {
+	nodes   []dfaNode
+	classes [256]uint16
+	width   int
+	states  []dfaState
+}
+
+type dfaState struct {
+	// next holds the next state per byte class, or -1.
+	next []int32
+	// best is the first node (in insertion order) that is complete in this
+	// state, or -1.
+	best int
+}
+
+func (d *dfa) next(s int32, b byte) int32 {
+	return d.states[s].next[d.classes[b]]
+}
+
+// nfaState is a state of the non-deterministic automaton that the DFA is
+// built from. Every pattern node has a state that is reached when its element
+// is complete; literal elements have a chain of states leading up to it.
+type nfaState struct {
+	// node is the pattern node completed in this state, or -1.
+	node int
+	// b and next hold the transition of literal states.
+	b    byte
+	next int
+	// loop marks wildcard states, which consume any byte but the separator.
+	loop bool
+	// children are the states entered on a separator.
+	children []int
+}
+
+func compileDFA(nodes []dfaNode) *dfa {
+	d := &dfa{nodes: make([]dfaNode, len(nodes))}
+	copy(d.nodes, nodes)
+
+	// Byte classes; class 0 holds all bytes not used in a pattern.
+	var used [256]bool
+	used['/'] = true
+	for _, n := range nodes {
+		if n.key != wildcard {
+			for i := 0; i < len(n.key); i++ {
+				used[n.key[i]] = true
+			}
+		}
+	}
+	d.width = 1
+	for b := range used {
+		if used[b] {
+			d.classes[b] = uint16(d.width)
+			d.width += 1
+		}
+	}
+	reps := make([]int, d.width)
+	for i := range reps {
+		reps[i] = -1
+	}
+	for b := 255; b >= 0; b-- {
+		reps[d.classes[b]] = b
+	}
+
+	// Preorder rank, so that the earliest inserted branch wins.
+	rank := make([]int, len(nodes))
+	r := 0
+	var visit func(n int)
+	visit = func(n int) {
+		rank[n] = r
+		r += 1
+		for _, c := range nodes[n].children {
+			visit(c)
+		}
+	}
+	visit(0)
+
+	// Non-deterministic automaton; state i completes node i.
+	states := make([]nfaState, len(nodes))
+	for i, n := range nodes {
+		states[i] = nfaState{node: i, next: -1, loop: i != 0 && n.key == wildcard}
+	}
+	for i, n := range nodes {
+		for _, c := range n.children {
+			start := c
+			if nodes[c].key != wildcard {
+				for j := len(nodes[c].key) - 1; j >= 0; j-- {
+					states = append(states, nfaState{node: -1, b: nodes[c].key[j], next: start})
+					start = len(states) - 1
+				}
+			}
+			states[i].children = append(states[i].children, start)
+		}
+	}
+
+	// Subset construction.
+	sep := int(d.classes['/'])
+	index := map[string]int32{}
+	var sets [][]int
+	add := func(set []int) int32 {
+		k := setKey(set)
+		if s, ok := index[k]; ok {
+			return s
+		}
+		s := int32(len(d.states))
+		index[k] = s
+		sets = append(sets, set)
+		best := -1
+		for _, x := range set {
+			if n := states[x].node; n >= 0 && (best < 0 || rank[n] < rank[best]) {
+				best = n
+			}
+		}
+		d.states = append(d.states, dfaState{best: best})
+		return s
+	}
+	add([]int{0})
+	for s := 0; s < len(sets); s++ {
+		next := make([]int32, d.width)
+		for c := range next {
+			next[c] = -1
+			if reps[c] < 0 {
+				continue
+			}
+			seen := map[int]bool{}
+			var set []int
+			for _, x := range sets[s] {
+				st := states[x]
+				var ys []int
+				switch {
+				case c == sep:
+					ys = st.children
+				case st.loop:
+					ys = []int{x}
+				case st.next >= 0 && int(d.classes[st.b]) == c:
+					ys = []int{st.next}
+				}
+				for _, y := range ys {
+					if !seen[y] {
+						seen[y] = true
+						set = append(set, y)
+					}
+				}
+			}
+			if len(set) > 0 {
+				sort.Ints(set)
+				next[c] = add(set)
+			}
+		}
+		d.states[s].next = next
+	}
+	return d
+}
+
+func setKey(set []int) string {
+	bs := make([]byte, binary.MaxVarintLen64*len(set))
+	n := 0
+	for _, x := range set {
+		n += binary.PutUvarint(bs[n:], uint64(x))
+	}
+	return string(bs[:n])
+}
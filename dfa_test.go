@@ -0,0 +1,135 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDFAMatcher_Trace(t *testing.T) {
+	m := NewDFAMatcher()
+	m.Add("/foo/bar", 1)
+	m.Add("/foo/*/baz", 2)
+	m.Add("/foo/bar/baz", 3)
+	m.Add("/*/qux", 4)
+	m.Add("/foo", 5)
+
+	cases := []struct {
+		path    string
+		value   interface{}
+		pattern string
+	}{
+		{"/foo/bar", 1, "/foo/bar"},
+		{"/foo/bla/baz", 2, "/foo/*/baz"},
+		{"/foo/bar/baz", 3, "/foo/bar/baz"},
+		{"/bar/qux", 4, "/*/qux"},
+		{"/foo/qux", nil, "/foo/*"},
+		{"/foo", 5, "/foo"},
+		{"foo", 5, "/foo"},
+		{"/fo", nil, "/*"},
+		{"/fo/o", nil, ""},
+		{"/foo/bar/baz/qux", nil, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.path, func(t *testing.T) {
+			mt := &MatchTrace{}
+			v, pattern := m.Trace(c.path, mt)
+			if v != c.value || pattern != c.pattern {
+				t.Errorf("expected %v %q, got %v %q", c.value, c.pattern, v, pattern)
+			}
+			if mt.Inspected == 0 {
+				t.Errorf("expected trace")
+			}
+		})
+	}
+}
+
+func TestDFAMatcher_recompile(t *testing.T) {
+	m := NewDFAMatcher()
+	m.Add("/foo", 1)
+	m.Compile()
+	if v, _ := m.Trace("/bar", nil); v != nil {
+		t.Errorf("expected nil, got %v", v)
+	}
+	m.Add("/bar", 2)
+	if v, _ := m.Trace("/bar", nil); v != 2 {
+		t.Errorf("expected 2, got %v", v)
+	}
+	m.Add("/foo", 3)
+	if v, _ := m.Trace("/foo", nil); v != 3 {
+		t.Errorf("expected 3, got %v", v)
+	}
+}
+
+// TestDFAMatcher_trie compares the automaton to the wildcard trie on random
+// pattern sets.
+func TestDFAMatcher_trie(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	elements := []string{"a", "ab", "b", "ba", "*", "c"}
+	randomPath := func() string {
+		n := 1 + rnd.Intn(4)
+		xs := make([]string, n)
+		for i := range xs {
+			xs[i] = elements[rnd.Intn(len(elements))]
+		}
+		return "/" + strings.Join(xs, "/")
+	}
+	for i := 0; i < 50; i++ {
+		trie := newWildcardTrie("/")
+		m := NewDFAMatcher()
+		for j := 0; j < 1+rnd.Intn(30); j++ {
+			p := randomPath()
+			trie.Add(p, j)
+			m.Add(p, j)
+		}
+		for j := 0; j < 100; j++ {
+			path := randomPath()
+			if rnd.Intn(5) == 0 {
+				path += "x"
+			}
+			v1, p1 := trie.Trace(path, nil)
+			v2, p2 := m.Trace(path, nil)
+			if v1 != v2 || p1 != p2 {
+				t.Fatalf("%s: expected %v %q, got %v %q", path, v1, p1, v2, p2)
+			}
+			if p1, p2 := trie.Prefix(path), m.Prefix(path); p1 != p2 {
+				t.Fatalf("%s: expected prefix %q, got %q", path, p1, p2)
+			}
+		}
+	}
+}
+
+func TestDFAMatcher_mux(t *testing.T) {
+	tr := NewTreeMux(OptionMatcher(NewDFAMatcher()))
+	tr.HandleFunc("/foo/*", bodyHandler("foo"))
+	tr.HandleFunc("/foo/bar", bodyHandler("bar"))
+	tr.HandleFunc("/foo/*", bodyHandler("header"), WithPredicate(hasHeader("X-Foo")))
+
+	cases := []struct {
+		path   string
+		header bool
+		want   string
+	}{
+		{"/foo/bla", false, "foo"},
+		{"/foo/bla", true, "header"},
+		{"/foo/bar", false, "foo"},
+		{"/bar", false, "404 page not found\n"},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, c.path, nil)
+		if c.header {
+			r.Header.Set("X-Foo", "1")
+		}
+		w := httptest.NewRecorder()
+		tr.ServeHTTP(w, r)
+		if got := w.Body.String(); got != c.want {
+			t.Errorf("%s: expected %q, got %q", c.path, c.want, got)
+		}
+	}
+}
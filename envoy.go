@@ -0,0 +1,355 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+type envoyRouteConfig struct {
+	VirtualHosts []struct {
+		Domains []string     `json:"domains"`
+		Routes  []envoyRoute `json:"routes"`
+	} `json:"virtual_hosts"`
+}
+
+type envoyRoute struct {
+	Match struct {
+		Prefix        string             `json:"prefix"`
+		Path          string             `json:"path"`
+		SafeRegex     *envoyRegex        `json:"safe_regex"`
+		CaseSensitive *bool              `json:"case_sensitive"`
+		Headers       []envoyHeaderMatch `json:"headers"`
+	} `json:"match"`
+	Route *struct {
+		Cluster          string `json:"cluster"`
+		WeightedClusters *struct {
+			Clusters []struct {
+				Name   string `json:"name"`
+				Weight int    `json:"weight"`
+			} `json:"clusters"`
+		} `json:"weighted_clusters"`
+	} `json:"route"`
+	Redirect       *envoyRedirect `json:"redirect"`
+	DirectResponse *struct {
+		Status int `json:"status"`
+		Body   *struct {
+			InlineString string `json:"inline_string"`
+		} `json:"body"`
+	} `json:"direct_response"`
+}
+
+type envoyRegex struct {
+	Regex string `json:"regex"`
+}
+
+type envoyHeaderMatch struct {
+	Name           string      `json:"name"`
+	ExactMatch     *string     `json:"exact_match"`
+	PrefixMatch    *string     `json:"prefix_match"`
+	SuffixMatch    *string     `json:"suffix_match"`
+	SafeRegexMatch *envoyRegex `json:"safe_regex_match"`
+	PresentMatch   bool        `json:"present_match"`
+	InvertMatch    bool        `json:"invert_match"`
+}
+
+type envoyRedirect struct {
+	HTTPSRedirect  bool   `json:"https_redirect"`
+	SchemeRedirect string `json:"scheme_redirect"`
+	HostRedirect   string `json:"host_redirect"`
+	PortRedirect   int    `json:"port_redirect"`
+	PathRedirect   string `json:"path_redirect"`
+	PrefixRewrite  string `json:"prefix_rewrite"`
+	ResponseCode   string `json:"response_code"`
+	StripQuery     bool   `json:"strip_query"`
+}
+
+// ImportEnvoy registers routes for an Envoy route configuration, encoded as
+// JSON with snake_case field names. Requests are forwarded to the handlers
+// of the named clusters; weighted clusters are combined with a Split.
+//
+// Supported are virtual host domains (selected like Envoy does), prefix, path and safe_regex matches,
+// header matches, cluster routes, redirects and direct responses. Unlike
+// Envoy, which uses the first matching route, exact path matches take
+// precedence over prefix matches, and longer prefixes over shorter ones.
+// Regular expression matches are treated as a prefix match on "/". Case
+// sensitive path matches on paths that end with a slash, or that have
+// elements the mux would take for wildcards or parameters (like "*" or
+// "{id}"), are not supported.
+//
+// The routes are only registered when all of them are valid. Otherwise, a
+// RegistrationErrors listing all problems is returned.
+func (t *treeMux) ImportEnvoy(data []byte, clusters map[string]http.Handler) error {
+	var cfg envoyRouteConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return fmt.Errorf("could not parse route configuration: %w", err)
+	}
+	b := &batch{t: t}
+	domains := make([][]string, len(cfg.VirtualHosts))
+	for i, vh := range cfg.VirtualHosts {
+		domains[i] = vh.Domains
+	}
+	for i, vh := range cfg.VirtualHosts {
+		var options []RouteOption
+		if len(domains) > 1 || len(vh.Domains) != 1 || vh.Domains[0] != "*" {
+			options = append(options, WithPredicate(virtualHostPredicate(domains, i)))
+		}
+		for _, rt := range vh.Routes {
+			if err := b.importEnvoyRoute(rt, clusters, options); err != nil {
+				b.fail(rt.pattern(), err)
+			}
+		}
+	}
+	return b.commit()
+}
+
+// pattern returns a description of the route's match, for use in errors.
+func (rt envoyRoute) pattern() string {
+	switch {
+	case rt.Match.SafeRegex != nil:
+		return rt.Match.SafeRegex.Regex
+	case rt.Match.Path != "":
+		return rt.Match.Path
+	}
+	return rt.Match.Prefix
+}
+
+func (b *batch) importEnvoyRoute(rt envoyRoute, clusters map[string]http.Handler, options []RouteOption) error {
+	h, err := envoyAction(rt, clusters)
+	if err != nil {
+		return err
+	}
+	options = append([]RouteOption{}, options...)
+	for _, hm := range rt.Match.Headers {
+		p, err := envoyHeaderPredicate(hm)
+		if err != nil {
+			return err
+		}
+		options = append(options, WithPredicate(p))
+	}
+
+	caseSensitive := rt.Match.CaseSensitive == nil || *rt.Match.CaseSensitive
+	match := rt.Match
+	switch {
+	case match.SafeRegex != nil:
+		re, err := regexp.Compile("^(?:" + match.SafeRegex.Regex + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid regular expression: %w", err)
+		}
+		options = append(options, WithPredicate(func(r *http.Request) bool {
+			return re.MatchString(r.URL.Path)
+		}))
+		b.handlePrefix("/", h, options)
+	case match.Path != "" && caseSensitive:
+		b.handleExact(match.Path, h, options)
+	case match.Path != "":
+		options = append(options, WithPredicate(func(r *http.Request) bool {
+			return strings.EqualFold(r.URL.Path, match.Path)
+		}))
+		b.handlePrefix("/", h, options)
+	case match.Prefix != "":
+		// Envoy prefixes are string prefixes, so "/api" also matches
+		// "/apis". Register on the parent path to catch those.
+		prefix := match.Prefix
+		dir := "/"
+		if caseSensitive {
+			dir = prefix[:strings.LastIndex(prefix, "/")+1]
+		}
+		if prefix != "/" {
+			options = append(options, WithPredicate(func(r *http.Request) bool {
+				if caseSensitive {
+					return strings.HasPrefix(r.URL.Path, prefix)
+				}
+				return strings.HasPrefix(strings.ToLower(r.URL.Path), strings.ToLower(prefix))
+			}))
+		}
+		b.handlePrefix(dir, h, options)
+	default:
+		return fmt.Errorf("route without prefix, path or safe_regex match")
+	}
+	return nil
+}
+
+// envoyAction returns the handler for the route's action.
+func envoyAction(rt envoyRoute, clusters map[string]http.Handler) (http.Handler, error) {
+	switch {
+	case rt.Route != nil && rt.Route.WeightedClusters != nil:
+		var vs []Variant
+		for _, c := range rt.Route.WeightedClusters.Clusters {
+			h, ok := clusters[c.Name]
+			if !ok {
+				return nil, fmt.Errorf("unknown cluster '%s'", c.Name)
+			}
+			if c.Weight > 0 {
+				vs = append(vs, Variant{Name: c.Name, Weight: c.Weight, Handler: h})
+			}
+		}
+		if len(vs) == 0 {
+			return nil, fmt.Errorf("weighted clusters without weight")
+		}
+		return Split{Variants: vs}, nil
+	case rt.Route != nil:
+		h, ok := clusters[rt.Route.Cluster]
+		if !ok {
+			return nil, fmt.Errorf("unknown cluster '%s'", rt.Route.Cluster)
+		}
+		return h, nil
+	case rt.Redirect != nil:
+		return envoyRedirectHandler(*rt.Redirect, rt.Match.Prefix)
+	case rt.DirectResponse != nil:
+		status := rt.DirectResponse.Status
+		var body string
+		if rt.DirectResponse.Body != nil {
+			body = rt.DirectResponse.Body.InlineString
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(body))
+		}), nil
+	}
+	return nil, fmt.Errorf("route without route, redirect or direct_response action")
+}
+
+var envoyRedirectCodes = map[string]int{
+	"":                   http.StatusMovedPermanently,
+	"MOVED_PERMANENTLY":  http.StatusMovedPermanently,
+	"FOUND":              http.StatusFound,
+	"SEE_OTHER":          http.StatusSeeOther,
+	"TEMPORARY_REDIRECT": http.StatusTemporaryRedirect,
+	"PERMANENT_REDIRECT": http.StatusPermanentRedirect,
+}
+
+func envoyRedirectHandler(rd envoyRedirect, prefix string) (http.Handler, error) {
+	code, ok := envoyRedirectCodes[rd.ResponseCode]
+	if !ok {
+		return nil, fmt.Errorf("unsupported response code '%s'", rd.ResponseCode)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u := *r.URL
+		u.Scheme = "http"
+		if r.TLS != nil {
+			u.Scheme = "https"
+		}
+		u.Host = r.Host
+		if rd.HTTPSRedirect {
+			u.Scheme = "https"
+		} else if rd.SchemeRedirect != "" {
+			u.Scheme = rd.SchemeRedirect
+		}
+		if rd.HostRedirect != "" {
+			u.Host = rd.HostRedirect
+		}
+		if rd.PortRedirect != 0 {
+			host := u.Host
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+			u.Host = net.JoinHostPort(host, fmt.Sprint(rd.PortRedirect))
+		}
+		if rd.PathRedirect != "" {
+			u.Path = rd.PathRedirect
+			u.RawPath = ""
+		} else if rd.PrefixRewrite != "" && prefix != "" {
+			u.Path = rd.PrefixRewrite + strings.TrimPrefix(u.Path, prefix)
+			u.RawPath = ""
+		}
+		if rd.StripQuery {
+			u.RawQuery = ""
+		}
+		http.Redirect(w, r, u.String(), code)
+	}), nil
+}
+
+func envoyHeaderPredicate(m envoyHeaderMatch) (Predicate, error) {
+	var ok func(vs []string) bool
+	anyValue := func(f func(string) bool) func([]string) bool {
+		return func(vs []string) bool {
+			for _, v := range vs {
+				if f(v) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	switch {
+	case m.ExactMatch != nil:
+		want := *m.ExactMatch
+		ok = anyValue(func(v string) bool { return v == want })
+	case m.PrefixMatch != nil:
+		want := *m.PrefixMatch
+		ok = anyValue(func(v string) bool { return strings.HasPrefix(v, want) })
+	case m.SuffixMatch != nil:
+		want := *m.SuffixMatch
+		ok = anyValue(func(v string) bool { return strings.HasSuffix(v, want) })
+	case m.SafeRegexMatch != nil:
+		re, err := regexp.Compile("^(?:" + m.SafeRegexMatch.Regex + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression for header '%s': %w", m.Name, err)
+		}
+		ok = anyValue(re.MatchString)
+	case m.PresentMatch:
+		ok = func(vs []string) bool { return len(vs) > 0 }
+	default:
+		return nil, fmt.Errorf("unsupported match for header '%s'", m.Name)
+	}
+	name := m.Name
+	invert := m.InvertMatch
+	return func(r *http.Request) bool {
+		return ok(r.Header.Values(name)) != invert
+	}, nil
+}
+
+// domainScore returns how well the host matches an Envoy virtual host
+// domain: exact matches are better than suffix wildcards, which are better
+// than prefix wildcards, which are better than "*". Longer wildcard domains are
+// better than shorter ones. It returns -1 if the domain does not match.
+func domainScore(domain, host string) int {
+	d := strings.ToLower(domain)
+	if !strings.Contains(d, ":") {
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+	}
+	const rank = 1 << 16
+	switch {
+	case d == "*":
+		return 0
+	case strings.HasPrefix(d, "*"):
+		if len(host) > len(d)-1 && strings.HasSuffix(host, d[1:]) {
+			return 2*rank + len(d)
+		}
+	case strings.HasSuffix(d, "*"):
+		if len(host) > len(d)-1 && strings.HasPrefix(host, d[:len(d)-1]) {
+			return rank + len(d)
+		}
+	case host == d:
+		return 3 * rank
+	}
+	return -1
+}
+
+// virtualHostPredicate returns a predicate that holds when the virtual host
+// with index i is the best match for the request host, like Envoy selects
+// virtual hosts.
+func virtualHostPredicate(domains [][]string, i int) Predicate {
+	return func(r *http.Request) bool {
+		host := strings.ToLower(r.Host)
+		best, bestScore := -1, -1
+		for j, ds := range domains {
+			for _, d := range ds {
+				if score := domainScore(d, host); score > bestScore {
+					best, bestScore = j, score
+				}
+			}
+		}
+		return best == i
+	}
+}
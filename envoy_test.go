@@ -0,0 +1,122 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestImportEnvoy(t *testing.T) {
+	config := `{
+  "name": "local_route",
+  "virtual_hosts": [
+    {
+      "name": "api",
+      "domains": ["api.example.com", "api.example.com:8443"],
+      "routes": [
+        {"match": {"prefix": "/v2", "headers": [{"name": "X-Canary", "present_match": true}]}, "route": {"cluster": "canary"}},
+        {"match": {"prefix": "/v2"}, "route": {"weighted_clusters": {"clusters": [{"name": "v2", "weight": 100}, {"name": "canary", "weight": 0}]}}},
+        {"match": {"path": "/health"}, "direct_response": {"status": 200, "body": {"inline_string": "ok"}}},
+        {"match": {"safe_regex": {"regex": "/users/[0-9]+"}}, "route": {"cluster": "users"}},
+        {"match": {"prefix": "/old/"}, "redirect": {"prefix_rewrite": "/v2/", "response_code": "FOUND"}},
+        {"match": {"path": "/CaseLess", "case_sensitive": false}, "route": {"cluster": "v2"}}
+      ]
+    },
+    {
+      "name": "www",
+      "domains": ["*.example.com"],
+      "routes": [
+        {"match": {"prefix": "/"}, "redirect": {"https_redirect": true, "strip_query": true}}
+      ]
+    }
+  ]
+}`
+	clusters := map[string]http.Handler{
+		"v2":     bodyHandler("v2"),
+		"canary": bodyHandler("canary"),
+		"users":  bodyHandler("users"),
+	}
+
+	cases := []struct {
+		name         string
+		host         string
+		target       string
+		header       string
+		wantStatus   int
+		wantBody     string
+		wantLocation string
+	}{
+		{"string prefix", "api.example.com", "/v2beta/x", "", 200, "v2", ""},
+		{"header", "api.example.com", "/v2/x", "1", 200, "canary", ""},
+		{"direct response", "api.example.com", "/health", "", 200, "ok", ""},
+		{"regex", "api.example.com", "/users/12", "", 200, "users", ""},
+		{"regex no match", "api.example.com", "/users/abc", "", 404, "404 page not found\n", ""},
+		{"prefix rewrite", "api.example.com", "/old/a?b=c", "", 302, "", "http://api.example.com/v2/a?b=c"},
+		{"prefix not matched", "api.example.com", "/old", "", 404, "404 page not found\n", ""},
+		{"case insensitive", "api.example.com", "/caseless", "", 200, "v2", ""},
+		{"domain with port", "api.example.com:8443", "/health", "", 200, "ok", ""},
+		{"wildcard domain", "www.example.com", "/a?b=c", "", 301, "", "https://www.example.com/a"},
+		{"unknown domain", "example.org", "/health", "", 404, "404 page not found\n", ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tr := NewTreeMux()
+			if err := tr.ImportEnvoy([]byte(config), clusters); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			r := httptest.NewRequest(http.MethodGet, c.target, nil)
+			r.Host = c.host
+			if c.header != "" {
+				r.Header.Set("X-Canary", c.header)
+			}
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, r)
+
+			if w.Code != c.wantStatus {
+				t.Errorf("expected status %d, got %d", c.wantStatus, w.Code)
+			}
+			if c.wantBody != "" && w.Body.String() != c.wantBody {
+				t.Errorf("expected body %q, got %q", c.wantBody, w.Body.String())
+			}
+			if got := w.Header().Get("Location"); got != c.wantLocation {
+				t.Errorf("expected location %q, got %q", c.wantLocation, got)
+			}
+		})
+	}
+}
+
+func TestImportEnvoy_Errors(t *testing.T) {
+	cases := []struct {
+		name string
+		data string
+	}{
+		{"invalid json", `{`},
+		{"unknown cluster", `{"virtual_hosts": [{"domains": ["*"], "routes": [{"match": {"prefix": "/"}, "route": {"cluster": "foo"}}]}]}`},
+		{"no action", `{"virtual_hosts": [{"domains": ["*"], "routes": [{"match": {"prefix": "/"}}]}]}`},
+		{"no match", `{"virtual_hosts": [{"domains": ["*"], "routes": [{"match": {}, "route": {"cluster": "v1"}}]}]}`},
+		{"invalid regex", `{"virtual_hosts": [{"domains": ["*"], "routes": [{"match": {"safe_regex": {"regex": "("}}, "route": {"cluster": "v1"}}]}]}`},
+		{"unsupported header match", `{"virtual_hosts": [{"domains": ["*"], "routes": [{"match": {"prefix": "/", "headers": [{"name": "a"}]}, "route": {"cluster": "v1"}}]}]}`},
+		{"unsupported response code", `{"virtual_hosts": [{"domains": ["*"], "routes": [{"match": {"prefix": "/"}, "redirect": {"response_code": "TEAPOT"}}]}]}`},
+		{"path root", `{"virtual_hosts": [{"domains": ["*"], "routes": [{"match": {"path": "/"}, "route": {"cluster": "v1"}}]}]}`},
+		{"path trailing slash", `{"virtual_hosts": [{"domains": ["*"], "routes": [{"match": {"path": "/health/"}, "route": {"cluster": "v1"}}]}]}`},
+		{"path wildcard", `{"virtual_hosts": [{"domains": ["*"], "routes": [{"match": {"path": "/files/*"}, "route": {"cluster": "v1"}}]}]}`},
+		{"path colon param", `{"virtual_hosts": [{"domains": ["*"], "routes": [{"match": {"path": "/users/:id"}, "route": {"cluster": "v1"}}]}]}`},
+		{"path braces", `{"virtual_hosts": [{"domains": ["*"], "routes": [{"match": {"path": "/users/{id}"}, "route": {"cluster": "v1"}}]}]}`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tr := NewTreeMux()
+			if err := tr.ImportEnvoy([]byte(c.data), map[string]http.Handler{"v1": bodyHandler("v1")}); err == nil {
+				t.Errorf("expected error")
+			}
+			if s := tr.Snapshot(); len(s) > 0 {
+				t.Errorf("expected no routes, got:\n%s", s)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// errorFunc writes a router-generated error response.
+type errorFunc func(w http.ResponseWriter, r *http.Request, status int)
+
+// ErrorPage holds the data available to error page templates. Detail explains
+// the error, when the handler provided an explanation (see StatusError).
+type ErrorPage struct {
+	Status   int
+	Title    string
+	Detail   string
+	Path     string
+	Pattern  string
+	Metadata map[string]interface{}
+}
+
+// ErrorRenderer renders the error responses generated by the mux (not found,
+// forbidden, recovered panics, etc.).
+//
+// The response format is negotiated using the Accept header: clients that
+// prefer JSON get an RFC 7807 problem, others get the HTML template, or plain
+// text when there is no template.
+type ErrorRenderer struct {
+	// Template renders HTML error pages with an ErrorPage. A template named
+	// after the status code (i.e. "404") is used when present.
+	Template *template.Template
+	// AlwaysProblem skips content negotiation and always responds with an
+	// RFC 7807 problem.
+	AlwaysProblem bool
+	// ProblemExtensions lists the route metadata keys (see WithMetadata) that
+	// are added to problems as extension members.
+	ProblemExtensions []string
+}
+
+// ProblemRenderer returns an ErrorRenderer that always responds with RFC 7807
+// problems, extended with the given route metadata.
+func ProblemRenderer(extensions ...string) *ErrorRenderer {
+	return &ErrorRenderer{AlwaysProblem: true, ProblemExtensions: extensions}
+}
+
+// Render writes an error response for the given status.
+func (e *ErrorRenderer) Render(w http.ResponseWriter, r *http.Request, status int) {
+	page := ErrorPage{
+		Status: status,
+		Title:  http.StatusText(status),
+		Detail: errorDetail(r),
+		Path:   r.URL.Path,
+	}
+	if rt := routeFromContext(r); rt != nil {
+		page.Pattern = rt.pattern
+		page.Metadata = rt.metadata
+	}
+
+	switch {
+	case e.AlwaysProblem || prefersJSON(r):
+		e.writeProblem(w, page)
+	case e.Template != nil:
+		tmpl := e.Template
+		if t := e.Template.Lookup(fmt.Sprint(status)); t != nil {
+			tmpl = t
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(status)
+		_ = tmpl.Execute(w, page)
+	default:
+		plainError(w, status)
+	}
+}
+
+// prefersJSON reports whether the Accept header gives a JSON type a higher
+// quality than HTML. HTML is also accepted through wildcards; on equal
+// quality, the type listed first wins.
+func prefersJSON(r *http.Request) bool {
+	var jsonQ, htmlQ float64
+	jsonAt, htmlAt := -1, -1
+	for i, mr := range strings.Split(r.Header.Get("Accept"), ",") {
+		typ, q := parseMediaRange(mr)
+		switch {
+		case typ == "application/json" || strings.HasSuffix(typ, "+json"):
+			if q > jsonQ {
+				jsonQ, jsonAt = q, i
+			}
+		case typ == "text/html" || typ == "text/*" || typ == "*/*":
+			if q > htmlQ {
+				htmlQ, htmlAt = q, i
+			}
+		}
+	}
+	if jsonQ == htmlQ {
+		return jsonQ > 0 && jsonAt < htmlAt
+	}
+	return jsonQ > htmlQ
+}
+
+// parseMediaRange returns the (lower case) type and quality of a media range
+// from an Accept header. Invalid quality values count as zero.
+func parseMediaRange(s string) (string, float64) {
+	typ, params, _ := strings.Cut(s, ";")
+	q := 1.0
+	for _, p := range strings.Split(params, ";") {
+		k, v, ok := strings.Cut(p, "=")
+		if !ok || !strings.EqualFold(strings.TrimSpace(k), "q") {
+			continue
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil || f < 0 || f > 1 {
+			f = 0
+		}
+		q = f
+	}
+	return strings.ToLower(strings.TrimSpace(typ)), q
+}
+
+func (e *ErrorRenderer) writeProblem(w http.ResponseWriter, page ErrorPage) {
+	problem := make(map[string]interface{}, 4+len(e.ProblemExtensions))
+	for _, k := range e.ProblemExtensions {
+		if v, ok := page.Metadata[k]; ok {
+			problem[k] = v
+		}
+	}
+	problem["type"] = "about:blank"
+	problem["title"] = page.Title
+	problem["status"] = page.Status
+	if page.Detail != "" {
+		problem["detail"] = page.Detail
+	}
+	problem["instance"] = page.Path
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(page.Status)
+	_ = json.NewEncoder(w).Encode(problem)
+}
+
+// withErrorDetail returns the request with an explanation for the error
+// response to be rendered.
+func withErrorDetail(r *http.Request, detail string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), errorDetailKey, detail))
+}
+
+func errorDetail(r *http.Request) string {
+	s, _ := r.Context().Value(errorDetailKey).(string)
+	return s
+}
+
+// plainError writes a plain text error response.
+func plainError(w http.ResponseWriter, status int) {
+	http.Error(w, fmt.Sprintf("%d %s", status, strings.ToLower(http.StatusText(status))), status)
+}
+
+type errorRenderer struct {
+	prefix   string
+	renderer *ErrorRenderer
+}
+
+type optionErrorRenderer struct {
+	errorRenderer
+}
+
+func (o optionErrorRenderer) Apply(mux *treeMux) {
+	mux.errorRenderers = append(mux.errorRenderers, o.errorRenderer)
+	// longest prefix first
+	sort.SliceStable(mux.errorRenderers, func(i, j int) bool {
+		return len(mux.errorRenderers[i].prefix) > len(mux.errorRenderers[j].prefix)
+	})
+}
+
+func (o optionErrorRenderer) private() {}
+
+// OptionErrorRenderer renders the errors generated by the mux for paths in the
+// given subtree with the renderer. Use "/" for all paths; the renderer with
+// the longest matching prefix is used.
+//
+// Handlers set with OptionNotFound and the like take precedence.
+func OptionErrorRenderer(prefix string, renderer *ErrorRenderer) Option {
+	prefix = strings.TrimSuffix(normalisePattern(prefix), "/")
+	return optionErrorRenderer{errorRenderer{prefix, renderer}}
+}
+
+// writeError writes an error response using the renderer for the path.
+func (t *treeMux) writeError(w http.ResponseWriter, r *http.Request, status int) {
+	for _, er := range t.errorRenderers {
+		if r.URL.Path == er.prefix || strings.HasPrefix(r.URL.Path, er.prefix+"/") {
+			er.renderer.Render(w, r, status)
+			return
+		}
+	}
+	if status == http.StatusNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	plainError(w, status)
+}
+
+// errorHandler returns a handler that writes an error response.
+func (t *treeMux) errorHandler(status int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		t.writeError(w, r, status)
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"html/template"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestOptionErrorRenderer(t *testing.T) {
+	tmpl := template.Must(template.New("error").Parse(`<p>{{.Status}} {{.Title}}</p>`))
+	template.Must(tmpl.New("404").Parse(`<p>Nothing at {{.Path}}</p>`))
+	api := &ErrorRenderer{}
+
+	cases := []struct {
+		name            string
+		path            string
+		accept          string
+		wantCode        int
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			"html not found",
+			"/missing", "text/html",
+			404, "text/html; charset=utf-8", "<p>Nothing at /missing</p>",
+		},
+		{
+			"html status template",
+			"/guarded", "text/html,application/json",
+			403, "text/html; charset=utf-8", "<p>403 Forbidden</p>",
+		},
+		{
+			"json",
+			"/missing", "application/json",
+			404, "application/problem+json",
+			`{"instance":"/missing","status":404,"title":"Not Found","type":"about:blank"}` + "\n",
+		},
+		{
+			"subtree without template",
+			"/api/missing", "text/html",
+			404, "text/plain; charset=utf-8", "404 not found\n",
+		},
+		{
+			"subtree json",
+			"/api/guarded", "application/problem+json",
+			403, "application/problem+json",
+			`{"instance":"/api/guarded","status":403,"title":"Forbidden","type":"about:blank"}` + "\n",
+		},
+		{
+			"subtree prefix is not a string prefix",
+			"/apiary", "text/html",
+			404, "text/html; charset=utf-8", "<p>Nothing at /apiary</p>",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tr := NewTreeMux(
+				OptionErrorRenderer("/", &ErrorRenderer{Template: tmpl}),
+				OptionErrorRenderer("/api/", api),
+			)
+			deny := func(*http.Request) bool { return false }
+			tr.Handle("/guarded", bodyHandler("guarded"), WithGuard(deny))
+			tr.Handle("/api/guarded", bodyHandler("guarded"), WithGuard(deny))
+
+			r := httptest.NewRequest(http.MethodGet, c.path, nil)
+			r.Header.Set("Accept", c.accept)
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, r)
+			if w.Code != c.wantCode {
+				t.Errorf("expected %v, got %v", c.wantCode, w.Code)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != c.wantContentType {
+				t.Errorf("expected %q, got %q", c.wantContentType, ct)
+			}
+			if w.Body.String() != c.wantBody {
+				t.Errorf("expected %q, got %q", c.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestProblemRenderer(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	cases := []struct {
+		name     string
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{
+			"not found",
+			"/api/missing",
+			404,
+			`{"instance":"/api/missing","status":404,"title":"Not Found","type":"about:blank"}` + "\n",
+		},
+		{
+			"recovered panic with extensions",
+			"/api/panic",
+			500,
+			`{"instance":"/api/panic","service":"orders","status":500,"title":"Internal Server Error","type":"about:blank"}` + "\n",
+		},
+		{
+			"rate limited",
+			"/api/limited",
+			429,
+			`{"instance":"/api/limited","status":429,"title":"Too Many Requests","type":"about:blank"}` + "\n",
+		},
+		{
+			"outside subtree",
+			"/missing",
+			404,
+			"404 page not found\n",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tr := NewTreeMux(
+				OptionRecovery(nil),
+				OptionErrorRenderer("/api", ProblemRenderer("service")),
+			)
+			tr.HandleFunc("/api/panic", func(http.ResponseWriter, *http.Request) {
+				panic("oops")
+			}, WithMetadata("service", "orders"), WithMetadata("secret", "x"))
+			tr.Handle("/api/limited", bodyHandler("ok"), WithRateLimit(RateLimit{Limit: 0, Period: time.Minute}))
+
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+			if w.Code != c.wantCode {
+				t.Errorf("expected %v, got %v", c.wantCode, w.Code)
+			}
+			if w.Body.String() != c.wantBody {
+				t.Errorf("expected %s, got %s", c.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestPrefersJSON(t *testing.T) {
+	cases := []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"application/json", true},
+		{"application/problem+json", true},
+		{"text/html", false},
+		{"application/json, text/html", true},
+		{"text/html, application/json", false},
+		{"application/json;q=0.1, text/html", false},
+		{"text/html;q=0.5, application/json", true},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", false},
+		{"application/json, */*;q=0.1", true},
+		{"application/json;q=0.5, */*", false},
+		{"application/json;q=0", false},
+		{"Application/JSON; Q=0.9, text/html; q=0.8", true},
+		{"application/json;q=abc", false},
+	}
+	for _, c := range cases {
+		t.Run(c.accept, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept", c.accept)
+			if got := prefersJSON(r); got != c.want {
+				t.Errorf("expected %v, got %v", c.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOptionFallthrough(t *testing.T) {
+	stats := NewNotFoundStats()
+	legacy := http.NewServeMux()
+	legacy.HandleFunc("/old/", bodyHandler("legacy"))
+	tr := NewTreeMux(OptionFallthrough(legacy), OptionNotFoundStats(stats))
+	tr.HandleFunc("/old/migrated", bodyHandler("migrated"))
+	tr.HandleFunc("/new", bodyHandler("new"), WithMethods(http.MethodPost))
+
+	cases := []struct {
+		method     string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{http.MethodGet, "/old/migrated", http.StatusOK, "migrated"},
+		{http.MethodGet, "/old/other", http.StatusOK, "legacy"},
+		{http.MethodGet, "/new", http.StatusMethodNotAllowed, "405 method not allowed\n"},
+		{http.MethodGet, "/unknown", http.StatusNotFound, "404 page not found\n"},
+	}
+	for _, c := range cases {
+		t.Run(c.path, func(t *testing.T) {
+			r := httptest.NewRequest(c.method, c.path, nil)
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, r)
+			if w.Code != c.wantStatus {
+				t.Errorf("expected %d, got %d", c.wantStatus, w.Code)
+			}
+			if got := w.Body.String(); got != c.wantBody {
+				t.Errorf("expected %q, got %q", c.wantBody, got)
+			}
+		})
+	}
+	if counts := stats.Counts(); len(counts) != 0 {
+		t.Errorf("expected no not found counts, got %v", counts)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/old/other", nil)
+	h, _ := tr.Handler(r)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if got := w.Body.String(); got != "legacy" {
+		t.Errorf("expected fallthrough handler, got %q", got)
+	}
+}
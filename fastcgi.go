@@ -0,0 +1,261 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FastCGI is a handler that forwards requests to a FastCGI responder, like
+// PHP-FPM. Register it with HandleFastCGI to have the SCRIPT_NAME and
+// PATH_INFO derived from the mount path.
+type FastCGI struct {
+	// Network and Address of the FastCGI server, i.e. "unix" and
+	// "/run/php/php-fpm.sock".
+	Network string
+	Address string
+	// ScriptFilename is the path of the script, as seen by the server.
+	ScriptFilename string
+	// Env holds extra parameters to send.
+	Env map[string]string
+	// DialTimeout limits the time to connect to the server, defaults to ten
+	// seconds.
+	DialTimeout time.Duration
+
+	root string
+	fail errorFunc
+}
+
+const (
+	fcgiVersion       = 1
+	fcgiBeginRequest  = 1
+	fcgiEndRequest    = 3
+	fcgiParams        = 4
+	fcgiStdin         = 5
+	fcgiStdout        = 6
+	fcgiStderr        = 7
+	fcgiRoleResponder = 1
+	fcgiMaxWrite      = 65535
+	fcgiRequestID     = 1
+)
+
+// logf logs through the mux logger, or the package log when the handler is
+// not registered with a mux. Errors and the script's standard error output
+// are logged.
+func (f *FastCGI) logf(r *http.Request, level LogLevel, msg string, keysAndValues ...interface{}) {
+	if rt := routeFromContext(r); rt != nil && rt.logf != nil {
+		rt.logf(level, msg, keysAndValues...)
+		return
+	}
+	StdLogger(nil).Log(level, msg, keysAndValues...)
+}
+
+func (f *FastCGI) writeError(w http.ResponseWriter, r *http.Request, status int) {
+	if f.fail != nil {
+		f.fail(w, r, status)
+		return
+	}
+	plainError(w, status)
+}
+
+// cancelReader stops reading once done is closed.
+type cancelReader struct {
+	r    io.Reader
+	done <-chan struct{}
+}
+
+func (c cancelReader) Read(p []byte) (int, error) {
+	select {
+	case <-c.done:
+		return 0, errFastCGICancelled
+	default:
+		return c.r.Read(p)
+	}
+}
+
+var errFastCGICancelled = errors.New("request cancelled")
+
+func (f *FastCGI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	timeout := f.DialTimeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	d := net.Dialer{Timeout: timeout}
+	conn, err := d.DialContext(r.Context(), f.Network, f.Address)
+	if err != nil {
+		f.logf(r, LogError, "could not connect to FastCGI server", "address", f.Address, "error", err)
+		f.writeError(w, r, http.StatusBadGateway)
+		return
+	}
+	if deadline, ok := r.Context().Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	env := cgiEnv(r, f.root)
+	env["SCRIPT_FILENAME"] = f.ScriptFilename
+	for k, v := range f.Env {
+		env[k] = v
+	}
+
+	// The request body must not be read after the handler returns, so the
+	// writer is cancelled and awaited before that.
+	done := make(chan struct{})
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		err := f.writeRequest(conn, env, cancelReader{r.Body, done})
+		if err != nil && !errors.Is(err, errFastCGICancelled) && !errors.Is(err, net.ErrClosed) {
+			f.logf(r, LogError, "could not send FastCGI request", "address", f.Address, "error", err)
+		}
+	}()
+	defer func() {
+		close(done)
+		_ = conn.Close()
+		<-sent
+	}()
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(f.readResponse(r, conn, pw))
+	}()
+	defer pr.Close()
+
+	br := bufio.NewReader(pr)
+	header, err := textproto.NewReader(br).ReadMIMEHeader()
+	if err != nil {
+		f.logf(r, LogError, "invalid FastCGI response", "address", f.Address, "error", err)
+		f.writeError(w, r, http.StatusBadGateway)
+		return
+	}
+
+	status := http.StatusOK
+	if s := header.Get("Status"); s != "" {
+		code, err := strconv.Atoi(strings.SplitN(s, " ", 2)[0])
+		if err != nil {
+			f.logf(r, LogError, "invalid FastCGI status", "address", f.Address, "status", s)
+			f.writeError(w, r, http.StatusBadGateway)
+			return
+		}
+		status = code
+		header.Del("Status")
+	} else if header.Get("Location") != "" {
+		status = http.StatusFound
+	}
+	for k, vs := range header {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	w.WriteHeader(status)
+	_, _ = io.Copy(w, br)
+}
+
+func writeRecord(w io.Writer, typ uint8, content []byte) error {
+	padding := uint8(-len(content) & 7)
+	h := []byte{fcgiVersion, typ, 0, fcgiRequestID, 0, 0, padding, 0}
+	binary.BigEndian.PutUint16(h[4:], uint16(len(content)))
+	if _, err := w.Write(h); err != nil {
+		return err
+	}
+	if _, err := w.Write(content); err != nil {
+		return err
+	}
+	_, err := w.Write(make([]byte, padding))
+	return err
+}
+
+// writeStream writes the data as records of the given type, terminated by an
+// empty record.
+func writeStream(w io.Writer, typ uint8, r io.Reader) error {
+	buf := make([]byte, fcgiMaxWrite)
+	for r != nil {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if err := writeRecord(w, typ, buf[:n]); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return writeRecord(w, typ, nil)
+}
+
+func encodeLength(b *bytes.Buffer, n int) {
+	if n < 128 {
+		b.WriteByte(byte(n))
+		return
+	}
+	var x [4]byte
+	binary.BigEndian.PutUint32(x[:], uint32(n)|1<<31)
+	b.Write(x[:])
+}
+
+func (f *FastCGI) writeRequest(w io.Writer, env map[string]string, body io.Reader) error {
+	bw := bufio.NewWriter(w)
+	begin := []byte{0, fcgiRoleResponder, 0, 0, 0, 0, 0, 0}
+	if err := writeRecord(bw, fcgiBeginRequest, begin); err != nil {
+		return err
+	}
+	params := &bytes.Buffer{}
+	for k, v := range env {
+		encodeLength(params, len(k))
+		encodeLength(params, len(v))
+		params.WriteString(k)
+		params.WriteString(v)
+	}
+	if err := writeStream(bw, fcgiParams, params); err != nil {
+		return err
+	}
+	if err := writeStream(bw, fcgiStdin, body); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func (f *FastCGI) readResponse(req *http.Request, r io.Reader, stdout io.Writer) error {
+	br := bufio.NewReader(r)
+	h := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(br, h); err != nil {
+			return fmt.Errorf("could not read record: %w", err)
+		}
+		if h[0] != fcgiVersion {
+			return fmt.Errorf("unsupported FastCGI version %d", h[0])
+		}
+		content := make([]byte, int(binary.BigEndian.Uint16(h[4:]))+int(h[6]))
+		if _, err := io.ReadFull(br, content); err != nil {
+			return fmt.Errorf("could not read record: %w", err)
+		}
+		content = content[:binary.BigEndian.Uint16(h[4:])]
+		switch h[1] {
+		case fcgiStdout:
+			if _, err := stdout.Write(content); err != nil {
+				return err
+			}
+		case fcgiStderr:
+			if len(content) > 0 {
+				f.logf(req, LogWarning, "FastCGI error output", "address", f.Address, "output", strings.TrimSpace(string(content)))
+			}
+		case fcgiEndRequest:
+			return nil
+		}
+	}
+}
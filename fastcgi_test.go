@@ -0,0 +1,148 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/fcgi"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestTreeMux_HandleFastCGI(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	defer l.Close()
+	go func() {
+		_ = fcgi.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			env := fcgi.ProcessEnv(r)
+			if r.URL.Path == "/app/missing" {
+				http.NotFound(w, r)
+				return
+			}
+			b, _ := ioutil.ReadAll(r.Body)
+			_, _ = fmt.Fprintf(w, "%s %s %s %s %s", r.Method, r.URL.Path, env["SCRIPT_FILENAME"], env["APP_ENV"], b)
+		}))
+	}()
+
+	tr := NewTreeMux()
+	tr.HandleFastCGI("/app/", &FastCGI{
+		Network:        "tcp",
+		Address:        l.Addr().String(),
+		ScriptFilename: "/srv/index.php",
+		Env:            map[string]string{"APP_ENV": "test"},
+	})
+
+	cases := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"get", http.MethodGet, "/app/users/1", "", 200, "GET /app/users/1 /srv/index.php test "},
+		{"post", http.MethodPost, "/app/users", "hello", 200, "POST /app/users /srv/index.php test hello"},
+		{"large body", http.MethodPost, "/app", strings.Repeat("x", 70000), 200, "POST /app /srv/index.php test " + strings.Repeat("x", 70000)},
+		{"status", http.MethodGet, "/app/missing", "", 404, "404 page not found\n"},
+		{"outside", http.MethodGet, "/other", "", 404, "404 page not found\n"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, httptest.NewRequest(c.method, c.target, strings.NewReader(c.body)))
+			if w.Code != c.wantStatus {
+				t.Errorf("expected status %d, got %d", c.wantStatus, w.Code)
+			}
+			if got := w.Body.String(); got != c.wantBody {
+				t.Errorf("expected %q, got %q", c.wantBody, got)
+			}
+		})
+	}
+}
+
+func TestFastCGI_Unavailable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+
+	logs := &syncLog{}
+	tr := NewTreeMux(OptionLogger(logs), OptionErrorRenderer("/", ProblemRenderer()))
+	tr.HandleFastCGI("/", &FastCGI{Network: "tcp", Address: addr})
+	w := httptest.NewRecorder()
+	tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("expected status %d, got %d", http.StatusBadGateway, w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("expected problem details, got %q", ct)
+	}
+	if got := logs.String(); !strings.Contains(got, "could not connect to FastCGI server") {
+		t.Errorf("expected error to be logged, got %q", got)
+	}
+}
+
+// lateReader records whether it is read after the handler returned.
+type lateReader struct {
+	r        io.Reader
+	returned *int32
+	late     *int32
+}
+
+func (l lateReader) Read(p []byte) (int, error) {
+	if atomic.LoadInt32(l.returned) == 1 {
+		atomic.StoreInt32(l.late, 1)
+	}
+	return l.r.Read(p)
+}
+
+func TestFastCGI_InvalidResponse(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// respond without reading the request body
+		_ = writeRecord(conn, fcgiStdout, []byte("not a header\r\n\r\n"))
+		_ = writeRecord(conn, fcgiEndRequest, make([]byte, 8))
+		_, _ = io.Copy(ioutil.Discard, conn)
+	}()
+
+	logs := &syncLog{}
+	tr := NewTreeMux(OptionLogger(logs))
+	tr.HandleFastCGI("/", &FastCGI{Network: "tcp", Address: l.Addr().String()})
+
+	var returned, late int32
+	body := lateReader{strings.NewReader(strings.Repeat("x", 1<<22)), &returned, &late}
+	w := httptest.NewRecorder()
+	tr.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", body))
+	atomic.StoreInt32(&returned, 1)
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("expected status %d, got %d", http.StatusBadGateway, w.Code)
+	}
+	if got := logs.String(); !strings.Contains(got, "invalid FastCGI response") {
+		t.Errorf("expected error to be logged, got %q", got)
+	}
+	if atomic.LoadInt32(&late) == 1 {
+		t.Errorf("request body read after the handler returned")
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"hash/fnv"
+	"strings"
+)
+
+const (
+	bloomBits   = 1 << 16
+	bloomHashes = 3
+)
+
+// bloomFilter is a fixed-size Bloom filter over strings. It never reports a
+// false negative.
+type bloomFilter struct {
+	bits [bloomBits / 64]uint64
+}
+
+func (b *bloomFilter) add(s string) {
+	h1, h2 := bloomHash(s)
+	for i := uint32(0); i < bloomHashes; i++ {
+		n := (h1 + i*h2) % bloomBits
+		b.bits[n/64] |= 1 << (n % 64)
+	}
+}
+
+func (b *bloomFilter) mayContain(s string) bool {
+	h1, h2 := bloomHash(s)
+	for i := uint32(0); i < bloomHashes; i++ {
+		n := (h1 + i*h2) % bloomBits
+		if b.bits[n/64]&(1<<(n%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func bloomHash(s string) (uint32, uint32) {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+	x := h.Sum64()
+	return uint32(x), uint32(x>>32) | 1
+}
+
+// missFilter tracks the first elements of all patterns. Requests with a first
+// element that is not in the filter cannot match any route.
+type missFilter struct {
+	filter bloomFilter
+	// any is set when a pattern matches any first element (i.e. starts
+	// with a wildcard, or is a prefix route for the root).
+	any bool
+}
+
+func (f *missFilter) add(pattern string) {
+	first := firstElement(pattern)
+	if isWildcard(first) || first == "" {
+		f.any = true
+		return
+	}
+	f.filter.add(first)
+}
+
+// miss reports whether the path certainly does not match a route.
+func (f *missFilter) miss(path string) bool {
+	return !f.any && !f.filter.mayContain(firstElement(path))
+}
+
+func firstElement(path string) string {
+	path = strings.TrimPrefix(path, "/")
+	if i := strings.Index(path, "/"); i >= 0 {
+		return path[:i]
+	}
+	return path
+}
+
+type optionFastMiss struct{}
+
+func (o optionFastMiss) Apply(mux *treeMux) {
+	mux.fastMiss = &missFilter{}
+}
+
+func (o optionFastMiss) private() {}
+
+// OptionFastMiss makes the mux keep a Bloom filter of the first path elements
+// of all routes. Requests for paths starting with any other element are not
+// found without consulting the matcher, so junk requests from scanners and
+// probes are cheap to reject. This assumes the first path element is matched
+// literally (or by a wildcard), as by the default matcher.
+func OptionFastMiss() Option {
+	return optionFastMiss{}
+}
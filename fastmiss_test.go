@@ -0,0 +1,78 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBloomFilter(t *testing.T) {
+	b := &bloomFilter{}
+	for i := 0; i < 1000; i++ {
+		b.add(fmt.Sprintf("element-%d", i))
+	}
+	for i := 0; i < 1000; i++ {
+		if s := fmt.Sprintf("element-%d", i); !b.mayContain(s) {
+			t.Fatalf("false negative for %q", s)
+		}
+	}
+	fp := 0
+	for i := 0; i < 1000; i++ {
+		if b.mayContain(fmt.Sprintf("other-%d", i)) {
+			fp += 1
+		}
+	}
+	if fp > 10 {
+		t.Errorf("expected few false positives, got %d", fp)
+	}
+}
+
+func TestOptionFastMiss(t *testing.T) {
+	cases := []struct {
+		name      string
+		patterns  []string
+		prefix    string
+		path      string
+		want      string
+		inspected bool
+	}{
+		{"match", []string{"/foo/bar", "/bar"}, "", "/foo/bar", "/foo/bar", true},
+		{"miss", []string{"/foo/bar", "/bar"}, "", "/wp-admin/login.php", "", false},
+		{"known first element", []string{"/foo/bar", "/bar"}, "", "/foo/qux", "", true},
+		{"wildcard", []string{"/foo/bar", "/*/baz"}, "", "/qux/baz", "/*/baz", true},
+		{"constrained wildcard", []string{"/foo/bar", "/{id:[0-9]+}/baz"}, "", "/12/baz", "/*:[0-9]+/baz", true},
+		{"prefix", []string{"/foo/bar"}, "/static", "/static/x.js", "/static/**", true},
+		{"root prefix", []string{"/foo/bar"}, "/", "/x.js", "/**", true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var mt MatchTrace
+			tr := NewTreeMux(
+				OptionFastMiss(),
+				OptionMatchTrace(func(_ *http.Request, _ string, trace MatchTrace, _ time.Duration) {
+					mt = trace
+				}),
+			)
+			for _, p := range c.patterns {
+				tr.HandleFunc(p, bodyHandler(p))
+			}
+			if c.prefix != "" {
+				tr.Mount(c.prefix, bodyHandler(c.prefix))
+			}
+			r := httptest.NewRequest(http.MethodGet, c.path, nil)
+			tr.ServeHTTP(httptest.NewRecorder(), r)
+			if _, pattern := tr.Handler(r); pattern != c.want {
+				t.Errorf("expected %q, got %q", c.want, pattern)
+			}
+			if got := mt.Inspected > 0; got != c.inspected {
+				t.Errorf("expected inspected %v, got %v", c.inspected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+func (t *treeMux) ServeFiles(pattern string, root http.FileSystem, options ...RouteOption) {
+	_, param, ok := parseCatchAll(normalisePattern(t.expandFragments(pattern)))
+	if !ok {
+		panic(fmt.Sprintf("pattern %s does not end with a catch-all", pattern))
+	}
+	t.Handle(pattern, t.fileHandler(root, param.name), options...)
+}
+
+// fileHandler serves the file named by the catch-all parameter. Directories
+// are served by their index.html, as there are no listings.
+func (t *treeMux) fileHandler(root http.FileSystem, param string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rest := Params(r)[param]
+		for _, x := range strings.Split(rest, "/") {
+			if x == ".." {
+				t.notFound(w, r)
+				return
+			}
+		}
+		f, info, err := openFile(root, path.Clean("/"+rest))
+		if err != nil {
+			t.notFound(w, r)
+			return
+		}
+		defer f.Close()
+		http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+	})
+}
+
+// openFile opens the named file, or the index.html of the named directory.
+func openFile(root http.FileSystem, name string) (http.File, fs.FileInfo, error) {
+	f, err := root.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err == nil && info.IsDir() {
+		_ = f.Close()
+		if f, err = root.Open(path.Join(name, "index.html")); err != nil {
+			return nil, nil, err
+		}
+		info, err = f.Stat()
+	}
+	if err == nil && info.IsDir() {
+		err = fs.ErrNotExist
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, nil, err
+	}
+	return f, info, nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestTreeMux_ServeFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app.js":          {Data: []byte("js")},
+		"css/site.css":    {Data: []byte("css")},
+		"docs/index.html": {Data: []byte("docs")},
+		"empty/x/y.txt":   {Data: []byte("y")},
+	}
+	tr := NewTreeMux(OptionNotFound(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte("custom"))
+	}))
+	tr.ServeFiles("/assets/**", http.FS(fsys))
+	tr.ServeFiles("/named/{file...}", http.FS(fsys))
+
+	cases := []struct {
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"/assets/app.js", http.StatusOK, "js"},
+		{"/assets/css/site.css", http.StatusOK, "css"},
+		{"/named/css/site.css", http.StatusOK, "css"},
+		{"/assets/docs", http.StatusOK, "docs"},
+		{"/assets/docs/", http.StatusOK, "docs"},
+		{"/assets/empty", http.StatusNotFound, "custom"},
+		{"/assets/missing.js", http.StatusNotFound, "custom"},
+		{"/assets/css/../app.js", http.StatusNotFound, "custom"},
+		{"/assets/%2e%2e/app.js", http.StatusNotFound, "custom"},
+	}
+	for _, c := range cases {
+		t.Run(c.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+			if w.Code != c.wantStatus {
+				t.Errorf("expected %d, got %d", c.wantStatus, w.Code)
+			}
+			if got := w.Body.String(); got != c.wantBody {
+				t.Errorf("expected %q, got %q", c.wantBody, got)
+			}
+		})
+	}
+}
+
+func TestTreeMux_ServeFiles_noCatchAll(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic")
+		}
+	}()
+	NewTreeMux().ServeFiles("/assets", http.Dir("."))
+}
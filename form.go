@@ -0,0 +1,188 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const (
+	defaultFormMaxMemory    = 32 << 20
+	defaultFormMaxBodyBytes = 10 << 20
+)
+
+// FormLimits configures WithForm.
+type FormLimits struct {
+	// MaxMemory is the number of bytes of multipart files kept in memory;
+	// the remainder is stored in temporary files. Defaults to 32 MiB.
+	MaxMemory int64
+	// MaxBodyBytes limits the size of the request body. Defaults to 10 MiB.
+	MaxBodyBytes int64
+}
+
+func (l FormLimits) maxMemory() int64 {
+	if l.MaxMemory == 0 {
+		return defaultFormMaxMemory
+	}
+	return l.MaxMemory
+}
+
+func (l FormLimits) maxBodyBytes() int64 {
+	if l.MaxBodyBytes == 0 {
+		return defaultFormMaxBodyBytes
+	}
+	return l.MaxBodyBytes
+}
+
+type withForm struct {
+	value FormLimits
+}
+
+func (o withForm) Apply(rt *route) {
+	rt.form = &o.value
+}
+
+func (o withForm) private() {}
+
+// WithForm parses URL-encoded and multipart form bodies before the handler is
+// called, so it can use the request's Form, PostForm and MultipartForm fields
+// or the typed accessors of RequestForm. Malformed bodies get a 400 response,
+// bodies exceeding the limit a 413. Temporary files are removed when the
+// handler returns.
+func WithForm(limits FormLimits) RouteOption {
+	return withForm{limits}
+}
+
+var errBodyTooLarge = errors.New("body too large")
+
+// limitedBody is a request body that fails after reading more than max bytes.
+type limitedBody struct {
+	io.ReadCloser
+	max      int64
+	exceeded bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.max < 0 {
+		b.exceeded = true
+		return 0, errBodyTooLarge
+	}
+	if int64(len(p)) > b.max+1 {
+		p = p[:b.max+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.max -= int64(n)
+	if b.max < 0 {
+		b.exceeded = true
+		return n, errBodyTooLarge
+	}
+	return n, err
+}
+
+// parseForm wraps the handler so that it gets a request with a parsed form.
+func parseForm(h http.Handler, limits *FormLimits, fail errorFunc) http.Handler {
+	if limits == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body *limitedBody
+		if r.Body != nil {
+			body = &limitedBody{ReadCloser: r.Body, max: limits.maxBodyBytes()}
+			r.Body = body
+		}
+		var err error
+		if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct == "multipart/form-data" {
+			err = r.ParseMultipartForm(limits.maxMemory())
+		} else {
+			err = r.ParseForm()
+		}
+		if r.MultipartForm != nil {
+			defer func() {
+				_ = r.MultipartForm.RemoveAll()
+			}()
+		}
+		if err != nil {
+			if body != nil && body.exceeded {
+				fail(w, r, http.StatusRequestEntityTooLarge)
+				return
+			}
+			fail(w, r, http.StatusBadRequest)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// Form gives typed access to a parsed form (see WithForm). The accessors
+// report false when the field is missing or cannot be parsed.
+type Form struct {
+	// Values holds the form values, including those of the query string.
+	Values url.Values
+	// Files holds the multipart files.
+	Files map[string][]*multipart.FileHeader
+}
+
+// RequestForm returns the parsed form of the request.
+func RequestForm(r *http.Request) Form {
+	f := Form{Values: r.Form}
+	if r.MultipartForm != nil {
+		f.Files = r.MultipartForm.File
+	}
+	return f
+}
+
+// String returns the first value of the field.
+func (f Form) String(name string) (string, bool) {
+	vs, ok := f.Values[name]
+	if !ok || len(vs) == 0 {
+		return "", false
+	}
+	return vs[0], true
+}
+
+// Int returns the first value of the field as an integer.
+func (f Form) Int(name string) (int64, bool) {
+	s, ok := f.String(name)
+	if !ok {
+		return 0, false
+	}
+	i, err := strconv.ParseInt(s, 10, 64)
+	return i, err == nil
+}
+
+// Float returns the first value of the field as a floating point number.
+func (f Form) Float(name string) (float64, bool) {
+	s, ok := f.String(name)
+	if !ok {
+		return 0, false
+	}
+	x, err := strconv.ParseFloat(s, 64)
+	return x, err == nil
+}
+
+// Bool returns the first value of the field as a boolean.
+func (f Form) Bool(name string) (bool, bool) {
+	s, ok := f.String(name)
+	if !ok {
+		return false, false
+	}
+	b, err := strconv.ParseBool(s)
+	return b, err == nil
+}
+
+// File returns the first file of the field.
+func (f Form) File(name string) (*multipart.FileHeader, bool) {
+	fs := f.Files[name]
+	if len(fs) == 0 {
+		return nil, false
+	}
+	return fs[0], true
+}
@@ -0,0 +1,75 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWithForm(t *testing.T) {
+	tr := NewTreeMux()
+	tr.HandleFunc("/form", func(w http.ResponseWriter, r *http.Request) {
+		f := RequestForm(r)
+		name, _ := f.String("name")
+		age, ok := f.Int("age")
+		_, _ = fmt.Fprintf(w, "%s %d %v", name, age, ok)
+		if fh, ok := f.File("file"); ok {
+			file, _ := fh.Open()
+			bs, _ := ioutil.ReadAll(file)
+			_, _ = fmt.Fprintf(w, " %s", bs)
+		}
+	}, WithForm(FormLimits{MaxBodyBytes: 1024}))
+
+	multipartBody := func(fields map[string]string, file string) (string, string) {
+		buf := &bytes.Buffer{}
+		mw := multipart.NewWriter(buf)
+		for k, v := range fields {
+			_ = mw.WriteField(k, v)
+		}
+		if file != "" {
+			fw, _ := mw.CreateFormFile("file", "file.txt")
+			_, _ = fw.Write([]byte(file))
+		}
+		_ = mw.Close()
+		return buf.String(), mw.FormDataContentType()
+	}
+	mpBody, mpType := multipartBody(map[string]string{"name": "bob", "age": "42"}, "hello")
+
+	cases := []struct {
+		name        string
+		body        string
+		contentType string
+		want        int
+		wantBody    string
+	}{
+		{"urlencoded", "name=alice&age=30", "application/x-www-form-urlencoded", http.StatusOK, "alice 30 true"},
+		{"bad int", "name=alice&age=old", "application/x-www-form-urlencoded", http.StatusOK, "alice 0 false"},
+		{"multipart", mpBody, mpType, http.StatusOK, "bob 42 true hello"},
+		{"malformed urlencoded", "name=%zz", "application/x-www-form-urlencoded", http.StatusBadRequest, ""},
+		{"malformed multipart", "garbage", "multipart/form-data; boundary=xyz", http.StatusBadRequest, ""},
+		{"too large", "name=" + strings.Repeat("x", 2000), "application/x-www-form-urlencoded", http.StatusRequestEntityTooLarge, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/form", strings.NewReader(c.body))
+			r.Header.Set("Content-Type", c.contentType)
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, r)
+			if w.Code != c.want {
+				t.Fatalf("expected %d, got %d", c.want, w.Code)
+			}
+			if c.wantBody != "" && w.Body.String() != c.wantBody {
+				t.Errorf("expected %q, got %q", c.wantBody, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"fmt"
+	"strings"
+)
+
+func (t *treeMux) DefineFragment(name, fragment string) {
+	if name == "" || strings.ContainsAny(name, "{}/") {
+		panic(fmt.Sprintf("invalid fragment name %q", name))
+	}
+	if strings.Contains(fragment, "{@") {
+		panic(fmt.Sprintf("fragment %s cannot reference other fragments", name))
+	}
+	t.routesMux.Lock()
+	defer t.routesMux.Unlock()
+	if _, ok := t.fragments[name]; ok {
+		panic(fmt.Sprintf("fragment %s is already defined", name))
+	}
+	if t.fragments == nil {
+		t.fragments = make(map[string]string)
+	}
+	t.fragments[name] = fragment
+}
+
+// expandFragments replaces the fragment references in the path. It panics on
+// undefined fragments.
+func (t *treeMux) expandFragments(path string) string {
+	if !strings.Contains(path, "{@") {
+		return path
+	}
+	t.routesMux.RLock()
+	defer t.routesMux.RUnlock()
+	var sb strings.Builder
+	for {
+		before, after, ok := strings.Cut(path, "{@")
+		sb.WriteString(before)
+		if !ok {
+			return sb.String()
+		}
+		name, rest, ok := strings.Cut(after, "}")
+		fragment, defined := t.fragments[name]
+		if !ok || !defined {
+			panic(fmt.Sprintf("undefined fragment {@%s}", name))
+		}
+		sb.WriteString(fragment)
+		path = rest
+	}
+}
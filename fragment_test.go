@@ -0,0 +1,65 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTreeMux_DefineFragment(t *testing.T) {
+	tr := NewTreeMux()
+	tr.DefineFragment("tenant", "tenants/{tenant}")
+	tr.DefineFragment("id", "{id}")
+	tr.DefineFragment("assets", "static/v1")
+	params := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(fmt.Sprint(Params(r))))
+	}
+	tr.HandleFunc("/{@tenant}/users/{@id}", params)
+	tr.Mount("/{@assets}", bodyHandler("assets"))
+
+	cases := []struct {
+		path string
+		want string
+	}{
+		{"/tenants/acme/users/42", "map[id:42 tenant:acme]"},
+		{"/static/v1/x.js", "assets"},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+		if got := w.Body.String(); got != c.want {
+			t.Errorf("%s: expected %q, got %q", c.path, c.want, got)
+		}
+	}
+}
+
+func TestTreeMux_DefineFragment_invalid(t *testing.T) {
+	cases := []struct {
+		name string
+		fn   func(tr TreeMux)
+	}{
+		{"empty name", func(tr TreeMux) { tr.DefineFragment("", "foo") }},
+		{"nested", func(tr TreeMux) { tr.DefineFragment("foo", "{@bar}") }},
+		{"redefined", func(tr TreeMux) {
+			tr.DefineFragment("foo", "foo")
+			tr.DefineFragment("foo", "bar")
+		}},
+		{"undefined", func(tr TreeMux) { tr.HandleFunc("/{@foo}/bar", bodyHandler("")) }},
+		{"unterminated", func(tr TreeMux) { tr.HandleFunc("/{@foo", bodyHandler("")) }},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic")
+				}
+			}()
+			c.fn(NewTreeMux())
+		})
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// httpRulePattern converts a google.api.http path template, as used in
+// annotated protos and grpc-gateway, into a pattern and custom verb. Variables
+// become wildcards, i.e. "/v1/{name=shelves/*}/books/{book}:publish" becomes
+// "/v1/shelves/*/books/*" with verb "publish".
+func httpRulePattern(template string) (string, string, error) {
+	if !strings.HasPrefix(template, "/") {
+		return "", "", fmt.Errorf("template '%s' does not start with '/'", template)
+	}
+	s := template[1:]
+
+	var verb string
+	if i := strings.LastIndex(s, ":"); i > strings.LastIndex(s, "}") && i > strings.LastIndex(s, "/") {
+		s, verb = s[:i], s[i+1:]
+	}
+
+	var out []string
+	for len(s) > 0 {
+		var seg string
+		if s[0] == '{' {
+			end := strings.Index(s, "}")
+			if end < 0 {
+				return "", "", fmt.Errorf("unterminated variable in template '%s'", template)
+			}
+			seg, s = s[1:end], s[end+1:]
+			if i := strings.Index(seg, "="); i >= 0 {
+				seg = seg[i+1:]
+			} else {
+				seg = wildcard
+			}
+		} else if i := strings.IndexAny(s, "/{"); i >= 0 {
+			seg, s = s[:i], s[i:]
+		} else {
+			seg, s = s, ""
+		}
+		for _, x := range strings.Split(seg, "/") {
+			if x == "**" {
+				return "", "", fmt.Errorf("template '%s': '**' is not supported", template)
+			}
+			if x == "" || strings.ContainsAny(x, "{}=") {
+				return "", "", fmt.Errorf("invalid template '%s'", template)
+			}
+			out = append(out, x)
+		}
+		if strings.HasPrefix(s, "/") {
+			s = s[1:]
+			if s == "" {
+				return "", "", fmt.Errorf("template '%s' ends with '/'", template)
+			}
+		} else if s != "" {
+			return "", "", fmt.Errorf("invalid template '%s'", template)
+		}
+	}
+	return "/" + strings.Join(out, "/"), verb, nil
+}
+
+// HandleHTTPRule registers a handler for an HTTP rule from an annotated proto
+// (google.api.http), so native handlers can serve the same paths as
+// grpc-gateway transcoded methods. Custom verbs on a wildcard are matched
+// with a predicate.
+//
+//	t.HandleHTTPRule(http.MethodPost, "/v1/{name=topics/*}:publish", publish)
+func (t *treeMux) HandleHTTPRule(method, template string, handler http.Handler, options ...RouteOption) error {
+	pattern, verb, err := httpRulePattern(template)
+	if err != nil {
+		return err
+	}
+	options = append([]RouteOption{WithPredicate(func(r *http.Request) bool {
+		return r.Method == method
+	})}, options...)
+	if verb != "" {
+		last := pattern[strings.LastIndex(pattern, "/")+1:]
+		if last == wildcard {
+			suffix := ":" + verb
+			options = append(options, WithPredicate(func(r *http.Request) bool {
+				return strings.HasSuffix(r.URL.Path, suffix)
+			}))
+		} else {
+			pattern += ":" + verb
+		}
+	}
+	t.Handle(pattern, handler, options...)
+	return nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPRulePattern(t *testing.T) {
+	cases := []struct {
+		template    string
+		wantPattern string
+		wantVerb    string
+		wantErr     bool
+	}{
+		{"/v1/shelves", "/v1/shelves", "", false},
+		{"/v1/shelves/{shelf}", "/v1/shelves/*", "", false},
+		{"/v1/{name=shelves/*/books/*}", "/v1/shelves/*/books/*", "", false},
+		{"/v1/{name=topics/*}:publish", "/v1/topics/*", "publish", false},
+		{"/v1/shelves:batchGet", "/v1/shelves", "batchGet", false},
+		{"/v1/shelves/*", "/v1/shelves/*", "", false},
+		{"/v1/{name=files/**}", "", "", true},
+		{"v1/shelves", "", "", true},
+		{"/v1/{shelf", "", "", true},
+		{"/v1/shelves/", "", "", true},
+		{"/v1/a{shelf}", "", "", true},
+	}
+	for _, c := range cases {
+		t.Run(c.template, func(t *testing.T) {
+			pattern, verb, err := httpRulePattern(c.template)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("expected error %v, got %v", c.wantErr, err)
+			}
+			if pattern != c.wantPattern || verb != c.wantVerb {
+				t.Errorf("expected %q, %q, got %q, %q", c.wantPattern, c.wantVerb, pattern, verb)
+			}
+		})
+	}
+}
+
+func TestTreeMux_HandleHTTPRule(t *testing.T) {
+	gw := http.NewServeMux()
+	gw.HandleFunc("/v1/", bodyHandler("gateway"))
+
+	tr := NewTreeMux()
+	tr.Mount("/v1", gw)
+	must := func(err error) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	must(tr.HandleHTTPRule(http.MethodGet, "/v1/{name=shelves/*}", bodyHandler("get shelf")))
+	must(tr.HandleHTTPRule(http.MethodPost, "/v1/{name=topics/*}:publish", bodyHandler("publish")))
+	must(tr.HandleHTTPRule(http.MethodPost, "/v1/shelves:batchGet", bodyHandler("batch")))
+
+	cases := []struct {
+		name   string
+		method string
+		target string
+		want   string
+	}{
+		{"rule", http.MethodGet, "/v1/shelves/1", "get shelf"},
+		{"other method", http.MethodDelete, "/v1/shelves/1", "gateway"},
+		{"verb on wildcard", http.MethodPost, "/v1/topics/news:publish", "publish"},
+		{"missing verb", http.MethodPost, "/v1/topics/news", "gateway"},
+		{"verb on literal", http.MethodPost, "/v1/shelves:batchGet", "batch"},
+		{"mounted", http.MethodGet, "/v1/books/1/pages", "gateway"},
+		{"outside mount", http.MethodGet, "/v2/shelves/1", "404 page not found\n"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, httptest.NewRequest(c.method, c.target, nil))
+			if got := w.Body.String(); got != c.want {
+				t.Errorf("expected %q, got %q", c.want, got)
+			}
+		})
+	}
+}
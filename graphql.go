@@ -0,0 +1,128 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// MetadataGraphQLOperation is the metadata key holding the name or hash of
+// the operation of routes registered with HandleGraphQL.
+const MetadataGraphQLOperation = "graphql.operation"
+
+const maxGraphQLBody = 1 << 20
+
+// GraphQLOperation configures the route of a GraphQL operation. An operation
+// is identified by its name, its persisted query hash or both.
+type GraphQLOperation struct {
+	// Name is the operation name.
+	Name string
+	// Hash is the SHA-256 hash of an automatic persisted query.
+	Hash string
+	// Options apply to the operation's route, in addition to the options
+	// of the endpoint.
+	Options []RouteOption
+}
+
+// graphQLBody is a request body that has already been inspected for the
+// operation name and persisted query hash. When the body could not be read,
+// err holds the reason.
+type graphQLBody struct {
+	io.ReadCloser
+	name string
+	hash string
+	err  error
+}
+
+type graphQLParams struct {
+	OperationName string          `json:"operationName"`
+	Extensions    json.RawMessage `json:"extensions"`
+}
+
+type graphQLExtensions struct {
+	PersistedQuery struct {
+		SHA256Hash string `json:"sha256Hash"`
+	} `json:"persistedQuery"`
+}
+
+// graphQLOperation returns the operation name and persisted query hash of the
+// request. The body of POST requests is inspected once and restored.
+func graphQLOperation(r *http.Request) (string, string) {
+	if r.Method == http.MethodGet {
+		q := r.URL.Query()
+		return q.Get("operationName"), persistedQueryHash([]byte(q.Get("extensions")))
+	}
+	if b, ok := r.Body.(*graphQLBody); ok {
+		return b.name, b.hash
+	}
+	body, err := readBody(r, maxGraphQLBody)
+	if err != nil {
+		r.Body = &graphQLBody{ReadCloser: http.NoBody, err: err}
+		return "", ""
+	}
+	var p graphQLParams
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		_ = json.Unmarshal(body, &p)
+	}
+	b := &graphQLBody{ReadCloser: r.Body, name: p.OperationName, hash: persistedQueryHash(p.Extensions)}
+	r.Body = b
+	return b.name, b.hash
+}
+
+func persistedQueryHash(extensions []byte) string {
+	var ext graphQLExtensions
+	if len(extensions) == 0 || json.Unmarshal(extensions, &ext) != nil {
+		return ""
+	}
+	return ext.PersistedQuery.SHA256Hash
+}
+
+func isGetOrPost(r *http.Request) bool {
+	return r.Method == http.MethodGet || r.Method == http.MethodPost
+}
+
+// rejectUnreadable wraps the handler so that requests with a body that could
+// not be inspected are rejected, rather than passed on without a body.
+func rejectUnreadable(h http.Handler, fail errorFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if b, ok := r.Body.(*graphQLBody); ok && b.err != nil {
+			if errors.Is(b.err, errBodyTooLarge) {
+				fail(w, r, http.StatusRequestEntityTooLarge)
+			} else {
+				fail(w, r, http.StatusBadRequest)
+			}
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func (t *treeMux) HandleGraphQL(path string, handler http.Handler, operations []GraphQLOperation, options ...RouteOption) {
+	handler = rejectUnreadable(handler, t.writeError)
+	for _, op := range operations {
+		op := op
+		id := op.Name
+		if op.Hash != "" {
+			id = op.Hash
+		}
+		opts := append([]RouteOption{}, options...)
+		opts = append(opts, WithMetadata(MetadataGraphQLOperation, id))
+		opts = append(opts, op.Options...)
+		opts = append(opts, WithPredicate(isGetOrPost), WithPredicate(func(r *http.Request) bool {
+			name, hash := graphQLOperation(r)
+			return (op.Name == "" || op.Name == name) && (op.Hash == "" || op.Hash == hash)
+		}))
+		t.Handle(path, handler, opts...)
+	}
+	t.Handle(path, handler, append(append([]RouteOption{}, options...), WithPredicate(isGetOrPost))...)
+	t.Handle(path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", "GET, POST")
+		t.writeError(w, r, http.StatusMethodNotAllowed)
+	}))
+}
@@ -0,0 +1,94 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestTreeMux_HandleGraphQL(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		op, _ := RouteMetadata(r, MetadataGraphQLOperation)
+		b, _ := ioutil.ReadAll(r.Body)
+		_, _ = fmt.Fprintf(w, "%v %d", op, len(b))
+	}
+	tr := NewTreeMux()
+	tr.HandleGraphQL("/graphql", http.HandlerFunc(h), []GraphQLOperation{
+		{Name: "Login", Options: []RouteOption{WithGuard(hasHeader("X-Client"))}},
+		{Hash: "abc123"},
+	})
+
+	getTarget := "/graphql?" + url.Values{
+		"operationName": {"Feed"},
+		"extensions":    {`{"persistedQuery":{"version":1,"sha256Hash":"abc123"}}`},
+	}.Encode()
+	login := `{"operationName":"Login","query":"mutation Login { login }"}`
+
+	cases := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		client     bool
+		wantStatus int
+		wantBody   string
+	}{
+		{"operation name", http.MethodPost, "/graphql", login, true, 200, fmt.Sprintf("Login %d", len(login))},
+		{"operation options", http.MethodPost, "/graphql", login, false, 403, "403 forbidden\n"},
+		{"persisted query", http.MethodGet, getTarget, "", false, 200, "abc123 0"},
+		{"other operation", http.MethodPost, "/graphql", `{"operationName":"Feed"}`, false, 200, "<nil> 24"},
+		{"invalid body", http.MethodPost, "/graphql", `{`, false, 200, "<nil> 1"},
+		{"method not allowed", http.MethodPut, "/graphql", login, true, 405, "405 method not allowed\n"},
+		{"body too large", http.MethodPost, "/graphql", strings.Repeat(" ", maxGraphQLBody+1), false, 413, "413 request entity too large\n"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest(c.method, c.target, strings.NewReader(c.body))
+			r.Header.Set("Content-Type", "application/json")
+			if c.client {
+				r.Header.Set("X-Client", "web")
+			}
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, r)
+			if w.Code != c.wantStatus {
+				t.Errorf("expected status %d, got %d", c.wantStatus, w.Code)
+			}
+			if got := w.Body.String(); got != c.wantBody {
+				t.Errorf("expected %q, got %q", c.wantBody, got)
+			}
+		})
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestTreeMux_HandleGraphQL_unreadable(t *testing.T) {
+	called := false
+	tr := NewTreeMux()
+	tr.HandleGraphQL("/graphql", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}), []GraphQLOperation{{Name: "Login"}})
+
+	r := httptest.NewRequest(http.MethodPost, "/graphql", failingReader{})
+	w := httptest.NewRecorder()
+	tr.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", w.Code)
+	}
+	if called {
+		t.Errorf("expected handler not to be called")
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Group registers routes on a mux under a shared prefix, with shared route
+// options. Its methods behave like their TreeMux counterparts; the group's
+// options are applied before those given per route.
+type Group struct {
+	t       *treeMux
+	prefix  string
+	options []RouteOption
+}
+
+func (t *treeMux) Group(prefix string, options ...RouteOption) *Group {
+	return &Group{t: t, prefix: strings.TrimSuffix(normalisePattern(prefix), "/"), options: options}
+}
+
+// path returns the full path for a path in the group.
+func (g *Group) path(path string) string {
+	return g.prefix + normalisePattern(path)
+}
+
+// with returns the group's options followed by the route's options.
+func (g *Group) with(options []RouteOption) []RouteOption {
+	if len(g.options) == 0 {
+		return options
+	}
+	return append(append([]RouteOption{}, g.options...), options...)
+}
+
+// Group creates a nested group, with the prefix appended to the group's
+// prefix and the options added to the group's options.
+func (g *Group) Group(prefix string, options ...RouteOption) *Group {
+	return &Group{t: g.t, prefix: strings.TrimSuffix(g.path(prefix), "/"), options: g.with(options)}
+}
+
+func (g *Group) Handle(path string, handler http.Handler, options ...RouteOption) {
+	g.t.Handle(g.path(path), handler, g.with(options)...)
+}
+
+func (g *Group) HandleFunc(path string, handler func(http.ResponseWriter, *http.Request), options ...RouteOption) {
+	g.t.HandleFunc(g.path(path), handler, g.with(options)...)
+}
+
+func (g *Group) Any(path string, handler http.Handler, options ...RouteOption) {
+	g.t.Any(g.path(path), handler, g.with(options)...)
+}
+
+func (g *Group) HandleMethod(method, path string, handler http.Handler, options ...RouteOption) {
+	g.t.HandleMethod(method, g.path(path), handler, g.with(options)...)
+}
+
+func (g *Group) Get(path string, handler func(http.ResponseWriter, *http.Request), options ...RouteOption) {
+	g.t.Get(g.path(path), handler, g.with(options)...)
+}
+
+func (g *Group) Post(path string, handler func(http.ResponseWriter, *http.Request), options ...RouteOption) {
+	g.t.Post(g.path(path), handler, g.with(options)...)
+}
+
+func (g *Group) Put(path string, handler func(http.ResponseWriter, *http.Request), options ...RouteOption) {
+	g.t.Put(g.path(path), handler, g.with(options)...)
+}
+
+func (g *Group) Delete(path string, handler func(http.ResponseWriter, *http.Request), options ...RouteOption) {
+	g.t.Delete(g.path(path), handler, g.with(options)...)
+}
+
+func (g *Group) Patch(path string, handler func(http.ResponseWriter, *http.Request), options ...RouteOption) {
+	g.t.Patch(g.path(path), handler, g.with(options)...)
+}
+
+func (g *Group) Mount(path string, handler http.Handler, options ...RouteOption) {
+	g.t.Mount(g.path(path), handler, g.with(options)...)
+}
@@ -0,0 +1,56 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTreeMux_Group(t *testing.T) {
+	tr := NewTreeMux()
+	var version interface{}
+	handler := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			version, _ = RouteMetadata(r, "version")
+			_, _ = w.Write([]byte(body))
+		}
+	}
+	api := tr.Group("/api/")
+	v1 := api.Group("v1", WithMetadata("version", 1))
+	v1.Get("/items/*", handler("get"))
+	v1.Post("items/*", handler("post"))
+	v1.Handle("/legacy", handler("legacy"), WithMetadata("version", 0))
+	v1.Mount("/static", handler("static"))
+	api.HandleFunc("/health", handler("health"))
+
+	cases := []struct {
+		method  string
+		path    string
+		body    string
+		version interface{}
+	}{
+		{http.MethodGet, "/api/v1/items/1", "get", 1},
+		{http.MethodPost, "/api/v1/items/1", "post", 1},
+		{http.MethodGet, "/api/v1/legacy", "legacy", 0},
+		{http.MethodGet, "/api/v1/static/app.js", "static", 1},
+		{http.MethodGet, "/api/health", "health", nil},
+		{http.MethodGet, "/items/1", "404 page not found\n", nil},
+	}
+	for _, c := range cases {
+		t.Run(c.path, func(t *testing.T) {
+			version = nil
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, httptest.NewRequest(c.method, c.path, nil))
+			if w.Body.String() != c.body {
+				t.Errorf("expected %q, got %q", c.body, w.Body.String())
+			}
+			if version != c.version {
+				t.Errorf("expected version %v, got %v", c.version, version)
+			}
+		})
+	}
+}
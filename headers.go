@@ -0,0 +1,46 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+)
+
+type withResponseHeader struct {
+	key   string
+	value string
+}
+
+func (o withResponseHeader) Apply(rt *route) {
+	if rt.responseHeaders == nil {
+		rt.responseHeaders = http.Header{}
+	}
+	rt.responseHeaders.Set(o.key, o.value)
+}
+
+func (o withResponseHeader) private() {}
+
+// WithResponseHeader sets a static header on all responses of the route,
+// before anything else handles the request. Later options for the same header
+// replace earlier ones, so route options override those of a Group. Handlers
+// and middleware can still override or remove the header before writing the
+// response.
+func WithResponseHeader(key, value string) RouteOption {
+	return withResponseHeader{key, value}
+}
+
+// setResponseHeaders wraps the handler so that the headers are set on its
+// responses.
+func setResponseHeaders(h http.Handler, headers http.Header) http.Handler {
+	if len(headers) == 0 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for k, vs := range headers {
+			w.Header()[k] = append([]string(nil), vs...)
+		}
+		h.ServeHTTP(w, r)
+	})
+}
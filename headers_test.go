@@ -0,0 +1,48 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithResponseHeader(t *testing.T) {
+	tr := NewTreeMux()
+	g := tr.Group("/api", WithResponseHeader("Cache-Control", "no-store"), WithResponseHeader("X-Api", "1"))
+	g.HandleFunc("/plain", bodyHandler("plain"))
+	g.HandleFunc("/cached", bodyHandler("cached"), WithResponseHeader("Cache-Control", "max-age=60"))
+	g.HandleFunc("/handler", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "private")
+		w.Header().Del("X-Api")
+	})
+	g.HandleFunc("/limited", bodyHandler("limited"), WithGuard(func(*http.Request) bool { return false }))
+
+	cases := []struct {
+		path   string
+		status int
+		want   http.Header
+	}{
+		{"/api/plain", http.StatusOK, http.Header{"Cache-Control": {"no-store"}, "X-Api": {"1"}}},
+		{"/api/cached", http.StatusOK, http.Header{"Cache-Control": {"max-age=60"}, "X-Api": {"1"}}},
+		{"/api/handler", http.StatusOK, http.Header{"Cache-Control": {"private"}, "X-Api": nil}},
+		{"/api/limited", http.StatusForbidden, http.Header{"Cache-Control": {"no-store"}, "X-Api": {"1"}}},
+	}
+	for _, c := range cases {
+		t.Run(c.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+			if w.Code != c.status {
+				t.Errorf("expected %d, got %d", c.status, w.Code)
+			}
+			for k, vs := range c.want {
+				if got := w.Header().Values(k); len(got) != len(vs) || len(vs) > 0 && got[0] != vs[0] {
+					t.Errorf("expected %s %v, got %v", k, vs, got)
+				}
+			}
+		})
+	}
+}
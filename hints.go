@@ -0,0 +1,105 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"strings"
+)
+
+// informational reports whether the status is an informational (1xx) status
+// after which the final response still follows.
+func informational(status int) bool {
+	return status >= 100 && status < 200 && status != http.StatusSwitchingProtocols
+}
+
+// SendEarlyHints sends a 103 Early Hints response with the given Link header
+// values (i.e. "</style.css>; rel=preload; as=style"), so the client can
+// start loading resources while the response is prepared. The links are also
+// kept in the header of the final response. It reports whether the hints were
+// sent: that is not done for HTTP/1.0 clients and for routes with a time limit
+// (see WithTimeout), as those buffer the response.
+func SendEarlyHints(w http.ResponseWriter, r *http.Request, links ...string) bool {
+	if len(links) == 0 || !r.ProtoAtLeast(1, 1) {
+		return false
+	}
+	if _, ok := Deadline(r); ok {
+		return false
+	}
+	for _, l := range links {
+		w.Header().Add("Link", l)
+	}
+	w.WriteHeader(http.StatusEarlyHints)
+	return true
+}
+
+type withEarlyHints struct {
+	value []string
+}
+
+func (o withEarlyHints) Apply(rt *route) {
+	rt.earlyHints = append(rt.earlyHints, o.value...)
+}
+
+func (o withEarlyHints) private() {}
+
+// WithEarlyHints sends a 103 Early Hints response with the given links before
+// the route's handler is called, for GET and HEAD requests (see
+// SendEarlyHints). Unlike with SendEarlyHints, this also works for routes with
+// a time limit.
+func WithEarlyHints(links ...string) RouteOption {
+	return withEarlyHints{links}
+}
+
+// earlyHints wraps the handler so that the links are sent as early hints
+// before it is called.
+func earlyHints(h http.Handler, links []string) http.Handler {
+	if len(links) == 0 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			SendEarlyHints(w, r, links...)
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+type withTrailers struct {
+	value []string
+}
+
+func (o withTrailers) Apply(rt *route) {
+	rt.trailers = append(rt.trailers, o.value...)
+}
+
+func (o withTrailers) private() {}
+
+// WithTrailers declares the trailers the route's handler sets (see
+// SetTrailer), in the Trailer header of the response.
+func WithTrailers(names ...string) RouteOption {
+	return withTrailers{names}
+}
+
+// declareTrailers wraps the handler so that the trailers are declared before
+// it is called.
+func declareTrailers(h http.Handler, names []string) http.Handler {
+	if len(names) == 0 {
+		return h
+	}
+	trailer := strings.Join(names, ", ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Trailer", trailer)
+		h.ServeHTTP(w, r)
+	})
+}
+
+// SetTrailer sets a trailer, to be sent after the response body. It can be
+// called after the body was written, also for routes with a time limit.
+// Declare the trailer with WithTrailers; otherwise it is only sent when the
+// response body is chunked.
+func SetTrailer(w http.ResponseWriter, name, value string) {
+	w.Header().Set(http.TrailerPrefix+name, value)
+}
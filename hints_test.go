@@ -0,0 +1,105 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEarlyHints(t *testing.T) {
+	tr := NewTreeMux(OptionAccessLog(), OptionLogger(&syncLog{}))
+	tr.HandleFunc("/route", bodyHandler("route"), WithEarlyHints("</a.css>; rel=preload; as=style"))
+	tr.HandleFunc("/timeout", bodyHandler("timeout"), WithEarlyHints("</a.css>; rel=preload; as=style"), WithTimeout(time.Second))
+	tr.HandleFunc("/handler", func(w http.ResponseWriter, r *http.Request) {
+		sent := SendEarlyHints(w, r, "</b.js>; rel=preload; as=script")
+		_, _ = w.Write([]byte(strconv.FormatBool(sent)))
+	})
+	tr.HandleFunc("/handler-timeout", func(w http.ResponseWriter, r *http.Request) {
+		sent := SendEarlyHints(w, r, "</b.js>; rel=preload; as=script")
+		_, _ = w.Write([]byte(strconv.FormatBool(sent)))
+	}, WithTimeout(time.Second))
+	srv := httptest.NewServer(tr)
+	defer srv.Close()
+
+	cases := []struct {
+		path   string
+		method string
+		hints  []string
+		body   string
+	}{
+		{"/route", http.MethodGet, []string{"</a.css>; rel=preload; as=style"}, "route"},
+		{"/route", http.MethodPost, nil, "route"},
+		{"/timeout", http.MethodGet, []string{"</a.css>; rel=preload; as=style"}, "timeout"},
+		{"/handler", http.MethodGet, []string{"</b.js>; rel=preload; as=script"}, "true"},
+		{"/handler-timeout", http.MethodGet, nil, "false"},
+	}
+	for _, c := range cases {
+		t.Run(c.method+" "+c.path, func(t *testing.T) {
+			var mux sync.Mutex
+			var hints []string
+			trace := &httptrace.ClientTrace{
+				Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+					mux.Lock()
+					defer mux.Unlock()
+					if code == http.StatusEarlyHints {
+						hints = append(hints, header.Values("Link")...)
+					}
+					return nil
+				},
+			}
+			ctx := httptrace.WithClientTrace(context.Background(), trace)
+			r, _ := http.NewRequestWithContext(ctx, c.method, srv.URL+c.path, nil)
+			resp, err := http.DefaultClient.Do(r)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer resp.Body.Close()
+			b, _ := ioutil.ReadAll(resp.Body)
+			if resp.StatusCode != http.StatusOK || string(b) != c.body {
+				t.Errorf("expected 200 %q, got %d %q", c.body, resp.StatusCode, b)
+			}
+			mux.Lock()
+			defer mux.Unlock()
+			if len(hints) != len(c.hints) || len(hints) > 0 && hints[0] != c.hints[0] {
+				t.Errorf("expected hints %v, got %v", c.hints, hints)
+			}
+		})
+	}
+}
+
+func TestTrailers(t *testing.T) {
+	tr := NewTreeMux()
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("body"))
+		SetTrailer(w, "X-Checksum", "abc")
+	}
+	tr.HandleFunc("/declared", handler, WithTrailers("X-Checksum"))
+	tr.HandleFunc("/timeout", handler, WithTrailers("X-Checksum"), WithTimeout(time.Second))
+	srv := httptest.NewServer(tr)
+	defer srv.Close()
+
+	for _, path := range []string{"/declared", "/timeout"} {
+		t.Run(path, func(t *testing.T) {
+			resp, err := http.Get(srv.URL + path)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer resp.Body.Close()
+			_, _ = ioutil.ReadAll(resp.Body)
+			if got := resp.Trailer.Get("X-Checksum"); got != "abc" {
+				t.Errorf("expected trailer, got %q", got)
+			}
+		})
+	}
+}
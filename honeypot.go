@@ -0,0 +1,28 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+)
+
+// MetadataHoneypot is the route metadata key marking honeypot routes.
+const MetadataHoneypot = "honeypot"
+
+func (t *treeMux) Honeypot(patterns []string, action func(r *http.Request), options ...RouteOption) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if action != nil {
+			action(r)
+		}
+		t.notFound(w, r)
+	})
+	options = append([]RouteOption{
+		WithMetadata(MetadataHoneypot, true),
+		WithSitemap(SitemapEntry{Exclude: true}),
+	}, options...)
+	for _, p := range patterns {
+		t.Handle(p, h, options...)
+	}
+}
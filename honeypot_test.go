@@ -0,0 +1,43 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTreeMux_Honeypot(t *testing.T) {
+	var hits []string
+	tr := NewTreeMux()
+	tr.HandleFunc("/foo", bodyHandler("foo"))
+	tr.Honeypot([]string{"/wp-admin/**", "/.env"}, func(r *http.Request) {
+		hit, _ := RouteMetadata(r, MetadataHoneypot)
+		if hit == true {
+			hits = append(hits, r.URL.Path)
+		}
+	})
+
+	cases := []struct {
+		path string
+		want int
+	}{
+		{"/foo", http.StatusOK},
+		{"/wp-admin/install.php", http.StatusNotFound},
+		{"/.env", http.StatusNotFound},
+		{"/bar", http.StatusNotFound},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+		if w.Code != c.want {
+			t.Errorf("%s: expected %d, got %d", c.path, c.want, w.Code)
+		}
+	}
+	if len(hits) != 2 || hits[0] != "/wp-admin/install.php" || hits[1] != "/.env" {
+		t.Errorf("expected honeypot hits, got %v", hits)
+	}
+}
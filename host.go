@@ -0,0 +1,107 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// hostTable holds the routes of a host.
+type hostTable struct {
+	matcher   WildcardTrie[*endpoint]
+	endpoints map[string]*endpoint
+}
+
+// hostKey turns a host name into a trie key, with the labels in reverse
+// order (i.e. "/com/example/api" for "api.example.com"), so that hosts in the
+// same domain share a branch.
+func hostKey(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	xs := strings.Split(strings.TrimSuffix(strings.ToLower(host), "."), ".")
+	for i, j := 0, len(xs)-1; i < j; i, j = i+1, j-1 {
+		xs[i], xs[j] = xs[j], xs[i]
+	}
+	return "/" + strings.Join(xs, "/")
+}
+
+type withHost struct {
+	value string
+}
+
+func (o withHost) Apply(rt *route) {
+	rt.host = o.value
+}
+
+func (o withHost) private() {}
+
+func (t *treeMux) HandleHost(host, path string, handler http.Handler, options ...RouteOption) {
+	if host == "" || strings.ContainsAny(host, "/:") {
+		panic(fmt.Sprintf("invalid host %q", host))
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	path = t.expandFragments(path)
+	if _, _, ok := parseCatchAll(normalisePattern(path)); ok {
+		panic(fmt.Sprintf("catch-alls are not supported for hosts: %s", path))
+	}
+	pattern, params := parsePathParams(normalisePattern(path))
+	if err := checkPattern(pattern); err != nil {
+		panic(err.Error())
+	}
+	options = append([]RouteOption{withHost{host}}, options...)
+	if params != nil {
+		options = append([]RouteOption{withPathParams{params}}, options...)
+	}
+	rt := t.newRoute(pattern, handler, options)
+	t.register(t.hostEndpoint(host, pattern), rt)
+}
+
+// hostEndpoint returns the endpoint for the pattern on the host, adding it
+// when needed.
+func (t *treeMux) hostEndpoint(host, pattern string) *endpoint {
+	t.routesMux.Lock()
+	defer t.routesMux.Unlock()
+	if t.hosts == nil {
+		t.hosts = NewWildcardTrie[*hostTable]("/")
+	}
+	key := hostKey(host)
+	h, p := t.hosts.Get(key)
+	if h == nil || p != key {
+		h = &hostTable{matcher: NewWildcardTrie[*endpoint]("/"), endpoints: map[string]*endpoint{}}
+		t.hosts.Add(key, h)
+	}
+	e, ok := h.endpoints[pattern]
+	if !ok {
+		e = &endpoint{pattern: pattern, host: host}
+		h.matcher.Add(pattern, e)
+		h.endpoints[pattern] = e
+	}
+	return e
+}
+
+// matchHost returns the host route for the request, or nil if there is none,
+// and the endpoint for the path, if any. Like in match, routes that only
+// differ in method give a method not allowed response.
+func (t *treeMux) matchHost(r *http.Request, mt *MatchTrace) (*route, *endpoint) {
+	h, _ := t.hosts.Get(hostKey(r.Host))
+	if h == nil {
+		return nil, nil
+	}
+	e, _ := h.matcher.Trace(r.URL.Path, mt)
+	if e == nil {
+		return nil, nil
+	}
+	if rt := e.lookup(r); rt != nil {
+		return rt, e
+	}
+	if allow := e.allowedMethods(r); len(allow) > 0 {
+		return t.methodNotAllowed(e, allow), e
+	}
+	return nil, e
+}
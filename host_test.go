@@ -0,0 +1,90 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHostKey(t *testing.T) {
+	cases := []struct {
+		host string
+		want string
+	}{
+		{"api.example.com", "/com/example/api"},
+		{"API.Example.com.", "/com/example/api"},
+		{"api.example.com:8080", "/com/example/api"},
+		{"localhost", "/localhost"},
+	}
+	for _, c := range cases {
+		if got := hostKey(c.host); got != c.want {
+			t.Errorf("%s: expected %q, got %q", c.host, c.want, got)
+		}
+	}
+}
+
+func TestTreeMux_HandleHost(t *testing.T) {
+	tr := NewTreeMux()
+	tr.HandleFunc("/v1/*", bodyHandler("default"))
+	tr.HandleFunc("/status", bodyHandler("status"))
+	tr.HandleHost("api.example.com", "/v1/{id}", bodyHandler("api"))
+	tr.HandleHost("api.example.com", "/v1/{id}", bodyHandler("api post"), WithMethods(http.MethodPost))
+	tr.HandleHost("api.example.com", "/v2/{id}", bodyHandler("api v2"), WithMethods(http.MethodPost))
+	tr.HandleHost("*.example.com", "/v1/{id}", bodyHandler("tenant"))
+
+	cases := []struct {
+		method string
+		host   string
+		path   string
+		want   string
+	}{
+		{http.MethodGet, "api.example.com", "/v1/foo", "api"},
+		{http.MethodPost, "api.example.com", "/v1/foo", "api post"},
+		{http.MethodGet, "API.example.com:443", "/v1/foo", "api"},
+		{http.MethodGet, "api.example.com", "/status", "status"},
+		{http.MethodGet, "api.example.com", "/v2/foo", "405 method not allowed\n"},
+		{http.MethodGet, "acme.example.com", "/v1/foo", "tenant"},
+		{http.MethodGet, "example.com", "/v1/foo", "default"},
+		{http.MethodGet, "other.org", "/v1/foo", "default"},
+	}
+	for _, c := range cases {
+		t.Run(c.host+c.path, func(t *testing.T) {
+			r := httptest.NewRequest(c.method, c.path, nil)
+			r.Host = c.host
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, r)
+			if got := w.Body.String(); got != c.want {
+				t.Errorf("expected %q, got %q", c.want, got)
+			}
+		})
+	}
+
+	want := `/status
+/v1/*
+/v1/* host=*.example.com
+/v1/* host=api.example.com methods=POST
+/v1/* host=api.example.com
+/v2/* host=api.example.com methods=POST
+`
+	if got := string(tr.Snapshot()); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestTreeMux_HandleHost_invalid(t *testing.T) {
+	for _, c := range [][2]string{{"", "/foo"}, {"example.com/foo", "/bar"}, {"example.com", "/static/**"}} {
+		t.Run(strings.Join(c[:], " "), func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic")
+				}
+			}()
+			NewTreeMux().HandleHost(c[0], c[1], bodyHandler(""))
+		})
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// acmeChallengePath is the path prefix of ACME HTTP-01 challenges (RFC 8555,
+// section 8.3), which have to be served over plaintext HTTP.
+const acmeChallengePath = "/.well-known/acme-challenge/"
+
+// httpsRedirect redirects plaintext requests to HTTPS.
+type httpsRedirect struct {
+	host    string
+	trusted []*net.IPNet
+}
+
+// plaintext reports whether the request was made over plaintext HTTP. The
+// X-Forwarded-Proto header is only consulted for requests from trusted
+// proxies.
+func (h *httpsRedirect) plaintext(r *http.Request) bool {
+	if r.TLS != nil {
+		return false
+	}
+	proto := r.Header.Get("X-Forwarded-Proto")
+	if proto == "" || !h.trustedProxy(r) {
+		return true
+	}
+	proto, _, _ = strings.Cut(proto, ",")
+	return !strings.EqualFold(strings.TrimSpace(proto), "https")
+}
+
+func (h *httpsRedirect) trustedProxy(r *http.Request) bool {
+	ip := net.ParseIP(ClientIP(r))
+	if ip == nil {
+		return false
+	}
+	for _, n := range h.trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// redirect writes a redirect for plaintext requests and reports whether it
+// did so.
+func (h *httpsRedirect) redirect(w http.ResponseWriter, r *http.Request) bool {
+	if !h.plaintext(r) || strings.HasPrefix(r.URL.Path, acmeChallengePath) {
+		return false
+	}
+	host := h.host
+	if host == "" {
+		host = r.Host
+		if hostname, _, err := net.SplitHostPort(host); err == nil {
+			host = hostname
+		}
+	}
+	status := http.StatusPermanentRedirect
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		status = http.StatusMovedPermanently
+	}
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), status)
+	return true
+}
+
+type optionRedirectHTTP struct {
+	value *httpsRedirect
+}
+
+func (o optionRedirectHTTP) Apply(mux *treeMux) {
+	mux.httpsRedirect = o.value
+}
+
+func (o optionRedirectHTTP) private() {}
+
+// OptionRedirectHTTP redirects plaintext requests to HTTPS before they are
+// routed. GET and HEAD requests get a 301 response, others a 308 to preserve
+// the method and body. Requests are redirected to the given host (with an
+// optional port), or to the requested host on the default port when empty.
+//
+// Behind a proxy that terminates TLS, list the proxy addresses (or CIDR
+// ranges) as trusted to have the X-Forwarded-Proto header they set taken into
+// account. ACME HTTP-01 challenges are never redirected. It panics on an
+// invalid proxy address.
+func OptionRedirectHTTP(host string, trustedProxies ...string) Option {
+	h := &httpsRedirect{host: host}
+	for _, proxy := range trustedProxies {
+		cidr := proxy
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(fmt.Sprintf("invalid trusted proxy %q", proxy))
+		}
+		h.trusted = append(h.trusted, n)
+	}
+	return optionRedirectHTTP{h}
+}
@@ -0,0 +1,74 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOptionRedirectHTTP(t *testing.T) {
+	cases := []struct {
+		name       string
+		host       string
+		method     string
+		target     string
+		remote     string
+		proto      string
+		tls        bool
+		wantStatus int
+		wantURL    string
+	}{
+		{"get", "", http.MethodGet, "http://example.com/foo?q=1", "", "", false, 301, "https://example.com/foo?q=1"},
+		{"post", "", http.MethodPost, "http://example.com/foo", "", "", false, 308, "https://example.com/foo"},
+		{"port dropped", "", http.MethodGet, "http://example.com:8080/foo", "", "", false, 301, "https://example.com/foo"},
+		{"host", "example.org:8443", http.MethodGet, "http://example.com/foo", "", "", false, 301, "https://example.org:8443/foo"},
+		{"tls", "", http.MethodGet, "https://example.com/foo", "", "", true, 200, ""},
+		{"acme challenge", "", http.MethodGet, "http://example.com/.well-known/acme-challenge/token", "", "", false, 200, ""},
+		{"trusted proxy https", "", http.MethodGet, "http://example.com/foo", "10.0.0.1:1234", "https", false, 200, ""},
+		{"trusted proxy http", "", http.MethodGet, "http://example.com/foo", "10.0.0.1:1234", "http", false, 301, "https://example.com/foo"},
+		{"trusted proxy chain", "", http.MethodGet, "http://example.com/foo", "10.0.0.1:1234", "HTTPS, http", false, 200, ""},
+		{"trusted ip", "", http.MethodGet, "http://example.com/foo", "192.168.1.1:1234", "https", false, 200, ""},
+		{"untrusted proxy", "", http.MethodGet, "http://example.com/foo", "172.16.0.1:1234", "https", false, 301, "https://example.com/foo"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tr := NewTreeMux(OptionRedirectHTTP(c.host, "10.0.0.0/8", "192.168.1.1"))
+			tr.Any("/foo", bodyHandler("foo"))
+			tr.Mount("/.well-known/acme-challenge", bodyHandler("challenge"))
+			r := httptest.NewRequest(c.method, c.target, nil)
+			if c.remote != "" {
+				r.RemoteAddr = c.remote
+			}
+			if c.proto != "" {
+				r.Header.Set("X-Forwarded-Proto", c.proto)
+			}
+			if c.tls {
+				r.TLS = &tls.ConnectionState{}
+			} else {
+				r.TLS = nil
+			}
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, r)
+			if w.Code != c.wantStatus {
+				t.Errorf("expected status %d, got %d", c.wantStatus, w.Code)
+			}
+			if got := w.Header().Get("Location"); got != c.wantURL {
+				t.Errorf("expected %q, got %q", c.wantURL, got)
+			}
+		})
+	}
+}
+
+func TestOptionRedirectHTTP_invalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic")
+		}
+	}()
+	OptionRedirectHTTP("", "proxy.local")
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Job is a background task of a route, like a cache refresher or a poller.
+// It should return when the context is done.
+type Job func(ctx context.Context)
+
+const (
+	jobMinBackoff = time.Second
+	jobMaxBackoff = time.Minute
+)
+
+type withJob struct {
+	value Job
+}
+
+func (o withJob) Apply(rt *route) {
+	rt.jobs = append(rt.jobs, o.value)
+}
+
+func (o withJob) private() {}
+
+// WithJob runs the job in the background for as long as the route is
+// registered. The job's context is cancelled when the route is replaced or
+// the mux shuts down; Shutdown waits for the job to return. Jobs that panic
+// are restarted after a backoff.
+func WithJob(job Job) RouteOption {
+	return withJob{job}
+}
+
+// supervisor runs the background jobs of routes.
+type supervisor struct {
+	wg sync.WaitGroup
+}
+
+// start runs the route's jobs. It returns the function that stops them.
+func (s *supervisor) start(rt *route) context.CancelFunc {
+	ctx, cancel := context.WithCancel(context.Background())
+	for _, job := range rt.jobs {
+		s.wg.Add(1)
+		go s.run(ctx, rt, job)
+	}
+	return cancel
+}
+
+// run runs the job until it returns without panicking, or the context is
+// done.
+func (s *supervisor) run(ctx context.Context, rt *route, job Job) {
+	defer s.wg.Done()
+	backoff := jobMinBackoff
+	for !s.runOnce(ctx, rt, job) {
+		timer := rt.clock.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+		if backoff *= 2; backoff > jobMaxBackoff {
+			backoff = jobMaxBackoff
+		}
+	}
+}
+
+// runOnce runs the job and reports whether it returned normally.
+func (s *supervisor) runOnce(ctx context.Context, rt *route, job Job) (ok bool) {
+	defer func() {
+		if v := recover(); v != nil {
+			rt.logf(LogError, "job panicked", "pattern", rt.pattern, "panic", v)
+		}
+	}()
+	job(ctx)
+	return true
+}
+
+// wait waits for all jobs to return, or the context to be done.
+func (s *supervisor) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithJob(t *testing.T) {
+	cases := []struct {
+		name    string
+		replace bool
+	}{
+		{"shutdown", false},
+		{"replaced", true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tr := NewTreeMux()
+			started := make(chan struct{})
+			stopped := make(chan struct{})
+			tr.HandleFunc("/foo", bodyHandler("foo"), WithJob(func(ctx context.Context) {
+				close(started)
+				<-ctx.Done()
+				close(stopped)
+			}))
+			<-started
+			if c.replace {
+				tr.HandleFunc("/foo", bodyHandler("bar"))
+			} else if err := tr.Shutdown(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			select {
+			case <-stopped:
+			case <-time.After(time.Second):
+				t.Fatal("job was not stopped")
+			}
+		})
+	}
+}
+
+func TestWithJob_panic(t *testing.T) {
+	var runs int32
+	done := make(chan struct{})
+	tr := NewTreeMux(OptionLogger(&syncLog{}))
+	tr.HandleFunc("/foo", bodyHandler("foo"), WithJob(func(ctx context.Context) {
+		if atomic.AddInt32(&runs, 1) == 1 {
+			panic("boom")
+		}
+		close(done)
+	}))
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("job was not restarted")
+	}
+	if err := tr.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const maxJSONBody = 1 << 20
+
+// StatusError is an error with an HTTP status code. HandleJSON uses it to map
+// errors onto responses.
+type StatusError struct {
+	Code    int
+	Message string
+}
+
+// Errorf returns a StatusError with a formatted message.
+func Errorf(code int, format string, args ...interface{}) error {
+	return &StatusError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func (e *StatusError) Error() string {
+	return e.Message
+}
+
+// HandleJSON registers a typed JSON handler. The pattern is a path,
+// optionally preceded by a method and a space (i.e. "POST /items"). Requests
+// for the path with another method get a 405 response. The request body, if
+// any, is decoded into a Req, and the Resp returned by fn is encoded as the
+// response.
+//
+// Errors are mapped to responses centrally: a StatusError (possibly wrapped)
+// results in its status code, with its message as the problem detail (see
+// ErrorRenderer). Any other error results in a 500 response that does not
+// reveal the error, which is logged instead. Bodies that cannot be decoded
+// get a 400 response. Error responses are rendered like other errors of the
+// mux, so use OptionErrorRenderer to get RFC 7807 problems.
+func HandleJSON[Req, Resp interface{}](t TreeMux, pattern string, fn func(r *http.Request, req Req) (Resp, error), options ...RouteOption) {
+	path := pattern
+	if method, p, ok := strings.Cut(pattern, " "); ok {
+		path = strings.TrimSpace(p)
+		options = append([]RouteOption{WithMethods(method)}, options...)
+	}
+	fail := errorFunc(func(w http.ResponseWriter, _ *http.Request, status int) {
+		plainError(w, status)
+	})
+	if mux, ok := t.(*treeMux); ok {
+		fail = mux.writeError
+	}
+	t.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		var req Req
+		if r.Body != nil && r.ContentLength != 0 {
+			err := json.NewDecoder(io.LimitReader(r.Body, maxJSONBody)).Decode(&req)
+			if err != nil && err != io.EOF {
+				writeJSONError(w, r, fail, Errorf(http.StatusBadRequest, "invalid request body: %s", err))
+				return
+			}
+		}
+		resp, err := fn(r, req)
+		if err != nil {
+			writeJSONError(w, r, fail, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}, options...)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, r *http.Request, fail errorFunc, err error) {
+	var se *StatusError
+	if errors.As(err, &se) {
+		fail(w, withErrorDetail(r, se.Message), se.Code)
+		return
+	}
+	if rt := routeFromContext(r); rt != nil {
+		rt.logf(LogError, "handler failed", "pattern", rt.pattern, "error", err)
+	}
+	fail(w, r, http.StatusInternalServerError)
+}
@@ -0,0 +1,99 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testItem struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func TestHandleJSON(t *testing.T) {
+	logs := &bytes.Buffer{}
+	tr := NewTreeMux(
+		OptionLogger(StdLogger(log.New(logs, "", 0))),
+		OptionErrorRenderer("/", ProblemRenderer()),
+	)
+	HandleJSON(tr, "POST /items", func(r *http.Request, req testItem) (testItem, error) {
+		switch req.Name {
+		case "":
+			return testItem{}, Errorf(http.StatusUnprocessableEntity, "name is required")
+		case "conflict":
+			return testItem{}, fmt.Errorf("wrapped: %w", Errorf(http.StatusConflict, "exists"))
+		case "boom":
+			return testItem{}, errors.New("database down")
+		}
+		req.Count += 1
+		return req, nil
+	})
+	HandleJSON(tr, "DELETE /items", func(r *http.Request, _ struct{}) (struct{}, error) {
+		return struct{}{}, nil
+	})
+	HandleJSON(tr, "/items/count", func(r *http.Request, _ struct{}) (int, error) {
+		return 42, nil
+	})
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   int
+		resp   string
+		allow  string
+	}{
+		{"ok", http.MethodPost, "/items", `{"name": "foo", "count": 1}`, http.StatusOK, `{"name":"foo","count":2}`, ""},
+		{"status error", http.MethodPost, "/items", `{}`, http.StatusUnprocessableEntity, `{"detail":"name is required","instance":"/items","status":422,"title":"Unprocessable Entity","type":"about:blank"}`, ""},
+		{"wrapped status error", http.MethodPost, "/items", `{"name": "conflict"}`, http.StatusConflict, `{"detail":"exists","instance":"/items","status":409,"title":"Conflict","type":"about:blank"}`, ""},
+		{"internal error", http.MethodPost, "/items", `{"name": "boom"}`, http.StatusInternalServerError, `{"instance":"/items","status":500,"title":"Internal Server Error","type":"about:blank"}`, ""},
+		{"bad body", http.MethodPost, "/items", `{"name": 1}`, http.StatusBadRequest, "", ""},
+		{"wrong method", http.MethodGet, "/items", ``, http.StatusMethodNotAllowed, "", "DELETE, POST"},
+		{"no body", http.MethodGet, "/items/count", ``, http.StatusOK, `42`, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, r)
+			if w.Code != c.want {
+				t.Fatalf("expected %d, got %d", c.want, w.Code)
+			}
+			if got := strings.TrimSpace(w.Body.String()); c.resp != "" && got != c.resp {
+				t.Errorf("expected %s, got %s", c.resp, got)
+			}
+			if got := w.Header().Get("Allow"); got != c.allow {
+				t.Errorf("expected Allow %q, got %q", c.allow, got)
+			}
+		})
+	}
+	if !strings.Contains(logs.String(), "database down") {
+		t.Errorf("expected internal error to be logged, got %q", logs.String())
+	}
+}
+
+func TestHandleJSON_defaultErrors(t *testing.T) {
+	tr := NewTreeMux()
+	HandleJSON(tr, "POST /items", func(r *http.Request, _ struct{}) (struct{}, error) {
+		return struct{}{}, Errorf(http.StatusConflict, "exists")
+	})
+	w := httptest.NewRecorder()
+	tr.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/items", nil))
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected %d, got %d", http.StatusConflict, w.Code)
+	}
+	if got, want := w.Body.String(), "409 conflict\n"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,547 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// KindIngress is a networking.k8s.io/v1 Ingress.
+	KindIngress = "Ingress"
+	// KindHTTPRoute is a Gateway API gateway.networking.k8s.io/v1 HTTPRoute.
+	KindHTTPRoute = "HTTPRoute"
+)
+
+// KubernetesExport configures the export of the route table as a Kubernetes
+// resource.
+type KubernetesExport struct {
+	// Kind is the kind of resource, KindIngress or KindHTTPRoute.
+	Kind string
+	// Name is the name of the resource.
+	Name string
+	// Namespace is the namespace of the resource, if any.
+	Namespace string
+	// Host restricts the rules to a host name, if set.
+	Host string
+	// Service is the name of the backend service.
+	Service string
+	// Port is the port of the backend service.
+	Port int
+	// Gateway is the name of the parent gateway of an HTTPRoute.
+	Gateway string
+	// IngressClass is the class name of an Ingress, if set.
+	IngressClass string
+}
+
+// pathMatch is a path rule of a Kubernetes resource.
+type pathMatch struct {
+	host   string
+	prefix bool
+	value  string
+	method string
+}
+
+// pathMatches returns the path rules that cover all the mux's routes. As
+// Kubernetes resources do not support wildcards, patterns with wildcards are
+// exported as a prefix match on the part before the first wildcard. Routes
+// restricted to methods get a rule per method.
+func (t *treeMux) pathMatches() []pathMatch {
+	seen := make(map[pathMatch]bool)
+	var ms []pathMatch
+	for _, rt := range t.routes() {
+		m := pathMatch{host: rt.host, value: rt.pattern}
+		if i := strings.Index(rt.pattern, "/"+wildcard); i >= 0 {
+			m.prefix = true
+			m.value = rt.pattern[:i+1]
+			if m.value != "/" {
+				m.value = strings.TrimSuffix(m.value, "/")
+			}
+		}
+		methods := rt.methods
+		if len(methods) == 0 {
+			methods = []string{""}
+		}
+		for _, method := range methods {
+			m.method = method
+			if !seen[m] {
+				seen[m] = true
+				ms = append(ms, m)
+			}
+		}
+	}
+	// a rule for all methods makes the rules per method redundant
+	n := 0
+	for _, m := range ms {
+		all := m
+		all.method = ""
+		if m.method == "" || !seen[all] {
+			ms[n] = m
+			n += 1
+		}
+	}
+	ms = ms[:n]
+	sort.Slice(ms, func(i, j int) bool {
+		switch {
+		case ms[i].host != ms[j].host:
+			return ms[i].host < ms[j].host
+		case ms[i].value != ms[j].value:
+			return ms[i].value < ms[j].value
+		case ms[i].prefix != ms[j].prefix:
+			return !ms[i].prefix
+		}
+		return ms[i].method < ms[j].method
+	})
+	return ms
+}
+
+// byHost groups the path rules by host, in order. Rules without host come
+// first.
+func byHost(ms []pathMatch) [][]pathMatch {
+	var groups [][]pathMatch
+	for i, m := range ms {
+		if i == 0 || m.host != ms[i-1].host {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], m)
+	}
+	return groups
+}
+
+// ExportKubernetes writes the route table as a YAML Kubernetes resource to w,
+// so that edge configuration can be kept in sync with the actual routes.
+// Routes added with HandleHost get an Ingress rule for their host. Since
+// host names apply to a whole HTTPRoute, they get an HTTPRoute of their own,
+// named after the resource and the host.
+func (t *treeMux) ExportKubernetes(w io.Writer, cfg KubernetesExport) error {
+	if cfg.Name == "" || cfg.Service == "" {
+		return fmt.Errorf("name and service are required")
+	}
+	bw := bufio.NewWriter(w)
+	switch cfg.Kind {
+	case KindIngress:
+		writeIngress(bw, cfg, t.pathMatches())
+	case KindHTTPRoute:
+		for i, ms := range byHost(t.pathMatches()) {
+			c := cfg
+			if host := ms[0].host; host != "" {
+				c.Name = cfg.Name + "-" + strings.ReplaceAll(host, "*", "wildcard")
+				c.Host = host
+			}
+			if i > 0 {
+				bw.WriteString("---\n")
+			}
+			writeHTTPRoute(bw, c, ms)
+		}
+	default:
+		return fmt.Errorf("unsupported kind '%s'", cfg.Kind)
+	}
+	return bw.Flush()
+}
+
+func writeMetadata(w *bufio.Writer, cfg KubernetesExport) {
+	w.WriteString("metadata:\n")
+	fmt.Fprintf(w, "  name: %s\n", strconv.Quote(cfg.Name))
+	if cfg.Namespace != "" {
+		fmt.Fprintf(w, "  namespace: %s\n", strconv.Quote(cfg.Namespace))
+	}
+}
+
+func writeIngress(w *bufio.Writer, cfg KubernetesExport, ms []pathMatch) {
+	w.WriteString("apiVersion: networking.k8s.io/v1\n")
+	w.WriteString("kind: Ingress\n")
+	writeMetadata(w, cfg)
+	w.WriteString("spec:\n")
+	if cfg.IngressClass != "" {
+		fmt.Fprintf(w, "  ingressClassName: %s\n", strconv.Quote(cfg.IngressClass))
+	}
+	w.WriteString("  rules:\n")
+	for _, ms := range byHost(ms) {
+		host := ms[0].host
+		if host == "" {
+			host = cfg.Host
+		}
+		if host != "" {
+			fmt.Fprintf(w, "  - host: %s\n", strconv.Quote(host))
+			w.WriteString("    http:\n")
+		} else {
+			w.WriteString("  - http:\n")
+		}
+		w.WriteString("      paths:\n")
+		for i, m := range ms {
+			// Ingress does not support methods
+			if i > 0 && m.prefix == ms[i-1].prefix && m.value == ms[i-1].value {
+				continue
+			}
+			fmt.Fprintf(w, "      - path: %s\n", strconv.Quote(m.value))
+			if m.prefix {
+				w.WriteString("        pathType: Prefix\n")
+			} else {
+				w.WriteString("        pathType: Exact\n")
+			}
+			w.WriteString("        backend:\n")
+			w.WriteString("          service:\n")
+			fmt.Fprintf(w, "            name: %s\n", strconv.Quote(cfg.Service))
+			w.WriteString("            port:\n")
+			fmt.Fprintf(w, "              number: %d\n", cfg.Port)
+		}
+	}
+}
+
+func writeHTTPRoute(w *bufio.Writer, cfg KubernetesExport, ms []pathMatch) {
+	w.WriteString("apiVersion: gateway.networking.k8s.io/v1\n")
+	w.WriteString("kind: HTTPRoute\n")
+	writeMetadata(w, cfg)
+	w.WriteString("spec:\n")
+	if cfg.Gateway != "" {
+		w.WriteString("  parentRefs:\n")
+		fmt.Fprintf(w, "  - name: %s\n", strconv.Quote(cfg.Gateway))
+	}
+	if cfg.Host != "" {
+		w.WriteString("  hostnames:\n")
+		fmt.Fprintf(w, "  - %s\n", strconv.Quote(cfg.Host))
+	}
+	w.WriteString("  rules:\n")
+	for _, m := range ms {
+		w.WriteString("  - matches:\n")
+		w.WriteString("    - path:\n")
+		if m.prefix {
+			w.WriteString("        type: PathPrefix\n")
+		} else {
+			w.WriteString("        type: Exact\n")
+		}
+		fmt.Fprintf(w, "        value: %s\n", strconv.Quote(m.value))
+		if m.method != "" {
+			fmt.Fprintf(w, "      method: %s\n", m.method)
+		}
+		w.WriteString("    backendRefs:\n")
+		fmt.Fprintf(w, "    - name: %s\n", strconv.Quote(cfg.Service))
+		fmt.Fprintf(w, "      port: %d\n", cfg.Port)
+	}
+}
+
+type k8sObject struct {
+	Kind  string            `json:"kind"`
+	Spec  json.RawMessage   `json:"spec"`
+	Items []json.RawMessage `json:"items"`
+}
+
+type httpRouteSpec struct {
+	Hostnames []string        `json:"hostnames"`
+	Rules     []httpRouteRule `json:"rules"`
+}
+
+type httpRouteRule struct {
+	Matches     []httpRouteMatch `json:"matches"`
+	BackendRefs []struct {
+		Name   string `json:"name"`
+		Weight *int   `json:"weight"`
+	} `json:"backendRefs"`
+}
+
+type httpRouteMatch struct {
+	Path *struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	} `json:"path"`
+	Headers     []httpRouteValueMatch `json:"headers"`
+	QueryParams []httpRouteValueMatch `json:"queryParams"`
+	Method      string                `json:"method"`
+}
+
+type httpRouteValueMatch struct {
+	Type  string `json:"type"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type ingressSpec struct {
+	DefaultBackend *ingressBackend `json:"defaultBackend"`
+	Rules          []struct {
+		Host string `json:"host"`
+		HTTP *struct {
+			Paths []struct {
+				Path     string         `json:"path"`
+				PathType string         `json:"pathType"`
+				Backend  ingressBackend `json:"backend"`
+			} `json:"paths"`
+		} `json:"http"`
+	} `json:"rules"`
+}
+
+type ingressBackend struct {
+	Service *struct {
+		Name string `json:"name"`
+	} `json:"service"`
+}
+
+// ImportKubernetes registers routes for the Gateway API HTTPRoute and Ingress
+// resources in data, which holds a JSON encoded resource or list of
+// resources (i.e. the output of "kubectl get -o json"). Requests are
+// dispatched to the handlers named by the backend references; multiple
+// weighted backends are combined with a Split.
+//
+// Supported are exact and prefix path matches, exact and regular expression
+// header and query parameter matches, method matches and host names. Matches
+// with conditions are evaluated in the order they are defined in.
+// Exact matches on paths that end with a slash, or that have elements the mux
+// would take for wildcards or parameters (like "*" or "{id}"), are not
+// supported.
+//
+// The resources are only registered when all of them are valid. Otherwise,
+// a RegistrationErrors listing all problems is returned.
+func (t *treeMux) ImportKubernetes(data []byte, backends map[string]http.Handler) error {
+	b := &batch{t: t}
+	b.importKubernetes(data, backends)
+	return b.commit()
+}
+
+func (b *batch) importKubernetes(data []byte, backends map[string]http.Handler) {
+	var obj k8sObject
+	if err := json.Unmarshal(data, &obj); err != nil {
+		b.fail("", fmt.Errorf("could not parse resource: %w", err))
+		return
+	}
+	if obj.Items != nil {
+		for _, item := range obj.Items {
+			b.importKubernetes(item, backends)
+		}
+		return
+	}
+	switch obj.Kind {
+	case KindHTTPRoute:
+		var spec httpRouteSpec
+		if err := json.Unmarshal(obj.Spec, &spec); err != nil {
+			b.fail("", fmt.Errorf("could not parse HTTPRoute: %w", err))
+			return
+		}
+		b.importHTTPRoute(spec, backends)
+	case KindIngress:
+		var spec ingressSpec
+		if err := json.Unmarshal(obj.Spec, &spec); err != nil {
+			b.fail("", fmt.Errorf("could not parse Ingress: %w", err))
+			return
+		}
+		b.importIngress(spec, backends)
+	default:
+		b.fail("", fmt.Errorf("unsupported kind '%s'", obj.Kind))
+	}
+}
+
+func (b *batch) importHTTPRoute(spec httpRouteSpec, backends map[string]http.Handler) {
+	var options []RouteOption
+	if len(spec.Hostnames) > 0 {
+		options = append(options, WithPredicate(hostPredicate(spec.Hostnames)))
+	}
+	for _, rule := range spec.Rules {
+		matches := rule.Matches
+		if len(matches) == 0 {
+			matches = []httpRouteMatch{{}}
+		}
+		_, pattern := matches[0].path()
+
+		var vs []Variant
+		failed := false
+		for _, ref := range rule.BackendRefs {
+			h, ok := backends[ref.Name]
+			if !ok {
+				b.fail(pattern, fmt.Errorf("unknown backend '%s'", ref.Name))
+				failed = true
+				continue
+			}
+			w := 1
+			if ref.Weight != nil {
+				w = *ref.Weight
+			}
+			if w > 0 {
+				vs = append(vs, Variant{Name: ref.Name, Weight: w, Handler: h})
+			}
+		}
+		if failed {
+			continue
+		}
+		var h http.Handler
+		switch len(vs) {
+		case 0:
+			b.fail(pattern, fmt.Errorf("rule without backends"))
+			continue
+		case 1:
+			h = vs[0].Handler
+		default:
+			h = Split{Variants: vs}
+		}
+
+		for _, m := range matches {
+			if err := b.importHTTPRouteMatch(m, h, options); err != nil {
+				_, path := m.path()
+				b.fail(path, err)
+			}
+		}
+	}
+}
+
+// path returns the path match type and value, with defaults applied.
+func (m httpRouteMatch) path() (string, string) {
+	typ, path := "PathPrefix", "/"
+	if m.Path != nil {
+		if m.Path.Type != "" {
+			typ = m.Path.Type
+		}
+		if m.Path.Value != "" {
+			path = m.Path.Value
+		}
+	}
+	return typ, path
+}
+
+func (b *batch) importHTTPRouteMatch(m httpRouteMatch, h http.Handler, options []RouteOption) error {
+	options = append([]RouteOption{}, options...)
+	if m.Method != "" {
+		method := m.Method
+		options = append(options, WithPredicate(func(r *http.Request) bool {
+			return r.Method == method
+		}))
+	}
+	for _, hm := range m.Headers {
+		hm := hm
+		p, err := valuePredicate(hm, func(r *http.Request) []string {
+			return r.Header.Values(hm.Name)
+		})
+		if err != nil {
+			return err
+		}
+		options = append(options, WithPredicate(p))
+	}
+	for _, qm := range m.QueryParams {
+		qm := qm
+		p, err := valuePredicate(qm, func(r *http.Request) []string {
+			return r.URL.Query()[qm.Name]
+		})
+		if err != nil {
+			return err
+		}
+		options = append(options, WithPredicate(p))
+	}
+
+	typ, path := m.path()
+	switch typ {
+	case "Exact":
+		b.handleExact(path, h, options)
+	case "PathPrefix":
+		b.handlePrefix(path, h, options)
+	default:
+		return fmt.Errorf("unsupported path match type '%s'", typ)
+	}
+	return nil
+}
+
+// valuePredicate returns a predicate that holds when one of the values
+// satisfies the match.
+func valuePredicate(m httpRouteValueMatch, values func(r *http.Request) []string) (Predicate, error) {
+	var ok func(string) bool
+	switch m.Type {
+	case "", "Exact":
+		ok = func(v string) bool {
+			return v == m.Value
+		}
+	case "RegularExpression":
+		re, err := regexp.Compile(m.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression for '%s': %w", m.Name, err)
+		}
+		ok = re.MatchString
+	default:
+		return nil, fmt.Errorf("unsupported match type '%s'", m.Type)
+	}
+	return func(r *http.Request) bool {
+		for _, v := range values(r) {
+			if ok(v) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// hostPredicate returns a predicate that holds when the request host matches
+// one of the host names. A leading "*." matches one or more labels.
+func hostPredicate(hosts []string) Predicate {
+	return func(r *http.Request) bool {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.ToLower(host)
+		for _, h := range hosts {
+			h = strings.ToLower(h)
+			if strings.HasPrefix(h, "*.") {
+				if strings.HasSuffix(host, h[1:]) && len(host) > len(h)-1 {
+					return true
+				}
+			} else if host == h {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func (b *batch) importIngress(spec ingressSpec, backends map[string]http.Handler) {
+	backend := func(b ingressBackend) (http.Handler, error) {
+		if b.Service == nil {
+			return nil, fmt.Errorf("only service backends are supported")
+		}
+		h, ok := backends[b.Service.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown backend '%s'", b.Service.Name)
+		}
+		return h, nil
+	}
+	for _, rule := range spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		var options []RouteOption
+		if rule.Host != "" {
+			options = append(options, WithPredicate(hostPredicate([]string{rule.Host})))
+		}
+		for _, p := range rule.HTTP.Paths {
+			path := p.Path
+			if path == "" {
+				path = "/"
+			}
+			h, err := backend(p.Backend)
+			if err != nil {
+				b.fail(path, err)
+				continue
+			}
+			switch p.PathType {
+			case "Exact":
+				b.handleExact(path, h, options)
+			case "Prefix", "ImplementationSpecific":
+				b.handlePrefix(path, h, options)
+			default:
+				b.fail(path, fmt.Errorf("unsupported path type '%s'", p.PathType))
+			}
+		}
+	}
+	if spec.DefaultBackend != nil {
+		h, err := backend(*spec.DefaultBackend)
+		if err != nil {
+			b.fail("/", err)
+			return
+		}
+		b.handlePrefix("/", h, nil)
+	}
+}
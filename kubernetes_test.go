@@ -0,0 +1,450 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestExportKubernetes(t *testing.T) {
+	cases := []struct {
+		name    string
+		cfg     KubernetesExport
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "ingress",
+			cfg: KubernetesExport{
+				Kind:         KindIngress,
+				Name:         "app",
+				Namespace:    "prod",
+				Host:         "app.example.com",
+				Service:      "app",
+				Port:         8080,
+				IngressClass: "nginx",
+			},
+			want: `apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: "app"
+  namespace: "prod"
+spec:
+  ingressClassName: "nginx"
+  rules:
+  - host: "app.example.com"
+    http:
+      paths:
+      - path: "/countries"
+        pathType: Prefix
+        backend:
+          service:
+            name: "app"
+            port:
+              number: 8080
+      - path: "/foo"
+        pathType: Exact
+        backend:
+          service:
+            name: "app"
+            port:
+              number: 8080
+`,
+		},
+		{
+			name: "http route",
+			cfg: KubernetesExport{
+				Kind:    KindHTTPRoute,
+				Name:    "app",
+				Gateway: "edge",
+				Service: "app",
+				Port:    80,
+			},
+			want: `apiVersion: gateway.networking.k8s.io/v1
+kind: HTTPRoute
+metadata:
+  name: "app"
+spec:
+  parentRefs:
+  - name: "edge"
+  rules:
+  - matches:
+    - path:
+        type: PathPrefix
+        value: "/countries"
+    backendRefs:
+    - name: "app"
+      port: 80
+  - matches:
+    - path:
+        type: Exact
+        value: "/foo"
+    backendRefs:
+    - name: "app"
+      port: 80
+`,
+		},
+		{
+			name:    "unknown kind",
+			cfg:     KubernetesExport{Kind: "Service", Name: "app", Service: "app"},
+			wantErr: true,
+		},
+		{
+			name:    "missing service",
+			cfg:     KubernetesExport{Kind: KindIngress, Name: "app"},
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tr := NewTreeMux()
+			tr.HandleFunc("/foo", bodyHandler("foo"))
+			tr.HandleFunc("/countries/*", bodyHandler("country"))
+			tr.HandleFunc("/countries/*/cities/*", bodyHandler("city"))
+
+			buf := &bytes.Buffer{}
+			err := tr.ExportKubernetes(buf, c.cfg)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("expected error %v, got %v", c.wantErr, err)
+			}
+			if got := buf.String(); got != c.want {
+				t.Errorf("expected:\n%s\ngot:\n%s", c.want, got)
+			}
+		})
+	}
+}
+
+func TestImportKubernetes(t *testing.T) {
+	httpRoute := `{
+  "apiVersion": "v1",
+  "kind": "List",
+  "items": [
+    {
+      "apiVersion": "gateway.networking.k8s.io/v1",
+      "kind": "HTTPRoute",
+      "metadata": {"name": "app"},
+      "spec": {
+        "hostnames": ["*.example.com"],
+        "rules": [
+          {
+            "matches": [
+              {"path": {"type": "PathPrefix", "value": "/api"}, "headers": [{"name": "X-Version", "value": "v2"}]},
+              {"path": {"type": "PathPrefix", "value": "/api"}, "queryParams": [{"type": "RegularExpression", "name": "v", "value": "^2"}]}
+            ],
+            "backendRefs": [{"name": "v2"}]
+          },
+          {
+            "matches": [{"path": {"type": "PathPrefix", "value": "/api"}}],
+            "backendRefs": [{"name": "v1"}, {"name": "v2", "weight": 0}]
+          },
+          {
+            "matches": [{"path": {"type": "Exact", "value": "/api/status"}, "method": "GET"}],
+            "backendRefs": [{"name": "status"}]
+          }
+        ]
+      }
+    },
+    {
+      "apiVersion": "networking.k8s.io/v1",
+      "kind": "Ingress",
+      "metadata": {"name": "legacy"},
+      "spec": {
+        "rules": [
+          {"host": "legacy.test", "http": {"paths": [
+            {"path": "/old", "pathType": "Prefix", "backend": {"service": {"name": "v1"}}}
+          ]}}
+        ],
+        "defaultBackend": {"service": {"name": "status"}}
+      }
+    }
+  ]
+}`
+	backends := map[string]http.Handler{
+		"v1":     bodyHandler("v1"),
+		"v2":     bodyHandler("v2"),
+		"status": bodyHandler("status"),
+	}
+
+	cases := []struct {
+		name   string
+		method string
+		host   string
+		target string
+		header string
+		want   string
+	}{
+		{"prefix", http.MethodGet, "a.example.com", "/api/users/1", "", "v1"},
+		{"prefix root", http.MethodGet, "a.example.com", "/api", "", "v1"},
+		{"header", http.MethodGet, "a.example.com", "/api/users", "v2", "v2"},
+		{"query", http.MethodGet, "a.example.com", "/api/users?v=2.1", "", "v2"},
+		{"exact", http.MethodGet, "a.example.com", "/api/status", "", "status"},
+		{"exact wrong method", http.MethodPost, "a.example.com", "/api/status", "", "v1"},
+		{"not a prefix", http.MethodGet, "a.example.com", "/apix", "", "status"},
+		{"other host", http.MethodGet, "example.com", "/api/users", "", "status"},
+		{"ingress", http.MethodGet, "legacy.test:8080", "/old/page", "", "v1"},
+		{"default backend", http.MethodGet, "legacy.test", "/new", "", "status"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tr := NewTreeMux()
+			if err := tr.ImportKubernetes([]byte(httpRoute), backends); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			r := httptest.NewRequest(c.method, c.target, nil)
+			r.Host = c.host
+			if c.header != "" {
+				r.Header.Set("X-Version", c.header)
+			}
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, r)
+
+			if got := w.Body.String(); got != c.want {
+				t.Errorf("expected %q, got %q", c.want, got)
+			}
+		})
+	}
+}
+
+func TestExportKubernetes_methodsAndHosts(t *testing.T) {
+	tr := NewTreeMux()
+	tr.HandleFunc("/foo", bodyHandler("foo"), WithMethods(http.MethodGet, http.MethodPut))
+	tr.HandleFunc("/bar", bodyHandler("bar"), WithMethods(http.MethodGet))
+	tr.HandleFunc("/bar", bodyHandler("bar"))
+	tr.HandleHost("api.example.com", "/users/{id}", bodyHandler("user"))
+
+	buf := &bytes.Buffer{}
+	if err := tr.ExportKubernetes(buf, KubernetesExport{Kind: KindHTTPRoute, Name: "app", Service: "app", Port: 80}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `apiVersion: gateway.networking.k8s.io/v1
+kind: HTTPRoute
+metadata:
+  name: "app"
+spec:
+  rules:
+  - matches:
+    - path:
+        type: Exact
+        value: "/bar"
+    backendRefs:
+    - name: "app"
+      port: 80
+  - matches:
+    - path:
+        type: Exact
+        value: "/foo"
+      method: GET
+    backendRefs:
+    - name: "app"
+      port: 80
+  - matches:
+    - path:
+        type: Exact
+        value: "/foo"
+      method: PUT
+    backendRefs:
+    - name: "app"
+      port: 80
+---
+apiVersion: gateway.networking.k8s.io/v1
+kind: HTTPRoute
+metadata:
+  name: "app-api.example.com"
+spec:
+  hostnames:
+  - "api.example.com"
+  rules:
+  - matches:
+    - path:
+        type: PathPrefix
+        value: "/users"
+    backendRefs:
+    - name: "app"
+      port: 80
+`
+	if got := buf.String(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestExportKubernetes_roundTrip(t *testing.T) {
+	cases := []struct {
+		name   string
+		kind   string
+		method string
+		host   string
+		target string
+		want   string
+	}{
+		{"http route method", KindHTTPRoute, http.MethodGet, "example.com", "/foo", "app"},
+		{"http route other method", KindHTTPRoute, http.MethodPost, "example.com", "/foo", "404 page not found\n"},
+		{"http route wildcard", KindHTTPRoute, http.MethodGet, "example.com", "/countries/nl", "app"},
+		{"http route host", KindHTTPRoute, http.MethodGet, "api.example.com", "/users/1", "app"},
+		{"http route other host", KindHTTPRoute, http.MethodGet, "example.com", "/users/1", "404 page not found\n"},
+		{"ingress method", KindIngress, http.MethodGet, "example.com", "/foo", "app"},
+		{"ingress wildcard", KindIngress, http.MethodGet, "example.com", "/countries/nl", "app"},
+		{"ingress host", KindIngress, http.MethodGet, "api.example.com", "/users/1", "app"},
+		{"ingress other host", KindIngress, http.MethodGet, "example.com", "/users/1", "404 page not found\n"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			src := NewTreeMux()
+			src.HandleFunc("/foo", bodyHandler("foo"), WithMethods(http.MethodGet))
+			src.HandleFunc("/countries/*", bodyHandler("country"))
+			src.HandleHost("api.example.com", "/users/{id}", bodyHandler("user"))
+			buf := &bytes.Buffer{}
+			if err := src.ExportKubernetes(buf, KubernetesExport{Kind: c.kind, Name: "app", Service: "app", Port: 80}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			dst := NewTreeMux()
+			if err := dst.ImportKubernetes(yamlToJSON(t, buf.String()), map[string]http.Handler{"app": bodyHandler("app")}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			r := httptest.NewRequest(c.method, c.target, nil)
+			r.Host = c.host
+			w := httptest.NewRecorder()
+			dst.ServeHTTP(w, r)
+			if got := w.Body.String(); got != c.want {
+				t.Errorf("expected %q, got %q", c.want, got)
+			}
+		})
+	}
+}
+
+// yamlToJSON converts the YAML written by ExportKubernetes into JSON. It only
+// supports block mappings and sequences with scalar values. Multiple documents
+// are turned into a list.
+func yamlToJSON(t *testing.T, data string) []byte {
+	var items []interface{}
+	for _, doc := range strings.Split(data, "---\n") {
+		lines := strings.Split(strings.TrimSuffix(doc, "\n"), "\n")
+		v, _ := parseYAML(t, lines, 0, 0)
+		items = append(items, v)
+	}
+	bs, err := json.Marshal(map[string]interface{}{"kind": "List", "items": items})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return bs
+}
+
+// parseYAML parses the block starting at line i with the given indentation and
+// returns its value and the index of the line after it.
+func parseYAML(t *testing.T, lines []string, i, indent int) (interface{}, int) {
+	pad := strings.Repeat(" ", indent)
+	if strings.HasPrefix(lines[i], pad+"- ") {
+		var xs []interface{}
+		for i < len(lines) && strings.HasPrefix(lines[i], pad+"- ") {
+			// parse the item as a mapping, with the dash as indentation
+			lines[i] = pad + "  " + lines[i][indent+2:]
+			if !strings.Contains(lines[i], ":") {
+				xs = append(xs, yamlScalar(t, strings.TrimSpace(lines[i])))
+				i += 1
+				continue
+			}
+			var v interface{}
+			v, i = parseYAML(t, lines, i, indent+2)
+			xs = append(xs, v)
+		}
+		return xs, i
+	}
+	m := map[string]interface{}{}
+	for i < len(lines) && strings.HasPrefix(lines[i], pad) && lines[i][indent] != ' ' && lines[i][indent] != '-' {
+		key, value, _ := strings.Cut(lines[i][indent:], ":")
+		i += 1
+		if value != "" {
+			m[key] = yamlScalar(t, strings.TrimSpace(value))
+			continue
+		}
+		next := indent + 2
+		if strings.HasPrefix(lines[i], pad+"- ") {
+			next = indent
+		}
+		m[key], i = parseYAML(t, lines, i, next)
+	}
+	return m, i
+}
+
+func yamlScalar(t *testing.T, s string) interface{} {
+	if strings.HasPrefix(s, `"`) {
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			t.Fatalf("invalid string %s: %v", s, err)
+		}
+		return v
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		return n
+	}
+	return s
+}
+
+func TestImportKubernetes_Errors(t *testing.T) {
+	cases := []struct {
+		name string
+		data string
+	}{
+		{"invalid json", `{`},
+		{"unknown kind", `{"kind": "Service"}`},
+		{"unknown backend", `{"kind": "HTTPRoute", "spec": {"rules": [{"backendRefs": [{"name": "foo"}]}]}}`},
+		{"no backends", `{"kind": "HTTPRoute", "spec": {"rules": [{"backendRefs": [{"name": "v1", "weight": 0}]}]}}`},
+		{"regex path", `{"kind": "HTTPRoute", "spec": {"rules": [{"matches": [{"path": {"type": "RegularExpression", "value": "/.*"}}], "backendRefs": [{"name": "v1"}]}]}}`},
+		{"invalid regex", `{"kind": "HTTPRoute", "spec": {"rules": [{"matches": [{"headers": [{"type": "RegularExpression", "name": "a", "value": "("}]}], "backendRefs": [{"name": "v1"}]}]}}`},
+		{"ingress resource backend", `{"kind": "Ingress", "spec": {"defaultBackend": {"resource": {"kind": "Bucket"}}}}`},
+		{"exact root", `{"kind": "HTTPRoute", "spec": {"rules": [{"matches": [{"path": {"type": "Exact", "value": "/"}}], "backendRefs": [{"name": "v1"}]}]}}`},
+		{"exact trailing slash", `{"kind": "HTTPRoute", "spec": {"rules": [{"matches": [{"path": {"type": "Exact", "value": "/foo/"}}], "backendRefs": [{"name": "v1"}]}]}}`},
+		{"exact wildcard", `{"kind": "HTTPRoute", "spec": {"rules": [{"matches": [{"path": {"type": "Exact", "value": "/foo/*"}}], "backendRefs": [{"name": "v1"}]}]}}`},
+		{"exact param", `{"kind": "HTTPRoute", "spec": {"rules": [{"matches": [{"path": {"type": "Exact", "value": "/foo/{id}"}}], "backendRefs": [{"name": "v1"}]}]}}`},
+		{"ingress exact colon", `{"kind": "Ingress", "spec": {"rules": [{"http": {"paths": [{"path": "/foo/:id", "pathType": "Exact", "backend": {"service": {"name": "v1"}}}]}}]}}`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tr := NewTreeMux()
+			err := tr.ImportKubernetes([]byte(c.data), map[string]http.Handler{"v1": bodyHandler("v1")})
+			if err == nil {
+				t.Errorf("expected error")
+			}
+			if s := tr.Snapshot(); len(s) > 0 {
+				t.Errorf("expected no routes, got:\n%s", s)
+			}
+		})
+	}
+}
+
+func TestImportKubernetes_literalPaths(t *testing.T) {
+	tr := NewTreeMux()
+	err := tr.ImportKubernetes([]byte(`{"kind": "HTTPRoute", "spec": {"rules": [
+  {"matches": [{"path": {"type": "Exact", "value": "/v1/items:batch"}}], "backendRefs": [{"name": "v1"}]},
+  {"matches": [{"path": {"type": "PathPrefix", "value": "/files/*"}}], "backendRefs": [{"name": "v1"}]}
+]}}`), map[string]http.Handler{"v1": bodyHandler("v1")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cases := []struct {
+		path string
+		want int
+	}{
+		{"/v1/items:batch", 200},
+		{"/files/*/a", 200},
+		{"/files/a", 404},
+	}
+	for _, c := range cases {
+		t.Run(c.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+			if w.Code != c.want {
+				t.Errorf("expected %d, got %d", c.want, w.Code)
+			}
+		})
+	}
+}
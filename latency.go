@@ -0,0 +1,172 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets are the upper bounds, in seconds, of the latency
+// histogram buckets used when none are given.
+var DefaultLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// LatencyHistogram records a latency histogram per route. Observations are
+// annotated with the trace ID of the request as exemplar, so that operators
+// can go from a latency spike straight to example traces of the route.
+// Exemplars are only part of the OpenMetrics format (see WriteOpenMetrics),
+// which the Admin mux serves when it is accepted by the client.
+type LatencyHistogram struct {
+	buckets []float64
+	traceID func(*http.Request) string
+
+	mux    sync.Mutex
+	routes map[string]*latencyCounts
+}
+
+type latencyCounts struct {
+	// counts holds the observations per bucket, not cumulative, with an
+	// extra bucket for the rest.
+	counts    []uint64
+	sum       float64
+	exemplars []*exemplar
+}
+
+// exemplar is the last observation of a bucket with a trace ID.
+type exemplar struct {
+	traceID string
+	value   float64
+	at      time.Time
+}
+
+// NewLatencyHistogram creates a LatencyHistogram with the given bucket upper
+// bounds, or DefaultLatencyBuckets when nil. The trace ID of a request is
+// taken from the W3C "traceparent" header, unless traceID is given.
+func NewLatencyHistogram(buckets []float64, traceID func(*http.Request) string) *LatencyHistogram {
+	if buckets == nil {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	if traceID == nil {
+		traceID = traceParentID
+	}
+	return &LatencyHistogram{buckets: buckets, traceID: traceID, routes: make(map[string]*latencyCounts)}
+}
+
+// traceParentID returns the trace ID of a W3C "traceparent" header, or an
+// empty string.
+func traceParentID(r *http.Request) string {
+	xs := strings.Split(r.Header.Get("traceparent"), "-")
+	if len(xs) != 4 || len(xs[1]) != 32 || strings.Trim(xs[1], "0") == "" {
+		return ""
+	}
+	return xs[1]
+}
+
+func (h *LatencyHistogram) observe(pattern string, d time.Duration, traceID string, now time.Time) {
+	v := d.Seconds()
+	i := sort.SearchFloat64s(h.buckets, v)
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	c, ok := h.routes[pattern]
+	if !ok {
+		c = &latencyCounts{
+			counts:    make([]uint64, len(h.buckets)+1),
+			exemplars: make([]*exemplar, len(h.buckets)+1),
+		}
+		h.routes[pattern] = c
+	}
+	c.counts[i] += 1
+	c.sum += v
+	if traceID != "" {
+		c.exemplars[i] = &exemplar{traceID: traceID, value: v, at: now}
+	}
+}
+
+// WriteMetrics writes the histograms in the Prometheus text exposition
+// format, without exemplars.
+func (h *LatencyHistogram) WriteMetrics(w io.Writer) error {
+	return h.write(w, false)
+}
+
+// WriteOpenMetrics writes the histograms in the OpenMetrics text format, with
+// exemplars.
+func (h *LatencyHistogram) WriteOpenMetrics(w io.Writer) error {
+	return h.write(w, true)
+}
+
+func (h *LatencyHistogram) write(w io.Writer, exemplars bool) error {
+	const name = "treemux_request_duration_seconds"
+	if _, err := fmt.Fprintf(w, "# HELP %s Request latency per route.\n# TYPE %s histogram\n", name, name); err != nil {
+		return err
+	}
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	patterns := make([]string, 0, len(h.routes))
+	for p := range h.routes {
+		patterns = append(patterns, p)
+	}
+	sort.Strings(patterns)
+	for _, p := range patterns {
+		c := h.routes[p]
+		var total uint64
+		for i, n := range c.counts {
+			total += n
+			le := "+Inf"
+			if i < len(h.buckets) {
+				le = strconv.FormatFloat(h.buckets[i], 'g', -1, 64)
+			}
+			line := fmt.Sprintf("%s_bucket{pattern=%q,le=%q} %d", name, p, le, total)
+			if e := c.exemplars[i]; exemplars && e != nil {
+				line += fmt.Sprintf(" # {trace_id=%q} %g %.3f", e.traceID, e.value, float64(e.at.UnixNano())/1e9)
+			}
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s_sum{pattern=%q} %g\n%s_count{pattern=%q} %d\n", name, p, c.sum, name, p, total); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type optionLatencyHistogram struct {
+	value *LatencyHistogram
+}
+
+func (o optionLatencyHistogram) Apply(mux *treeMux) {
+	mux.latency = o.value
+}
+
+func (o optionLatencyHistogram) private() {}
+
+// OptionLatencyHistogram records the latency of all routes in the given
+// histogram.
+func OptionLatencyHistogram(h *LatencyHistogram) Option {
+	return optionLatencyHistogram{h}
+}
+
+// observeLatency wraps the handler so that its latency is recorded.
+func observeLatency(h http.Handler, pattern string, hist *LatencyHistogram, clock Clock) http.Handler {
+	if hist == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := clock.Now()
+		defer func() {
+			now := clock.Now()
+			hist.observe(pattern, now.Sub(start), hist.traceID(r), now)
+		}()
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,105 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTraceParentID(t *testing.T) {
+	cases := []struct {
+		header string
+		want   string
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", ""},
+		{"00-4bf92f35-00f067aa0ba902b7-01", ""},
+		{"", ""},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("traceparent", c.header)
+		if got := traceParentID(r); got != c.want {
+			t.Errorf("%q: expected %q, got %q", c.header, c.want, got)
+		}
+	}
+}
+
+func TestLatencyHistogram(t *testing.T) {
+	h := NewLatencyHistogram([]float64{1, .1}, nil)
+	h.observe("/foo", 50*time.Millisecond, "abc", time.Now())
+	h.observe("/foo", 500*time.Millisecond, "", time.Now())
+	h.observe("/foo", 2*time.Second, "def", time.Now())
+
+	buf := &bytes.Buffer{}
+	if err := h.WriteMetrics(buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP treemux_request_duration_seconds Request latency per route.
+# TYPE treemux_request_duration_seconds histogram
+treemux_request_duration_seconds_bucket{pattern="/foo",le="0.1"} 1
+treemux_request_duration_seconds_bucket{pattern="/foo",le="1"} 2
+treemux_request_duration_seconds_bucket{pattern="/foo",le="+Inf"} 3
+treemux_request_duration_seconds_sum{pattern="/foo"} 2.55
+treemux_request_duration_seconds_count{pattern="/foo"} 3
+`
+	if got := buf.String(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	buf.Reset()
+	if err := h.WriteOpenMetrics(buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		`le="0.1"} 1 # {trace_id="abc"} 0.05 `,
+		`le="1"} 2` + "\n",
+		`le="+Inf"} 3 # {trace_id="def"} 2 `,
+	} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("expected %q in:\n%s", s, buf.String())
+		}
+	}
+}
+
+func TestOptionLatencyHistogram(t *testing.T) {
+	tr := NewTreeMux(OptionLatencyHistogram(NewLatencyHistogram(nil, nil)))
+	tr.HandleFunc("/foo/*", bodyHandler("foo"))
+	r := httptest.NewRequest(http.MethodGet, "/foo/bar", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	tr.ServeHTTP(httptest.NewRecorder(), r)
+
+	admin := tr.Admin()
+	cases := []struct {
+		accept      string
+		contentType string
+		want        []string
+	}{
+		{"", "text/plain; version=0.0.4", []string{`treemux_request_duration_seconds_count{pattern="/foo/*"} 1`}},
+		{"application/openmetrics-text; version=1.0.0", "application/openmetrics-text; version=1.0.0; charset=utf-8", []string{
+			`# {trace_id="4bf92f3577b34da6a3ce929d0e0e4736"}`,
+			"# EOF\n",
+		}},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		r.Header.Set("Accept", c.accept)
+		w := httptest.NewRecorder()
+		admin.ServeHTTP(w, r)
+		if got := w.Header().Get("Content-Type"); got != c.contentType {
+			t.Errorf("expected %q, got %q", c.contentType, got)
+		}
+		for _, s := range c.want {
+			if !strings.Contains(w.Body.String(), s) {
+				t.Errorf("expected %q in:\n%s", s, w.Body.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"io"
+)
+
+// Route describes a registered route.
+type Route struct {
+	Pattern string
+	// Host is set for host routes (see HandleHost).
+	Host string
+	Name string
+	// Methods are the methods the route is restricted to, if any.
+	Methods []string
+	// Params are the names of the wildcards in the pattern, in order (see
+	// Params).
+	Params   []string
+	Metadata map[string]interface{}
+}
+
+// RouteRegisterer is implemented by handlers that want to be notified when
+// they are added to a mux, i.e. to start background work.
+//
+// Handlers that implement io.Closer, or have a Close method without return
+// value, are closed when their route is removed: when it is replaced by a new
+// registration, or when the mux is shut down. A handler used for multiple
+// routes is notified, and closed, for each of them.
+type RouteRegisterer interface {
+	Register(rt Route)
+}
+
+type closer interface {
+	Close()
+}
+
+func (rt *route) info() Route {
+	var params []string
+	for _, p := range rt.pathParams {
+		params = append(params, p.name)
+	}
+	return Route{
+		Pattern:  rt.pattern,
+		Host:     rt.host,
+		Name:     rt.name,
+		Methods:  rt.methods,
+		Params:   params,
+		Metadata: rt.metadata,
+	}
+}
+
+// register adds the route to the endpoint and notifies the handlers of the
+// new and the replaced route.
+func (t *treeMux) register(e *endpoint, rt *route) {
+	t.routesMux.Lock()
+	old := e.add(rt)
+	t.routesMux.Unlock()
+	if old != nil {
+		t.removed(old)
+	}
+	if len(rt.jobs) > 0 {
+		rt.stopJobs = t.jobs.start(rt)
+	}
+	if r, ok := rt.handler.(RouteRegisterer); ok {
+		r.Register(rt.info())
+	}
+}
+
+// removed clears the route's store and closes its handler, if it can be
+// closed.
+func (t *treeMux) removed(rt *route) {
+	if rt.stopJobs != nil {
+		rt.stopJobs()
+	}
+	rt.store.clear()
+	switch c := rt.handler.(type) {
+	case io.Closer:
+		if err := c.Close(); err != nil {
+			rt.logf(LogError, "could not close handler", "pattern", rt.pattern, "error", err)
+		}
+	case closer:
+		c.Close()
+	}
+}
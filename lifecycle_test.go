@@ -0,0 +1,69 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+type lifecycleHandler struct {
+	name   string
+	events *[]string
+	err    error
+}
+
+func (h lifecycleHandler) ServeHTTP(http.ResponseWriter, *http.Request) {}
+
+func (h lifecycleHandler) Register(rt Route) {
+	*h.events = append(*h.events, "register "+h.name+" "+rt.Pattern+" "+rt.Name)
+}
+
+func (h lifecycleHandler) Close() error {
+	*h.events = append(*h.events, "close "+h.name)
+	return h.err
+}
+
+type plainCloser struct {
+	events *[]string
+}
+
+func (h plainCloser) ServeHTTP(http.ResponseWriter, *http.Request) {}
+
+func (h plainCloser) Close() {
+	*h.events = append(*h.events, "close plain")
+}
+
+func TestTreeMux_Lifecycle(t *testing.T) {
+	var events []string
+	tr := NewTreeMux(OptionLogger(LoggerFunc(func(level LogLevel, msg string, kvs ...interface{}) {
+		events = append(events, msg)
+	})))
+
+	tr.Handle("/foo", lifecycleHandler{name: "a", events: &events}, WithName("foo"))
+	tr.Handle("/foo", lifecycleHandler{name: "b", events: &events, err: errors.New("oops")})
+	tr.Handle("/foo", lifecycleHandler{name: "c", events: &events}, WithPredicate(hasHeader("X-Foo")))
+	tr.Mount("/bar", plainCloser{events: &events})
+	if err := tr.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{
+		"register a /foo foo",
+		"close a",
+		"register b /foo ",
+		"register c /foo ",
+		"close plain",
+		"close c",
+		"close b",
+		"could not close handler",
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("expected %v, got %v", want, events)
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// LogLevel is the severity of a log entry.
+type LogLevel int
+
+const (
+	LogDebug LogLevel = iota
+	LogInfo
+	LogWarning
+	LogError
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogDebug:
+		return "DEBUG"
+	case LogInfo:
+		return "INFO"
+	case LogWarning:
+		return "WARNING"
+	case LogError:
+		return "ERROR"
+	}
+	return fmt.Sprintf("LogLevel(%d)", int(l))
+}
+
+// Logger is used by the mux for debug logging, access logging and warnings.
+// The key-value pairs alternate between string keys and arbitrary values.
+//
+// Adapters are provided for the standard library (StdLogger, SlogLogger), zap
+// (ZapLogger) and zerolog (ZerologLogger). Other libraries are easily adapted
+// with a LoggerFunc.
+type Logger interface {
+	Log(level LogLevel, msg string, keysAndValues ...interface{})
+}
+
+// LoggerFunc is an adapter to allow the use of ordinary functions as a Logger.
+type LoggerFunc func(level LogLevel, msg string, keysAndValues ...interface{})
+
+func (f LoggerFunc) Log(level LogLevel, msg string, keysAndValues ...interface{}) {
+	f(level, msg, keysAndValues...)
+}
+
+type stdLogger struct {
+	l *log.Logger
+}
+
+// StdLogger returns a Logger that writes lines like "WARNING: msg key=value"
+// to the given standard library logger. When l is nil, the package-level
+// functions of package log are used. This is the default.
+func StdLogger(l *log.Logger) Logger {
+	return stdLogger{l}
+}
+
+func (s stdLogger) Log(level LogLevel, msg string, keysAndValues ...interface{}) {
+	b := &strings.Builder{}
+	b.WriteString(level.String())
+	b.WriteString(": ")
+	b.WriteString(msg)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		b.WriteRune(' ')
+		b.WriteString(fmt.Sprint(keysAndValues[i]))
+		b.WriteRune('=')
+		if i+1 < len(keysAndValues) {
+			b.WriteString(fmt.Sprint(keysAndValues[i+1]))
+		}
+	}
+	if s.l == nil {
+		log.Print(b.String())
+		return
+	}
+	s.l.Print(b.String())
+}
+
+// SugaredLogger is the part of zap's SugaredLogger used by ZapLogger.
+type SugaredLogger interface {
+	Debugw(msg string, keysAndValues ...interface{})
+	Infow(msg string, keysAndValues ...interface{})
+	Warnw(msg string, keysAndValues ...interface{})
+	Errorw(msg string, keysAndValues ...interface{})
+}
+
+type zapLogger struct {
+	s SugaredLogger
+}
+
+// ZapLogger returns a Logger that writes to a zap SugaredLogger (or anything
+// with the same methods).
+func ZapLogger(s SugaredLogger) Logger {
+	return zapLogger{s}
+}
+
+func (z zapLogger) Log(level LogLevel, msg string, keysAndValues ...interface{}) {
+	switch level {
+	case LogDebug:
+		z.s.Debugw(msg, keysAndValues...)
+	case LogInfo:
+		z.s.Infow(msg, keysAndValues...)
+	case LogWarning:
+		z.s.Warnw(msg, keysAndValues...)
+	default:
+		z.s.Errorw(msg, keysAndValues...)
+	}
+}
+
+// ZerologEvent is the part of zerolog's *Event used by ZerologLogger.
+type ZerologEvent[E any] interface {
+	Fields(fields interface{}) E
+	Msg(msg string)
+}
+
+// Zerolog is the part of zerolog's *Logger used by ZerologLogger.
+type Zerolog[E ZerologEvent[E]] interface {
+	Debug() E
+	Info() E
+	Warn() E
+	Error() E
+}
+
+type zerologLogger[E ZerologEvent[E]] struct {
+	l Zerolog[E]
+}
+
+// ZerologLogger returns a Logger that writes to a zerolog Logger (or anything
+// with the same methods). The event type cannot be inferred, so it has to be
+// given explicitly.
+//
+//	treemux.ZerologLogger[*zerolog.Event](&logger)
+func ZerologLogger[E ZerologEvent[E]](l Zerolog[E]) Logger {
+	return zerologLogger[E]{l}
+}
+
+func (z zerologLogger[E]) Log(level LogLevel, msg string, keysAndValues ...interface{}) {
+	var e E
+	switch level {
+	case LogDebug:
+		e = z.l.Debug()
+	case LogInfo:
+		e = z.l.Info()
+	case LogWarning:
+		e = z.l.Warn()
+	default:
+		e = z.l.Error()
+	}
+	if n := len(keysAndValues); n > 0 {
+		if n%2 == 1 {
+			keysAndValues = append(keysAndValues, nil)
+		}
+		e = e.Fields(keysAndValues)
+	}
+	e.Msg(msg)
+}
+
+type optionLogger struct {
+	value Logger
+}
+
+func (o optionLogger) Apply(mux *treeMux) {
+	mux.logger = o.value
+}
+
+func (o optionLogger) private() {}
+
+// OptionLogger sets the logger used by the mux.
+func OptionLogger(l Logger) Option {
+	return optionLogger{l}
+}
+
+type withLogLevel struct {
+	value LogLevel
+}
+
+func (o withLogLevel) Apply(rt *route) {
+	rt.logLevel = &o.value
+}
+
+func (o withLogLevel) private() {}
+
+// WithLogLevel sets the minimum level of log entries about the route,
+// overriding the mux default (info, or debug with OptionDebug). For instance,
+// use LogWarning to keep a busy route out of the access log.
+func WithLogLevel(level LogLevel) RouteOption {
+	return withLogLevel{level}
+}
+
+// logFunc logs an entry if its level is high enough.
+type logFunc func(level LogLevel, msg string, keysAndValues ...interface{})
+
+// routeLogger returns the log function for entries about the route. The route
+// can be nil, for unmatched requests.
+func (t *treeMux) routeLogger(rt *route) logFunc {
+	min := LogInfo
+	if t.debug {
+		min = LogDebug
+	}
+	if rt != nil && rt.logLevel != nil {
+		min = *rt.logLevel
+	}
+	return func(level LogLevel, msg string, keysAndValues ...interface{}) {
+		if level >= min {
+			t.logger.Log(level, msg, keysAndValues...)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package treemux
+
+import (
+	"context"
+	"log/slog"
+)
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+// SlogLogger returns a Logger that writes to a structured logger from package
+// log/slog.
+func SlogLogger(l *slog.Logger) Logger {
+	return slogLogger{l}
+}
+
+func (s slogLogger) Log(level LogLevel, msg string, keysAndValues ...interface{}) {
+	var l slog.Level
+	switch level {
+	case LogDebug:
+		l = slog.LevelDebug
+	case LogInfo:
+		l = slog.LevelInfo
+	case LogWarning:
+		l = slog.LevelWarn
+	default:
+		l = slog.LevelError
+	}
+	s.l.Log(context.Background(), l, msg, keysAndValues...)
+}
@@ -0,0 +1,143 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestStdLogger(t *testing.T) {
+	cases := []struct {
+		name  string
+		level LogLevel
+		msg   string
+		kvs   []interface{}
+		want  string
+	}{
+		{"plain", LogInfo, "hello", nil, "INFO: hello\n"},
+		{"pairs", LogWarning, "hello", []interface{}{"a", 1, "b", "x"}, "WARNING: hello a=1 b=x\n"},
+		{"odd", LogError, "hello", []interface{}{"a"}, "ERROR: hello a=\n"},
+		{"unknown level", LogLevel(7), "hello", nil, "LogLevel(7): hello\n"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			StdLogger(log.New(buf, "", 0)).Log(c.level, c.msg, c.kvs...)
+			if got := buf.String(); got != c.want {
+				t.Errorf("expected %q, got %q", c.want, got)
+			}
+		})
+	}
+}
+
+type fakeSugared struct {
+	lines []string
+}
+
+func (f *fakeSugared) add(level, msg string, kvs []interface{}) {
+	f.lines = append(f.lines, fmt.Sprint(level, " ", msg, " ", kvs))
+}
+
+func (f *fakeSugared) Debugw(msg string, kvs ...interface{}) { f.add("debug", msg, kvs) }
+func (f *fakeSugared) Infow(msg string, kvs ...interface{})  { f.add("info", msg, kvs) }
+func (f *fakeSugared) Warnw(msg string, kvs ...interface{})  { f.add("warn", msg, kvs) }
+func (f *fakeSugared) Errorw(msg string, kvs ...interface{}) { f.add("error", msg, kvs) }
+
+func TestZapLogger(t *testing.T) {
+	s := &fakeSugared{}
+	l := ZapLogger(s)
+	l.Log(LogDebug, "a", "k", 1)
+	l.Log(LogInfo, "b")
+	l.Log(LogWarning, "c")
+	l.Log(LogError, "d")
+
+	want := []string{"debug a [k 1]", "info b []", "warn c []", "error d []"}
+	if !reflect.DeepEqual(s.lines, want) {
+		t.Errorf("expected %v, got %v", want, s.lines)
+	}
+}
+
+// fakeZerolog mimics zerolog, which returns a nil *Event for disabled levels.
+type fakeZerolog struct {
+	lines []string
+	min   string
+}
+
+type fakeEvent struct {
+	z      *fakeZerolog
+	level  string
+	fields interface{}
+}
+
+func (f *fakeZerolog) event(level string) *fakeEvent {
+	if level == f.min {
+		return nil
+	}
+	return &fakeEvent{z: f, level: level}
+}
+
+func (f *fakeZerolog) Debug() *fakeEvent { return f.event("debug") }
+func (f *fakeZerolog) Info() *fakeEvent  { return f.event("info") }
+func (f *fakeZerolog) Warn() *fakeEvent  { return f.event("warn") }
+func (f *fakeZerolog) Error() *fakeEvent { return f.event("error") }
+
+func (e *fakeEvent) Fields(fields interface{}) *fakeEvent {
+	if e != nil {
+		e.fields = fields
+	}
+	return e
+}
+
+func (e *fakeEvent) Msg(msg string) {
+	if e != nil {
+		e.z.lines = append(e.z.lines, fmt.Sprint(e.level, " ", msg, " ", e.fields))
+	}
+}
+
+func TestZerologLogger(t *testing.T) {
+	z := &fakeZerolog{min: "debug"}
+	l := ZerologLogger[*fakeEvent](z)
+	l.Log(LogDebug, "a", "k", 1)
+	l.Log(LogInfo, "b", "k", 1)
+	l.Log(LogWarning, "c", "odd")
+	l.Log(LogError, "d")
+
+	want := []string{"info b [k 1]", "warn c [odd <nil>]", "error d <nil>"}
+	if !reflect.DeepEqual(z.lines, want) {
+		t.Errorf("expected %v, got %v", want, z.lines)
+	}
+}
+
+// syncLog is a Logger, safe for concurrent use, that collects lines in
+// StdLogger format. When logged is not nil, every line is sent to it as well.
+type syncLog struct {
+	mux    sync.Mutex
+	lines  []string
+	logged chan string
+}
+
+func (l *syncLog) Log(level LogLevel, msg string, kvs ...interface{}) {
+	buf := &bytes.Buffer{}
+	StdLogger(log.New(buf, "", 0)).Log(level, msg, kvs...)
+	line := strings.TrimSpace(buf.String())
+	l.mux.Lock()
+	l.lines = append(l.lines, line)
+	l.mux.Unlock()
+	if l.logged != nil {
+		l.logged <- line
+	}
+}
+
+func (l *syncLog) String() string {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	return strings.Join(l.lines, "\n")
+}
@@ -0,0 +1,47 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+// Step records a matching step and reports whether the budget is exceeded, in
+// which case matching should be aborted. Matchers call it for every unit of
+// work, to support OptionMatchBudget. It is safe to call on a nil trace.
+func (mt *MatchTrace) Step() bool {
+	if mt == nil {
+		return false
+	}
+	mt.Inspected += 1
+	if mt.budget > 0 && mt.Inspected > mt.budget {
+		mt.Exceeded = true
+	}
+	return mt.Exceeded
+}
+
+// Budget returns the number of steps allowed, or zero when unlimited.
+func (mt *MatchTrace) Budget() int {
+	if mt == nil {
+		return 0
+	}
+	return mt.budget
+}
+
+type optionMatchBudget struct {
+	value int
+}
+
+func (o optionMatchBudget) Apply(mux *treeMux) {
+	mux.matchBudget = o.value
+}
+
+func (o optionMatchBudget) private() {}
+
+// OptionMatchBudget limits the number of steps the matcher may take for a
+// single request. When exceeded, matching is aborted, a warning is logged and
+// the request is not found. This protects against paths crafted to cause
+// excessive backtracking. A step is a trie node inspection for the default
+// matcher and a path byte for the DFAMatcher; custom matchers support a
+// budget by calling MatchTrace.Step.
+func OptionMatchBudget(steps int) Option {
+	return optionMatchBudget{steps}
+}
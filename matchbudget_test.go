@@ -0,0 +1,106 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// listMatcher matches static patterns by comparing them in order.
+type listMatcher struct {
+	patterns []string
+	values   []interface{}
+}
+
+func (m *listMatcher) Add(pattern string, v interface{}) {
+	m.patterns = append(m.patterns, pattern)
+	m.values = append(m.values, v)
+}
+
+func (m *listMatcher) Trace(path string, mt *MatchTrace) (interface{}, string) {
+	for i, p := range m.patterns {
+		if mt.Step() {
+			return nil, ""
+		}
+		if p == path {
+			return m.values[i], p
+		}
+	}
+	return nil, ""
+}
+
+func (m *listMatcher) Prefix(string) string {
+	return ""
+}
+
+func TestOptionMatchBudget(t *testing.T) {
+	// Each wildcard branch is explored before failing on the last element.
+	patterns := []string{
+		"/*/*/*/*/a",
+		"/*/*/*/b/a",
+		"/*/*/c/*/a",
+		"/*/d/*/*/a",
+		"/x/y/z/w/v",
+	}
+	cases := []struct {
+		name    string
+		matcher Matcher
+		budget  int
+		path    string
+		want    int
+		logged  bool
+	}{
+		{"within budget", nil, 100, "/x/y/z/w/a", http.StatusOK, false},
+		{"exceeded", nil, 5, "/x/y/z/w/a", http.StatusNotFound, true},
+		{"no budget", nil, 0, "/x/y/z/w/a", http.StatusOK, false},
+		{"miss within budget", nil, 100, "/x/y/z/w/q", http.StatusNotFound, false},
+		{"dfa within budget", NewDFAMatcher(), 20, "/x/y/z/w/a", http.StatusOK, false},
+		{"dfa exceeded", NewDFAMatcher(), 5, "/x/y/z/w/a", http.StatusNotFound, true},
+		{"custom within budget", &listMatcher{}, 5, "/x/y/z/w/v", http.StatusOK, false},
+		{"custom exceeded", &listMatcher{}, 4, "/x/y/z/w/v", http.StatusNotFound, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			options := []Option{OptionMatchBudget(c.budget), OptionLogger(StdLogger(log.New(buf, "", 0)))}
+			if c.matcher != nil {
+				options = append(options, OptionMatcher(c.matcher))
+			}
+			tr := NewTreeMux(options...)
+			for _, p := range patterns {
+				tr.HandleFunc(p, bodyHandler(p))
+			}
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+			if w.Code != c.want {
+				t.Errorf("expected %d, got %d", c.want, w.Code)
+			}
+			if got := strings.Contains(buf.String(), "match budget exceeded"); got != c.logged {
+				t.Errorf("expected logged %v, got %q", c.logged, buf.String())
+			}
+		})
+	}
+}
+
+func TestMatchTrace_Budget(t *testing.T) {
+	var mt *MatchTrace
+	if mt.Budget() != 0 || mt.Step() {
+		t.Errorf("expected nil trace to be unlimited")
+	}
+	mt = &MatchTrace{budget: 2}
+	for i, want := range []bool{false, false, true} {
+		if got := mt.Step(); got != want {
+			t.Errorf("step %d: expected %v, got %v", i, want, got)
+		}
+	}
+	if mt.Budget() != 2 || mt.Inspected != 3 {
+		t.Errorf("expected budget 2 and 3 steps, got %d and %d", mt.Budget(), mt.Inspected)
+	}
+}
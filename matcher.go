@@ -0,0 +1,41 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+// Matcher finds the value registered for the pattern matching a path. It is
+// the matching engine of the mux, which is a wildcard trie by default (see
+// OptionMatcher). A Matcher does not need to be safe for concurrent use
+// while patterns are added.
+type Matcher interface {
+	// Add registers the value for the pattern, replacing the previous value
+	// for the same pattern.
+	Add(pattern string, v interface{})
+	// Trace returns the value and the pattern matching the path, or nil and
+	// an empty string. The work done is recorded in mt, which can be nil;
+	// matching should be aborted when MatchTrace.Step reports the budget is
+	// exceeded.
+	Trace(path string, mt *MatchTrace) (interface{}, string)
+	// Prefix returns the pattern of the longest registered prefix of the
+	// path, used for statistics on unmatched requests (see NotFoundStats).
+	// Matchers that do not track prefixes can return an empty string.
+	Prefix(path string) string
+}
+
+type optionMatcher struct {
+	value Matcher
+}
+
+func (o optionMatcher) Apply(mux *treeMux) {
+	mux.matcher = o.value
+}
+
+func (o optionMatcher) private() {}
+
+// OptionMatcher replaces the default wildcard trie with another matching
+// engine. Patterns passed to the matcher have a leading slash. Route options,
+// predicates and all other mux features work as before.
+func OptionMatcher(m Matcher) Option {
+	return optionMatcher{m}
+}
@@ -0,0 +1,67 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// caseInsensitiveMatcher matches static patterns, ignoring case.
+type caseInsensitiveMatcher struct {
+	values   map[string]interface{}
+	patterns map[string]string
+}
+
+func (m *caseInsensitiveMatcher) Add(pattern string, v interface{}) {
+	m.values[strings.ToLower(pattern)] = v
+	m.patterns[strings.ToLower(pattern)] = pattern
+}
+
+func (m *caseInsensitiveMatcher) Trace(path string, mt *MatchTrace) (interface{}, string) {
+	if mt.Step() {
+		return nil, ""
+	}
+	k := strings.ToLower(path)
+	return m.values[k], m.patterns[k]
+}
+
+func (m *caseInsensitiveMatcher) Prefix(string) string {
+	return ""
+}
+
+func TestOptionMatcher(t *testing.T) {
+	m := &caseInsensitiveMatcher{values: map[string]interface{}{}, patterns: map[string]string{}}
+	tr := NewTreeMux(OptionMatcher(m))
+	tr.HandleFunc("/Foo/Bar", bodyHandler("foo"))
+	tr.HandleFunc("/Foo/Bar", bodyHandler("header"), WithPredicate(hasHeader("X-Foo")))
+
+	cases := []struct {
+		name   string
+		path   string
+		header bool
+		want   string
+	}{
+		{"exact", "/Foo/Bar", false, "foo"},
+		{"other case", "/FOO/bar", false, "foo"},
+		{"predicate", "/foo/BAR", true, "header"},
+		{"wildcard not supported", "/foo/*", false, "404 page not found\n"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, c.path, nil)
+			if c.header {
+				r.Header.Set("X-Foo", "1")
+			}
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, r)
+			if got := w.Body.String(); got != c.want {
+				t.Errorf("expected %q, got %q", c.want, got)
+			}
+		})
+	}
+}
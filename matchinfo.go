@@ -0,0 +1,84 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"context"
+	"net/http"
+)
+
+// MissKind tells why a request did not match a route.
+type MissKind int
+
+const (
+	// MissNone is used for requests that matched a route.
+	MissNone MissKind = iota
+	// MissPath is used when no pattern matches the path.
+	MissPath
+	// MissMethod is used when the routes for the path only differ in method
+	// from the request (see WithMethods).
+	MissMethod
+	// MissPredicate is used when a pattern matches the path, but the
+	// predicates of its routes rejected the request (see WithPredicate).
+	MissPredicate
+	// MissBudget is used when matching was given up because it took too
+	// many steps (see OptionMatchBudget).
+	MissBudget
+)
+
+func (k MissKind) String() string {
+	switch k {
+	case MissNone:
+		return "none"
+	case MissPath:
+		return "path unknown"
+	case MissMethod:
+		return "method mismatch"
+	case MissPredicate:
+		return "predicate rejected"
+	case MissBudget:
+		return "match budget exceeded"
+	}
+	return "unknown"
+}
+
+// MatchInfo describes how a request was matched.
+type MatchInfo struct {
+	// Pattern is the pattern of the matched route or, for misses, of the
+	// routes that applied to the path, if any.
+	Pattern string
+	Miss    MissKind
+}
+
+// RequestMatchInfo returns how the request was matched. It is available to
+// route handlers, the not found (see OptionNotFound), method not allowed and
+// fallthrough handlers and the NotFound hook of RouterTrace:
+//
+//	mux := treemux.NewTreeMux(treemux.OptionNotFound(func(w http.ResponseWriter, r *http.Request) {
+//		if treemux.RequestMatchInfo(r).Miss == treemux.MissPredicate {
+//			...
+//		}
+//	}))
+func RequestMatchInfo(r *http.Request) MatchInfo {
+	if info, ok := r.Context().Value(matchInfoKey).(MatchInfo); ok {
+		return info
+	}
+	if rt := routeFromContext(r); rt != nil {
+		return MatchInfo{Pattern: rt.pattern}
+	}
+	return MatchInfo{}
+}
+
+func withMatchInfo(r *http.Request, info MatchInfo) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), matchInfoKey, info))
+}
+
+// withMiss wraps the handler so that the match info is available from the
+// request context.
+func withMiss(h http.Handler, info MatchInfo) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, withMatchInfo(r, info))
+	})
+}
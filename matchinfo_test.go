@@ -0,0 +1,108 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestMatchInfo(t *testing.T) {
+	var got MatchInfo
+	record := func(w http.ResponseWriter, r *http.Request) {
+		got = RequestMatchInfo(r)
+	}
+	tr := NewTreeMux(OptionNotFound(record), OptionMethodNotAllowed(record))
+	tr.HandleFunc("/foo/*", record)
+	tr.HandleFunc("/bar", record, WithMethods(http.MethodPost))
+	tr.HandleFunc("/baz", record, WithPredicate(hasHeader("X-Baz")))
+	tr.Mount("/static", http.HandlerFunc(record), WithPredicate(hasHeader("X-Static")))
+
+	cases := []struct {
+		method string
+		path   string
+		want   MatchInfo
+	}{
+		{http.MethodGet, "/foo/x", MatchInfo{Pattern: "/foo/*"}},
+		{http.MethodGet, "/qux", MatchInfo{Miss: MissPath}},
+		{http.MethodGet, "/bar", MatchInfo{Pattern: "/bar", Miss: MissMethod}},
+		{http.MethodGet, "/baz", MatchInfo{Pattern: "/baz", Miss: MissPredicate}},
+		{http.MethodGet, "/static/x.js", MatchInfo{Miss: MissPredicate}},
+	}
+	for _, c := range cases {
+		t.Run(c.path, func(t *testing.T) {
+			got = MatchInfo{Miss: -1}
+			tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(c.method, c.path, nil))
+			if got != c.want {
+				t.Errorf("expected %+v, got %+v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestRequestMatchInfo_trace(t *testing.T) {
+	var got MissKind
+	tr := NewTreeMux(OptionRouterTrace(&RouterTrace{
+		NotFound: func(r *http.Request) {
+			got = RequestMatchInfo(r).Miss
+		},
+	}))
+	tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/foo", nil))
+	if got != MissPath {
+		t.Errorf("expected %s, got %s", MissPath, got)
+	}
+}
+
+type countingMatcher struct {
+	Matcher
+	traces int
+}
+
+func (m *countingMatcher) Trace(path string, mt *MatchTrace) (interface{}, string) {
+	m.traces += 1
+	return m.Matcher.Trace(path, mt)
+}
+
+func TestRequestMatchInfo_shortCuts(t *testing.T) {
+	cases := []struct {
+		name       string
+		option     Option
+		path       string
+		want       MissKind
+		wantTraces int
+	}{
+		{"plain", nil, "/qux", MissPath, 2},
+		{"fast miss", OptionFastMiss(), "/qux", MissPath, 0},
+		{"not found cache", OptionNotFoundCache(16), "/qux", MissPath, 1},
+		{"match budget", OptionMatchBudget(2), "/a/b/c/d/e/f", MissBudget, 2},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var got MissKind
+			m := &countingMatcher{Matcher: newWildcardTrie("/")}
+			options := []Option{OptionMatcher(m), OptionNotFound(func(w http.ResponseWriter, r *http.Request) {
+				got = RequestMatchInfo(r).Miss
+			})}
+			if c.option != nil {
+				options = append(options, c.option)
+			}
+			tr := NewTreeMux(options...)
+			tr.HandleFunc("/a/b/c/d/e/*", bodyHandler("deep"))
+			tr.HandleFunc("/foo", bodyHandler("foo"))
+
+			for i := 0; i < 2; i += 1 {
+				got = -1
+				tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, c.path, nil))
+				if got != c.want {
+					t.Errorf("expected %s, got %s", c.want, got)
+				}
+			}
+			if m.traces != c.wantTraces {
+				t.Errorf("expected %d matcher traces, got %d", c.wantTraces, m.traces)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// stripMatrixParams returns the request with the matrix parameters removed
+// from its path, and made available through MatrixParams. Requests without
+// matrix parameters are returned as is.
+func stripMatrixParams(r *http.Request) *http.Request {
+	raw := r.URL.EscapedPath()
+	if !strings.Contains(raw, ";") {
+		return r
+	}
+	xs := strings.Split(raw, "/")
+	ps := make([]string, len(xs))
+	params := make([]url.Values, len(xs)-1)
+	for i, x := range xs {
+		if j := strings.IndexByte(x, ';'); j >= 0 {
+			if i > 0 {
+				params[i-1] = parseMatrixParams(x[j+1:])
+			}
+			x = x[:j]
+			xs[i] = x
+		}
+		p, err := url.PathUnescape(x)
+		if err != nil {
+			return r
+		}
+		ps[i] = p
+	}
+	u := *r.URL
+	u.Path = strings.Join(ps, "/")
+	u.RawPath = strings.Join(xs, "/")
+	r2 := r.WithContext(context.WithValue(r.Context(), matrixKey, params))
+	r2.URL = &u
+	return r2
+}
+
+func parseMatrixParams(s string) url.Values {
+	vs := url.Values{}
+	for _, kv := range strings.Split(s, ";") {
+		if kv == "" {
+			continue
+		}
+		k, v := kv, ""
+		if i := strings.IndexByte(kv, '='); i >= 0 {
+			k, v = kv[:i], kv[i+1:]
+		}
+		if x, err := url.PathUnescape(k); err == nil {
+			k = x
+		}
+		if x, err := url.PathUnescape(v); err == nil {
+			v = x
+		}
+		vs.Add(k, v)
+	}
+	return vs
+}
+
+// MatrixParams returns the matrix parameters that were stripped from the
+// request path (see OptionMatrixParams), with one entry per path element. For
+// "/cars;color=red/engine;hp=300" it returns the values color=red and hp=300.
+// Elements without parameters have a nil entry. It returns nil if the path
+// had no matrix parameters.
+func MatrixParams(r *http.Request) []url.Values {
+	params, _ := r.Context().Value(matrixKey).([]url.Values)
+	return params
+}
+
+type optionMatrixParams struct{}
+
+func (o optionMatrixParams) Apply(mux *treeMux) {
+	mux.matrixParams = true
+}
+
+func (o optionMatrixParams) private() {}
+
+// OptionMatrixParams makes the mux strip matrix parameters (like in
+// "/resource;version=2/sub") from the path elements before matching. Handlers
+// get the request with the stripped path and can retrieve the parameters with
+// MatrixParams.
+func OptionMatrixParams() Option {
+	return optionMatrixParams{}
+}
@@ -0,0 +1,76 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestOptionMatrixParams(t *testing.T) {
+	var gotPath string
+	var gotParams []url.Values
+	tr := NewTreeMux(OptionMatrixParams())
+	tr.HandleFunc("/cars/*/engine", func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotParams = MatrixParams(r)
+	})
+
+	cases := []struct {
+		name   string
+		path   string
+		want   int
+		params []url.Values
+	}{
+		{"none", "/cars/volvo/engine", http.StatusOK, nil},
+		{
+			"matrix",
+			"/cars;year=2020;year=2021/volvo;color=red;new/engine",
+			http.StatusOK,
+			[]url.Values{{"year": {"2020", "2021"}}, {"color": {"red"}, "new": {""}}, nil},
+		},
+		{
+			"escaped",
+			"/cars/v%3Bolvo;c%3Dolor=r%20ed/engine",
+			http.StatusOK,
+			[]url.Values{nil, {"c=olor": {"r ed"}}, nil},
+		},
+		{"no match", "/cars;x=1/volvo/wheels", http.StatusNotFound, nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			gotPath, gotParams = "", nil
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+			if w.Code != c.want {
+				t.Fatalf("expected %d, got %d", c.want, w.Code)
+			}
+			if c.want != http.StatusOK {
+				return
+			}
+			if gotPath != "/cars/volvo/engine" && gotPath != "/cars/v;olvo/engine" {
+				t.Errorf("expected stripped path, got %q", gotPath)
+			}
+			if !reflect.DeepEqual(gotParams, c.params) {
+				t.Errorf("expected %v, got %v", c.params, gotParams)
+			}
+		})
+	}
+}
+
+func TestOptionMatrixParams_disabled(t *testing.T) {
+	tr := NewTreeMux()
+	tr.HandleFunc("/cars/*", bodyHandler("car"))
+	r := httptest.NewRequest(http.MethodGet, "/cars/volvo;color=red", nil)
+	if _, p := tr.Handler(r); p != "/cars/*" {
+		t.Errorf("expected element to match as a whole, got %q", p)
+	}
+	if _, p := tr.Handler(httptest.NewRequest(http.MethodGet, "/cars;x=1/volvo", nil)); p != "" {
+		t.Errorf("expected no match, got %q", p)
+	}
+}
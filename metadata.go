@@ -0,0 +1,40 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+)
+
+type withMetadata struct {
+	key   string
+	value interface{}
+}
+
+func (o withMetadata) Apply(rt *route) {
+	if rt.metadata == nil {
+		rt.metadata = make(map[string]interface{})
+	}
+	rt.metadata[o.key] = o.value
+}
+
+func (o withMetadata) private() {}
+
+// WithMetadata attaches a key-value pair to the route. Metadata has no effect
+// on routing; it is available to handlers, error renderers and tooling.
+func WithMetadata(key string, value interface{}) RouteOption {
+	return withMetadata{key, value}
+}
+
+// RouteMetadata returns the metadata value of the route the request was
+// matched to. The boolean is false when there is no such value.
+func RouteMetadata(r *http.Request, key string) (interface{}, bool) {
+	rt := routeFromContext(r)
+	if rt == nil {
+		return nil, false
+	}
+	v, ok := rt.metadata[key]
+	return v, ok
+}
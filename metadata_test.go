@@ -0,0 +1,31 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouteMetadata(t *testing.T) {
+	var got interface{}
+	var ok bool
+	h := func(w http.ResponseWriter, r *http.Request) {
+		got, ok = RouteMetadata(r, "owner")
+	}
+	tr := NewTreeMux()
+	tr.HandleFunc("/with", h, WithMetadata("owner", "team-a"), WithMetadata("tier", 1))
+	tr.HandleFunc("/without", h)
+
+	tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/with", nil))
+	if !ok || got != "team-a" {
+		t.Errorf("expected team-a, got %v (%v)", got, ok)
+	}
+	tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/without", nil))
+	if ok {
+		t.Errorf("expected no metadata, got %v", got)
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+type withMethods struct {
+	value []string
+}
+
+func (o withMethods) Apply(rt *route) {
+	rt.methods = append(rt.methods, o.value...)
+}
+
+func (o withMethods) private() {}
+
+// WithMethods restricts the route to requests with one of the methods.
+// Requests for the path with another method are passed on to other routes
+// registered for the same pattern, or get a 405 response listing the allowed
+// methods if there are none. Any method token is accepted, so extension
+// methods like WebDAV's PROPFIND can be routed too. It panics when a method is
+// not a valid token.
+func WithMethods(methods ...string) RouteOption {
+	for _, m := range methods {
+		if !validMethod(m) {
+			panic(fmt.Sprintf("invalid method %q", m))
+		}
+	}
+	return withMethods{methods}
+}
+
+// validMethod reports whether the method is a token (RFC 7230, section 3.2.6).
+func validMethod(m string) bool {
+	if m == "" {
+		return false
+	}
+	for i := 0; i < len(m); i++ {
+		c := m[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func (t *treeMux) Any(path string, handler http.Handler, options ...RouteOption) {
+	t.Handle(path, handler, options...)
+}
+
+func (t *treeMux) HandleMethod(method, path string, handler http.Handler, options ...RouteOption) {
+	t.Handle(path, handler, append([]RouteOption{WithMethods(method)}, options...)...)
+}
+
+func (t *treeMux) Get(path string, handler func(http.ResponseWriter, *http.Request), options ...RouteOption) {
+	t.HandleMethod(http.MethodGet, path, http.HandlerFunc(handler), options...)
+}
+
+func (t *treeMux) Post(path string, handler func(http.ResponseWriter, *http.Request), options ...RouteOption) {
+	t.HandleMethod(http.MethodPost, path, http.HandlerFunc(handler), options...)
+}
+
+func (t *treeMux) Put(path string, handler func(http.ResponseWriter, *http.Request), options ...RouteOption) {
+	t.HandleMethod(http.MethodPut, path, http.HandlerFunc(handler), options...)
+}
+
+func (t *treeMux) Delete(path string, handler func(http.ResponseWriter, *http.Request), options ...RouteOption) {
+	t.HandleMethod(http.MethodDelete, path, http.HandlerFunc(handler), options...)
+}
+
+func (t *treeMux) Patch(path string, handler func(http.ResponseWriter, *http.Request), options ...RouteOption) {
+	t.HandleMethod(http.MethodPatch, path, http.HandlerFunc(handler), options...)
+}
+
+// allowsMethod reports whether the route accepts requests with the method.
+func (rt *route) allowsMethod(method string) bool {
+	if len(rt.methods) == 0 {
+		return true
+	}
+	for _, m := range rt.methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedMethods returns the methods accepted by the endpoint's routes, when
+// the request was only rejected because of its method. Otherwise, it returns
+// nil.
+func (e *endpoint) allowedMethods(r *http.Request) []string {
+	seen := map[string]bool{}
+	var allow []string
+	for _, rt := range e.routes {
+		if rt.allowsMethod(r.Method) {
+			return nil
+		}
+		for _, m := range rt.methods {
+			if !seen[m] {
+				seen[m] = true
+				allow = append(allow, m)
+			}
+		}
+	}
+	sort.Strings(allow)
+	return allow
+}
+
+// methodNotAllowed returns a route for requests to the endpoint with a method
+// it does not accept. It sets the Allow header and calls the mux's handler for
+// these requests, which responds with a 405 by default.
+func (t *treeMux) methodNotAllowed(e *endpoint, allow []string) *route {
+	header := strings.Join(allow, ", ")
+	rt := &route{pattern: e.pattern, logf: t.logf, clock: t.clock}
+	rt.serve = withRoute(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", header)
+		t.notAllowed(w, withMatchInfo(r, MatchInfo{Pattern: e.pattern, Miss: MissMethod}))
+	}), rt)
+	return rt
+}
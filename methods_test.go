@@ -0,0 +1,173 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMethodNotAllowed(t *testing.T) {
+	tr := NewTreeMux()
+	tr.HandleFunc("/items", bodyHandler("get"), WithMethods(http.MethodGet))
+	tr.HandleFunc("/items", bodyHandler("post"), WithMethods(http.MethodPost, http.MethodPut))
+	tr.HandleFunc("/header", bodyHandler("header"), WithMethods(http.MethodGet), WithPredicate(hasHeader("X-Foo")))
+	tr.HandleFunc("/any", bodyHandler("get"), WithMethods(http.MethodGet))
+	tr.HandleFunc("/any", bodyHandler("any"))
+	tr.HandleFunc("/mounted/foo", bodyHandler("get"), WithMethods(http.MethodGet))
+	tr.Mount("/mounted", bodyHandler("mount"))
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		want   int
+		body   string
+		allow  string
+	}{
+		{"get", http.MethodGet, "/items", http.StatusOK, "get", ""},
+		{"put", http.MethodPut, "/items", http.StatusOK, "post", ""},
+		{"not allowed", http.MethodDelete, "/items", http.StatusMethodNotAllowed, "405 method not allowed\n", "GET, POST, PUT"},
+		{"predicate fails", http.MethodGet, "/header", http.StatusNotFound, "404 page not found\n", ""},
+		{"unconditional route", http.MethodDelete, "/any", http.StatusOK, "any", ""},
+		{"mount", http.MethodPost, "/mounted/foo", http.StatusOK, "mount", ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, httptest.NewRequest(c.method, c.path, nil))
+			if w.Code != c.want || w.Body.String() != c.body {
+				t.Errorf("expected %d %q, got %d %q", c.want, c.body, w.Code, w.Body.String())
+			}
+			if got := w.Header().Get("Allow"); got != c.allow {
+				t.Errorf("expected Allow %q, got %q", c.allow, got)
+			}
+		})
+	}
+}
+
+func TestOptionMethodNotAllowed(t *testing.T) {
+	tr := NewTreeMux(OptionMethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotImplemented)
+		_, _ = w.Write([]byte("allowed: " + w.Header().Get("Allow")))
+	}))
+	tr.HandleMethod(http.MethodGet, "/items", bodyHandler("get"))
+	tr.HandleMethod(http.MethodPost, "/items", bodyHandler("post"))
+
+	w := httptest.NewRecorder()
+	tr.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/items", nil))
+	if want := "allowed: GET, POST"; w.Code != http.StatusNotImplemented || w.Body.String() != want {
+		t.Errorf("expected %d %q, got %d %q", http.StatusNotImplemented, want, w.Code, w.Body.String())
+	}
+}
+
+func TestTreeMux_Any(t *testing.T) {
+	tr := NewTreeMux()
+	tr.HandleFunc("/dav/*", bodyHandler("propfind"), WithMethods("PROPFIND"))
+	tr.HandleFunc("/dav/*", bodyHandler("mkcol"), WithMethods("MKCOL"))
+	tr.Any("/dav/*", bodyHandler("any"))
+	tr.HandleFunc("/cal/*", bodyHandler("report"), WithMethods("REPORT", "PROPFIND"))
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		want   int
+		body   string
+		allow  string
+	}{
+		{"custom verb", "PROPFIND", "/dav/x", http.StatusOK, "propfind", ""},
+		{"other custom verb", "MKCOL", "/dav/x", http.StatusOK, "mkcol", ""},
+		{"any", "LOCK", "/dav/x", http.StatusOK, "any", ""},
+		{"any standard", http.MethodGet, "/dav/x", http.StatusOK, "any", ""},
+		{"custom verbs only", "REPORT", "/cal/x", http.StatusOK, "report", ""},
+		{"custom verbs not allowed", http.MethodGet, "/cal/x", http.StatusMethodNotAllowed, "405 method not allowed\n", "PROPFIND, REPORT"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, httptest.NewRequest(c.method, c.path, nil))
+			if w.Code != c.want || w.Body.String() != c.body {
+				t.Errorf("expected %d %q, got %d %q", c.want, c.body, w.Code, w.Body.String())
+			}
+			if got := w.Header().Get("Allow"); got != c.allow {
+				t.Errorf("expected Allow %q, got %q", c.allow, got)
+			}
+		})
+	}
+}
+
+func TestTreeMux_HandleMethod(t *testing.T) {
+	tr := NewTreeMux()
+	tr.HandleMethod(http.MethodGet, "/foo/*", bodyHandler("header"), WithPredicate(hasHeader("X-Foo")))
+	tr.HandleMethod(http.MethodGet, "/foo/*", bodyHandler("get"))
+	tr.HandleMethod(http.MethodPut, "/foo/*", bodyHandler("put"))
+
+	cases := []struct {
+		name   string
+		method string
+		header bool
+		want   int
+		body   string
+	}{
+		{"get", http.MethodGet, false, http.StatusOK, "get"},
+		{"put", http.MethodPut, false, http.StatusOK, "put"},
+		{"with predicate", http.MethodGet, true, http.StatusOK, "header"},
+		{"predicate other method", http.MethodPut, true, http.StatusOK, "put"},
+		{"not allowed", http.MethodDelete, false, http.StatusMethodNotAllowed, "405 method not allowed\n"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest(c.method, "/foo/bar", nil)
+			if c.header {
+				r.Header.Set("X-Foo", "1")
+			}
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, r)
+			if w.Code != c.want || w.Body.String() != c.body {
+				t.Errorf("expected %d %q, got %d %q", c.want, c.body, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestTreeMux_verbs(t *testing.T) {
+	tr := NewTreeMux()
+	tr.Get("/items/*", bodyHandler("get"))
+	tr.Post("/items/*", bodyHandler("post"))
+	tr.Put("/items/*", bodyHandler("put"))
+	tr.Delete("/items/*", bodyHandler("delete"))
+	tr.Patch("/items/*", bodyHandler("patch"))
+
+	for _, m := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodPatch} {
+		t.Run(m, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, httptest.NewRequest(m, "/items/1", nil))
+			if want := strings.ToLower(m); w.Body.String() != want {
+				t.Errorf("expected %q, got %q", want, w.Body.String())
+			}
+		})
+	}
+	w := httptest.NewRecorder()
+	tr.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, "/items/1", nil))
+	if want := "DELETE, GET, PATCH, POST, PUT"; w.Header().Get("Allow") != want {
+		t.Errorf("expected Allow %q, got %q", want, w.Header().Get("Allow"))
+	}
+}
+
+func TestWithMethods_invalid(t *testing.T) {
+	for _, m := range []string{"", "GET POST", "GET,POST", "Ü"} {
+		t.Run(m, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic")
+				}
+			}()
+			WithMethods(m)
+		})
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+)
+
+func (t *treeMux) Use(middleware ...func(http.Handler) http.Handler) {
+	t.middleware = append(t.middleware, middleware...)
+}
+
+// wrap applies the global middleware to the handler.
+func (t *treeMux) wrap(h http.Handler) http.Handler {
+	return applyMiddleware(h, t.middleware)
+}
+
+type withMiddleware struct {
+	value []func(http.Handler) http.Handler
+}
+
+func (o withMiddleware) Apply(rt *route) {
+	rt.middleware = append(rt.middleware, o.value...)
+}
+
+func (o withMiddleware) private() {}
+
+// WithMiddleware wraps the route's handler with the middleware, the first
+// middleware being the outermost. It is applied inside the other route
+// options, so guards and the like run before it.
+//
+//	t.Handle("/admin/*", h, treemux.WithMiddleware(requireAdmin))
+func WithMiddleware(middleware ...func(http.Handler) http.Handler) RouteOption {
+	return withMiddleware{middleware}
+}
+
+// applyMiddleware wraps the handler with the middleware, the first middleware
+// being the outermost.
+func applyMiddleware(h http.Handler, middleware []func(http.Handler) http.Handler) http.Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+	return h
+}
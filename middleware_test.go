@@ -0,0 +1,83 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func appendHeader(value string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Middleware", value)
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestTreeMux_Use(t *testing.T) {
+	cases := []struct {
+		name    string
+		options []Option
+		path    string
+		want    int
+	}{
+		{"matched", nil, "/foo", http.StatusOK},
+		{"not found", nil, "/bar", http.StatusNotFound},
+		{"traced", []Option{OptionRouterTrace(&RouterTrace{GotRequest: func(*http.Request) {}})}, "/foo", http.StatusOK},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tr := NewTreeMux(c.options...)
+			tr.HandleFunc("/foo", bodyHandler("foo"))
+			tr.Use(appendHeader("a"), appendHeader("b"))
+			tr.Use(appendHeader("c"))
+
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+			if w.Code != c.want {
+				t.Errorf("expected %d, got %d", c.want, w.Code)
+			}
+			got := w.Header().Values("X-Middleware")
+			if len(got) != 3 || got[0] != "a" || got[1] != "b" || got[2] != "c" {
+				t.Errorf("expected [a b c], got %v", got)
+			}
+		})
+	}
+}
+
+func TestWithMiddleware(t *testing.T) {
+	tr := NewTreeMux()
+	tr.Use(appendHeader("global"))
+	tr.HandleFunc("/admin/*", bodyHandler("admin"), WithMiddleware(appendHeader("a"), appendHeader("b")), WithGuard(hasHeader("X-Foo")))
+	tr.HandleFunc("/public", bodyHandler("public"))
+
+	cases := []struct {
+		name   string
+		path   string
+		header bool
+		want   []string
+	}{
+		{"route middleware", "/admin/x", true, []string{"global", "a", "b"}},
+		{"guarded", "/admin/x", false, []string{"global"}},
+		{"other route", "/public", false, []string{"global"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, c.path, nil)
+			if c.header {
+				r.Header.Set("X-Foo", "1")
+			}
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, r)
+			if got := w.Header().Values("X-Middleware"); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("expected %v, got %v", c.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"container/list"
+	"sync"
+)
+
+// maxCachedPath is the length of the longest path that is cached, which keeps
+// the memory used by the cache in check.
+const maxCachedPath = 256
+
+// missCache is a bounded least-recently-used set of paths that do not match
+// any route, regardless of the request.
+type missCache struct {
+	mux   sync.Mutex
+	size  int
+	order *list.List
+	paths map[string]*list.Element
+}
+
+func newMissCache(size int) *missCache {
+	return &missCache{size: size, order: list.New(), paths: make(map[string]*list.Element)}
+}
+
+func (c *missCache) contains(path string) bool {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	el, ok := c.paths[path]
+	if ok {
+		c.order.MoveToFront(el)
+	}
+	return ok
+}
+
+func (c *missCache) add(path string) {
+	if len(path) > maxCachedPath {
+		return
+	}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if _, ok := c.paths[path]; ok {
+		return
+	}
+	c.paths[path] = c.order.PushFront(path)
+	if c.order.Len() > c.size {
+		el := c.order.Back()
+		c.order.Remove(el)
+		delete(c.paths, el.Value.(string))
+	}
+}
+
+// reset empties the cache; it is called when routes are added.
+func (c *missCache) reset() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.order.Init()
+	c.paths = make(map[string]*list.Element)
+}
+
+type optionNotFoundCache struct {
+	value int
+}
+
+func (o optionNotFoundCache) Apply(mux *treeMux) {
+	if o.value > 0 {
+		mux.missCache = newMissCache(o.value)
+	}
+}
+
+func (o optionNotFoundCache) private() {}
+
+// OptionNotFoundCache makes the mux remember up to size recent paths that did
+// not match any route, so repeated requests for them are not found without
+// matching. Paths that match a route for some requests only (i.e. due to
+// predicates) are never cached, nor are very long paths. The cache is cleared
+// when routes are added.
+func OptionNotFoundCache(size int) Option {
+	return optionNotFoundCache{size}
+}
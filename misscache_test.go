@@ -0,0 +1,81 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMissCache(t *testing.T) {
+	c := newMissCache(2)
+	c.add("/a")
+	c.add("/b")
+	c.contains("/a")
+	c.add("/c")
+	for p, want := range map[string]bool{"/a": true, "/b": false, "/c": true} {
+		if got := c.contains(p); got != want {
+			t.Errorf("%s: expected %v, got %v", p, want, got)
+		}
+	}
+	c.add("/" + strings.Repeat("x", maxCachedPath))
+	if c.order.Len() != 2 {
+		t.Errorf("expected long path to be skipped")
+	}
+	c.reset()
+	if c.contains("/a") {
+		t.Errorf("expected empty cache")
+	}
+}
+
+func TestOptionNotFoundCache(t *testing.T) {
+	var mt MatchTrace
+	tr := NewTreeMux(
+		OptionNotFoundCache(10),
+		OptionMatchTrace(func(_ *http.Request, _ string, trace MatchTrace, _ time.Duration) {
+			mt = trace
+		}),
+	)
+	tr.HandleFunc("/foo", bodyHandler("foo"), WithPredicate(hasHeader("X-Foo")))
+
+	cases := []struct {
+		name      string
+		path      string
+		header    bool
+		want      int
+		inspected bool
+	}{
+		{"first miss", "/bar", false, http.StatusNotFound, true},
+		{"cached miss", "/bar", false, http.StatusNotFound, false},
+		{"predicate miss", "/foo", false, http.StatusNotFound, true},
+		{"predicate miss not cached", "/foo", false, http.StatusNotFound, true},
+		{"predicate match", "/foo", true, http.StatusOK, true},
+	}
+	for _, c := range cases {
+		mt = MatchTrace{}
+		r := httptest.NewRequest(http.MethodGet, c.path, nil)
+		if c.header {
+			r.Header.Set("X-Foo", "1")
+		}
+		w := httptest.NewRecorder()
+		tr.ServeHTTP(w, r)
+		if w.Code != c.want {
+			t.Errorf("%s: expected %d, got %d", c.name, c.want, w.Code)
+		}
+		if got := mt.Inspected > 0; got != c.inspected {
+			t.Errorf("%s: expected inspected %v, got %v", c.name, c.inspected, got)
+		}
+	}
+
+	tr.HandleFunc("/bar", bodyHandler("bar"))
+	w := httptest.NewRecorder()
+	tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/bar", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected cache to be invalidated, got %d", w.Code)
+	}
+}
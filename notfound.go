@@ -0,0 +1,69 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// NotFoundStats counts requests that could not be matched, grouped by the
+// deepest route pattern they did match. That shows which missing routes
+// clients actually request.
+//
+// Wildcards are taken into account, so a request for "/foo/bar/baz" with only
+// "/foo/*" registered is counted under "/foo/*". Requests that do not share a
+// single element with a route are counted under "/".
+type NotFoundStats struct {
+	mux    sync.Mutex
+	counts map[string]int64
+}
+
+// NewNotFoundStats creates an empty NotFoundStats.
+func NewNotFoundStats() *NotFoundStats {
+	return &NotFoundStats{counts: make(map[string]int64)}
+}
+
+func (s *NotFoundStats) record(prefix string) {
+	if prefix == "" {
+		prefix = "/"
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.counts[prefix] += 1
+}
+
+// Counts returns a copy of the counts per prefix.
+func (s *NotFoundStats) Counts() map[string]int64 {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	m := make(map[string]int64, len(s.counts))
+	for k, v := range s.counts {
+		m[k] = v
+	}
+	return m
+}
+
+// ServeHTTP serves the counts as a JSON object, for use as a debug endpoint.
+func (s *NotFoundStats) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.Counts())
+}
+
+type optionNotFoundStats struct {
+	value *NotFoundStats
+}
+
+func (o optionNotFoundStats) Apply(mux *treeMux) {
+	mux.notFoundStats = o.value
+}
+
+func (o optionNotFoundStats) private() {}
+
+// OptionNotFoundStats records unmatched requests in the given NotFoundStats.
+func OptionNotFoundStats(s *NotFoundStats) Option {
+	return optionNotFoundStats{s}
+}
@@ -0,0 +1,48 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestOptionNotFoundStats(t *testing.T) {
+	stats := NewNotFoundStats()
+	tr := NewTreeMux(OptionNotFoundStats(stats))
+	tr.Handle("/users/*/orders", bodyHandler("orders"))
+	tr.Handle("/users/*/profile", bodyHandler("profile"))
+	tr.Handle("/static/css", bodyHandler("css"))
+
+	for _, p := range []string{
+		"/users/1/orders",
+		"/users/1/invoices",
+		"/users/2/invoices",
+		"/users",
+		"/static/js",
+		"/wp-admin",
+	} {
+		tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, p, nil))
+	}
+
+	want := map[string]int64{
+		"/users/*": 2,
+		"/users":   1,
+		"/static":  1,
+		"/":        1,
+	}
+	if got := stats.Counts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	w := httptest.NewRecorder()
+	stats.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/notfound", nil))
+	wantBody := `{"/":1,"/static":1,"/users":1,"/users/*":2}` + "\n"
+	if w.Body.String() != wantBody {
+		t.Errorf("expected %s, got %s", wantBody, w.Body.String())
+	}
+}
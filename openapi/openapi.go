@@ -0,0 +1,151 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+// Package openapi generates an OpenAPI 3 skeleton from the routes of a
+// TreeMux, so that hand-written specifications can be checked against, or
+// started from, the actual route table.
+//
+// Every route becomes an operation for each of its methods, or for GET when it
+// is not restricted to any. Wildcards become path parameters, named after the
+// wildcard, with the regular expression of constrained wildcards as schema
+// pattern. Route metadata is added as "x-" extensions of the operation. Host
+// routes (see TreeMux.HandleHost) are left out, as OpenAPI paths do not have
+// hosts.
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/HayoVanLoon/go-treemux"
+)
+
+// Version is the OpenAPI version of the generated documents.
+const Version = "3.0.3"
+
+// Document is an OpenAPI document.
+type Document struct {
+	OpenAPI string              `json:"openapi"`
+	Info    Info                `json:"info"`
+	Paths   map[string]PathItem `json:"paths"`
+}
+
+// Info holds the metadata of the API.
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// PathItem holds the operations of a path, by lower case method.
+type PathItem map[string]*Operation
+
+// Operation describes an operation on a path.
+type Operation struct {
+	OperationID string              `json:"operationId,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+	// Extensions are added to the operation with an "x-" prefix.
+	Extensions map[string]interface{} `json:"-"`
+}
+
+func (o *Operation) MarshalJSON() ([]byte, error) {
+	type operation Operation
+	bs, err := json.Marshal((*operation)(o))
+	if err != nil || len(o.Extensions) == 0 {
+		return bs, err
+	}
+	keys := make([]string, 0, len(o.Extensions))
+	for k := range o.Extensions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	bs = bs[:len(bs)-1]
+	for _, k := range keys {
+		name, _ := json.Marshal("x-" + k)
+		v, err := json.Marshal(o.Extensions[k])
+		if err != nil {
+			return nil, err
+		}
+		bs = append(append(append(append(bs, ','), name...), ':'), v...)
+	}
+	return append(bs, '}'), nil
+}
+
+// Parameter describes a path parameter.
+type Parameter struct {
+	Name     string `json:"name"`
+	In       string `json:"in"`
+	Required bool   `json:"required"`
+	Schema   Schema `json:"schema"`
+}
+
+// Schema describes the values of a parameter.
+type Schema struct {
+	Type    string `json:"type"`
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// Response describes a response of an operation.
+type Response struct {
+	Description string `json:"description"`
+}
+
+// Generate returns the skeleton for the routes of the mux.
+func Generate(mux treemux.TreeMux, info Info) *Document {
+	doc := &Document{OpenAPI: Version, Info: info, Paths: map[string]PathItem{}}
+	for _, rt := range mux.Routes() {
+		if rt.Host != "" {
+			continue
+		}
+		path, params := pathTemplate(rt)
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = PathItem{}
+			doc.Paths[path] = item
+		}
+		methods := rt.Methods
+		if len(methods) == 0 {
+			methods = []string{http.MethodGet}
+		}
+		for _, m := range methods {
+			m = strings.ToLower(m)
+			if _, ok := item[m]; ok {
+				// Conditional routes come first; keep the first one.
+				continue
+			}
+			item[m] = &Operation{
+				OperationID: rt.Name,
+				Parameters:  params,
+				Responses:   map[string]Response{"default": {Description: "Default response."}},
+				Extensions:  rt.Metadata,
+			}
+		}
+	}
+	return doc
+}
+
+// pathTemplate returns the OpenAPI path template of the route pattern and its
+// path parameters.
+func pathTemplate(rt treemux.Route) (string, []Parameter) {
+	xs := strings.Split(strings.TrimPrefix(rt.Pattern, "/"), "/")
+	var params []Parameter
+	for i, x := range xs {
+		if x != "*" && x != "**" && !strings.HasPrefix(x, "*:") {
+			continue
+		}
+		name := x
+		if len(params) < len(rt.Params) {
+			name = rt.Params[len(params)]
+		}
+		p := Parameter{Name: name, In: "path", Required: true, Schema: Schema{Type: "string"}}
+		if strings.HasPrefix(x, "*:") {
+			p.Schema.Pattern = "^(?:" + x[2:] + ")$"
+		}
+		params = append(params, p)
+		xs[i] = "{" + name + "}"
+	}
+	return "/" + strings.Join(xs, "/"), params
+}
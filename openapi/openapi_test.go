@@ -0,0 +1,45 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/HayoVanLoon/go-treemux"
+)
+
+func TestGenerate(t *testing.T) {
+	noop := func(http.ResponseWriter, *http.Request) {}
+	mux := treemux.NewTreeMux()
+	mux.HandleFunc("/orders/{id:[0-9]+}", noop, treemux.WithName("getOrder"), treemux.WithMethods(http.MethodGet),
+		treemux.WithMetadata("team", "sales"))
+	mux.HandleFunc("/orders/{id:[0-9]+}", noop, treemux.WithMethods(http.MethodDelete))
+	mux.HandleFunc("/countries/:country/cities/*", noop)
+	mux.HandleFunc("/static/{path...}", noop)
+	mux.HandleHost("api.example.com", "/hosted", http.HandlerFunc(noop))
+
+	bs, err := json.Marshal(Generate(mux, Info{Title: "Shop", Version: "1.0"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"openapi":"3.0.3","info":{"title":"Shop","version":"1.0"},"paths":{` +
+		`"/countries/{country}/cities/{1}":{"get":{"parameters":[` +
+		`{"name":"country","in":"path","required":true,"schema":{"type":"string"}},` +
+		`{"name":"1","in":"path","required":true,"schema":{"type":"string"}}],` +
+		`"responses":{"default":{"description":"Default response."}}}},` +
+		`"/orders/{id}":{` +
+		`"delete":{"parameters":[{"name":"id","in":"path","required":true,"schema":{"type":"string","pattern":"^(?:[0-9]+)$"}}],` +
+		`"responses":{"default":{"description":"Default response."}}},` +
+		`"get":{"operationId":"getOrder","parameters":[{"name":"id","in":"path","required":true,"schema":{"type":"string","pattern":"^(?:[0-9]+)$"}}],` +
+		`"responses":{"default":{"description":"Default response."}},"x-team":"sales"}},` +
+		`"/static/{path}":{"get":{"parameters":[` +
+		`{"name":"path","in":"path","required":true,"schema":{"type":"string"}}],` +
+		`"responses":{"default":{"description":"Default response."}}}}}}`
+	if got := string(bs); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// pathParam is a named wildcard in a pattern.
+type pathParam struct {
+	// index is the position of the path element, not counting the root.
+	index int
+	name  string
+	// rest marks catch-alls, which match the remainder of the path.
+	rest bool
+}
+
+// parsePathParams replaces the named wildcards (":name" or "{name}") in the
+// pattern with regular wildcards, and returns all wildcards. Unnamed
+// wildcards are named after their index among the wildcards of the pattern.
+// Wildcards with a regular expression ("{name:expr}") become constrained
+// wildcards ("*:expr"), which only match path elements matching the
+// expression. It panics on empty or duplicate names and invalid expressions.
+func parsePathParams(pattern string) (string, []pathParam) {
+	if !strings.ContainsAny(pattern, ":{*") {
+		return pattern, nil
+	}
+	xs := strings.Split(strings.TrimPrefix(pattern, "/"), "/")
+	var params []pathParam
+	seen := map[string]bool{}
+	for i, x := range xs {
+		var name string
+		switch {
+		case x == wildcard:
+			name = strconv.Itoa(len(params))
+		case strings.HasPrefix(x, ":"):
+			name = x[1:]
+		case strings.HasPrefix(x, "{") && strings.HasSuffix(x, "}"):
+			name = x[1 : len(x)-1]
+		default:
+			continue
+		}
+		key := wildcard
+		if n, expr, ok := strings.Cut(name, ":"); ok && x[0] == '{' {
+			name, key = n, constrainedPrefix+expr
+			if _, err := compileConstraint(key); expr == "" || err != nil {
+				panic(fmt.Sprintf("invalid path parameter %q in %s", x, pattern))
+			}
+		}
+		if name == "" || seen[name] {
+			panic(fmt.Sprintf("invalid path parameter %q in %s", x, pattern))
+		}
+		seen[name] = true
+		params = append(params, pathParam{i, name, false})
+		xs[i] = key
+	}
+	return "/" + strings.Join(xs, "/"), params
+}
+
+// parseCatchAll splits a pattern ending with a catch-all ("**" or
+// "{name...}") into the prefix and the catch-all parameter. Unnamed
+// catch-alls are named "**". It panics when the prefix has wildcards, since
+// prefixes are matched literally.
+func parseCatchAll(pattern string) (string, pathParam, bool) {
+	i := strings.LastIndex(pattern, "/")
+	last := pattern[i+1:]
+	var name string
+	switch {
+	case last == "**":
+		name = last
+	case strings.HasPrefix(last, "{") && strings.HasSuffix(last, "...}"):
+		name = last[1 : len(last)-4]
+	default:
+		return "", pathParam{}, false
+	}
+	prefix := pattern[:i]
+	if name == "" || strings.ContainsAny(prefix, ":{*") {
+		panic(fmt.Sprintf("invalid catch-all in %s", pattern))
+	}
+	return prefix, pathParam{strings.Count(prefix, "/"), name, true}, true
+}
+
+// checkPattern returns an error when the pattern cannot be added to a
+// matcher, so that registrations fail before anything is locked or changed.
+func checkPattern(pattern string) error {
+	return NewWildcardTrie[struct{}]("/").TryAdd(pattern, struct{}{})
+}
+
+// canonicalPattern returns the pattern in the form routes are known by, with
+// path parameters and catch-alls rewritten to wildcards, so that routes can be
+// looked up using the pattern they were registered with. Invalid patterns are
+// only normalised.
+func canonicalPattern(pattern string) (p string) {
+	p = normalisePattern(pattern)
+	defer func() {
+		_ = recover()
+	}()
+	if prefix, _, ok := parseCatchAll(p); ok {
+		return prefix + "/**"
+	}
+	p, _ = parsePathParams(p)
+	return p
+}
+
+// checkCatchAll returns an error when the pattern has a catch-all that is not
+// its final element.
+func checkCatchAll(pattern string) error {
+	xs := strings.Split(strings.TrimPrefix(pattern, "/"), "/")
+	var found []string
+	for _, x := range xs {
+		if x == "**" || strings.HasPrefix(x, "{") && strings.HasSuffix(x, "...}") {
+			found = append(found, x)
+		}
+	}
+	switch {
+	case len(found) > 1:
+		return fmt.Errorf("pattern %s has %d catch-alls (%s), but only one is allowed", pattern, len(found), strings.Join(found, ", "))
+	case len(found) == 1 && found[0] != xs[len(xs)-1]:
+		return fmt.Errorf("catch-all %s is not the final element of %s", found[0], pattern)
+	}
+	return nil
+}
+
+type withPathParams struct {
+	value []pathParam
+}
+
+func (o withPathParams) Apply(rt *route) {
+	rt.pathParams = o.value
+}
+
+func (o withPathParams) private() {}
+
+// capturePathParams wraps the handler so that the values of the wildcards are
+// available from the request context.
+func capturePathParams(h http.Handler, params []pathParam) http.Handler {
+	if len(params) == 0 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		xs := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		values := make(map[string]string, len(params))
+		for _, p := range params {
+			switch {
+			case p.rest && p.index < len(xs):
+				values[p.name] = strings.Join(xs[p.index:], "/")
+			case p.rest:
+				values[p.name] = ""
+			case p.index < len(xs):
+				values[p.name] = xs[p.index]
+			}
+		}
+		ctx := context.WithValue(r.Context(), pathParamsKey, values)
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// PathParam returns the value of the named wildcard in the pattern of the
+// matched route, or an empty string if the pattern has no such wildcard.
+//
+//	t.HandleFunc("/countries/:country/cities/{city}", func(w http.ResponseWriter, r *http.Request) {
+//	  country := treemux.PathParam(r, "country")
+//	  ...
+//	})
+func PathParam(r *http.Request, name string) string {
+	return Params(r)[name]
+}
+
+// Params returns the values of the wildcards in the pattern of the matched
+// route, by name (see PathParam). Unnamed wildcards are keyed by their index
+// among the wildcards of the pattern, so for a route "/countries/*/cities/:city"
+// that would be "0" and "city". The remainder of the path matched by a
+// catch-all is keyed by its name, or "**" when unnamed. It returns nil when
+// there are no wildcards.
+func Params(r *http.Request) map[string]string {
+	values, _ := r.Context().Value(pathParamsKey).(map[string]string)
+	return values
+}
@@ -0,0 +1,161 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParsePathParams(t *testing.T) {
+	cases := []struct {
+		pattern string
+		want    string
+		params  []pathParam
+	}{
+		{"/foo/bar", "/foo/bar", nil},
+		{"/countries/:country/cities", "/countries/*/cities", []pathParam{{1, "country", false}}},
+		{"/countries/{country}/cities/{city}", "/countries/*/cities/*", []pathParam{{1, "country", false}, {3, "city", false}}},
+		{"/:a/*/{b}", "/*/*/*", []pathParam{{0, "a", false}, {1, "1", false}, {2, "b", false}}},
+		{"/foo/*/bar/*", "/foo/*/bar/*", []pathParam{{1, "0", false}, {3, "1", false}}},
+		{"/foo/{bar", "/foo/{bar", nil},
+		{"/orders/{id:[0-9]{3}}/*", "/orders/*:[0-9]{3}/*", []pathParam{{1, "id", false}, {2, "1", false}}},
+	}
+	for _, c := range cases {
+		t.Run(c.pattern, func(t *testing.T) {
+			got, params := parsePathParams(c.pattern)
+			if got != c.want || !reflect.DeepEqual(params, c.params) {
+				t.Errorf("expected %q %v, got %q %v", c.want, c.params, got, params)
+			}
+		})
+	}
+}
+
+func TestParsePathParams_invalid(t *testing.T) {
+	for _, p := range []string{"/foo/:", "/foo/{}", "/:a/:a", "/foo/{id:}", "/foo/{:[0-9]+}", "/foo/{id:(}"} {
+		t.Run(p, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic")
+				}
+			}()
+			parsePathParams(p)
+		})
+	}
+}
+
+func TestPathParam(t *testing.T) {
+	tr := NewTreeMux()
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(PathParam(r, "country") + "," + PathParam(r, "city") + "," + PathParam(r, "other")))
+	}
+	tr.HandleFunc("/countries/:country/cities/{city}", handler)
+	tr.HandleFunc("/countries/{country}", handler)
+	tr.HandleFunc("/plain/*", handler)
+
+	cases := []struct {
+		path string
+		want string
+	}{
+		{"/countries/belgium/cities/wommelgem", "belgium,wommelgem,"},
+		{"/countries/fr%20ance", "fr ance,,"},
+		{"/plain/foo", ",,"},
+	}
+	for _, c := range cases {
+		t.Run(c.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+			if got := w.Body.String(); got != c.want {
+				t.Errorf("expected %q, got %q", c.want, got)
+			}
+		})
+	}
+	r := httptest.NewRequest(http.MethodGet, "/countries/belgium", nil)
+	if _, pattern := tr.Handler(r); pattern != "/countries/*" {
+		t.Errorf("expected wildcard pattern, got %q", pattern)
+	}
+}
+
+func TestParams(t *testing.T) {
+	tr := NewTreeMux()
+	var got map[string]string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		got = Params(r)
+	}
+	tr.HandleFunc("/countries/*/cities/:city", handler)
+	tr.HandleFunc("/static", handler)
+
+	cases := []struct {
+		path string
+		want map[string]string
+	}{
+		{"/countries/belgium/cities/wommelgem", map[string]string{"0": "belgium", "city": "wommelgem"}},
+		{"/static", nil},
+	}
+	for _, c := range cases {
+		t.Run(c.path, func(t *testing.T) {
+			got = nil
+			tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, c.path, nil))
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("expected %v, got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestCatchAll(t *testing.T) {
+	tr := NewTreeMux()
+	var got map[string]string
+	handler := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			got = Params(r)
+			_, _ = w.Write([]byte(name))
+		}
+	}
+	tr.Handle("/static/**", handler("static"))
+	tr.Handle("/static/favicon.ico", handler("favicon"))
+	tr.Handle("/files/{path...}", handler("files"))
+
+	cases := []struct {
+		path   string
+		body   string
+		params map[string]string
+	}{
+		{"/static/css/app.css", "static", map[string]string{"**": "css/app.css"}},
+		{"/static/app.js", "static", map[string]string{"**": "app.js"}},
+		{"/static", "static", map[string]string{"**": ""}},
+		{"/static/favicon.ico", "favicon", nil},
+		{"/files/a/b/c.txt", "files", map[string]string{"path": "a/b/c.txt"}},
+		{"/other/app.js", "404 page not found\n", nil},
+	}
+	for _, c := range cases {
+		t.Run(c.path, func(t *testing.T) {
+			got = nil
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+			if w.Body.String() != c.body {
+				t.Errorf("expected %q, got %q", c.body, w.Body.String())
+			}
+			if !reflect.DeepEqual(got, c.params) {
+				t.Errorf("expected %v, got %v", c.params, got)
+			}
+		})
+	}
+}
+
+func TestCatchAll_invalid(t *testing.T) {
+	for _, p := range []string{"/users/*/files/**", "/users/:id/**", "/files/{...}"} {
+		t.Run(p, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic")
+				}
+			}()
+			NewTreeMux().Handle(p, bodyHandler(""))
+		})
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"net/http"
+	"sync"
+)
+
+// hllPrecision is the number of hash bits used to select a register. With
+// 1024 registers, estimates have a standard error of about 3%.
+const hllPrecision = 10
+
+// hyperLogLog estimates the number of distinct strings added to it in fixed
+// memory.
+type hyperLogLog struct {
+	registers [1 << hllPrecision]uint8
+}
+
+func (h *hyperLogLog) add(s string) {
+	f := fnv.New64a()
+	_, _ = f.Write([]byte(s))
+	x := mix64(f.Sum64())
+	idx := x >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(x<<hllPrecision|1<<(hllPrecision-1))) + 1
+	if rank > h.registers[idx] {
+		h.registers[idx] = rank
+	}
+}
+
+// mix64 is the finalizer of MurmurHash3. FNV-1a alone does not spread the
+// hashes of short, similar strings over the high bits.
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+func (h *hyperLogLog) estimate() uint64 {
+	const m = float64(len(h.registers))
+	sum, zeros := 0.0, 0
+	for _, r := range h.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros += 1
+		}
+	}
+	e := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if e <= 2.5*m && zeros > 0 {
+		// small range correction
+		e = m * math.Log(m/float64(zeros))
+	}
+	return uint64(e + .5)
+}
+
+// ParamStats estimates the number of distinct values seen per wildcard of
+// each route (see Params), in a few kilobytes per wildcard. Unexpectedly
+// large or small numbers point at unexpected ID spaces or at clients abusing
+// catch-all routes.
+type ParamStats struct {
+	mux      sync.Mutex
+	sketches map[string]map[string]*hyperLogLog
+}
+
+// NewParamStats creates an empty ParamStats.
+func NewParamStats() *ParamStats {
+	return &ParamStats{sketches: make(map[string]map[string]*hyperLogLog)}
+}
+
+func (s *ParamStats) record(pattern string, params map[string]string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	m, ok := s.sketches[pattern]
+	if !ok {
+		m = make(map[string]*hyperLogLog, len(params))
+		s.sketches[pattern] = m
+	}
+	for name, v := range params {
+		h, ok := m[name]
+		if !ok {
+			h = &hyperLogLog{}
+			m[name] = h
+		}
+		h.add(v)
+	}
+}
+
+// Cardinality returns the estimated number of distinct values per route
+// pattern and wildcard name.
+func (s *ParamStats) Cardinality() map[string]map[string]uint64 {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	c := make(map[string]map[string]uint64, len(s.sketches))
+	for p, m := range s.sketches {
+		c[p] = make(map[string]uint64, len(m))
+		for name, h := range m {
+			c[p][name] = h.estimate()
+		}
+	}
+	return c
+}
+
+// ServeHTTP serves the estimates as a JSON object, for use as a debug
+// endpoint.
+func (s *ParamStats) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.Cardinality())
+}
+
+type optionParamStats struct {
+	value *ParamStats
+}
+
+func (o optionParamStats) Apply(mux *treeMux) {
+	mux.paramStats = o.value
+}
+
+func (o optionParamStats) private() {}
+
+// OptionParamStats records the wildcard values of matched requests in the
+// given ParamStats.
+func OptionParamStats(s *ParamStats) Option {
+	return optionParamStats{s}
+}
+
+// recordParams wraps the handler so that the wildcard values of its requests
+// are recorded.
+func recordParams(h http.Handler, pattern string, s *ParamStats) http.Handler {
+	if s == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if params := Params(r); len(params) > 0 {
+			s.record(pattern, params)
+		}
+		h.ServeHTTP(w, r)
+	})
+}
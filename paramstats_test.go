@@ -0,0 +1,58 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHyperLogLog(t *testing.T) {
+	for _, n := range []int{0, 10, 1000, 100000} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			h := &hyperLogLog{}
+			for i := 0; i < n; i++ {
+				h.add(fmt.Sprintf("value-%d", i))
+				h.add(fmt.Sprintf("value-%d", i))
+			}
+			got := float64(h.estimate())
+			if got < float64(n)*0.9 || got > float64(n)*1.1 {
+				t.Errorf("expected about %d, got %v", n, got)
+			}
+		})
+	}
+}
+
+func TestOptionParamStats(t *testing.T) {
+	stats := NewParamStats()
+	tr := NewTreeMux(OptionParamStats(stats))
+	tr.HandleFunc("/users/:user/orders/*", bodyHandler("order"))
+	tr.HandleFunc("/static", bodyHandler("static"))
+	for i := 0; i < 100; i++ {
+		path := fmt.Sprintf("/users/%d/orders/%d", i%5, i)
+		tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/static", nil))
+
+	card := stats.Cardinality()
+	if len(card) != 1 {
+		t.Fatalf("expected one pattern, got %v", card)
+	}
+	got := card["/users/*/orders/*"]
+	if got["user"] != 5 || got["1"] < 95 || got["1"] > 105 {
+		t.Errorf("unexpected estimates %v", got)
+	}
+
+	w := httptest.NewRecorder()
+	tr.Admin().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := ioutil.ReadAll(w.Body)
+	if want := `treemux_param_cardinality{pattern="/users/*/orders/*",param="user"} 5`; !strings.Contains(string(body), want) {
+		t.Errorf("expected %q in metrics, got %s", want, body)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+)
+
+// PolicyInput describes a matched request to a PolicyEvaluator.
+type PolicyInput struct {
+	// Pattern is the pattern of the matched route.
+	Pattern string
+	// Method is the request method.
+	Method string
+	// Params holds the wildcard values (see Params).
+	Params map[string]string
+	// Metadata is the route's metadata (see WithMetadata). It must not be
+	// modified.
+	Metadata map[string]interface{}
+	// Request gives access to the other request attributes, like headers
+	// and the client address.
+	Request *http.Request
+}
+
+// PolicyDecision is the outcome of a policy evaluation.
+type PolicyDecision struct {
+	// Allow lets the request through to the handler.
+	Allow bool
+	// Headers are obligations: response headers to add to allowed requests.
+	Headers http.Header
+}
+
+// PolicyEvaluator makes authorization decisions for matched requests, for
+// instance by querying an external policy engine like Open Policy Agent.
+type PolicyEvaluator interface {
+	Evaluate(input PolicyInput) (PolicyDecision, error)
+}
+
+// PolicyEvaluatorFunc is an adapter to allow the use of ordinary functions as
+// a PolicyEvaluator.
+type PolicyEvaluatorFunc func(input PolicyInput) (PolicyDecision, error)
+
+func (f PolicyEvaluatorFunc) Evaluate(input PolicyInput) (PolicyDecision, error) {
+	return f(input)
+}
+
+type optionPolicy struct {
+	value PolicyEvaluator
+}
+
+func (o optionPolicy) Apply(mux *treeMux) {
+	mux.policy = o.value
+}
+
+func (o optionPolicy) private() {}
+
+// OptionPolicy makes the mux consult the evaluator for every matched request,
+// after request verification (see WithVerifier). Denied requests get the
+// mux's forbidden handler (see OptionForbidden); evaluation errors are logged
+// and result in a 500 response.
+func OptionPolicy(evaluator PolicyEvaluator) Option {
+	return optionPolicy{evaluator}
+}
+
+// evaluatePolicy wraps the handler so that it is only called for requests the
+// policy allows.
+func evaluatePolicy(h http.Handler, rt *route, policy PolicyEvaluator, forbidden http.Handler, fail errorFunc) http.Handler {
+	if policy == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d, err := policy.Evaluate(PolicyInput{
+			Pattern:  rt.pattern,
+			Method:   r.Method,
+			Params:   Params(r),
+			Metadata: rt.metadata,
+			Request:  r,
+		})
+		if err != nil {
+			rt.logf(LogError, "policy evaluation failed", "pattern", rt.pattern, "error", err)
+			fail(w, r, http.StatusInternalServerError)
+			return
+		}
+		if !d.Allow {
+			forbidden.ServeHTTP(w, r)
+			return
+		}
+		for k, vs := range d.Headers {
+			for _, v := range vs {
+				w.Header().Add(k, v)
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
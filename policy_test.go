@@ -0,0 +1,66 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestOptionPolicy(t *testing.T) {
+	var got PolicyInput
+	policy := PolicyEvaluatorFunc(func(input PolicyInput) (PolicyDecision, error) {
+		got = input
+		switch input.Params["account"] {
+		case "error":
+			return PolicyDecision{}, errors.New("engine unavailable")
+		case "mine":
+			return PolicyDecision{Allow: true, Headers: http.Header{"X-Policy": {"ok"}}}, nil
+		}
+		return PolicyDecision{}, nil
+	})
+	log := &syncLog{}
+	tr := NewTreeMux(OptionPolicy(policy), OptionLogger(log))
+	tr.HandleMethod(http.MethodGet, "/accounts/:account", bodyHandler("account"), WithMetadata("scope", "accounts"))
+
+	cases := []struct {
+		name    string
+		account string
+		want    int
+		header  string
+	}{
+		{"allow", "mine", http.StatusOK, "ok"},
+		{"deny", "yours", http.StatusForbidden, ""},
+		{"error", "error", http.StatusInternalServerError, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/accounts/"+c.account, nil))
+			if w.Code != c.want {
+				t.Errorf("expected %d, got %d", c.want, w.Code)
+			}
+			if h := w.Header().Get("X-Policy"); h != c.header {
+				t.Errorf("expected header %q, got %q", c.header, h)
+			}
+			want := PolicyInput{
+				Pattern:  "/accounts/*",
+				Method:   http.MethodGet,
+				Params:   map[string]string{"account": c.account},
+				Metadata: map[string]interface{}{"scope": "accounts"},
+			}
+			got.Request = nil
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("expected %+v, got %+v", want, got)
+			}
+		})
+	}
+	if s := log.String(); s == "" {
+		t.Errorf("expected evaluation error to be logged")
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"strings"
+)
+
+// handlePrefix registers a handler for all paths starting with the given
+// path elements. Prefix routes are only considered for requests that do not
+// match a regular route; the longest prefix wins.
+func (t *treeMux) handlePrefix(path string, handler http.Handler, options ...RouteOption) {
+	prefix := strings.TrimSuffix(normalisePattern(t.expandFragments(path)), "/")
+	rt := t.newRoute(prefix+"/**", handler, options)
+	t.register(t.prefixEndpoint(prefix), rt)
+}
+
+// prefixEndpoint returns the endpoint for the prefix, adding it when needed.
+func (t *treeMux) prefixEndpoint(prefix string) *endpoint {
+	t.routesMux.Lock()
+	defer t.routesMux.Unlock()
+	if t.prefixes == nil {
+		t.prefixes = make(map[string]*endpoint)
+	}
+	e, ok := t.prefixes[prefix]
+	if !ok {
+		e = &endpoint{pattern: prefix + "/**"}
+		t.prefixes[prefix] = e
+		if t.missCache != nil {
+			t.missCache.reset()
+		}
+		if t.fastMiss != nil {
+			t.fastMiss.add(prefix)
+		}
+	}
+	return e
+}
+
+// matchPrefix returns the prefix route for the request, or nil if there is
+// none. It also reports whether any prefix routes exist for the path, whether
+// or not they match the request.
+func (t *treeMux) matchPrefix(r *http.Request) (*route, bool) {
+	if len(t.prefixes) == 0 {
+		return nil, false
+	}
+	p := strings.TrimSuffix(normalisePattern(r.URL.Path), "/")
+	found := false
+	for {
+		if e, ok := t.prefixes[p]; ok {
+			found = true
+			if rt := e.lookup(r); rt != nil {
+				return rt, true
+			}
+		}
+		if p == "" {
+			return nil, found
+		}
+		p = p[:strings.LastIndex(p, "/")]
+	}
+}
+
+func (t *treeMux) Mount(path string, handler http.Handler, options ...RouteOption) {
+	t.handlePrefix(path, handler, options...)
+}
@@ -0,0 +1,124 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// queryParam is a query parameter declared with WithQueryParams.
+type queryParam struct {
+	name     string
+	typ      string
+	optional bool
+}
+
+// queryParamTypes holds the validation per supported type.
+var queryParamTypes = map[string]func(string) error{
+	"string": func(string) error { return nil },
+	"int": func(s string) error {
+		_, err := strconv.ParseInt(s, 10, 64)
+		return err
+	},
+	"uint": func(s string) error {
+		_, err := strconv.ParseUint(s, 10, 64)
+		return err
+	},
+	"float": func(s string) error {
+		_, err := strconv.ParseFloat(s, 64)
+		return err
+	},
+	"bool": func(s string) error {
+		_, err := strconv.ParseBool(s)
+		return err
+	},
+}
+
+func parseQueryParam(spec string) queryParam {
+	name, typ := spec, "string"
+	if i := strings.IndexByte(spec, ':'); i >= 0 {
+		name, typ = spec[:i], spec[i+1:]
+	}
+	qp := queryParam{name: strings.TrimSuffix(name, "?"), typ: typ, optional: strings.HasSuffix(name, "?")}
+	if qp.name == "" {
+		panic(fmt.Sprintf("query parameter without name: '%s'", spec))
+	}
+	if _, ok := queryParamTypes[typ]; !ok {
+		panic(fmt.Sprintf("unsupported query parameter type '%s'", typ))
+	}
+	return qp
+}
+
+type withQueryParams struct {
+	value []queryParam
+}
+
+func (o withQueryParams) Apply(rt *route) {
+	rt.queryParams = append(rt.queryParams, o.value...)
+}
+
+func (o withQueryParams) private() {}
+
+// WithQueryParams declares the query parameters of the route. A parameter is
+// given by name, optionally followed by a question mark when it is optional
+// and a colon and a type: string (the default), int, uint, float or bool. For
+// example:
+//
+//	WithQueryParams("q", "page?:int", "exact?:bool")
+//
+// Requests that lack a required parameter, or have a value that is not of the
+// declared type, get a 400 response. The handler can read the validated values
+// with QueryParams. Invalid declarations cause a panic.
+func WithQueryParams(specs ...string) RouteOption {
+	qps := make([]queryParam, len(specs))
+	for i, s := range specs {
+		qps[i] = parseQueryParam(s)
+	}
+	return withQueryParams{qps}
+}
+
+// validateQuery wraps the handler so that it is only called for requests with
+// valid query parameters.
+func validateQuery(h http.Handler, qps []queryParam, fail errorFunc) http.Handler {
+	if len(qps) == 0 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		values := make(map[string]string, len(qps))
+		for _, qp := range qps {
+			vs, ok := query[qp.name]
+			if !ok {
+				if qp.optional {
+					continue
+				}
+				fail(w, r, http.StatusBadRequest)
+				return
+			}
+			for _, v := range vs {
+				if err := queryParamTypes[qp.typ](v); err != nil {
+					fail(w, r, http.StatusBadRequest)
+					return
+				}
+			}
+			values[qp.name] = vs[0]
+		}
+		ctx := context.WithValue(r.Context(), queryParamsKey, values)
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// QueryParams returns the values of the query parameters declared with
+// WithQueryParams. Only parameters present in the request are included; for
+// parameters with multiple values, the first is used. It returns nil when the
+// route declares no query parameters.
+func QueryParams(r *http.Request) map[string]string {
+	values, _ := r.Context().Value(queryParamsKey).(map[string]string)
+	return values
+}
@@ -0,0 +1,63 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestWithQueryParams(t *testing.T) {
+	var got map[string]string
+	tr := NewTreeMux()
+	tr.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) {
+		got = QueryParams(r)
+	}, WithQueryParams("q", "page?:int", "exact?:bool", "score?:float"))
+
+	cases := []struct {
+		name  string
+		query string
+		want  int
+		vals  map[string]string
+	}{
+		{"required only", "q=foo", http.StatusOK, map[string]string{"q": "foo"}},
+		{"all", "q=foo&page=2&exact=true&score=0.5&other=x", http.StatusOK,
+			map[string]string{"q": "foo", "page": "2", "exact": "true", "score": "0.5"}},
+		{"empty required", "q=", http.StatusOK, map[string]string{"q": ""}},
+		{"multiple", "q=foo&q=bar", http.StatusOK, map[string]string{"q": "foo"}},
+		{"missing required", "page=2", http.StatusBadRequest, nil},
+		{"bad int", "q=foo&page=two", http.StatusBadRequest, nil},
+		{"bad second value", "q=foo&page=1&page=x", http.StatusBadRequest, nil},
+		{"bad bool", "q=foo&exact=maybe", http.StatusBadRequest, nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got = nil
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search?"+c.query, nil))
+			if w.Code != c.want {
+				t.Fatalf("expected %d, got %d", c.want, w.Code)
+			}
+			if !reflect.DeepEqual(got, c.vals) {
+				t.Errorf("expected %v, got %v", c.vals, got)
+			}
+		})
+	}
+}
+
+func TestWithQueryParams_invalid(t *testing.T) {
+	for _, spec := range []string{"page:integer", ":int", "?"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected panic", spec)
+				}
+			}()
+			WithQueryParams(spec)
+		}()
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// QuotaStore keeps the usage counters of quotas. It has the same semantics as
+// a RateLimitStore, but counters live for a full quota period.
+type QuotaStore interface {
+	// Incr increments the counter for the key and returns the new value. A
+	// counter that does not exist is created with the given time to live.
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+}
+
+// NewMemoryQuotaStore creates a QuotaStore that keeps its counters in memory.
+// This is the default. Since the counters are lost on restart, a persistent
+// store is recommended for billing.
+func NewMemoryQuotaStore() QuotaStore {
+	return NewMemoryRateLimitStore()
+}
+
+type optionQuotaStore struct {
+	value QuotaStore
+}
+
+func (o optionQuotaStore) Apply(mux *treeMux) {
+	mux.quotaStore = o.value
+}
+
+func (o optionQuotaStore) private() {}
+
+// OptionQuotaStore sets the store used by quotas. Defaults to an in-memory
+// store.
+func OptionQuotaStore(s QuotaStore) Option {
+	return optionQuotaStore{s}
+}
+
+// Quota limits the number of requests per identity (like a tenant or an API
+// key) over long periods. Unlike rate limits, quotas are about accounting for
+// fairness or billing rather than protecting the service.
+type Quota struct {
+	// Identity identifies the tenant. Requests without identity are not
+	// counted. It must be set.
+	Identity func(r *http.Request) string
+	// Limit returns the number of requests the tenant may make per period
+	// on the route, or a negative number for no limit. Different plans can
+	// thus have different limits.
+	Limit func(identity, pattern string) int64
+	// Period is the length of the accounting period.
+	Period time.Duration
+	// Group names the quota, so routes with the same group share their
+	// usage. Defaults to the route pattern.
+	Group string
+}
+
+type withQuota struct {
+	value Quota
+}
+
+func (o withQuota) Apply(rt *route) {
+	rt.quota = &o.value
+}
+
+func (o withQuota) private() {}
+
+// WithQuota accounts the requests to the route per tenant. Requests over the
+// quota get a 429 response. All responses for counted requests get
+// X-Quota-Limit, X-Quota-Remaining and X-Quota-Reset (in Unix seconds)
+// headers. When the store fails, requests are let through. It panics when the
+// identity or limit function is missing, or the period is not positive.
+func WithQuota(q Quota) RouteOption {
+	if q.Identity == nil || q.Limit == nil || q.Period <= 0 {
+		panic(fmt.Sprintf("invalid quota per %s", q.Period))
+	}
+	return withQuota{q}
+}
+
+// enforceQuota wraps the handler with a quota.
+func enforceQuota(h http.Handler, pattern string, q *Quota, store QuotaStore, clock Clock, fail errorFunc, logf logFunc) http.Handler {
+	if q == nil {
+		return h
+	}
+	group := q.Group
+	if group == "" {
+		group = pattern
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := q.Identity(r)
+		if id == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		limit := q.Limit(id, pattern)
+		if limit < 0 {
+			h.ServeHTTP(w, r)
+			return
+		}
+		now := clock.Now()
+		period := now.UnixNano() / int64(q.Period)
+		reset := time.Unix(0, (period+1)*int64(q.Period))
+		key := "quota\x00" + group + "\x00" + id + "\x00" + strconv.FormatInt(period, 10)
+
+		n, err := store.Incr(r.Context(), key, reset.Sub(now))
+		if err != nil {
+			logf(LogError, "quota store failed", "pattern", pattern, "error", err)
+			h.ServeHTTP(w, r)
+			return
+		}
+		remaining := limit - n
+		if remaining < 0 {
+			remaining = 0
+		}
+		w.Header().Set("X-Quota-Limit", strconv.FormatInt(limit, 10))
+		w.Header().Set("X-Quota-Remaining", strconv.FormatInt(remaining, 10))
+		w.Header().Set("X-Quota-Reset", strconv.FormatInt(reset.Unix(), 10))
+		if n > limit {
+			w.Header().Set("Retry-After", strconv.Itoa(int(reset.Sub(now).Seconds()+.999)))
+			fail(w, r, http.StatusTooManyRequests)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
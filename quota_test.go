@@ -0,0 +1,78 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWithQuota(t *testing.T) {
+	quota := Quota{
+		Identity: func(r *http.Request) string {
+			return r.Header.Get("X-Tenant")
+		},
+		Limit: func(identity, _ string) int64 {
+			switch identity {
+			case "free":
+				return 1
+			case "enterprise":
+				return -1
+			}
+			return 2
+		},
+		Period: 24 * time.Hour,
+		Group:  "api",
+	}
+	cases := []struct {
+		name      string
+		options   []Option
+		tenants   []string
+		paths     []string
+		wantCodes []int
+	}{
+		{"within quota", nil, []string{"basic", "basic"}, []string{"/a", "/a"}, []int{200, 200}},
+		{"over quota", nil, []string{"free", "free"}, []string{"/a", "/a"}, []int{200, 429}},
+		{"shared group", nil, []string{"basic", "basic", "basic"}, []string{"/a", "/b", "/a"}, []int{200, 200, 429}},
+		{"per tenant", nil, []string{"free", "basic"}, []string{"/a", "/a"}, []int{200, 200}},
+		{"unlimited", nil, []string{"enterprise", "enterprise"}, []string{"/a", "/a"}, []int{200, 200}},
+		{"anonymous", nil, []string{"", "", ""}, []string{"/a", "/a", "/a"}, []int{200, 200, 200}},
+		{"failing store", []Option{OptionQuotaStore(failingStore{})}, []string{"free", "free"}, []string{"/a", "/a"}, []int{200, 200}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tr := NewTreeMux(append(c.options, OptionLogger(&syncLog{}))...)
+			tr.HandleFunc("/a", bodyHandler("a"), WithQuota(quota))
+			tr.HandleFunc("/b", bodyHandler("b"), WithQuota(quota))
+			for i, tenant := range c.tenants {
+				r := httptest.NewRequest(http.MethodGet, c.paths[i], nil)
+				if tenant != "" {
+					r.Header.Set("X-Tenant", tenant)
+				}
+				w := httptest.NewRecorder()
+				tr.ServeHTTP(w, r)
+				if w.Code != c.wantCodes[i] {
+					t.Errorf("request %d: expected %d, got %d", i, c.wantCodes[i], w.Code)
+				}
+				if w.Code == http.StatusTooManyRequests {
+					if w.Header().Get("X-Quota-Remaining") != "0" || w.Header().Get("Retry-After") == "" {
+						t.Errorf("expected quota headers, got %v", w.Header())
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestWithQuota_invalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic")
+		}
+	}()
+	WithQuota(Quota{Period: time.Hour})
+}
@@ -0,0 +1,167 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitStore keeps the counters of the rate limiter. Implementations
+// backed by a shared store (Redis, memcached) make limits apply across
+// replicas.
+type RateLimitStore interface {
+	// Incr increments the counter for the key and returns the new value. A
+	// counter that does not exist is created with the given time to live.
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+}
+
+type memoryCounter struct {
+	value   int64
+	expires time.Time
+}
+
+// memoryStore is a RateLimitStore for a single process.
+type memoryStore struct {
+	clock    Clock
+	mux      sync.Mutex
+	counters map[string]memoryCounter
+	sweepAt  int
+}
+
+// NewMemoryRateLimitStore creates a RateLimitStore that keeps its counters in
+// memory. This is the default.
+func NewMemoryRateLimitStore() RateLimitStore {
+	return newMemoryStore(realClock{})
+}
+
+func newMemoryStore(clock Clock) *memoryStore {
+	return &memoryStore{clock: clock, counters: make(map[string]memoryCounter), sweepAt: 1024}
+}
+
+func (s *memoryStore) Incr(_ context.Context, key string, ttl time.Duration) (int64, error) {
+	now := s.clock.Now()
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if len(s.counters) >= s.sweepAt {
+		s.sweep(now)
+	}
+	c, ok := s.counters[key]
+	if !ok || !now.Before(c.expires) {
+		c = memoryCounter{expires: now.Add(ttl)}
+	}
+	c.value += 1
+	s.counters[key] = c
+	return c.value, nil
+}
+
+func (s *memoryStore) sweep(now time.Time) {
+	for k, c := range s.counters {
+		if !now.Before(c.expires) {
+			delete(s.counters, k)
+		}
+	}
+	s.sweepAt = 2 * len(s.counters)
+	if s.sweepAt < 1024 {
+		s.sweepAt = 1024
+	}
+}
+
+type optionRateLimitStore struct {
+	value RateLimitStore
+}
+
+func (o optionRateLimitStore) Apply(mux *treeMux) {
+	mux.rateLimitStore = o.value
+}
+
+func (o optionRateLimitStore) private() {}
+
+// OptionRateLimitStore sets the store used by rate limits. Defaults to an
+// in-memory store.
+func OptionRateLimitStore(s RateLimitStore) Option {
+	return optionRateLimitStore{s}
+}
+
+// RateLimit limits the number of requests per client in fixed windows.
+type RateLimit struct {
+	// Limit is the number of requests allowed per period.
+	Limit int64
+	// Period is the length of a window.
+	Period time.Duration
+	// Key identifies the client. Defaults to ClientIP.
+	Key func(r *http.Request) string
+}
+
+type withRateLimit struct {
+	value RateLimit
+}
+
+func (o withRateLimit) Apply(rt *route) {
+	rt.rateLimit = &o.value
+}
+
+func (o withRateLimit) private() {}
+
+// WithRateLimit limits the rate at which clients can call the route. Requests
+// over the limit get a 429 response. When the store fails, requests are let
+// through. It panics when the period is not positive or the limit negative.
+func WithRateLimit(rl RateLimit) RouteOption {
+	if rl.Limit < 0 || rl.Period <= 0 {
+		panic(fmt.Sprintf("invalid rate limit %d per %s", rl.Limit, rl.Period))
+	}
+	return withRateLimit{rl}
+}
+
+// ClientIP returns the IP address of the remote end of the connection.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// rateLimit wraps the handler with a rate limit.
+func rateLimit(h http.Handler, pattern string, rl *RateLimit, store RateLimitStore, clock Clock, fail errorFunc, logf logFunc) http.Handler {
+	if rl == nil {
+		return h
+	}
+	keyFn := rl.Key
+	if keyFn == nil {
+		keyFn = ClientIP
+	}
+	limit := strconv.FormatInt(rl.Limit, 10)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		now := clock.Now()
+		window := now.UnixNano() / int64(rl.Period)
+		reset := time.Unix(0, (window+1)*int64(rl.Period))
+		key := pattern + "\x00" + keyFn(r) + "\x00" + strconv.FormatInt(window, 10)
+
+		n, err := store.Incr(r.Context(), key, rl.Period)
+		if err != nil {
+			logf(LogError, "rate limit store failed", "pattern", pattern, "error", err)
+			h.ServeHTTP(w, r)
+			return
+		}
+		remaining := rl.Limit - n
+		if remaining < 0 {
+			remaining = 0
+		}
+		w.Header().Set("X-RateLimit-Limit", limit)
+		w.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+		if n > rl.Limit {
+			w.Header().Set("Retry-After", strconv.Itoa(int(reset.Sub(now).Seconds()+.999)))
+			fail(w, r, http.StatusTooManyRequests)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,119 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type failingStore struct{}
+
+func (failingStore) Incr(context.Context, string, time.Duration) (int64, error) {
+	return 0, errors.New("unavailable")
+}
+
+func TestWithRateLimit(t *testing.T) {
+	cases := []struct {
+		name      string
+		options   []Option
+		addrs     []string
+		wantCodes []int
+		wantLog   string
+	}{
+		{
+			"within limit",
+			nil,
+			[]string{"1.2.3.4:1", "1.2.3.4:2"},
+			[]int{200, 200},
+			"",
+		},
+		{
+			"over limit",
+			nil,
+			[]string{"1.2.3.4:1", "1.2.3.4:2", "1.2.3.4:3"},
+			[]int{200, 200, 429},
+			"",
+		},
+		{
+			"per client",
+			nil,
+			[]string{"1.2.3.4:1", "1.2.3.4:2", "5.6.7.8:1"},
+			[]int{200, 200, 200},
+			"",
+		},
+		{
+			"failing store",
+			[]Option{OptionRateLimitStore(failingStore{})},
+			[]string{"1.2.3.4:1", "1.2.3.4:2", "1.2.3.4:3"},
+			[]int{200, 200, 200},
+			"ERROR: rate limit store failed",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			logs := &syncLog{}
+			tr := NewTreeMux(append(c.options, OptionLogger(logs))...)
+			tr.Handle("/login", bodyHandler("ok"), WithRateLimit(RateLimit{Limit: 2, Period: time.Minute}))
+			tr.Handle("/other", bodyHandler("ok"))
+
+			for i, addr := range c.addrs {
+				r := httptest.NewRequest(http.MethodPost, "/login", nil)
+				r.RemoteAddr = addr
+				w := httptest.NewRecorder()
+				tr.ServeHTTP(w, r)
+				if w.Code != c.wantCodes[i] {
+					t.Errorf("request %d: expected %v, got %v", i, c.wantCodes[i], w.Code)
+				}
+				if w.Code == 429 && w.Header().Get("Retry-After") == "" {
+					t.Errorf("expected Retry-After header")
+				}
+			}
+			if got := logs.String(); !strings.Contains(got, c.wantLog) || (c.wantLog == "" && got != "") {
+				t.Errorf("expected log %q, got %q", c.wantLog, got)
+			}
+		})
+	}
+}
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	s := NewMemoryRateLimitStore()
+	ctx := context.Background()
+	for i := int64(1); i <= 3; i++ {
+		if n, _ := s.Incr(ctx, "a", 10*time.Millisecond); n != i {
+			t.Errorf("expected %v, got %v", i, n)
+		}
+	}
+	time.Sleep(15 * time.Millisecond)
+	if n, _ := s.Incr(ctx, "a", 10*time.Millisecond); n != 1 {
+		t.Errorf("expected counter to have expired, got %v", n)
+	}
+}
+
+func TestWithRateLimit_invalid(t *testing.T) {
+	cases := []struct {
+		name string
+		rl   RateLimit
+	}{
+		{"zero period", RateLimit{Limit: 1}},
+		{"negative period", RateLimit{Limit: 1, Period: -time.Second}},
+		{"negative limit", RateLimit{Limit: -1, Period: time.Second}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic")
+				}
+			}()
+			WithRateLimit(c.rl)
+		})
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+// RecordedRequest is a sampled request, captured for replaying later.
+type RecordedRequest struct {
+	Time      time.Time
+	Pattern   string
+	Method    string
+	URL       string
+	Header    http.Header
+	Body      []byte
+	Truncated bool
+}
+
+// Request recreates the recorded request, with the body as far as it was
+// recorded.
+func (rr RecordedRequest) Request() *http.Request {
+	r := httptest.NewRequest(rr.Method, rr.URL, bytes.NewReader(rr.Body))
+	r.Header = rr.Header.Clone()
+	return r
+}
+
+// RecordSink receives recorded requests. It is called synchronously, before
+// the request is handled.
+type RecordSink interface {
+	Record(rr RecordedRequest)
+}
+
+// RecordSinkFunc is an adapter to allow the use of ordinary functions as a
+// RecordSink.
+type RecordSinkFunc func(rr RecordedRequest)
+
+func (f RecordSinkFunc) Record(rr RecordedRequest) {
+	f(rr)
+}
+
+type withRecording struct {
+	sink    RecordSink
+	rate    float64
+	maxBody int64
+}
+
+func (o withRecording) Apply(rt *route) {
+	rt.recording = &o
+}
+
+func (o withRecording) private() {}
+
+// WithRecording sends a sample (rate between 0 and 1) of the route's requests
+// to the sink. At most maxBody bytes of the body are recorded; the handler
+// still receives the complete body.
+func WithRecording(sink RecordSink, rate float64, maxBody int64) RouteOption {
+	return withRecording{sink: sink, rate: rate, maxBody: maxBody}
+}
+
+// record wraps the handler so that sampled requests are recorded.
+func record(h http.Handler, pattern string, o *withRecording, clock Clock) http.Handler {
+	if o == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if o.rate < 1 && rand.Float64() >= o.rate {
+			h.ServeHTTP(w, r)
+			return
+		}
+		rr := RecordedRequest{
+			Time:    clock.Now(),
+			Pattern: pattern,
+			Method:  r.Method,
+			URL:     r.URL.RequestURI(),
+			Header:  r.Header.Clone(),
+		}
+		if r.Body != nil && o.maxBody > 0 {
+			body, _ := ioutil.ReadAll(io.LimitReader(r.Body, o.maxBody+1))
+			if int64(len(body)) > o.maxBody {
+				rr.Truncated = true
+				rr.Body = body[:o.maxBody]
+			} else {
+				rr.Body = body
+			}
+			r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		}
+		o.sink.Record(rr)
+		h.ServeHTTP(w, r)
+	})
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// Replay sends the recorded requests to the handler and returns the recorded
+// responses, in the same order. Intended for reproducing issues in tests.
+func Replay(h http.Handler, rrs []RecordedRequest) []*httptest.ResponseRecorder {
+	ws := make([]*httptest.ResponseRecorder, len(rrs))
+	for i, rr := range rrs {
+		ws[i] = httptest.NewRecorder()
+		h.ServeHTTP(ws[i], rr.Request())
+	}
+	return ws
+}
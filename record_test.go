@@ -0,0 +1,62 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWithRecording(t *testing.T) {
+	var recorded []RecordedRequest
+	sink := RecordSinkFunc(func(rr RecordedRequest) {
+		recorded = append(recorded, rr)
+	})
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		bs, _ := ioutil.ReadAll(r.Body)
+		_, _ = w.Write([]byte(r.Method + " " + r.URL.RequestURI() + " " + r.Header.Get("X-Foo") + " " + string(bs)))
+	}
+
+	tr := NewTreeMux()
+	tr.HandleFunc("/items/*", echo, WithRecording(sink, 1, 5))
+	tr.HandleFunc("/other", echo)
+
+	reqs := []*http.Request{
+		httptest.NewRequest(http.MethodPost, "/items/1?q=2", strings.NewReader("hello world")),
+		httptest.NewRequest(http.MethodPut, "/items/2", strings.NewReader("hi")),
+		httptest.NewRequest(http.MethodGet, "/other", nil),
+	}
+	reqs[0].Header.Set("X-Foo", "bar")
+	var want []string
+	for _, r := range reqs {
+		w := httptest.NewRecorder()
+		tr.ServeHTTP(w, r)
+		want = append(want, w.Body.String())
+	}
+	if want[0] != "POST /items/1?q=2 bar hello world" {
+		t.Errorf("expected handler to receive complete request, got %q", want[0])
+	}
+
+	if len(recorded) != 2 {
+		t.Fatalf("expected 2 recorded requests, got %d", len(recorded))
+	}
+	if rr := recorded[0]; rr.Pattern != "/items/*" || string(rr.Body) != "hello" || !rr.Truncated {
+		t.Errorf("unexpected recording %+v", rr)
+	}
+	if rr := recorded[1]; string(rr.Body) != "hi" || rr.Truncated {
+		t.Errorf("unexpected recording %+v", rr)
+	}
+
+	ws := Replay(tr, recorded)
+	if got := ws[0].Body.String(); got != "POST /items/1?q=2 bar hello" {
+		t.Errorf("expected truncated replay, got %q", got)
+	}
+	if got := ws[1].Body.String(); got != want[1] {
+		t.Errorf("expected %q, got %q", want[1], got)
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+type optionRecovery struct {
+	value http.HandlerFunc
+}
+
+func (o optionRecovery) Apply(mux *treeMux) {
+	mux.recover = true
+	mux.recovery = o.value
+}
+
+func (o optionRecovery) private() {}
+
+// OptionRecovery recovers from panics in handlers. The panic is logged and the
+// given handler is used to respond, unless the handler had already started
+// writing a response. When handler is nil, a 500 response is sent.
+//
+// Panics with http.ErrAbortHandler are left alone.
+func OptionRecovery(handler http.HandlerFunc) Option {
+	return optionRecovery{handler}
+}
+
+// recovery wraps the handler so that panics are recovered from.
+func recovery(h http.Handler, pattern string, handler http.HandlerFunc, logf logFunc) http.Handler {
+	if handler == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := newResponseWriter(w)
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			logf(LogError, "recovered from panic", "pattern", pattern, "path", r.URL.Path, "panic", v, "stack", string(debug.Stack()))
+			if !rw.hasStarted() {
+				handler.ServeHTTP(rw, r)
+			}
+		}()
+		h.ServeHTTP(rw, r)
+	})
+}
+
+// PanicBudget configures how many panics a route may have before it is taken
+// out of service.
+type PanicBudget struct {
+	// Threshold is the number of panics within Window that disables a route.
+	Threshold int
+	// Window is the period panics are counted over. A disabled route is
+	// enabled again after the same period.
+	Window time.Duration
+	// Disabled handles requests for disabled routes. Defaults to a 503
+	// response.
+	Disabled http.Handler
+	// OnTrip is called when a route is disabled.
+	OnTrip func(pattern string)
+}
+
+type optionPanicBudget struct {
+	value PanicBudget
+}
+
+func (o optionPanicBudget) Apply(mux *treeMux) {
+	mux.panicBudget = &o.value
+}
+
+func (o optionPanicBudget) private() {}
+
+// OptionPanicBudget tracks panics per route. A route that exceeds the budget
+// is diverted to an error handler for a while, protecting the rest of the
+// service. Panics are passed on, so this is best combined with
+// OptionRecovery.
+func OptionPanicBudget(budget PanicBudget) Option {
+	return optionPanicBudget{budget}
+}
+
+// panicCounter keeps track of the panics of a single route.
+type panicCounter struct {
+	budget PanicBudget
+
+	mux           sync.Mutex
+	panics        []time.Time
+	disabledUntil time.Time
+}
+
+// disabled reports whether the route is currently out of service.
+func (c *panicCounter) disabled(now time.Time) bool {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return now.Before(c.disabledUntil)
+}
+
+// record registers a panic and reports whether it tripped the budget.
+func (c *panicCounter) record(now time.Time) bool {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	start := now.Add(-c.budget.Window)
+	i := 0
+	for i < len(c.panics) && !c.panics[i].After(start) {
+		i += 1
+	}
+	c.panics = append(c.panics[i:], now)
+	if len(c.panics) < c.budget.Threshold {
+		return false
+	}
+	c.panics = nil
+	c.disabledUntil = now.Add(c.budget.Window)
+	return true
+}
+
+// budget wraps the handler so that panics are counted against the budget.
+func budget(h http.Handler, pattern string, b *PanicBudget, clock Clock, fail errorFunc, logf logFunc) http.Handler {
+	if b == nil || b.Threshold <= 0 {
+		return h
+	}
+	c := &panicCounter{budget: *b}
+	disabled := b.Disabled
+	if disabled == nil {
+		disabled = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fail(w, r, http.StatusServiceUnavailable)
+		})
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.disabled(clock.Now()) {
+			disabled.ServeHTTP(w, r)
+			return
+		}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v != http.ErrAbortHandler && c.record(clock.Now()) {
+				logf(LogWarning, "disabled route after panics", "pattern", pattern, "panics", c.budget.Threshold)
+				if c.budget.OnTrip != nil {
+					c.budget.OnTrip(pattern)
+				}
+			}
+			panic(v)
+		}()
+		h.ServeHTTP(w, r)
+	})
+}
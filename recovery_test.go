@@ -0,0 +1,102 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestOptionRecovery(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	cases := []struct {
+		name     string
+		option   Option
+		path     string
+		wantCode int
+	}{
+		{"default", OptionRecovery(nil), "/panic", 500},
+		{"custom", OptionRecovery(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}), "/panic", 418},
+		{"already written", OptionRecovery(nil), "/late-panic", 202},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tr := NewTreeMux(c.option)
+			tr.HandleFunc("/panic", func(http.ResponseWriter, *http.Request) {
+				panic("oops")
+			})
+			tr.HandleFunc("/late-panic", func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				panic("oops")
+			})
+
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+			if w.Code != c.wantCode {
+				t.Errorf("expected %v, got %v", c.wantCode, w.Code)
+			}
+		})
+	}
+}
+
+func TestOptionPanicBudget(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	var tripped []string
+	tr := NewTreeMux(
+		OptionRecovery(nil),
+		OptionPanicBudget(PanicBudget{
+			Threshold: 2,
+			Window:    50 * time.Millisecond,
+			OnTrip: func(pattern string) {
+				tripped = append(tripped, pattern)
+			},
+		}),
+	)
+	tr.HandleFunc("/flaky", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("panic") != "" {
+			panic("oops")
+		}
+	})
+	tr.HandleFunc("/healthy", func(http.ResponseWriter, *http.Request) {})
+
+	steps := []struct {
+		path     string
+		wantCode int
+	}{
+		{"/flaky?panic=1", 500},
+		{"/flaky", 200},
+		{"/flaky?panic=1", 500},
+		{"/flaky", 503},
+		{"/healthy", 200},
+	}
+	for i, s := range steps {
+		w := httptest.NewRecorder()
+		tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, s.path, nil))
+		if w.Code != s.wantCode {
+			t.Errorf("step %d: expected %v, got %v", i, s.wantCode, w.Code)
+		}
+	}
+	if len(tripped) != 1 || tripped[0] != "/flaky" {
+		t.Errorf("expected a single trip for /flaky, got %v", tripped)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	w := httptest.NewRecorder()
+	tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/flaky", nil))
+	if w.Code != 200 {
+		t.Errorf("expected route to be enabled again, got %v", w.Code)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryAfterPolicy computes the Retry-After delay of a rejected request from
+// the delay suggested by the limiter: the time until a rate limit or quota
+// resets, or a second for shed requests.
+type RetryAfterPolicy func(suggested time.Duration) time.Duration
+
+// RetryAfterFixed always suggests the same delay.
+func RetryAfterFixed(d time.Duration) RetryAfterPolicy {
+	return func(time.Duration) time.Duration {
+		return d
+	}
+}
+
+// RetryAfterJitter adds a random delay of up to max to the suggested delay,
+// so clients rejected together do not all retry at the same moment.
+func RetryAfterJitter(max time.Duration) RetryAfterPolicy {
+	return func(suggested time.Duration) time.Duration {
+		if max <= 0 {
+			return suggested
+		}
+		return suggested + time.Duration(rand.Int63n(int64(max)))
+	}
+}
+
+// Rejection configures the responses for requests rejected by limits and
+// load shedding: rate limits and quotas (429), and admission control and
+// panic budgets (503).
+type Rejection struct {
+	// Renderer renders the responses, with content negotiation. Defaults to
+	// the mux's renderer for the path (see OptionErrorRenderer).
+	Renderer *ErrorRenderer
+	// RetryAfter computes the Retry-After header. Defaults to the delay
+	// suggested by the limiter.
+	RetryAfter RetryAfterPolicy
+}
+
+type withRejection struct {
+	value Rejection
+}
+
+func (o withRejection) Apply(rt *route) {
+	rt.rejection = &o.value
+}
+
+func (o withRejection) private() {}
+
+// WithRejection customises the responses of the route for requests that are
+// rejected by limits or shed.
+func WithRejection(rej Rejection) RouteOption {
+	return withRejection{rej}
+}
+
+// rejecter returns the function used by the route's limiters to write their
+// error responses.
+func (t *treeMux) rejecter(rt *route) errorFunc {
+	rej := rt.rejection
+	if rej == nil {
+		return t.writeError
+	}
+	return func(w http.ResponseWriter, r *http.Request, status int) {
+		if rej.RetryAfter != nil {
+			var suggested time.Duration
+			if s, err := strconv.Atoi(w.Header().Get("Retry-After")); err == nil {
+				suggested = time.Duration(s) * time.Second
+			}
+			d := rej.RetryAfter(suggested)
+			w.Header().Set("Retry-After", strconv.Itoa(int(d.Seconds()+.999)))
+		}
+		if rej.Renderer != nil {
+			rej.Renderer.Render(w, r, status)
+			return
+		}
+		t.writeError(w, r, status)
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRetryAfterJitter(t *testing.T) {
+	p := RetryAfterJitter(10 * time.Second)
+	for i := 0; i < 100; i++ {
+		if d := p(5 * time.Second); d < 5*time.Second || d >= 15*time.Second {
+			t.Fatalf("expected delay in [5s, 15s), got %s", d)
+		}
+	}
+}
+
+func TestWithRejection(t *testing.T) {
+	rl := RateLimit{Limit: 1, Period: time.Hour}
+	cases := []struct {
+		name        string
+		rejection   *Rejection
+		accept      string
+		contentType string
+		minRetry    int
+		maxRetry    int
+	}{
+		{"default", nil, "application/json", "text/plain; charset=utf-8", 1, 3600},
+		{"fixed", &Rejection{RetryAfter: RetryAfterFixed(30 * time.Second)}, "", "text/plain; charset=utf-8", 30, 30},
+		{"jitter", &Rejection{RetryAfter: RetryAfterJitter(time.Hour)}, "", "text/plain; charset=utf-8", 1, 7200},
+		{"renderer", &Rejection{Renderer: &ErrorRenderer{}}, "application/json", "application/problem+json", 1, 3600},
+		{"renderer html", &Rejection{Renderer: &ErrorRenderer{}}, "text/html", "text/plain; charset=utf-8", 1, 3600},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			options := []RouteOption{WithRateLimit(rl)}
+			if c.rejection != nil {
+				options = append(options, WithRejection(*c.rejection))
+			}
+			tr := NewTreeMux()
+			tr.HandleFunc("/foo", bodyHandler("foo"), options...)
+			var w *httptest.ResponseRecorder
+			for i := 0; i < 2; i++ {
+				r := httptest.NewRequest(http.MethodGet, "/foo", nil)
+				r.Header.Set("Accept", c.accept)
+				w = httptest.NewRecorder()
+				tr.ServeHTTP(w, r)
+			}
+			if w.Code != http.StatusTooManyRequests {
+				t.Fatalf("expected 429, got %d", w.Code)
+			}
+			if got := w.Header().Get("Content-Type"); got != c.contentType {
+				t.Errorf("expected %q, got %q", c.contentType, got)
+			}
+			retry, err := strconv.Atoi(w.Header().Get("Retry-After"))
+			if err != nil || retry < c.minRetry || retry > c.maxRetry {
+				t.Errorf("expected Retry-After in [%d, %d], got %q", c.minRetry, c.maxRetry, w.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
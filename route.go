@@ -0,0 +1,227 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Predicate reports whether a request satisfies some condition.
+type Predicate func(r *http.Request) bool
+
+// RouteOption configures a single route registration.
+type RouteOption interface {
+	Apply(rt *route)
+	private()
+}
+
+// route is a single registration: a handler plus the conditions under which
+// it applies.
+type route struct {
+	pattern    string
+	name       string
+	host       string
+	handler    http.Handler
+	predicates []Predicate
+	guards     []Predicate
+	verifiers  []RequestVerifier
+	timeout    time.Duration
+	hasTimeout bool
+	streaming  bool
+
+	watchdog    time.Duration
+	hasWatchdog bool
+	recording   *withRecording
+	slo         *SLO
+	priority    float64
+	rateLimit   *RateLimit
+
+	metadata           map[string]interface{}
+	logLevel           *LogLevel
+	logf               logFunc
+	logSampling        float64
+	hasLogSampling     bool
+	logRedaction       LogRedaction
+	shutdown           []ShutdownFunc
+	queryParams        []queryParam
+	form               *FormLimits
+	requestTransforms  []RequestTransform
+	responseTransforms []ResponseTransform
+	methods            []string
+	pathParams         []pathParam
+	sitemap            *SitemapEntry
+	earlyHints         []string
+	trailers           []string
+	quota              *Quota
+	rejection          *Rejection
+	middleware         []func(http.Handler) http.Handler
+	tarpit             *withTarpit
+	store              *Store
+	jobs               []Job
+	stopJobs           context.CancelFunc
+	responseHeaders    http.Header
+	clock              Clock
+
+	// serve is the handler with all route options applied.
+	serve http.Handler
+}
+
+// matches reports whether the route accepts the request method and all its
+// predicates hold for the request.
+func (rt *route) matches(r *http.Request) bool {
+	if !rt.allowsMethod(r.Method) {
+		return false
+	}
+	for _, p := range rt.predicates {
+		if !p(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// conditional reports whether the route only applies to some requests.
+func (rt *route) conditional() bool {
+	return len(rt.predicates) > 0 || len(rt.methods) > 0
+}
+
+// endpoint holds all routes registered for a single pattern.
+type endpoint struct {
+	pattern string
+	// host is set for the endpoints of a host (see HandleHost).
+	host   string
+	routes []*route
+}
+
+// add registers a route with the endpoint. Conditional routes are evaluated in
+// registration order, before the (single) unconditional route. Adding an
+// unconditional route replaces the previous one, which is returned.
+func (e *endpoint) add(rt *route) *route {
+	if rt.conditional() {
+		i := 0
+		for i < len(e.routes) && e.routes[i].conditional() {
+			i += 1
+		}
+		e.routes = append(e.routes[:i], append([]*route{rt}, e.routes[i:]...)...)
+		return nil
+	}
+	for i := range e.routes {
+		if !e.routes[i].conditional() {
+			old := e.routes[i]
+			e.routes[i] = rt
+			return old
+		}
+	}
+	e.routes = append(e.routes, rt)
+	return nil
+}
+
+// lookup returns the first route that matches the request, or nil.
+func (e *endpoint) lookup(r *http.Request) *route {
+	for _, rt := range e.routes {
+		if rt.matches(r) {
+			return rt
+		}
+	}
+	return nil
+}
+
+// normalisePattern returns the pattern with exactly one leading slash.
+func normalisePattern(path string) string {
+	return "/" + strings.TrimPrefix(path, "/")
+}
+
+type withPredicate struct {
+	value Predicate
+}
+
+func (o withPredicate) Apply(rt *route) {
+	rt.predicates = append(rt.predicates, o.value)
+}
+
+func (o withPredicate) private() {}
+
+// WithPredicate makes the route only match requests that satisfy the
+// predicate. Requests that do not, are passed on to other routes registered for
+// the same pattern, or are not found if there are none.
+func WithPredicate(p Predicate) RouteOption {
+	return withPredicate{p}
+}
+
+type withGuard struct {
+	value Predicate
+}
+
+func (o withGuard) Apply(rt *route) {
+	rt.guards = append(rt.guards, o.value)
+}
+
+func (o withGuard) private() {}
+
+// WithGuard rejects matched requests that do not satisfy the predicate with the
+// mux's forbidden handler (see OptionForbidden).
+func WithGuard(p Predicate) RouteOption {
+	return withGuard{p}
+}
+
+// compose applies the route options to the route's handler, innermost
+// wrapper first.
+func (t *treeMux) compose(rt *route) http.Handler {
+	reject := t.rejecter(rt)
+	h := applyMiddleware(rt.handler, rt.middleware)
+	h = declareTrailers(h, rt.trailers)
+	h = transformRequest(h, rt.requestTransforms)
+	h = transformResponse(h, rt.responseTransforms)
+	h = inject(h, rt.pattern, t.chaos, t.clock)
+	h = guard(h, rt.guards, t.forbidden)
+	h = evaluatePolicy(h, rt, t.policy, t.forbidden, t.writeError)
+	h = verify(h, rt.verifiers, t.writeError)
+	h = parseForm(h, rt.form, t.writeError)
+	h = validateQuery(h, rt.queryParams, t.writeError)
+	h = enforceQuota(h, rt.pattern, rt.quota, t.quotaStore, t.clock, reject, rt.logf)
+	h = rateLimit(h, rt.pattern, rt.rateLimit, t.rateLimitStore, t.clock, reject, rt.logf)
+	h = record(h, rt.pattern, rt.recording, t.clock)
+	h = budget(h, rt.pattern, t.panicBudget, t.clock, reject, rt.logf)
+	h = tracePanics(h, rt.pattern)
+	h = recovery(h, rt.pattern, t.recovery, rt.logf)
+	h = limit(h, t.routeTimeout(rt), t.clock)
+	h = earlyHints(h, rt.earlyHints)
+	h = watch(h, rt.pattern, t.routeWatchdog(rt), t.watchdogStack, t.clock, rt.logf)
+	if rt.slo != nil && t.sloTracker != nil {
+		h = trackSLO(h, t.sloTracker.register(rt.pattern, *rt.slo), t.clock)
+	}
+	h = observeLatency(h, rt.pattern, t.latency, t.clock)
+	h = admit(h, rt.pattern, rt.priority, t.admission, reject)
+	h = tarpit(h, rt.tarpit, t.clock, t.stopping)
+	h = observeClientGone(h, rt.pattern, t.onClientGone, t.clock)
+	h = meterUsage(h, rt.pattern, t.usage)
+	if t.accessLog {
+		h = t.accessLogger(h, rt)
+	}
+	h = setResponseHeaders(h, rt.responseHeaders)
+	h = recordParams(h, rt.pattern, t.paramStats)
+	h = capturePathParams(h, rt.pathParams)
+	h = withRoute(h, rt)
+	return h
+}
+
+// guard wraps the handler so that it is only called when all guards pass.
+func guard(h http.Handler, guards []Predicate, forbidden http.Handler) http.Handler {
+	if len(guards) == 0 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, g := range guards {
+			if !g(r) {
+				forbidden.ServeHTTP(w, r)
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,65 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func bodyHandler(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}
+}
+
+func hasHeader(key string) Predicate {
+	return func(r *http.Request) bool {
+		return r.Header.Get(key) != ""
+	}
+}
+
+func TestTreeMux_HandleRouteOptions(t *testing.T) {
+	cases := []struct {
+		name     string
+		path     string
+		header   string
+		wantCode int
+		wantBody string
+	}{
+		{"unconditional", "/foo", "", 200, "foo"},
+		{"first predicate", "/foo", "X-A", 200, "foo-a"},
+		{"second predicate", "/foo", "X-B", 200, "foo-b"},
+		{"predicate without fallback", "/bar", "", 404, "404 page not found\n"},
+		{"predicate on fallback-less route", "/bar", "X-A", 200, "bar-a"},
+		{"guard passes", "/guarded", "X-A", 200, "guarded"},
+		{"guard rejects", "/guarded", "", 403, "403 forbidden\n"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tr := NewTreeMux()
+			tr.Handle("/foo", bodyHandler("foo-a"), WithPredicate(hasHeader("X-A")))
+			tr.Handle("/foo", bodyHandler("unused"))
+			tr.Handle("/foo", bodyHandler("foo"))
+			tr.Handle("/foo", bodyHandler("foo-b"), WithPredicate(hasHeader("X-B")))
+			tr.Handle("/bar", bodyHandler("bar-a"), WithPredicate(hasHeader("X-A")))
+			tr.Handle("/guarded", bodyHandler("guarded"), WithGuard(hasHeader("X-A")))
+
+			r := httptest.NewRequest(http.MethodGet, c.path, nil)
+			if c.header != "" {
+				r.Header.Set(c.header, "1")
+			}
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, r)
+			if w.Code != c.wantCode {
+				t.Errorf("expected %v, got %v", c.wantCode, w.Code)
+			}
+			if w.Body.String() != c.wantBody {
+				t.Errorf("expected %q, got %q", c.wantBody, w.Body.String())
+			}
+		})
+	}
+}
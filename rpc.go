@@ -0,0 +1,46 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Metadata keys set on routes registered with MountService.
+const (
+	MetadataRPCService = "rpc.service"
+	MetadataRPCMethod  = "rpc.method"
+)
+
+func isPost(r *http.Request) bool {
+	return r.Method == http.MethodPost
+}
+
+func (t *treeMux) MountService(path string, handler http.Handler, procedures []string, options ...RouteOption) {
+	prefix := "/" + strings.Trim(path, "/")
+	if len(procedures) == 0 {
+		t.handlePrefix(prefix, handler, append([]RouteOption{WithPredicate(isPost)}, options...)...)
+		return
+	}
+
+	service := prefix[strings.LastIndex(prefix, "/")+1:]
+	notAllowed := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", http.MethodPost)
+		t.writeError(w, r, http.StatusMethodNotAllowed)
+	})
+	for _, p := range procedures {
+		if !strings.HasPrefix(p, "/") {
+			p = prefix + "/" + p
+		}
+		method := p[strings.LastIndex(p, "/")+1:]
+		common := append([]RouteOption{
+			WithMetadata(MetadataRPCService, service),
+			WithMetadata(MetadataRPCMethod, method),
+		}, options...)
+		t.Handle(p, handler, append(common, WithPredicate(isPost))...)
+		t.Handle(p, notAllowed, common...)
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTreeMux_MountService(t *testing.T) {
+	svc := func(w http.ResponseWriter, r *http.Request) {
+		s, _ := RouteMetadata(r, MetadataRPCService)
+		m, _ := RouteMetadata(r, MetadataRPCMethod)
+		_, _ = fmt.Fprintf(w, "%s %v %v", r.URL.Path, s, m)
+	}
+	tr := NewTreeMux()
+	tr.MountService("/acme.foo.v1.FooService/", http.HandlerFunc(svc), []string{"GetFoo", "/acme.foo.v1.FooService/ListFoos"})
+	tr.MountService("/twirp/acme.bar.v1.BarService", http.HandlerFunc(svc), nil)
+
+	cases := []struct {
+		name       string
+		method     string
+		target     string
+		wantStatus int
+		wantBody   string
+		wantAllow  string
+	}{
+		{"by name", http.MethodPost, "/acme.foo.v1.FooService/GetFoo", 200, "/acme.foo.v1.FooService/GetFoo acme.foo.v1.FooService GetFoo", ""},
+		{"by path", http.MethodPost, "/acme.foo.v1.FooService/ListFoos", 200, "/acme.foo.v1.FooService/ListFoos acme.foo.v1.FooService ListFoos", ""},
+		{"wrong method", http.MethodGet, "/acme.foo.v1.FooService/GetFoo", 405, "405 method not allowed\n", "POST"},
+		{"unknown procedure", http.MethodPost, "/acme.foo.v1.FooService/DeleteFoo", 404, "404 page not found\n", ""},
+		{"prefix", http.MethodPost, "/twirp/acme.bar.v1.BarService/GetBar", 200, "/twirp/acme.bar.v1.BarService/GetBar <nil> <nil>", ""},
+		{"prefix wrong method", http.MethodGet, "/twirp/acme.bar.v1.BarService/GetBar", 404, "404 page not found\n", ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, httptest.NewRequest(c.method, c.target, nil))
+			if w.Code != c.wantStatus {
+				t.Errorf("expected status %d, got %d", c.wantStatus, w.Code)
+			}
+			if got := w.Body.String(); got != c.wantBody {
+				t.Errorf("expected %q, got %q", c.wantBody, got)
+			}
+			if got := w.Header().Get("Allow"); got != c.wantAllow {
+				t.Errorf("expected Allow %q, got %q", c.wantAllow, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd.
+const listenFDsStart = 3
+
+// SystemdListeners returns the listeners passed by systemd socket activation,
+// in the order of the socket unit, or nil if there are none. As the sockets
+// outlive the process, the service can be restarted without refusing
+// connections. The environment variables used are unset, so child processes
+// do not inherit them.
+func SystemdListeners() ([]net.Listener, error) {
+	defer func() {
+		_ = os.Unsetenv("LISTEN_PID")
+		_ = os.Unsetenv("LISTEN_FDS")
+		_ = os.Unsetenv("LISTEN_FDNAMES")
+	}()
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	ls := make([]net.Listener, 0, n)
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("LISTEN_FD_%d", listenFDsStart+i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		f := os.NewFile(uintptr(listenFDsStart+i), name)
+		l, err := net.FileListener(f)
+		_ = f.Close()
+		if err != nil {
+			for _, l := range ls {
+				_ = l.Close()
+			}
+			return nil, fmt.Errorf("could not use file descriptor %d (%s): %w", listenFDsStart+i, name, err)
+		}
+		ls = append(ls, l)
+	}
+	return ls, nil
+}
+
+type defaultHostListener struct {
+	net.Listener
+	host string
+}
+
+type defaultHostConn struct {
+	net.Conn
+	host string
+}
+
+func (l defaultHostListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return defaultHostConn{Conn: c, host: l.host}, nil
+}
+
+// WithDefaultHost wraps the listener so that requests on it without a host
+// (i.e. HTTP/1.0 requests) get the given host when served with Serve. This
+// lets host predicates work for such requests.
+func WithDefaultHost(l net.Listener, host string) net.Listener {
+	return defaultHostListener{Listener: l, host: host}
+}
+
+type defaultHostKey struct{}
+
+// Serve serves the mux on all listeners, until one of them fails. It then
+// closes the other listeners and returns the error. After Shutdown, it
+// returns http.ErrServerClosed.
+//
+//	ls, err := treemux.SystemdListeners()
+//	...
+//	err = t.Serve(ls...)
+func (t *treeMux) Serve(listeners ...net.Listener) error {
+	if len(listeners) == 0 {
+		return errors.New("no listeners")
+	}
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Host == "" {
+				if host, ok := r.Context().Value(defaultHostKey{}).(string); ok {
+					r.Host = host
+				}
+			}
+			t.ServeHTTP(w, r)
+		}),
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			if dc, ok := c.(defaultHostConn); ok {
+				return context.WithValue(ctx, defaultHostKey{}, dc.host)
+			}
+			return ctx
+		},
+	}
+
+	t.serversMux.Lock()
+	t.servers = append(t.servers, srv)
+	t.serversMux.Unlock()
+	defer func() {
+		t.serversMux.Lock()
+		defer t.serversMux.Unlock()
+		for i := range t.servers {
+			if t.servers[i] == srv {
+				t.servers = append(t.servers[:i], t.servers[i+1:]...)
+				break
+			}
+		}
+	}()
+
+	errc := make(chan error, len(listeners))
+	wg := &sync.WaitGroup{}
+	for _, l := range listeners {
+		wg.Add(1)
+		go func(l net.Listener) {
+			defer wg.Done()
+			errc <- srv.Serve(l)
+		}(l)
+	}
+	err := <-errc
+	_ = srv.Close()
+	wg.Wait()
+	return err
+}
@@ -0,0 +1,109 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestSystemdListeners(t *testing.T) {
+	cases := []struct {
+		name string
+		pid  string
+		fds  string
+	}{
+		{"not activated", "", ""},
+		{"other process", strconv.Itoa(os.Getpid() + 1), "1"},
+		{"no fds", strconv.Itoa(os.Getpid()), "0"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Setenv("LISTEN_PID", c.pid)
+			t.Setenv("LISTEN_FDS", c.fds)
+			ls, err := SystemdListeners()
+			if err != nil || ls != nil {
+				t.Errorf("expected no listeners, got %v, %v", ls, err)
+			}
+			if v, ok := os.LookupEnv("LISTEN_FDS"); ok {
+				t.Errorf("expected LISTEN_FDS to be unset, got %q", v)
+			}
+		})
+	}
+}
+
+func TestTreeMux_Serve(t *testing.T) {
+	tr := NewTreeMux()
+	tr.HandleFunc("/host", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Host))
+	})
+
+	plain, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	other, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	done := make(chan error)
+	go func() {
+		done <- tr.Serve(plain, WithDefaultHost(other, "admin.internal"))
+	}()
+
+	cases := []struct {
+		name string
+		addr string
+		host string
+		want string
+	}{
+		{"with host", plain.Addr().String(), "example.com", "example.com"},
+		{"default host", other.Addr().String(), "", "admin.internal"},
+		{"default host overridden", other.Addr().String(), "example.com", "example.com"},
+		{"no default", plain.Addr().String(), "", ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			conn, err := net.Dial("tcp", c.addr)
+			if err != nil {
+				t.Fatalf("could not connect: %v", err)
+			}
+			defer conn.Close()
+			if c.host == "" {
+				_, _ = fmt.Fprint(conn, "GET /host HTTP/1.0\r\n\r\n")
+			} else {
+				_, _ = fmt.Fprintf(conn, "GET /host HTTP/1.0\r\nHost: %s\r\n\r\n", c.host)
+			}
+			resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+			if err != nil {
+				t.Fatalf("could not read response: %v", err)
+			}
+			b, _ := ioutil.ReadAll(resp.Body)
+			if got := string(b); got != c.want {
+				t.Errorf("expected %q, got %q", c.want, got)
+			}
+		})
+	}
+
+	_ = plain.Close()
+	if err := <-done; err == nil {
+		t.Errorf("expected error")
+	}
+	if _, err := net.Dial("tcp", other.Addr().String()); err == nil {
+		t.Errorf("expected other listener to be closed")
+	}
+}
+
+func TestTreeMux_Serve_NoListeners(t *testing.T) {
+	if err := NewTreeMux().Serve(); err == nil {
+		t.Errorf("expected error")
+	}
+}
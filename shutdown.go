@@ -0,0 +1,121 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// ShutdownFunc cleans up the resources of a route when the mux is shut down.
+type ShutdownFunc func(ctx context.Context) error
+
+type withShutdown struct {
+	value ShutdownFunc
+}
+
+func (o withShutdown) Apply(rt *route) {
+	rt.shutdown = append(rt.shutdown, o.value)
+}
+
+func (o withShutdown) private() {}
+
+// WithShutdown registers a function that is called by Shutdown, once all
+// requests have been served.
+func WithShutdown(fn ShutdownFunc) RouteOption {
+	return withShutdown{fn}
+}
+
+// inFlight counts the requests being served.
+type inFlight struct {
+	mux  sync.Mutex
+	n    int
+	idle chan struct{}
+}
+
+func (f *inFlight) add(delta int) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.n += delta
+	if f.n == 0 && f.idle != nil {
+		close(f.idle)
+		f.idle = nil
+	}
+}
+
+// wait blocks until no requests are being served, or the context is done.
+func (f *inFlight) wait(ctx context.Context) error {
+	f.mux.Lock()
+	if f.n == 0 {
+		f.mux.Unlock()
+		return nil
+	}
+	if f.idle == nil {
+		f.idle = make(chan struct{})
+	}
+	idle := f.idle
+	f.mux.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *treeMux) isDraining() bool {
+	return atomic.LoadInt32(&t.draining) == 1
+}
+
+// drained rejects the request if the mux is shutting down.
+func (t *treeMux) drained(w http.ResponseWriter, r *http.Request) bool {
+	if !t.isDraining() {
+		return false
+	}
+	w.Header().Set("Connection", "close")
+	t.writeError(w, r, http.StatusServiceUnavailable)
+	return true
+}
+
+func (t *treeMux) Shutdown(ctx context.Context) error {
+	if atomic.CompareAndSwapInt32(&t.draining, 0, 1) {
+		close(t.stopping)
+	}
+	if err := t.inFlight.wait(ctx); err != nil {
+		return err
+	}
+
+	var first error
+	for _, rt := range t.routes() {
+		for _, fn := range rt.shutdown {
+			if err := fn(ctx); err != nil && first == nil {
+				first = err
+			}
+		}
+		t.removed(rt)
+	}
+	if err := t.jobs.wait(ctx); err != nil && first == nil {
+		first = err
+	}
+	if t.usage != nil {
+		if err := t.usage.flush(ctx); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	t.serversMux.Lock()
+	servers := t.servers
+	t.servers = nil
+	t.serversMux.Unlock()
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,113 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTreeMux_Shutdown(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var calls []string
+	tr := NewTreeMux()
+	tr.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		calls = append(calls, "handler")
+	}, WithShutdown(func(ctx context.Context) error {
+		calls = append(calls, "cleanup")
+		return nil
+	}))
+	tr.HandleFunc("/fast", bodyHandler("fast"), WithShutdown(func(ctx context.Context) error {
+		return errors.New("oops")
+	}))
+
+	go tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+	<-started
+
+	done := make(chan error)
+	go func() {
+		done <- tr.Shutdown(context.Background())
+	}()
+	for !tr.(*treeMux).isDraining() {
+		time.Sleep(time.Millisecond)
+	}
+
+	w := httptest.NewRecorder()
+	tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+	if got := w.Header().Get("Connection"); got != "close" {
+		t.Errorf("expected Connection close, got %q", got)
+	}
+
+	select {
+	case <-done:
+		t.Fatalf("expected shutdown to wait for in-flight request")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(release)
+	if err := <-done; err == nil || err.Error() != "oops" {
+		t.Errorf("expected cleanup error, got %v", err)
+	}
+	if len(calls) != 2 || calls[0] != "handler" || calls[1] != "cleanup" {
+		t.Errorf("expected handler and cleanup, got %v", calls)
+	}
+}
+
+func TestTreeMux_Shutdown_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	tr := NewTreeMux()
+	tr.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+	go tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := tr.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestTreeMux_Shutdown_Serve(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	tr := NewTreeMux()
+	done := make(chan error)
+	go func() {
+		done <- tr.Serve(l)
+	}()
+	for {
+		tr.(*treeMux).serversMux.Lock()
+		n := len(tr.(*treeMux).servers)
+		tr.(*treeMux).serversMux.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := tr.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := <-done; err != http.ErrServerClosed {
+		t.Errorf("expected %v, got %v", http.ErrServerClosed, err)
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RequestVerifier checks the authenticity of a request before it is
+// dispatched.
+type RequestVerifier interface {
+	Verify(r *http.Request) error
+}
+
+// RequestVerifierFunc is an adapter to allow the use of ordinary functions as
+// a RequestVerifier.
+type RequestVerifierFunc func(r *http.Request) error
+
+func (f RequestVerifierFunc) Verify(r *http.Request) error {
+	return f(r)
+}
+
+var (
+	ErrMissingSignature = errors.New("missing signature")
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrSignatureExpired = errors.New("signature timestamp outside of allowed skew")
+)
+
+const defaultMaxSignedBody = 1 << 20
+
+// HMACVerifier verifies requests signed with an HMAC over the request body.
+//
+// When TimestampHeader is set, the signed message is the timestamp (in unix
+// seconds), a dot and the body. Otherwise, it is just the body.
+type HMACVerifier struct {
+	// SignatureHeader is the header holding the signature.
+	SignatureHeader string
+	// Prefix is stripped from the signature header value (i.e. "sha256=").
+	Prefix string
+	// Base64 indicates the signature is base64 instead of hex encoded.
+	Base64 bool
+	// Hash is the hash function to use, defaults to SHA-256.
+	Hash func() hash.Hash
+	// KeyIDHeader optionally holds the identifier of the signing key.
+	KeyIDHeader string
+	// Key looks up the secret for the given key identifier.
+	Key func(r *http.Request, keyID string) ([]byte, error)
+	// TimestampHeader optionally holds the signing time in unix seconds.
+	TimestampHeader string
+	// MaxSkew is the maximum allowed difference between the signing time and
+	// the current time. Defaults to five minutes.
+	MaxSkew time.Duration
+	// MaxBodyBytes limits the size of the body that is read for verification.
+	// Defaults to 1 MiB.
+	MaxBodyBytes int64
+}
+
+// Verify reads the request body, checks the signature and restores the body
+// so it can be read again by the handler.
+func (v HMACVerifier) Verify(r *http.Request) error {
+	raw := strings.TrimPrefix(r.Header.Get(v.SignatureHeader), v.Prefix)
+	if raw == "" {
+		return ErrMissingSignature
+	}
+	var sig []byte
+	var err error
+	if v.Base64 {
+		sig, err = base64.StdEncoding.DecodeString(raw)
+	} else {
+		sig, err = hex.DecodeString(raw)
+	}
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	var ts string
+	if v.TimestampHeader != "" {
+		ts = r.Header.Get(v.TimestampHeader)
+		if err := v.checkTimestamp(ts, requestClock(r).Now()); err != nil {
+			return err
+		}
+	}
+
+	var keyID string
+	if v.KeyIDHeader != "" {
+		keyID = r.Header.Get(v.KeyIDHeader)
+	}
+	key, err := v.Key(r, keyID)
+	if err != nil {
+		return fmt.Errorf("could not get signing key: %w", err)
+	}
+
+	body, err := readBody(r, v.maxBodyBytes())
+	if err != nil {
+		return err
+	}
+
+	h := v.hash()
+	mac := hmac.New(h, key)
+	if ts != "" {
+		mac.Write([]byte(ts + "."))
+	}
+	mac.Write(body)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (v HMACVerifier) checkTimestamp(ts string, now time.Time) error {
+	secs, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrMissingSignature
+	}
+	skew := v.MaxSkew
+	if skew == 0 {
+		skew = 5 * time.Minute
+	}
+	d := now.Sub(time.Unix(secs, 0))
+	if d > skew || d < -skew {
+		return ErrSignatureExpired
+	}
+	return nil
+}
+
+func (v HMACVerifier) hash() func() hash.Hash {
+	if v.Hash == nil {
+		return sha256.New
+	}
+	return v.Hash
+}
+
+func (v HMACVerifier) maxBodyBytes() int64 {
+	if v.MaxBodyBytes == 0 {
+		return defaultMaxSignedBody
+	}
+	return v.MaxBodyBytes
+}
+
+// readBody reads up to max bytes of the request body and replaces it with an
+// in-memory copy.
+func readBody(r *http.Request, max int64) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, max+1))
+	_ = r.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("could not read body: %w", err)
+	}
+	if int64(len(body)) > max {
+		return nil, fmt.Errorf("%w: exceeds %d bytes", errBodyTooLarge, max)
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+type withVerifier struct {
+	value RequestVerifier
+}
+
+func (o withVerifier) Apply(rt *route) {
+	rt.verifiers = append(rt.verifiers, o.value)
+}
+
+func (o withVerifier) private() {}
+
+// WithVerifier rejects matched requests that fail verification with a 401
+// response.
+func WithVerifier(v RequestVerifier) RouteOption {
+	return withVerifier{v}
+}
+
+// verify wraps the handler so that it is only called for verified requests.
+func verify(h http.Handler, verifiers []RequestVerifier, fail errorFunc) http.Handler {
+	if len(verifiers) == 0 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, v := range verifiers {
+			if err := v.Verify(r); err != nil {
+				fail(w, r, http.StatusUnauthorized)
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,127 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sign(key, msg string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(msg))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestHMACVerifier_Verify(t *testing.T) {
+	keys := func(_ *http.Request, id string) ([]byte, error) {
+		if id == "old" {
+			return []byte("old-secret"), nil
+		}
+		return []byte("secret"), nil
+	}
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+
+	cases := []struct {
+		name     string
+		verifier HMACVerifier
+		headers  map[string]string
+		body     string
+		want     error
+	}{
+		{
+			"valid",
+			HMACVerifier{SignatureHeader: "X-Sig", Key: keys},
+			map[string]string{"X-Sig": sign("secret", "hello")},
+			"hello",
+			nil,
+		},
+		{
+			"missing",
+			HMACVerifier{SignatureHeader: "X-Sig", Key: keys},
+			nil,
+			"hello",
+			ErrMissingSignature,
+		},
+		{
+			"tampered body",
+			HMACVerifier{SignatureHeader: "X-Sig", Key: keys},
+			map[string]string{"X-Sig": sign("secret", "hello")},
+			"hellO",
+			ErrInvalidSignature,
+		},
+		{
+			"prefix and key id",
+			HMACVerifier{SignatureHeader: "X-Sig", Prefix: "sha256=", KeyIDHeader: "X-Key", Key: keys},
+			map[string]string{"X-Sig": "sha256=" + sign("old-secret", "hello"), "X-Key": "old"},
+			"hello",
+			nil,
+		},
+		{
+			"timestamp",
+			HMACVerifier{SignatureHeader: "X-Sig", TimestampHeader: "X-Ts", Key: keys},
+			map[string]string{"X-Sig": sign("secret", now+".hello"), "X-Ts": now},
+			"hello",
+			nil,
+		},
+		{
+			"stale timestamp",
+			HMACVerifier{SignatureHeader: "X-Sig", TimestampHeader: "X-Ts", Key: keys},
+			map[string]string{"X-Sig": sign("secret", stale+".hello"), "X-Ts": stale},
+			"hello",
+			ErrSignatureExpired,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(c.body))
+			for k, v := range c.headers {
+				r.Header.Set(k, v)
+			}
+			if err := c.verifier.Verify(r); err != c.want {
+				t.Errorf("expected %v, got %v", c.want, err)
+			}
+			if bs, _ := ioutil.ReadAll(r.Body); c.want == nil && string(bs) != c.body {
+				t.Errorf("expected body %q to be restored, got %q", c.body, string(bs))
+			}
+		})
+	}
+}
+
+func TestWithVerifier(t *testing.T) {
+	v := HMACVerifier{
+		SignatureHeader: "X-Sig",
+		Key: func(*http.Request, string) ([]byte, error) {
+			return []byte("secret"), nil
+		},
+	}
+	tr := NewTreeMux()
+	tr.Handle("/hook", bodyHandler("ok"), WithVerifier(v))
+
+	for _, c := range []struct {
+		sig      string
+		wantCode int
+	}{
+		{sign("secret", "payload"), 200},
+		{sign("wrong", "payload"), 401},
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/hook", strings.NewReader("payload"))
+		r.Header.Set("X-Sig", c.sig)
+		w := httptest.NewRecorder()
+		tr.ServeHTTP(w, r)
+		if w.Code != c.wantCode {
+			t.Errorf("expected %v, got %v", c.wantCode, w.Code)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SitemapEntry configures how a route appears in the sitemap (see Sitemap).
+type SitemapEntry struct {
+	// Exclude leaves the route out of the sitemap.
+	Exclude bool
+	// LastModified is the time the resources of the route last changed.
+	LastModified time.Time
+	// ChangeFrequency is how often the resources change, i.e. "daily".
+	ChangeFrequency string
+	// Priority is the priority of the resources relative to other URLs of
+	// the site, between 0 and 1. Zero leaves it unspecified.
+	Priority float64
+}
+
+type withSitemap struct {
+	value SitemapEntry
+}
+
+func (o withSitemap) Apply(rt *route) {
+	rt.sitemap = &o.value
+}
+
+func (o withSitemap) private() {}
+
+// WithSitemap sets the sitemap metadata of the route, or excludes it.
+func WithSitemap(entry SitemapEntry) RouteOption {
+	return withSitemap{entry}
+}
+
+// Sitemap configures the generation of a sitemap (see sitemaps.org) from the
+// routes of a mux. All routes that accept GET requests are included, except
+// for mounted handlers.
+type Sitemap struct {
+	// BaseURL is prepended to the paths, i.e. "https://example.com".
+	BaseURL string
+	// Values returns the wildcard values of all resources of a route, one
+	// slice per resource, in the order of the wildcards in the pattern.
+	// Routes with wildcards are left out when it is nil or returns nil.
+	Values func(pattern string) ([][]string, error)
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc             string `xml:"loc"`
+	LastModified    string `xml:"lastmod,omitempty"`
+	ChangeFrequency string `xml:"changefreq,omitempty"`
+	Priority        string `xml:"priority,omitempty"`
+}
+
+func (t *treeMux) WriteSitemap(w io.Writer, cfg Sitemap) error {
+	t.routesMux.RLock()
+	defer t.routesMux.RUnlock()
+	patterns := make([]string, 0, len(t.endpoints))
+	for p := range t.endpoints {
+		patterns = append(patterns, p)
+	}
+	sort.Strings(patterns)
+
+	set := sitemapURLSet{XMLNS: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+	seen := map[string]bool{}
+	for _, p := range patterns {
+		for _, rt := range t.endpoints[p].routes {
+			if !rt.allowsMethod(http.MethodGet) || rt.sitemap != nil && rt.sitemap.Exclude {
+				continue
+			}
+			paths, err := sitemapPaths(p, cfg.Values)
+			if err != nil {
+				return err
+			}
+			for _, path := range paths {
+				if seen[path] {
+					continue
+				}
+				seen[path] = true
+				set.URLs = append(set.URLs, newSitemapURL(strings.TrimSuffix(cfg.BaseURL, "/")+path, rt.sitemap))
+			}
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(set); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// sitemapPaths returns the paths of the resources of the pattern.
+func sitemapPaths(pattern string, values func(string) ([][]string, error)) ([]string, error) {
+	if !strings.Contains(pattern, wildcard) {
+		return []string{pattern}, nil
+	}
+	if values == nil {
+		return nil, nil
+	}
+	vss, err := values(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("could not get sitemap values for '%s': %w", pattern, err)
+	}
+	paths := make([]string, 0, len(vss))
+	for _, vs := range vss {
+		p, err := expand(pattern, vs)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, p)
+	}
+	return paths, nil
+}
+
+func newSitemapURL(loc string, entry *SitemapEntry) sitemapURL {
+	u := sitemapURL{Loc: loc}
+	if entry == nil {
+		return u
+	}
+	if !entry.LastModified.IsZero() {
+		u.LastModified = entry.LastModified.UTC().Format(time.RFC3339)
+	}
+	u.ChangeFrequency = entry.ChangeFrequency
+	if entry.Priority > 0 {
+		u.Priority = strconv.FormatFloat(entry.Priority, 'f', -1, 64)
+	}
+	return u
+}
+
+func (t *treeMux) HandleSitemap(path string, cfg Sitemap, options ...RouteOption) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b := &strings.Builder{}
+		if err := t.WriteSitemap(b, cfg); err != nil {
+			if rt := routeFromContext(r); rt != nil {
+				rt.logf(LogError, "could not generate sitemap", "pattern", rt.pattern, "error", err)
+			}
+			t.writeError(w, r, http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		_, _ = io.WriteString(w, b.String())
+	})
+	options = append([]RouteOption{WithSitemap(SitemapEntry{Exclude: true})}, options...)
+	t.Handle(path, h, options...)
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTreeMux_WriteSitemap(t *testing.T) {
+	tr := NewTreeMux()
+	tr.HandleFunc("/home", bodyHandler("home"), WithSitemap(SitemapEntry{Priority: 1, ChangeFrequency: "daily"}))
+	tr.HandleFunc("/about", bodyHandler("about"), WithSitemap(SitemapEntry{LastModified: time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)}))
+	tr.HandleFunc("/admin", bodyHandler("admin"), WithSitemap(SitemapEntry{Exclude: true}))
+	tr.HandleFunc("/items", bodyHandler("items"), WithMethods(http.MethodPost))
+	tr.HandleFunc("/countries/:country", bodyHandler("country"), WithSitemap(SitemapEntry{Priority: .5}))
+	tr.HandleFunc("/users/*", bodyHandler("user"))
+	tr.Mount("/static", bodyHandler("static"))
+
+	cfg := Sitemap{
+		BaseURL: "https://example.com/",
+		Values: func(pattern string) ([][]string, error) {
+			if pattern == "/countries/*" {
+				return [][]string{{"belgium"}, {"côte d'ivoire"}}, nil
+			}
+			return nil, nil
+		},
+	}
+	buf := &bytes.Buffer{}
+	if err := tr.WriteSitemap(buf, cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url>
+    <loc>https://example.com/about</loc>
+    <lastmod>2022-03-01T12:00:00Z</lastmod>
+  </url>
+  <url>
+    <loc>https://example.com/countries/belgium</loc>
+    <priority>0.5</priority>
+  </url>
+  <url>
+    <loc>https://example.com/countries/c%C3%B4te%20d%27ivoire</loc>
+    <priority>0.5</priority>
+  </url>
+  <url>
+    <loc>https://example.com/home</loc>
+    <changefreq>daily</changefreq>
+    <priority>1</priority>
+  </url>
+</urlset>
+`
+	if got := buf.String(); got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+
+	cfg.Values = func(string) ([][]string, error) {
+		return nil, errors.New("database down")
+	}
+	if err := tr.WriteSitemap(&bytes.Buffer{}, cfg); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestTreeMux_HandleSitemap(t *testing.T) {
+	tr := NewTreeMux(OptionLogger(&syncLog{}))
+	tr.HandleSitemap("/sitemap.xml", Sitemap{BaseURL: "https://example.com"})
+	tr.HandleFunc("/about", bodyHandler("about"))
+
+	w := httptest.NewRecorder()
+	tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/sitemap.xml", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/xml; charset=utf-8" {
+		t.Fatalf("expected sitemap, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !bytes.Contains(w.Body.Bytes(), []byte("<loc>https://example.com/about</loc>")) {
+		t.Errorf("expected route added later, got %s", w.Body.String())
+	}
+	if bytes.Contains(w.Body.Bytes(), []byte("sitemap.xml")) {
+		t.Errorf("expected sitemap itself to be excluded, got %s", w.Body.String())
+	}
+
+	tr.HandleFunc("/users/*", bodyHandler("user"))
+	tr.HandleSitemap("/failing.xml", Sitemap{Values: func(string) ([][]string, error) {
+		return nil, errors.New("database down")
+	}})
+	w = httptest.NewRecorder()
+	tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/failing.xml", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d", w.Code)
+	}
+}
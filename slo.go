@@ -0,0 +1,243 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SLO holds the service level objectives of a route.
+type SLO struct {
+	// Latency is the duration within which requests should be handled.
+	Latency time.Duration
+	// LatencyTarget is the fraction (0 to 1) of requests that should be
+	// handled within Latency.
+	LatencyTarget float64
+	// AvailabilityTarget is the fraction (0 to 1) of requests that should not
+	// result in a server error (5xx).
+	AvailabilityTarget float64
+	// Window is the rolling period compliance is computed over, defaults to
+	// five minutes.
+	Window time.Duration
+}
+
+// SLOStatus is the compliance of a route with its objectives over the rolling
+// window.
+type SLOStatus struct {
+	Pattern      string  `json:"pattern"`
+	Requests     int64   `json:"requests"`
+	Latency      float64 `json:"latency"`
+	Availability float64 `json:"availability"`
+	// Compliant is false when either objective is not met.
+	Compliant bool `json:"compliant"`
+}
+
+const sloBuckets = 10
+
+type sloBucket struct {
+	index int64
+	total int64
+	fast  int64
+	ok    int64
+}
+
+// sloWindow tracks the compliance of a single route.
+type sloWindow struct {
+	slo        SLO
+	bucketSize time.Duration
+
+	mux     sync.Mutex
+	buckets [sloBuckets]sloBucket
+}
+
+func newSLOWindow(slo SLO) *sloWindow {
+	if slo.Window <= 0 {
+		slo.Window = 5 * time.Minute
+	}
+	return &sloWindow{slo: slo, bucketSize: slo.Window / sloBuckets}
+}
+
+func (w *sloWindow) observe(now time.Time, d time.Duration, status int) {
+	idx := now.UnixNano() / int64(w.bucketSize)
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	b := &w.buckets[idx%sloBuckets]
+	if b.index != idx {
+		*b = sloBucket{index: idx}
+	}
+	b.total += 1
+	if d <= w.slo.Latency {
+		b.fast += 1
+	}
+	if status < 500 {
+		b.ok += 1
+	}
+}
+
+func (w *sloWindow) status(now time.Time, pattern string) SLOStatus {
+	idx := now.UnixNano() / int64(w.bucketSize)
+	var total, fast, ok int64
+	w.mux.Lock()
+	for _, b := range w.buckets {
+		if b.index > idx-sloBuckets {
+			total += b.total
+			fast += b.fast
+			ok += b.ok
+		}
+	}
+	w.mux.Unlock()
+
+	s := SLOStatus{Pattern: pattern, Requests: total, Latency: 1, Availability: 1}
+	if total > 0 {
+		s.Latency = float64(fast) / float64(total)
+		s.Availability = float64(ok) / float64(total)
+	}
+	s.Compliant = s.Latency >= w.slo.LatencyTarget && s.Availability >= w.slo.AvailabilityTarget
+	return s
+}
+
+// SLOTracker computes the rolling compliance of routes that declared
+// objectives with WithSLO.
+type SLOTracker struct {
+	clock   Clock
+	mux     sync.RWMutex
+	windows map[string]*sloWindow
+}
+
+// NewSLOTracker creates an empty SLOTracker. It takes the time from the
+// clock of the mux it is used with (see OptionClock).
+func NewSLOTracker() *SLOTracker {
+	return &SLOTracker{clock: realClock{}, windows: make(map[string]*sloWindow)}
+}
+
+func (t *SLOTracker) register(pattern string, slo SLO) *sloWindow {
+	w := newSLOWindow(slo)
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.windows[pattern] = w
+	return w
+}
+
+// Status returns the compliance of the route with the given pattern, as it
+// was registered ("/orders/{id}" and "/orders/*" are the same route). The
+// boolean is false if the route has no objectives.
+func (t *SLOTracker) Status(pattern string) (SLOStatus, bool) {
+	pattern = canonicalPattern(pattern)
+	t.mux.RLock()
+	w, ok := t.windows[pattern]
+	t.mux.RUnlock()
+	if !ok {
+		return SLOStatus{}, false
+	}
+	return w.status(t.clock.Now(), pattern), true
+}
+
+// Report returns the compliance of all routes with objectives, sorted by
+// pattern.
+func (t *SLOTracker) Report() []SLOStatus {
+	now := t.clock.Now()
+	t.mux.RLock()
+	xs := make([]SLOStatus, 0, len(t.windows))
+	for p, w := range t.windows {
+		xs = append(xs, w.status(now, p))
+	}
+	t.mux.RUnlock()
+	sort.Slice(xs, func(i, j int) bool {
+		return xs[i].Pattern < xs[j].Pattern
+	})
+	return xs
+}
+
+// ServeHTTP serves the report as JSON, for use as a debug endpoint.
+func (t *SLOTracker) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(t.Report())
+}
+
+// WriteMetrics writes the report in the Prometheus text exposition format.
+func (t *SLOTracker) WriteMetrics(w io.Writer) error {
+	report := t.Report()
+	metrics := []struct {
+		name  string
+		help  string
+		value func(SLOStatus) float64
+	}{
+		{"treemux_slo_requests", "Requests in the SLO window.", func(s SLOStatus) float64 { return float64(s.Requests) }},
+		{"treemux_slo_latency_ratio", "Fraction of requests within the latency objective.", func(s SLOStatus) float64 { return s.Latency }},
+		{"treemux_slo_availability_ratio", "Fraction of requests without server error.", func(s SLOStatus) float64 { return s.Availability }},
+	}
+	for _, m := range metrics {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", m.name, m.help, m.name); err != nil {
+			return err
+		}
+		for _, s := range report {
+			if _, err := fmt.Fprintf(w, "%s{pattern=%q} %g\n", m.name, s.Pattern, m.value(s)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type optionSLOTracker struct {
+	value *SLOTracker
+}
+
+func (o optionSLOTracker) Apply(mux *treeMux) {
+	mux.sloTracker = o.value
+}
+
+func (o optionSLOTracker) private() {}
+
+// OptionSLOTracker tracks the objectives declared with WithSLO in the given
+// tracker. Without it, objectives are ignored.
+func OptionSLOTracker(t *SLOTracker) Option {
+	return optionSLOTracker{t}
+}
+
+type withSLO struct {
+	value SLO
+}
+
+func (o withSLO) Apply(rt *route) {
+	rt.slo = &o.value
+}
+
+func (o withSLO) private() {}
+
+// WithSLO declares the service level objectives of the route.
+func WithSLO(slo SLO) RouteOption {
+	return withSLO{slo}
+}
+
+// trackSLO wraps the handler so that its requests are observed by the window.
+func trackSLO(h http.Handler, w *sloWindow, clock Clock) http.Handler {
+	if w == nil {
+		return h
+	}
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		start := clock.Now()
+		rec := newResponseWriter(rw)
+		defer func() {
+			status := rec.Status()
+			now := clock.Now()
+			if v := recover(); v != nil {
+				w.observe(now, now.Sub(start), http.StatusInternalServerError)
+				panic(v)
+			}
+			if status == 0 {
+				status = http.StatusOK
+			}
+			w.observe(now, now.Sub(start), status)
+		}()
+		h.ServeHTTP(rec, r)
+	})
+}
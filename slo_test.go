@@ -0,0 +1,115 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithSLO(t *testing.T) {
+	tracker := NewSLOTracker()
+	tr := NewTreeMux(OptionSLOTracker(tracker))
+	slo := SLO{Latency: 10 * time.Millisecond, LatencyTarget: .5, AvailabilityTarget: .9}
+	tr.HandleFunc("/items/*", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/items/slow":
+			time.Sleep(20 * time.Millisecond)
+		case "/items/broken":
+			w.WriteHeader(http.StatusBadGateway)
+		case "/items/missing":
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}, WithSLO(slo))
+	tr.Handle("/other", bodyHandler("other"))
+
+	for _, p := range []string{"/items/1", "/items/slow", "/items/missing", "/items/2", "/other"} {
+		tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, p, nil))
+	}
+	got, ok := tracker.Status("/items/*")
+	want := SLOStatus{Pattern: "/items/*", Requests: 4, Latency: .75, Availability: 1, Compliant: true}
+	if !ok || got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if _, ok := tracker.Status("/other"); ok {
+		t.Errorf("expected no status for route without objectives")
+	}
+
+	tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/items/broken", nil))
+	got, _ = tracker.Status("/items/*")
+	want = SLOStatus{Pattern: "/items/*", Requests: 5, Latency: .8, Availability: .8, Compliant: false}
+	if got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := tracker.WriteMetrics(buf); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !strings.Contains(buf.String(), `treemux_slo_availability_ratio{pattern="/items/*"} 0.8`) {
+		t.Errorf("expected availability metric, got:\n%s", buf.String())
+	}
+}
+
+func TestSLOTracker_Status(t *testing.T) {
+	tracker := NewSLOTracker()
+	tr := NewTreeMux(OptionSLOTracker(tracker))
+	slo := SLO{Latency: time.Second, LatencyTarget: .5, AvailabilityTarget: .5}
+	tr.Handle("/orders/{id}", bodyHandler("ok"), WithSLO(slo))
+	tr.Handle("/files/{path...}", bodyHandler("ok"), WithSLO(slo))
+	tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orders/42", nil))
+	tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/files/a/b", nil))
+
+	cases := []struct {
+		pattern string
+		want    string
+		wantOk  bool
+	}{
+		{"/orders/{id}", "/orders/*", true},
+		{"/orders/:order", "/orders/*", true},
+		{"/orders/*", "/orders/*", true},
+		{"/files/{path...}", "/files/**", true},
+		{"/orders/{id}/items", "", false},
+	}
+	for _, c := range cases {
+		t.Run(c.pattern, func(t *testing.T) {
+			got, ok := tracker.Status(c.pattern)
+			if ok != c.wantOk {
+				t.Fatalf("expected %v, got %v", c.wantOk, ok)
+			}
+			if ok && (got.Pattern != c.want || got.Requests != 1) {
+				t.Errorf("expected 1 request for %s, got %+v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestSLOWindow(t *testing.T) {
+	w := newSLOWindow(SLO{Latency: time.Second, Window: 10 * time.Second})
+	start := time.Unix(1000, 0)
+	w.observe(start, 0, 500)
+	w.observe(start.Add(5*time.Second), 0, 200)
+
+	cases := []struct {
+		name         string
+		at           time.Duration
+		wantRequests int64
+	}{
+		{"both in window", 9 * time.Second, 2},
+		{"first expired", 12 * time.Second, 1},
+		{"all expired", 20 * time.Second, 0},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := w.status(start.Add(c.at), "").Requests; got != c.wantRequests {
+				t.Errorf("expected %v, got %v", c.wantRequests, got)
+			}
+		})
+	}
+}
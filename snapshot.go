@@ -0,0 +1,67 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+func (t *treeMux) Snapshot() []byte {
+	b := &bytes.Buffer{}
+	for _, rt := range t.routes() {
+		b.WriteString(rt.pattern)
+		if rt.host != "" {
+			fmt.Fprintf(b, " host=%s", rt.host)
+		}
+		if rt.name != "" {
+			fmt.Fprintf(b, " name=%s", rt.name)
+		}
+		if len(rt.methods) > 0 {
+			fmt.Fprintf(b, " methods=%s", strings.Join(rt.methods, ","))
+		}
+		if n := len(rt.predicates); n > 0 {
+			fmt.Fprintf(b, " predicates=%d", n)
+		}
+		if n := len(rt.guards); n > 0 {
+			fmt.Fprintf(b, " guards=%d", n)
+		}
+		if n := len(rt.verifiers); n > 0 {
+			fmt.Fprintf(b, " verifiers=%d", n)
+		}
+		if rt.hasTimeout {
+			fmt.Fprintf(b, " timeout=%s", rt.timeout)
+		}
+		if rt.streaming {
+			b.WriteString(" streaming")
+		}
+		if rt.rateLimit != nil {
+			fmt.Fprintf(b, " ratelimit=%d/%s", rt.rateLimit.Limit, rt.rateLimit.Period)
+		}
+		keys := make([]string, 0, len(rt.metadata))
+		for k := range rt.metadata {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(b, " %s=%q", k, fmt.Sprint(rt.metadata[k]))
+		}
+		b.WriteByte('\n')
+	}
+	return b.Bytes()
+}
+
+// HeaderRouteTableHash is the header with the SnapshotHash of a mux, set on
+// the route table responses of the Admin mux.
+const HeaderRouteTableHash = "X-Route-Table-Hash"
+
+func (t *treeMux) SnapshotHash() string {
+	sum := sha256.Sum256(t.Snapshot())
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,63 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTreeMux_Snapshot(t *testing.T) {
+	register := func(tr TreeMux, reverse bool) {
+		fs := []func(){
+			func() {
+				tr.HandleFunc("/foo/*", bodyHandler("foo"), WithName("getFoo"), WithTimeout(time.Second))
+			},
+			func() {
+				tr.HandleFunc("/bar", bodyHandler("bar"),
+					WithMetadata("team", "core"), WithMetadata("owner", "a b"), Streaming())
+			},
+			func() {
+				tr.Mount("/static", bodyHandler("static"), WithGuard(hasHeader("X-Foo")))
+			},
+			func() {
+				tr.HandleFunc("/bar", bodyHandler("bar"), WithPredicate(hasHeader("X-Bar")),
+					WithRateLimit(RateLimit{Limit: 10, Period: time.Minute}))
+			},
+		}
+		for i := range fs {
+			if reverse {
+				fs[len(fs)-1-i]()
+			} else {
+				fs[i]()
+			}
+		}
+	}
+	tr := NewTreeMux()
+	register(tr, false)
+
+	want := `/bar predicates=1 ratelimit=10/1m0s
+/bar streaming owner="a b" team="core"
+/foo/* name=getFoo timeout=1s
+/static/** guards=1
+`
+	if got := string(tr.Snapshot()); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	other := NewTreeMux()
+	register(other, true)
+	if got := string(other.Snapshot()); got != want {
+		t.Errorf("expected snapshot independent of registration order, got:\n%s", got)
+	}
+
+	if tr.SnapshotHash() != other.SnapshotHash() {
+		t.Errorf("expected equal hashes")
+	}
+	other.HandleFunc("/qux", bodyHandler("qux"))
+	if tr.SnapshotHash() == other.SnapshotHash() {
+		t.Errorf("expected hash to change")
+	}
+}
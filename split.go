@@ -0,0 +1,104 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+)
+
+// Variant is one of the handlers of a Split.
+type Variant struct {
+	Name    string
+	Weight  int
+	Handler http.Handler
+}
+
+// Split is a handler that divides requests over weighted variants, for canary
+// releases and experiments.
+//
+// Without affinity, every request is assigned at random. With a Key function,
+// requests with the same key always go to the same variant. With a Cookie,
+// the assigned variant is remembered by the client. When both are set, the
+// cookie takes precedence.
+type Split struct {
+	Variants []Variant
+	// Key extracts a stable identifier of the client (see HeaderKey).
+	Key func(r *http.Request) string
+	// Cookie is the name of the cookie the assigned variant is stored in.
+	Cookie string
+	// CookieMaxAge is the max age of the cookie in seconds, zero makes it a
+	// session cookie.
+	CookieMaxAge int
+}
+
+// HeaderKey returns a key function for Split that uses the value of a request
+// header.
+func HeaderKey(name string) func(*http.Request) string {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+func (s Split) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if len(s.Variants) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	if s.Cookie != "" {
+		if c, err := r.Cookie(s.Cookie); err == nil {
+			for _, v := range s.Variants {
+				if v.Name == c.Value {
+					v.Handler.ServeHTTP(w, r)
+					return
+				}
+			}
+		}
+	}
+	v := s.pick(r)
+	if s.Cookie != "" {
+		http.SetCookie(w, &http.Cookie{
+			Name:     s.Cookie,
+			Value:    v.Name,
+			Path:     "/",
+			MaxAge:   s.CookieMaxAge,
+			HttpOnly: true,
+		})
+	}
+	v.Handler.ServeHTTP(w, r)
+}
+
+func (s Split) pick(r *http.Request) Variant {
+	total := 0
+	for _, v := range s.Variants {
+		total += v.Weight
+	}
+	if total <= 0 {
+		return s.Variants[0]
+	}
+	var n int
+	if key := s.key(r); key != "" {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(key))
+		n = int(h.Sum64() % uint64(total))
+	} else {
+		n = rand.Intn(total)
+	}
+	for _, v := range s.Variants {
+		if n < v.Weight {
+			return v
+		}
+		n -= v.Weight
+	}
+	return s.Variants[len(s.Variants)-1]
+}
+
+func (s Split) key(r *http.Request) string {
+	if s.Key == nil {
+		return ""
+	}
+	return s.Key(r)
+}
@@ -0,0 +1,70 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	variants := []Variant{
+		{"stable", 9, bodyHandler("stable")},
+		{"canary", 1, bodyHandler("canary")},
+	}
+
+	t.Run("weights", func(t *testing.T) {
+		s := Split{Variants: variants, Key: HeaderKey("X-User")}
+		counts := map[string]int{}
+		for i := 0; i < 1000; i++ {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("X-User", fmt.Sprintf("user-%d", i))
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+			counts[w.Body.String()] += 1
+		}
+		if counts["canary"] < 50 || counts["canary"] > 150 {
+			t.Errorf("expected about 100 canary requests, got %v", counts)
+		}
+	})
+
+	t.Run("key affinity", func(t *testing.T) {
+		s := Split{Variants: variants, Key: HeaderKey("X-User")}
+		first := ""
+		for i := 0; i < 20; i++ {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("X-User", "alice")
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+			if first == "" {
+				first = w.Body.String()
+			}
+			if w.Body.String() != first {
+				t.Fatalf("expected %s, got %s", first, w.Body.String())
+			}
+		}
+	})
+
+	t.Run("cookie affinity", func(t *testing.T) {
+		s := Split{Variants: variants, Cookie: "variant"}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		cookies := w.Result().Cookies()
+		if len(cookies) != 1 || cookies[0].Value != w.Body.String() {
+			t.Fatalf("expected cookie for %s, got %v", w.Body.String(), cookies)
+		}
+		for i := 0; i < 20; i++ {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.AddCookie(&http.Cookie{Name: "variant", Value: "canary"})
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+			if w.Body.String() != "canary" {
+				t.Fatalf("expected canary, got %s", w.Body.String())
+			}
+		}
+	})
+}
@@ -0,0 +1,81 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Store is a concurrency-safe key-value store for state shared by the
+// requests of a route, like counters or timestamps. Every registered route
+// has its own store, which is cleared when the route is replaced or the mux
+// shuts down.
+type Store struct {
+	mux    sync.Mutex
+	values map[string]interface{}
+}
+
+// Get returns the value for the key. The boolean is false when there is no
+// such value.
+func (s *Store) Get(key string) (interface{}, bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	v, ok := s.values[key]
+	return v, ok
+}
+
+// Set stores the value for the key.
+func (s *Store) Set(key string, value interface{}) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.values == nil {
+		s.values = make(map[string]interface{})
+	}
+	s.values[key] = value
+}
+
+// Delete removes the value for the key.
+func (s *Store) Delete(key string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	delete(s.values, key)
+}
+
+// Update atomically replaces the value for the key with the result of fn,
+// which gets the current value (if any). It returns the new value.
+//
+//	n := treemux.RouteStore(r).Update("hits", func(v interface{}, _ bool) interface{} {
+//		n, _ := v.(int)
+//		return n + 1
+//	})
+func (s *Store) Update(key string, fn func(value interface{}, ok bool) interface{}) interface{} {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	v, ok := s.values[key]
+	v = fn(v, ok)
+	if s.values == nil {
+		s.values = make(map[string]interface{})
+	}
+	s.values[key] = v
+	return v
+}
+
+// clear removes all values.
+func (s *Store) clear() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.values = nil
+}
+
+// RouteStore returns the store of the route the request was matched to, or
+// nil when it was not matched.
+func RouteStore(r *http.Request) *Store {
+	rt := routeFromContext(r)
+	if rt == nil {
+		return nil
+	}
+	return rt.store
+}
@@ -0,0 +1,75 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func countHits(w http.ResponseWriter, r *http.Request) {
+	n := RouteStore(r).Update("hits", func(v interface{}, _ bool) interface{} {
+		n, _ := v.(int)
+		return n + 1
+	})
+	_, _ = fmt.Fprint(w, n)
+}
+
+func TestRouteStore(t *testing.T) {
+	tr := NewTreeMux()
+	tr.HandleFunc("/foo", countHits)
+	tr.HandleFunc("/bar", countHits)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/foo", nil))
+		}()
+	}
+	wg.Wait()
+
+	cases := []struct {
+		name    string
+		replace bool
+		path    string
+		want    string
+	}{
+		{"shared by requests", false, "/foo", "11"},
+		{"per route", false, "/bar", "1"},
+		{"cleared on replacement", true, "/foo", "1"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if c.replace {
+				tr.HandleFunc(c.path, countHits)
+			}
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+			if w.Body.String() != c.want {
+				t.Errorf("expected %s, got %s", c.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestStore(t *testing.T) {
+	s := &Store{}
+	if _, ok := s.Get("foo"); ok {
+		t.Errorf("expected no value")
+	}
+	s.Set("foo", 1)
+	if v, ok := s.Get("foo"); !ok || v != 1 {
+		t.Errorf("expected 1, got %v", v)
+	}
+	s.Delete("foo")
+	if _, ok := s.Get("foo"); ok {
+		t.Errorf("expected no value after delete")
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// ContentTypeNDJSON is the content type of newline-delimited JSON.
+const ContentTypeNDJSON = "application/x-ndjson"
+
+// Stream writes a streaming response. Every write is flushed to the client
+// immediately. Writes fail with the context's error once the client is gone.
+type Stream struct {
+	ctx     context.Context
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+// Write writes a chunk of the response.
+func (s *Stream) Write(p []byte) (int, error) {
+	if err := s.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := s.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+	return n, nil
+}
+
+// Send writes the value as a single line of JSON.
+func (s *Stream) Send(v interface{}) error {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = s.Write(append(bs, '\n'))
+	return err
+}
+
+// StreamFunc produces a streaming response.
+type StreamFunc func(r *http.Request, s *Stream) error
+
+func (t *treeMux) HandleStream(path, contentType string, fn StreamFunc, options ...RouteOption) {
+	if contentType == "" {
+		contentType = ContentTypeNDJSON
+	}
+	options = append([]RouteOption{Streaming()}, options...)
+	t.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("Content-Type", contentType)
+		h.Set("Cache-Control", "no-cache")
+		h.Set("X-Content-Type-Options", "nosniff")
+		// disables buffering by nginx and similar proxies
+		h.Set("X-Accel-Buffering", "no")
+
+		s := &Stream{ctx: r.Context(), w: w}
+		s.flusher, _ = w.(http.Flusher)
+		// send the headers right away, so clients do not wait for the first
+		// chunk
+		w.WriteHeader(http.StatusOK)
+		if s.flusher != nil {
+			s.flusher.Flush()
+		}
+		err := fn(r, s)
+		if err == nil || err == context.Canceled || err == context.DeadlineExceeded {
+			return
+		}
+		if rt := routeFromContext(r); rt != nil {
+			rt.logf(LogError, "stream failed", "pattern", rt.pattern, "error", err)
+		}
+	}, options...)
+}
@@ -0,0 +1,93 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTreeMux_HandleStream(t *testing.T) {
+	next := make(chan struct{})
+	logs := &syncLog{}
+	tr := NewTreeMux(OptionTimeout(10*time.Millisecond), OptionLogger(logs))
+	tr.HandleStream("/events", "", func(r *http.Request, s *Stream) error {
+		if !IsStreaming(r) {
+			t.Errorf("expected streaming route")
+		}
+		for i := 0; i < 3; i++ {
+			<-next
+			if err := s.Send(map[string]int{"n": i}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	tr.HandleStream("/fail", "text/plain", func(r *http.Request, s *Stream) error {
+		_, _ = s.Write([]byte("partial"))
+		return errors.New("oops")
+	})
+
+	srv := httptest.NewServer(tr)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != ContentTypeNDJSON {
+		t.Errorf("expected %s, got %s", ContentTypeNDJSON, ct)
+	}
+	sc := bufio.NewScanner(resp.Body)
+	for _, want := range []string{`{"n":0}`, `{"n":1}`, `{"n":2}`} {
+		// the next value is only produced after the previous was received,
+		// exceeding the mux time limit
+		time.Sleep(15 * time.Millisecond)
+		next <- struct{}{}
+		if !sc.Scan() {
+			t.Fatalf("expected %s, got %v", want, sc.Err())
+		}
+		if got := sc.Text(); got != want {
+			t.Errorf("expected %s, got %s", want, got)
+		}
+	}
+
+	resp, err = http.Get(srv.URL + "/fail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, _ := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/plain" || string(bs) != "partial" {
+		t.Errorf("expected partial text response, got %s %q", resp.Header.Get("Content-Type"), bs)
+	}
+	if !strings.Contains(logs.String(), "stream failed") {
+		t.Errorf("expected error to be logged, got %q", logs.String())
+	}
+}
+
+func TestStream_cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := httptest.NewRecorder()
+	s := &Stream{ctx: ctx, w: w}
+	if err := s.Send(1); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := s.Send(2); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if got := w.Body.String(); got != "1\n" {
+		t.Errorf("expected one value, got %q", got)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ConflictError is the panic value when a route conflicts with an existing one
+// under OptionStrictRegistration.
+type ConflictError struct {
+	Pattern  string
+	Existing string
+	// Shadowed is set when the existing pattern is a different one that
+	// matches all requests the new pattern would.
+	Shadowed bool
+}
+
+func (e ConflictError) Error() string {
+	if e.Shadowed {
+		return fmt.Sprintf("pattern %s is shadowed by existing pattern %s", e.Pattern, e.Existing)
+	}
+	return fmt.Sprintf("pattern %s conflicts with existing pattern %s", e.Pattern, e.Existing)
+}
+
+// checkConflict returns an error when the route would replace an existing
+// route, or when it can never be matched because of an existing pattern.
+func (t *treeMux) checkConflict(rt *route) error {
+	if rt.host != "" {
+		return nil
+	}
+	t.routesMux.RLock()
+	defer t.routesMux.RUnlock()
+	var e *endpoint
+	if prefix := strings.TrimSuffix(rt.pattern, "/**"); prefix != rt.pattern {
+		e = t.prefixes[prefix]
+	} else if e = t.endpoints[rt.pattern]; e == nil {
+		// Tracing the pattern as a path finds the pattern that takes
+		// precedence for all paths it matches, as its wildcards only
+		// match wildcards.
+		if _, p := t.matcher.Trace(rt.pattern, nil); p != "" && p != rt.pattern {
+			return ConflictError{Pattern: rt.pattern, Existing: p, Shadowed: true}
+		}
+	}
+	if e == nil || rt.conditional() {
+		return nil
+	}
+	for _, x := range e.routes {
+		if !x.conditional() {
+			return ConflictError{Pattern: rt.pattern, Existing: x.pattern}
+		}
+	}
+	return nil
+}
+
+type optionStrictRegistration struct{}
+
+func (o optionStrictRegistration) Apply(mux *treeMux) {
+	mux.strict = true
+}
+
+func (o optionStrictRegistration) private() {}
+
+// OptionStrictRegistration makes registering a route panic with a
+// ConflictError when it would silently replace an existing route, or when an
+// existing wildcard pattern would take precedence for all of its requests.
+// Conditional routes (see WithPredicate and WithMethods) never replace one
+// another, so they are only checked for shadowing. Patterns with a catch-all
+// that is not the final element, or with more than one catch-all, are
+// rejected as well. Otherwise, such catch-alls are taken for a literal ("**")
+// or a single wildcard ("{name...}").
+func OptionStrictRegistration() Option {
+	return optionStrictRegistration{}
+}
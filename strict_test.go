@@ -0,0 +1,76 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestOptionStrictRegistration(t *testing.T) {
+	cases := []struct {
+		name     string
+		existing []string
+		pattern  string
+		options  []RouteOption
+		want     error
+	}{
+		{"new", []string{"/foo"}, "/bar", nil, nil},
+		{"duplicate", []string{"/foo"}, "/foo", nil, ConflictError{Pattern: "/foo", Existing: "/foo"}},
+		{"duplicate param", []string{"/foo/{id}"}, "/foo/{name}", nil, ConflictError{Pattern: "/foo/*", Existing: "/foo/*"}},
+		{"conditional", []string{"/foo"}, "/foo", []RouteOption{WithMethods(http.MethodPost)}, nil},
+		{"shadowed", []string{"/foo/*"}, "/foo/bar", nil, ConflictError{Pattern: "/foo/bar", Existing: "/foo/*", Shadowed: true}},
+		{"shadowed conditional", []string{"/*/*"}, "/foo/*", []RouteOption{WithMethods(http.MethodPost)}, ConflictError{Pattern: "/foo/*", Existing: "/*/*", Shadowed: true}},
+		{"more specific first", []string{"/foo/bar"}, "/foo/*", nil, nil},
+		{"partly shadowed", []string{"/foo/*"}, "/*/bar", nil, nil},
+		{"duplicate prefix", []string{"/static/**"}, "/static/**", nil, ConflictError{Pattern: "/static/**", Existing: "/static/**"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tr := NewTreeMux(OptionStrictRegistration())
+			for _, p := range c.existing {
+				tr.HandleFunc(p, bodyHandler(p))
+			}
+			var got interface{}
+			func() {
+				defer func() {
+					got = recover()
+				}()
+				tr.HandleFunc(c.pattern, bodyHandler(c.pattern), c.options...)
+			}()
+			if c.want == nil && got != nil || c.want != nil && got != c.want {
+				t.Errorf("expected %v, got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestOptionStrictRegistration_catchAll(t *testing.T) {
+	cases := []struct {
+		name    string
+		pattern string
+		want    interface{}
+	}{
+		{"final", "/static/**", nil},
+		{"named final", "/static/{path...}", nil},
+		{"not final", "/static/**/foo", "catch-all ** is not the final element of /static/**/foo"},
+		{"named not final", "/static/{path...}/foo", "catch-all {path...} is not the final element of /static/{path...}/foo"},
+		{"two", "/static/{path...}/**", "pattern /static/{path...}/** has 2 catch-alls ({path...}, **), but only one is allowed"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var got interface{}
+			func() {
+				defer func() {
+					got = recover()
+				}()
+				NewTreeMux(OptionStrictRegistration()).HandleFunc(c.pattern, bodyHandler(""))
+			}()
+			if got != c.want {
+				t.Errorf("expected %v, got %v", c.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+type withTarpit struct {
+	min, max time.Duration
+}
+
+func (o withTarpit) Apply(rt *route) {
+	rt.tarpit = &o
+}
+
+func (o withTarpit) private() {}
+
+// WithTarpit delays the requests to the route by a random duration between
+// min and max, to slow down brute-force attacks and scanners (see Honeypot).
+// The delay is cut short when the client goes away or the mux shuts down; in
+// the first case the handler is not called. Waiting requests do not take up
+// admission slots (see OptionAdmission). It panics when min is negative or
+// max is less than min.
+func WithTarpit(min, max time.Duration) RouteOption {
+	if min < 0 || max < min {
+		panic(fmt.Sprintf("invalid tarpit delay between %s and %s", min, max))
+	}
+	return withTarpit{min, max}
+}
+
+func (o *withTarpit) delay() time.Duration {
+	if o.max == o.min {
+		return o.min
+	}
+	return o.min + time.Duration(rand.Int63n(int64(o.max-o.min)))
+}
+
+// tarpit wraps the handler so that it is called after a delay.
+func tarpit(h http.Handler, o *withTarpit, clock Clock, stopping <-chan struct{}) http.Handler {
+	if o == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timer := clock.NewTimer(o.delay())
+		defer timer.Stop()
+		select {
+		case <-timer.C():
+		case <-stopping:
+		case <-r.Context().Done():
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
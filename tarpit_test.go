@@ -0,0 +1,71 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWithTarpit(t *testing.T) {
+	cases := []struct {
+		name     string
+		cancel   bool
+		shutdown bool
+		body     string
+		minDelay time.Duration
+		maxDelay time.Duration
+	}{
+		{"delayed", false, false, "foo", 20 * time.Millisecond, time.Second},
+		{"client gone", true, false, "", 0, 500 * time.Millisecond},
+		{"shutdown", false, true, "slow", 0, 500 * time.Millisecond},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tr := NewTreeMux()
+			tr.HandleFunc("/foo", bodyHandler("foo"), WithTarpit(20*time.Millisecond, 30*time.Millisecond))
+			tr.HandleFunc("/slow", bodyHandler("slow"), WithTarpit(time.Hour, time.Hour))
+
+			path := "/foo"
+			ctx := context.Background()
+			if c.cancel || c.shutdown {
+				path = "/slow"
+			}
+			if c.cancel {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, 10*time.Millisecond)
+				defer cancel()
+			}
+			if c.shutdown {
+				go func() {
+					time.Sleep(10 * time.Millisecond)
+					_ = tr.Shutdown(context.Background())
+				}()
+			}
+			start := time.Now()
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil).WithContext(ctx))
+			d := time.Since(start)
+			if body := w.Body.String(); body != c.body {
+				t.Errorf("expected %q, got %q", c.body, body)
+			}
+			if d < c.minDelay || d > c.maxDelay {
+				t.Errorf("expected delay between %s and %s, got %s", c.minDelay, c.maxDelay, d)
+			}
+		})
+	}
+}
+
+func TestWithTarpit_invalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic")
+		}
+	}()
+	WithTarpit(time.Second, time.Millisecond)
+}
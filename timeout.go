@@ -0,0 +1,232 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type optionTimeout struct {
+	value time.Duration
+}
+
+func (o optionTimeout) Apply(mux *treeMux) {
+	mux.timeout = o.value
+}
+
+func (o optionTimeout) private() {}
+
+// OptionTimeout sets the default time limit for handling a request. Routes can
+// override it with WithTimeout. Streaming routes are exempt.
+func OptionTimeout(d time.Duration) Option {
+	return optionTimeout{d}
+}
+
+type withTimeout struct {
+	value time.Duration
+}
+
+func (o withTimeout) Apply(rt *route) {
+	rt.timeout = o.value
+	rt.hasTimeout = true
+}
+
+func (o withTimeout) private() {}
+
+// WithTimeout sets the time limit for handling requests on the route,
+// overriding the mux default. A zero duration disables the time limit.
+func WithTimeout(d time.Duration) RouteOption {
+	return withTimeout{d}
+}
+
+type streaming struct {
+}
+
+func (o streaming) Apply(rt *route) {
+	rt.streaming = true
+}
+
+func (o streaming) private() {}
+
+// Streaming marks a route as serving long-lived responses (long polling,
+// server-sent events, chunked streams). Wrappers that would buffer or cut off
+// the response, like time limits, are not applied to it. Third party wrappers
+// can use IsStreaming to do the same.
+func Streaming() RouteOption {
+	return streaming{}
+}
+
+// IsStreaming reports whether the request was matched to a streaming route.
+func IsStreaming(r *http.Request) bool {
+	rt := routeFromContext(r)
+	return rt != nil && rt.streaming
+}
+
+// routeTimeout returns the time limit that applies to the route.
+func (t *treeMux) routeTimeout(rt *route) time.Duration {
+	if rt.streaming {
+		return 0
+	}
+	if rt.hasTimeout {
+		return rt.timeout
+	}
+	return t.timeout
+}
+
+// limit wraps the handler with a time limit. Requests that exceed it get a 503
+// response, unless the handler had already started writing it. The limit is
+// set as the deadline of the request context, so that outgoing calls made with
+// it inherit the route's budget. Unlike http.TimeoutHandler, the response is
+// not buffered and flushing and hijacking keep working.
+func limit(h http.Handler, d time.Duration, clock Clock) http.Handler {
+	if d <= 0 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline := clock.Now().Add(d)
+		ctx, cancel := withDeadline(r.Context(), clock, deadline)
+		defer cancel()
+		ctx = context.WithValue(ctx, deadlineKey, deadline)
+
+		tw := &timeoutWriter{w: w, header: make(http.Header), ctx: ctx}
+		done := make(chan struct{})
+		panicked := make(chan interface{}, 1)
+		go func() {
+			defer func() {
+				if v := recover(); v != nil {
+					panicked <- v
+				}
+			}()
+			h.ServeHTTP(tw, r.WithContext(ctx))
+			close(done)
+		}()
+		select {
+		case <-done:
+		case v := <-panicked:
+			panic(v)
+		case <-ctx.Done():
+			tw.timeout()
+		}
+	})
+}
+
+// timeoutWriter passes the response on to the underlying writer until the
+// time limit is exceeded. Until the handler starts writing, it gets its own
+// header map, so it cannot touch the headers of the timeout response.
+type timeoutWriter struct {
+	w      http.ResponseWriter
+	header http.Header
+	ctx    context.Context
+
+	mux      sync.Mutex
+	started  bool
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	tw.mux.Lock()
+	defer tw.mux.Unlock()
+	if tw.started && !tw.timedOut {
+		// trailers are set after the header was written
+		return tw.w.Header()
+	}
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mux.Lock()
+	defer tw.mux.Unlock()
+	tw.writeHeaderLocked(status)
+}
+
+func (tw *timeoutWriter) writeHeaderLocked(status int) {
+	if tw.expiredLocked() || tw.started {
+		return
+	}
+	dst := tw.w.Header()
+	for k, vs := range tw.header {
+		dst[k] = vs
+	}
+	if !informational(status) {
+		tw.started = true
+	}
+	tw.w.WriteHeader(status)
+}
+
+func (tw *timeoutWriter) Write(bs []byte) (int, error) {
+	tw.mux.Lock()
+	defer tw.mux.Unlock()
+	if tw.expiredLocked() {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.writeHeaderLocked(http.StatusOK)
+	return tw.w.Write(bs)
+}
+
+func (tw *timeoutWriter) Flush() {
+	tw.mux.Lock()
+	defer tw.mux.Unlock()
+	f, ok := tw.w.(http.Flusher)
+	if !ok || tw.expiredLocked() {
+		return
+	}
+	tw.writeHeaderLocked(http.StatusOK)
+	f.Flush()
+}
+
+func (tw *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	tw.mux.Lock()
+	defer tw.mux.Unlock()
+	h, ok := tw.w.(http.Hijacker)
+	switch {
+	case tw.expiredLocked():
+		return nil, nil, http.ErrHandlerTimeout
+	case !ok:
+		return nil, nil, errors.New("hijacking not supported")
+	}
+	tw.started = true
+	return h.Hijack()
+}
+
+// timeout stops passing on the response and sends a 503 if nothing was
+// written yet.
+func (tw *timeoutWriter) timeout() {
+	tw.mux.Lock()
+	defer tw.mux.Unlock()
+	tw.timeoutLocked()
+}
+
+func (tw *timeoutWriter) timeoutLocked() {
+	if tw.timedOut {
+		return
+	}
+	if !tw.started {
+		tw.w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	tw.timedOut = true
+}
+
+// expiredLocked reports whether the time limit was exceeded. The handler can
+// see the deadline pass before limit does, so it checks the context as well.
+func (tw *timeoutWriter) expiredLocked() bool {
+	if !tw.timedOut && tw.ctx.Err() == context.DeadlineExceeded {
+		tw.timeoutLocked()
+	}
+	return tw.timedOut
+}
+
+// Deadline returns the time by which the request must have been handled
+// according to the time limit of its route. The boolean is false when the
+// route has no time limit.
+func Deadline(r *http.Request) (time.Time, bool) {
+	deadline, ok := r.Context().Value(deadlineKey).(time.Time)
+	return deadline, ok
+}
@@ -0,0 +1,148 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTreeMux_Timeout(t *testing.T) {
+	slow := func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(50 * time.Millisecond):
+		case <-r.Context().Done():
+			return
+		}
+		if IsStreaming(r) {
+			_, _ = w.Write([]byte("streamed"))
+			return
+		}
+		_, _ = w.Write([]byte("done"))
+	}
+
+	cases := []struct {
+		name     string
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{"mux default", "/default", 503, ""},
+		{"route override", "/override", 200, "done"},
+		{"disabled", "/disabled", 200, "done"},
+		{"streaming", "/streaming", 200, "streamed"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tr := NewTreeMux(OptionTimeout(10 * time.Millisecond))
+			tr.HandleFunc("/default", slow)
+			tr.HandleFunc("/override", slow, WithTimeout(time.Second))
+			tr.HandleFunc("/disabled", slow, WithTimeout(0))
+			tr.HandleFunc("/streaming", slow, Streaming(), WithTimeout(10*time.Millisecond))
+
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+			if w.Code != c.wantCode {
+				t.Errorf("expected %v, got %v", c.wantCode, w.Code)
+			}
+			if c.wantBody != "" && w.Body.String() != c.wantBody {
+				t.Errorf("expected %q, got %q", c.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	cases := []struct {
+		name  string
+		path  string
+		limit time.Duration
+	}{
+		{"route limit", "/limited", time.Second},
+		{"no limit", "/unlimited", 0},
+		{"streaming", "/streaming", 0},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var deadline, ctxDeadline time.Time
+			var ok, ctxOk bool
+			h := func(w http.ResponseWriter, r *http.Request) {
+				deadline, ok = Deadline(r)
+				ctxDeadline, ctxOk = r.Context().Deadline()
+			}
+			tr := NewTreeMux()
+			tr.HandleFunc("/limited", h, WithTimeout(time.Second))
+			tr.HandleFunc("/unlimited", h)
+			tr.HandleFunc("/streaming", h, WithTimeout(time.Second), Streaming())
+
+			start := time.Now()
+			tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, c.path, nil))
+			end := time.Now()
+
+			if ok != (c.limit > 0) {
+				t.Fatalf("expected deadline %v, got %v", c.limit > 0, ok)
+			}
+			if !ok {
+				return
+			}
+			if deadline.Before(start.Add(c.limit)) || deadline.After(end.Add(c.limit)) {
+				t.Errorf("expected deadline %v after start, got %v", c.limit, deadline.Sub(start))
+			}
+			if !ctxOk || ctxDeadline.After(deadline) {
+				t.Errorf("expected context deadline no later than %v, got %v", deadline, ctxDeadline)
+			}
+		})
+	}
+}
+
+func TestTreeMux_TimeoutFlush(t *testing.T) {
+	release := make(chan struct{})
+	tr := NewTreeMux()
+	tr.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("first\n"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		_, _ = w.Write([]byte("second\n"))
+	}, WithTimeout(time.Second))
+	srv := httptest.NewServer(tr)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/events")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	br := bufio.NewReader(resp.Body)
+	// the first line only arrives before the release when it was flushed
+	if line, err := br.ReadString('\n'); err != nil || line != "first\n" {
+		t.Fatalf("expected first line, got %q (%v)", line, err)
+	}
+	close(release)
+	if line, err := br.ReadString('\n'); err != nil || line != "second\n" {
+		t.Errorf("expected second line, got %q (%v)", line, err)
+	}
+}
+
+func TestTreeMux_TimeoutAfterWrite(t *testing.T) {
+	tr := NewTreeMux()
+	tr.HandleFunc("/partial", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("partial"))
+		<-r.Context().Done()
+		_, _ = w.Write([]byte(" more"))
+	}, WithTimeout(10*time.Millisecond))
+
+	w := httptest.NewRecorder()
+	tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/partial", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "partial" {
+		t.Errorf("expected 200 %q, got %d %q", "partial", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"strings"
+)
+
+// IsTLS is a Predicate that holds when the request was received over TLS.
+// Register a conditional route with it to serve TLS and plaintext requests
+// (i.e. h2c) for the same path with different handlers.
+func IsTLS(r *http.Request) bool {
+	return r.TLS != nil
+}
+
+// IsPlaintext is a Predicate that holds when the request was not received
+// over TLS.
+func IsPlaintext(r *http.Request) bool {
+	return r.TLS == nil
+}
+
+// TLSProtocol returns a Predicate that holds when one of the given
+// application protocols (i.e. "h2") was negotiated with ALPN.
+func TLSProtocol(protocols ...string) Predicate {
+	return func(r *http.Request) bool {
+		if r.TLS == nil {
+			return false
+		}
+		for _, p := range protocols {
+			if r.TLS.NegotiatedProtocol == p {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// TLSServerName returns a Predicate that holds when the client asked for one
+// of the given server names with SNI. Names are compared case-insensitively;
+// a name like "*.example.com" matches any single label in its place.
+func TLSServerName(names ...string) Predicate {
+	return func(r *http.Request) bool {
+		if r.TLS == nil || r.TLS.ServerName == "" {
+			return false
+		}
+		sni := strings.ToLower(r.TLS.ServerName)
+		for _, n := range names {
+			n = strings.ToLower(n)
+			if n == sni {
+				return true
+			}
+			if strings.HasPrefix(n, "*.") {
+				if i := strings.IndexByte(sni, '.'); i > 0 && sni[i:] == n[1:] {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// TLSCipherSuite returns a Predicate that holds when one of the given cipher
+// suites (see package crypto/tls) was negotiated.
+func TLSCipherSuite(suites ...uint16) Predicate {
+	return func(r *http.Request) bool {
+		if r.TLS == nil {
+			return false
+		}
+		for _, s := range suites {
+			if r.TLS.CipherSuite == s {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// TLSMinVersion returns a Predicate that holds when the negotiated TLS version
+// (i.e. tls.VersionTLS13) is at least the given version.
+func TLSMinVersion(version uint16) Predicate {
+	return func(r *http.Request) bool {
+		return r.TLS != nil && r.TLS.Version >= version
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTLSPredicates(t *testing.T) {
+	state := &tls.ConnectionState{
+		Version:            tls.VersionTLS13,
+		CipherSuite:        tls.TLS_AES_128_GCM_SHA256,
+		NegotiatedProtocol: "h2",
+		ServerName:         "API.example.com",
+	}
+
+	cases := []struct {
+		name  string
+		state *tls.ConnectionState
+		pred  Predicate
+		want  bool
+	}{
+		{"tls", state, IsTLS, true},
+		{"tls plaintext", nil, IsTLS, false},
+		{"plaintext", nil, IsPlaintext, true},
+		{"plaintext tls", state, IsPlaintext, false},
+		{"protocol", state, TLSProtocol("http/1.1", "h2"), true},
+		{"other protocol", state, TLSProtocol("http/1.1"), false},
+		{"protocol plaintext", nil, TLSProtocol("h2"), false},
+		{"server name", state, TLSServerName("api.example.com"), true},
+		{"wildcard server name", state, TLSServerName("*.example.com"), true},
+		{"other server name", state, TLSServerName("www.example.com", "*.example.org"), false},
+		{"wildcard one label", state, TLSServerName("*.com"), false},
+		{"server name plaintext", nil, TLSServerName("api.example.com"), false},
+		{"cipher suite", state, TLSCipherSuite(tls.TLS_AES_256_GCM_SHA384, tls.TLS_AES_128_GCM_SHA256), true},
+		{"other cipher suite", state, TLSCipherSuite(tls.TLS_AES_256_GCM_SHA384), false},
+		{"min version", state, TLSMinVersion(tls.VersionTLS12), true},
+		{"min version too low", state, TLSMinVersion(tls.VersionTLS13 + 1), false},
+		{"min version plaintext", nil, TLSMinVersion(tls.VersionTLS10), false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.TLS = c.state
+			if got := c.pred(r); got != c.want {
+				t.Errorf("expected %v, got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestIsTLS_routes(t *testing.T) {
+	tr := NewTreeMux()
+	tr.HandleFunc("/login", bodyHandler("tls"), WithPredicate(IsTLS))
+	tr.HandleFunc("/login", bodyHandler("plaintext"))
+
+	for _, state := range []*tls.ConnectionState{nil, {}} {
+		r := httptest.NewRequest(http.MethodGet, "/login", nil)
+		r.TLS = state
+		w := httptest.NewRecorder()
+		tr.ServeHTTP(w, r)
+		want := "plaintext"
+		if state != nil {
+			want = "tls"
+		}
+		if got := w.Body.String(); got != want {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// MatchTraceFunc receives the trace of matching a request to a route. The
+// pattern is empty when no route matched.
+type MatchTraceFunc func(r *http.Request, pattern string, mt MatchTrace, elapsed time.Duration)
+
+type optionMatchTrace struct {
+	value MatchTraceFunc
+}
+
+func (o optionMatchTrace) Apply(mux *treeMux) {
+	mux.matchTrace = o.value
+}
+
+func (o optionMatchTrace) private() {}
+
+// OptionMatchTrace traces the matching phase of every request: the trie nodes
+// that were inspected, the wildcards used and the time it took. It can be used
+// to emit spans or events to find pathological route tables.
+func OptionMatchTrace(fn MatchTraceFunc) Option {
+	return optionMatchTrace{fn}
+}
+
+// RouterTrace is a set of hooks that are called during the lifecycle of a
+// request, modelled after httptrace.ClientTrace. Any of the hooks may be nil.
+type RouterTrace struct {
+	// GotRequest is called when the mux receives a request.
+	GotRequest func(r *http.Request)
+	// Matched is called when the request was matched to a route.
+	Matched func(r *http.Request, pattern string)
+	// NotFound is called when no route matched the request. Why is
+	// available from RequestMatchInfo.
+	NotFound func(r *http.Request)
+	// HandlerDone is called when the handler has returned.
+	HandlerDone func(r *http.Request, pattern string, status int, elapsed time.Duration)
+	// Panic is called when the handler panics, before the panic is
+	// recovered from (see OptionRecovery).
+	Panic func(r *http.Request, pattern string, v interface{})
+}
+
+// compose returns a trace that calls the hooks of t before those of old.
+func (t *RouterTrace) compose(old *RouterTrace) *RouterTrace {
+	if old == nil {
+		return t
+	}
+	if t == nil {
+		return old
+	}
+	c := *t
+	if old.GotRequest != nil {
+		c.GotRequest = func(r *http.Request) {
+			if t.GotRequest != nil {
+				t.GotRequest(r)
+			}
+			old.GotRequest(r)
+		}
+	}
+	if old.Matched != nil {
+		c.Matched = func(r *http.Request, pattern string) {
+			if t.Matched != nil {
+				t.Matched(r, pattern)
+			}
+			old.Matched(r, pattern)
+		}
+	}
+	if old.NotFound != nil {
+		c.NotFound = func(r *http.Request) {
+			if t.NotFound != nil {
+				t.NotFound(r)
+			}
+			old.NotFound(r)
+		}
+	}
+	if old.HandlerDone != nil {
+		c.HandlerDone = func(r *http.Request, pattern string, status int, elapsed time.Duration) {
+			if t.HandlerDone != nil {
+				t.HandlerDone(r, pattern, status, elapsed)
+			}
+			old.HandlerDone(r, pattern, status, elapsed)
+		}
+	}
+	if old.Panic != nil {
+		c.Panic = func(r *http.Request, pattern string, v interface{}) {
+			if t.Panic != nil {
+				t.Panic(r, pattern, v)
+			}
+			old.Panic(r, pattern, v)
+		}
+	}
+	return &c
+}
+
+// WithRouterTrace returns a context that makes the mux call the trace's hooks
+// for requests with it. If the context already has a trace, both are called,
+// the new one first.
+func WithRouterTrace(ctx context.Context, trace *RouterTrace) context.Context {
+	return context.WithValue(ctx, routerTraceKey, trace.compose(ContextRouterTrace(ctx)))
+}
+
+// ContextRouterTrace returns the RouterTrace of the context, or nil.
+func ContextRouterTrace(ctx context.Context) *RouterTrace {
+	trace, _ := ctx.Value(routerTraceKey).(*RouterTrace)
+	return trace
+}
+
+type optionRouterTrace struct {
+	value *RouterTrace
+}
+
+func (o optionRouterTrace) Apply(mux *treeMux) {
+	mux.routerTrace = o.value
+}
+
+func (o optionRouterTrace) private() {}
+
+// OptionRouterTrace calls the trace's hooks for all requests. Hooks of traces
+// on the request context are called first.
+func OptionRouterTrace(trace *RouterTrace) Option {
+	return optionRouterTrace{trace}
+}
+
+// tracePanics wraps the handler so that panics are reported to the request's
+// trace.
+func tracePanics(h http.Handler, pattern string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		trace := ContextRouterTrace(r.Context())
+		if trace == nil || trace.Panic == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		defer func() {
+			if v := recover(); v != nil {
+				trace.Panic(r, pattern, v)
+				panic(v)
+			}
+		}()
+		h.ServeHTTP(w, r)
+	})
+}
+
+// serveTraced serves the request, calling the hooks of the trace.
+func (t *treeMux) serveTraced(w http.ResponseWriter, r *http.Request, trace *RouterTrace) {
+	start := t.clock.Now()
+	r = r.WithContext(context.WithValue(r.Context(), routerTraceKey, trace))
+	if trace.GotRequest != nil {
+		trace.GotRequest(r)
+	}
+	h, p, info := t.lookupHandler(r)
+	if p == "" {
+		if trace.NotFound != nil {
+			trace.NotFound(withMatchInfo(r, info))
+		}
+	} else if trace.Matched != nil {
+		trace.Matched(r, p)
+	}
+	rw := newResponseWriter(w)
+	t.wrap(h).ServeHTTP(rw, r)
+	if trace.HandlerDone != nil {
+		status := rw.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		trace.HandlerDone(r, p, status, t.clock.Now().Sub(start))
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOptionMatchTrace(t *testing.T) {
+	cases := []struct {
+		name        string
+		path        string
+		wantPattern string
+		want        MatchTrace
+	}{
+		{"literal", "/foo/bar", "/foo/bar", MatchTrace{Segments: 3, Inspected: 3}},
+		{"wildcard", "/foo/baz", "/foo/*", MatchTrace{Segments: 3, Inspected: 4, Wildcards: 1}},
+		{"backtrack", "/foo/bar/qux", "/foo/*/qux", MatchTrace{Segments: 4, Inspected: 5, Wildcards: 1, Backtracks: 1}},
+		{"not found", "/moo", "", MatchTrace{Segments: 2, Inspected: 2, Backtracks: 1}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var got MatchTrace
+			var pattern string
+			tr := NewTreeMux(OptionMatchTrace(func(r *http.Request, p string, mt MatchTrace, _ time.Duration) {
+				pattern, got = p, mt
+			}))
+			tr.Handle("/foo/bar", bodyHandler("bar"))
+			tr.Handle("/foo/*", bodyHandler("wildcard"))
+			tr.Handle("/foo/*/qux", bodyHandler("qux"))
+
+			tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, c.path, nil))
+			if pattern != c.wantPattern {
+				t.Errorf("expected %q, got %q", c.wantPattern, pattern)
+			}
+			if got != c.want {
+				t.Errorf("expected %+v, got %+v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestRouterTrace(t *testing.T) {
+	var events []string
+	record := func(prefix string) *RouterTrace {
+		return &RouterTrace{
+			GotRequest: func(r *http.Request) {
+				events = append(events, prefix+"got "+r.URL.Path)
+			},
+			Matched: func(r *http.Request, pattern string) {
+				events = append(events, prefix+"matched "+pattern)
+			},
+			NotFound: func(r *http.Request) {
+				events = append(events, prefix+"not found")
+			},
+			HandlerDone: func(r *http.Request, pattern string, status int, _ time.Duration) {
+				events = append(events, fmt.Sprintf("%sdone %s %d", prefix, pattern, status))
+			},
+			Panic: func(r *http.Request, pattern string, v interface{}) {
+				events = append(events, fmt.Sprintf("%spanic %s %v", prefix, pattern, v))
+			},
+		}
+	}
+
+	cases := []struct {
+		name   string
+		path   string
+		global bool
+		ctx    bool
+		want   []string
+	}{
+		{
+			"global",
+			"/foo/1", true, false,
+			[]string{"global got /foo/1", "global matched /foo/*", "global done /foo/* 200"},
+		},
+		{
+			"context first",
+			"/missing", true, true,
+			[]string{"ctx got /missing", "global got /missing", "ctx not found", "global not found",
+				"ctx done  404", "global done  404"},
+		},
+		{
+			"panic",
+			"/panic", false, true,
+			[]string{"ctx got /panic", "ctx matched /panic", "ctx panic /panic oops", "ctx done /panic 500"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			log.SetOutput(ioutil.Discard)
+			defer log.SetOutput(os.Stderr)
+			events = nil
+
+			options := []Option{OptionRecovery(nil)}
+			if c.global {
+				options = append(options, OptionRouterTrace(record("global ")))
+			}
+			tr := NewTreeMux(options...)
+			tr.Handle("/foo/*", bodyHandler("foo"))
+			tr.HandleFunc("/panic", func(http.ResponseWriter, *http.Request) {
+				panic("oops")
+			})
+
+			r := httptest.NewRequest(http.MethodGet, c.path, nil)
+			if c.ctx {
+				r = r.WithContext(WithRouterTrace(r.Context(), record("ctx ")))
+			}
+			tr.ServeHTTP(httptest.NewRecorder(), r)
+			if !reflect.DeepEqual(events, c.want) {
+				t.Errorf("expected %q, got %q", c.want, events)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"strings"
+)
+
+// RequestTransform modifies a request before it is passed to the handler.
+type RequestTransform func(r *http.Request)
+
+// SetRequestHeader returns a RequestTransform that sets a request header.
+func SetRequestHeader(key, value string) RequestTransform {
+	return func(r *http.Request) {
+		r.Header.Set(key, value)
+	}
+}
+
+// DeleteRequestHeader returns a RequestTransform that removes a request
+// header.
+func DeleteRequestHeader(key string) RequestTransform {
+	return func(r *http.Request) {
+		r.Header.Del(key)
+	}
+}
+
+// SetQueryParam returns a RequestTransform that sets a query parameter.
+func SetQueryParam(key, value string) RequestTransform {
+	return func(r *http.Request) {
+		q := r.URL.Query()
+		q.Set(key, value)
+		r.URL.RawQuery = q.Encode()
+	}
+}
+
+type withRequestTransform struct {
+	values []RequestTransform
+}
+
+func (o withRequestTransform) Apply(rt *route) {
+	rt.requestTransforms = append(rt.requestTransforms, o.values...)
+}
+
+func (o withRequestTransform) private() {}
+
+// WithRequestTransform applies the transformations, in order, to matched
+// requests right before they are passed to the handler. Guards and verifiers
+// see the original request. The transformations work on a copy, so the
+// original request is left untouched.
+func WithRequestTransform(fns ...RequestTransform) RouteOption {
+	return withRequestTransform{fns}
+}
+
+// transformRequest wraps the handler so that it receives transformed requests.
+func transformRequest(h http.Handler, fns []RequestTransform) http.Handler {
+	if len(fns) == 0 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r2 := r.Clone(r.Context())
+		for _, fn := range fns {
+			fn(r2)
+		}
+		h.ServeHTTP(w, r2)
+	})
+}
+
+// ResponseTransform modifies the response header right before it is written.
+type ResponseTransform func(header http.Header, status int)
+
+// SetResponseHeader returns a ResponseTransform that sets a response header,
+// overwriting any value set by the handler.
+func SetResponseHeader(key, value string) ResponseTransform {
+	return func(header http.Header, _ int) {
+		header.Set(key, value)
+	}
+}
+
+// DeleteResponseHeader returns a ResponseTransform that removes a response
+// header, i.e. to strip internal headers.
+func DeleteResponseHeader(key string) ResponseTransform {
+	return func(header http.Header, _ int) {
+		header.Del(key)
+	}
+}
+
+// RewriteLocation returns a ResponseTransform that replaces the prefix of the
+// Location header, i.e. to map the URLs of a proxied upstream onto the public
+// ones.
+func RewriteLocation(from, to string) ResponseTransform {
+	return func(header http.Header, _ int) {
+		if loc := header.Get("Location"); strings.HasPrefix(loc, from) {
+			header.Set("Location", to+strings.TrimPrefix(loc, from))
+		}
+	}
+}
+
+type withResponseTransform struct {
+	values []ResponseTransform
+}
+
+func (o withResponseTransform) Apply(rt *route) {
+	rt.responseTransforms = append(rt.responseTransforms, o.values...)
+}
+
+func (o withResponseTransform) private() {}
+
+// WithResponseTransform applies the transformations, in order, to the
+// response header of the handler before it is sent.
+func WithResponseTransform(fns ...ResponseTransform) RouteOption {
+	return withResponseTransform{fns}
+}
+
+// transformResponse wraps the handler so that its response header is
+// transformed.
+func transformResponse(h http.Handler, fns []ResponseTransform) http.Handler {
+	if len(fns) == 0 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := newResponseWriter(w)
+		rw.beforeHeader = func(status int) {
+			for _, fn := range fns {
+				fn(w.Header(), status)
+			}
+		}
+		h.ServeHTTP(rw, r)
+		if !rw.hasStarted() {
+			rw.WriteHeader(http.StatusOK)
+		}
+	})
+}
@@ -0,0 +1,101 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithRequestTransform(t *testing.T) {
+	var got *http.Request
+	tr := NewTreeMux()
+	tr.HandleFunc("/proxy/*", func(w http.ResponseWriter, r *http.Request) {
+		got = r
+	}, WithRequestTransform(
+		SetRequestHeader("Authorization", "Bearer upstream"),
+		DeleteRequestHeader("Cookie"),
+		SetQueryParam("page", "2"),
+	), WithGuard(func(r *http.Request) bool {
+		return r.Header.Get("Authorization") == "Bearer client"
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/proxy/foo?q=x&page=1", nil)
+	r.Header.Set("Authorization", "Bearer client")
+	r.Header.Set("Cookie", "session=1")
+	w := httptest.NewRecorder()
+	tr.ServeHTTP(w, r)
+
+	if w.Code != 200 || got == nil {
+		t.Fatalf("expected guard to see original request, got %v", w.Code)
+	}
+	if v := got.Header.Get("Authorization"); v != "Bearer upstream" {
+		t.Errorf("expected upstream token, got %q", v)
+	}
+	if v := got.Header.Get("Cookie"); v != "" {
+		t.Errorf("expected cookie to be removed, got %q", v)
+	}
+	if v := got.URL.RawQuery; v != "page=2&q=x" {
+		t.Errorf("expected rewritten query, got %q", v)
+	}
+	if v := r.Header.Get("Authorization"); v != "Bearer client" {
+		t.Errorf("expected original request to be untouched, got %q", v)
+	}
+}
+
+func TestWithResponseTransform(t *testing.T) {
+	cases := []struct {
+		name         string
+		handler      http.HandlerFunc
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			"redirect",
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Internal", "secret")
+				w.Header().Set("Cache-Control", "public")
+				w.Header().Set("Location", "http://upstream:8080/items/1")
+				w.WriteHeader(http.StatusFound)
+			},
+			302,
+			"https://example.com/api/items/1",
+		},
+		{
+			"implicit header",
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Internal", "secret")
+			},
+			200,
+			"",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tr := NewTreeMux()
+			tr.Handle("/api/*", c.handler, WithResponseTransform(
+				DeleteResponseHeader("X-Internal"),
+				SetResponseHeader("Cache-Control", "no-store"),
+				RewriteLocation("http://upstream:8080/", "https://example.com/api/"),
+			))
+
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/foo", nil))
+			if w.Code != c.wantCode {
+				t.Errorf("expected %v, got %v", c.wantCode, w.Code)
+			}
+			if v := w.Header().Get("X-Internal"); v != "" {
+				t.Errorf("expected internal header to be stripped, got %q", v)
+			}
+			if v := w.Header().Get("Cache-Control"); v != "no-store" {
+				t.Errorf("expected no-store, got %q", v)
+			}
+			if v := w.Header().Get("Location"); v != c.wantLocation {
+				t.Errorf("expected %q, got %q", c.wantLocation, v)
+			}
+		})
+	}
+}
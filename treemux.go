@@ -15,13 +15,37 @@
 //   "/countries/belgium/cities/wommelgem"
 //   "/countries/france/cities/lille"
 //
+// Wildcards can be named, with a colon or braces. Their values are available
+// to the handler with PathParam:
+//   t.Handle("/countries/:country/cities/{city}", handleCity)
+//
+// Named wildcards in braces can be restricted by a regular expression, which
+// has to match the whole path element. Paths that do not match fall through to
+// other routes:
+//   t.Handle("/orders/{id:[0-9]+}", handleOrder)
+//   t.Handle("/orders/export", handleExport)
+//
+// A final "**" (or "{name...}") is a catch-all that matches the remainder of
+// the path, including any further elements:
+//   t.Handle("/static/**", fileHandler)
+// Catch-all routes are only used for paths that do not match a regular route;
+// the longest prefix wins (see Mount).
+//
 // Wildcard cannot be used for parts of an element (i.e. `/foo*/bar` will not
 // work).
 package treemux
 
 import (
-	"log"
+	"context"
+	"io"
+	"io/fs"
+	"net"
 	"net/http"
+	"net/http/cgi"
+	"os"
+	"sort"
+	"sync"
+	"time"
 )
 
 type TreeMux interface {
@@ -34,56 +58,685 @@ type TreeMux interface {
 	// the same result.
 	//   t.Handle("/foo/bar", fn)
 	//   t.Handle("foo/bar", fn)
-	Handle(path string, handler http.Handler)
+	//
+	// Route options can be used to restrict the requests the handler applies
+	// to. Multiple handlers can be registered for the same path as long as
+	// they are conditional (see WithPredicate).
+	Handle(path string, handler http.Handler, options ...RouteOption)
 
 	// HandleFunc adds a new http.HandlerFunc for the given path. See Handle for
 	// more details.
-	HandleFunc(path string, handler func(http.ResponseWriter, *http.Request))
+	HandleFunc(path string, handler func(http.ResponseWriter, *http.Request), options ...RouteOption)
+
+	// TryHandle is like Handle, but returns a RegistrationError instead of
+	// panicking when the path is invalid, i.e. when it comes from user
+	// configuration. Nothing is registered in that case.
+	TryHandle(path string, handler http.Handler, options ...RouteOption) error
+
+	// Any adds a handler for the given path that accepts any method,
+	// including extension methods. Routes restricted to some methods (see
+	// WithMethods) take precedence, so Any handles the remaining methods.
+	//   t.Handle("/dav/*", propfind, WithMethods("PROPFIND"))
+	//   t.Any("/dav/*", dav)
+	Any(path string, handler http.Handler, options ...RouteOption)
+
+	// HandleMethod adds a handler for requests with the given method on the
+	// path. It is a shorthand for Handle with WithMethods, so the same path
+	// can dispatch to different handlers per method.
+	//   t.HandleMethod(http.MethodGet, "/foo/*", getFoo)
+	//   t.HandleMethod(http.MethodPut, "/foo/*", putFoo)
+	HandleMethod(method, path string, handler http.Handler, options ...RouteOption)
+
+	// Get, Post, Put, Delete and Patch add a handler function for requests
+	// with the corresponding method on the path (see HandleMethod).
+	//   t.Get("/items/*", getItem)
+	//   t.Put("/items/*", putItem)
+	Get(path string, handler func(http.ResponseWriter, *http.Request), options ...RouteOption)
+	Post(path string, handler func(http.ResponseWriter, *http.Request), options ...RouteOption)
+	Put(path string, handler func(http.ResponseWriter, *http.Request), options ...RouteOption)
+	Delete(path string, handler func(http.ResponseWriter, *http.Request), options ...RouteOption)
+	Patch(path string, handler func(http.ResponseWriter, *http.Request), options ...RouteOption)
+
+	// Use adds middleware that wraps the handler of every request, including
+	// the not found handler. The middleware is applied after the route was
+	// matched, the first middleware being the outermost. It should be called
+	// before the mux starts serving.
+	//   t.Use(requestID, authenticate)
+	Use(middleware ...func(http.Handler) http.Handler)
+
+	// Walk calls fn for every registered route, in order of pattern, until
+	// it returns false. The handler is the one that was registered, without
+	// the route options applied. Conditional routes on the same pattern
+	// are visited in order of registration.
+	Walk(fn func(pattern string, h http.Handler) bool)
+
+	// Routes returns a description of every registered route, in the same
+	// order as Walk, i.e. to generate documentation.
+	Routes() []Route
+
+	// View returns a read-only view of the mux that only exposes the routes
+	// passing the filter, i.e. to serve a restricted listener from the same
+	// route table:
+	//
+	//   public := mux.View(func(r treemux.Route) bool {
+	//   	return r.Metadata["internal"] == nil
+	//   })
+	View(filter func(Route) bool) *View
+
+	// HandleHost is like Handle, but the route only applies to requests for
+	// the host. Every host has a route tree of its own, which is consulted
+	// before the routes without a host. Host patterns can have wildcards for
+	// whole labels, like "*.example.com"; like with paths, the first
+	// registered matching host wins. Catch-alls are not supported.
+	//
+	// Features keyed by pattern, like metrics, do not distinguish between
+	// hosts.
+	HandleHost(host, path string, handler http.Handler, options ...RouteOption)
+
+	// ServeFiles serves the files of root on a catch-all pattern, using the
+	// remainder of the path as file name:
+	//
+	//   mux.ServeFiles("/assets/**", http.Dir("./public"))
+	//
+	// Paths with ".." elements are rejected. Directories are served by their
+	// index.html file, if any. Missing files get the not found response of the
+	// mux. It panics when the pattern does not end with a catch-all.
+	ServeFiles(pattern string, root http.FileSystem, options ...RouteOption)
+
+	// URL returns the path for a route, with its wildcards substituted by the
+	// params, in order. The route is identified by name (see WithName) or by
+	// the pattern it was registered with:
+	//
+	//   path, err := mux.URL("/orders/{id}/items/{item}", "42", "7")
+	//
+	// It returns an error when there is no such route, or when the params do
+	// not fit the pattern. Host routes are not supported.
+	URL(pattern string, params ...string) (string, error)
+
+	// DefineFragment defines a reusable piece of pattern, which can be
+	// referenced in patterns registered later as "{@name}":
+	//
+	//   mux.DefineFragment("tenant", "tenants/{tenant}")
+	//   mux.HandleFunc("/{@tenant}/users/{user}", handleUser)
+	//
+	// Fragments cannot reference other fragments. It panics when the
+	// fragment is already defined.
+	DefineFragment(name, fragment string)
+
+	// Group returns a Group for registering routes under the prefix, with
+	// the route options applied to all of them. Groups share the routes of
+	// the mux.
+	//   v1 := t.Group("/api/v1", WithMetadata("version", 1))
+	//   v1.Get("/items/*", getItem)
+	Group(prefix string, options ...RouteOption) *Group
+
+	// Mount adds a handler for all paths starting with the given path
+	// elements, like a grpc-gateway runtime.ServeMux or an http.ServeMux.
+	// Mounted handlers are only used for requests that do not match a
+	// regular route; the longest mount path wins.
+	//   t.Mount("/v1", gwmux)
+	Mount(path string, handler http.Handler, options ...RouteOption)
+
+	// MountService adds an RPC service handler, like one created by Connect
+	// or Twirp, under the service path (i.e. "/acme.foo.v1.FooService/").
+	// Every procedure gets an exact POST route, with the service and method
+	// names as route metadata (see MetadataRPCService). Procedures can be
+	// given by name or full path. Without procedures, all POST requests under
+	// the path are passed on.
+	//   path, h := foov1connect.NewFooServiceHandler(svc)
+	//   t.MountService(path, h, []string{"GetFoo", "ListFoos"})
+	MountService(path string, handler http.Handler, procedures []string, options ...RouteOption)
+
+	// Honeypot adds decoy routes for paths that only scanners and attackers
+	// request. Hits call the action, for instance to log the request or ban
+	// the client, and get the not found response, so they look like any
+	// other unknown path. The routes have the MetadataHoneypot metadata and
+	// are left out of sitemaps.
+	//   t.Honeypot([]string{"/wp-admin/**", "/.env"}, ban)
+	Honeypot(patterns []string, action func(r *http.Request), options ...RouteOption)
+
+	// ACME serves ACME HTTP-01 challenges (RFC 8555) with the manager, like
+	// an autocert.Manager. The challenge route has no route options, so
+	// challenges are not subject to guards or rate limits, and they are not
+	// redirected by OptionRedirectHTTP.
+	//   m := &autocert.Manager{Prompt: autocert.AcceptTOS, Cache: autocert.DirCache("certs")}
+	//   t.ACME(m)
+	ACME(manager ACMEManager)
+
+	// WellKnown serves a document at a well-known location, like
+	// "/robots.txt" or "/.well-known/security.txt". These documents are
+	// matched before any other route, so they cannot be shadowed by a
+	// wildcard or mounted handler.
+	//   t.WellKnown("/.well-known/change-password", treemux.WellKnownDocument{Redirect: "/account/password"})
+	WellKnown(path string, doc WellKnownDocument, options ...RouteOption)
+
+	// WellKnownFS serves robots.txt and all documents in the .well-known
+	// directory of the file system (see WellKnown), with the given maximum
+	// age (zero for the default).
+	//   //go:embed robots.txt .well-known
+	//   var static embed.FS
+	//   err := t.WellKnownFS(static, 0)
+	WellKnownFS(fsys fs.FS, maxAge time.Duration, options ...RouteOption) error
+
+	// WebDAV mounts a WebDAV handler, like the Handler of package
+	// golang.org/x/net/webdav, at the path. Requests for the path and all
+	// paths below it are passed on with any method, including the WebDAV
+	// methods, unless a regular route matches them. The request path is not
+	// stripped, as the handler needs it to refer to resources in its
+	// responses; configure the prefix on the handler instead.
+	//   t.WebDAV("/dav/**", &webdav.Handler{
+	//     Prefix:     "/dav",
+	//     FileSystem: webdav.Dir("/srv/dav"),
+	//     LockSystem: webdav.NewMemLS(),
+	//   })
+	WebDAV(path string, handler http.Handler, options ...RouteOption)
+
+	// HandleGraphQL adds a GraphQL endpoint for GET and POST requests.
+	// Operations, identified by name or persisted query hash, get their own
+	// route with additional options, like rate limits or guards, and their
+	// identifier as metadata (see MetadataGraphQLOperation). Other requests
+	// get the endpoint options only.
+	//   t.HandleGraphQL("/graphql", h, []GraphQLOperation{
+	//   	{Name: "Login", Options: []RouteOption{WithRateLimit(rl)}},
+	//   })
+	HandleGraphQL(path string, handler http.Handler, operations []GraphQLOperation, options ...RouteOption)
+
+	// HandleStream adds a streaming handler, writing a response of the given
+	// content type (newline-delimited JSON by default) in chunks that are
+	// flushed immediately. The route is marked Streaming, so time limits and
+	// other buffering wrappers are not applied. The response headers are
+	// sent before fn is called, so errors returned by fn can only be logged.
+	//   t.HandleStream("/events", "", func(r *http.Request, s *Stream) error {
+	//   	for ev := range events(r.Context()) {
+	//   		if err := s.Send(ev); err != nil {
+	//   			return err
+	//   		}
+	//   	}
+	//   	return nil
+	//   })
+	HandleStream(path, contentType string, fn StreamFunc, options ...RouteOption)
+
+	// HandleCGI adds a CGI script for all paths under the given path. The
+	// path becomes the SCRIPT_NAME, the rest of the request path the
+	// PATH_INFO. Internal redirects by the script are served by the mux,
+	// unless the handler has a PathLocationHandler.
+	HandleCGI(path string, handler *cgi.Handler, options ...RouteOption)
 
+	// HandleFastCGI adds a FastCGI responder for all paths under the given
+	// path, with the SCRIPT_NAME and PATH_INFO derived like HandleCGI does.
+	HandleFastCGI(path string, handler *FastCGI, options ...RouteOption)
+
+	// HandleHTTPRule adds a handler for the given method and google.api.http
+	// path template (see the function for details).
+	HandleHTTPRule(method, template string, handler http.Handler, options ...RouteOption) error
+
+	// Webhook adds a webhook receiver for the given path. The last wildcard
+	// or path parameter in the path identifies the provider, whose verifier is used to check
+	// the request before it is dispatched by event type. Requests for unknown
+	// providers are not found, events without a handler are acknowledged with
+	// a 204.
+	//   t.Webhook("/hooks/{provider}", verifiers, dispatcher)
+	Webhook(path string, verifiers map[string]RequestVerifier, dispatcher *WebhookDispatcher, options ...RouteOption)
+
+	// Serve serves the mux on the listeners, until one of them fails (see
+	// SystemdListeners and WithDefaultHost).
+	Serve(listeners ...net.Listener) error
+
+	// ListenAndServeUnix serves the mux on a unix domain socket with the
+	// given file permissions. A stale socket file is removed first, the
+	// socket file is removed when done. Paths starting with "@" are abstract
+	// sockets (Linux only).
+	ListenAndServeUnix(path string, perm os.FileMode) error
+
+	// Admin returns a new mux with administrative endpoints for this one,
+	// meant to be served on a private port:
+	//   /healthz            health check, fails when shutting down
+	//   /routes             route table as JSON
+	//   /metrics            metrics in the Prometheus text format, or in the
+	//                       OpenMetrics format (with exemplars) if accepted
+	//   /debug/routes       route table Snapshot
+	//   /debug/routes/hash  route table SnapshotHash
+	//   /debug/slo          SLO report (with OptionSLOTracker)
+	//   /debug/notfound     unmatched request counts (with OptionNotFoundStats)
+	//   /debug/params       wildcard value cardinality (with OptionParamStats)
+	// The admin mux uses the same logger, unless overridden by the options.
+	Admin(options ...Option) TreeMux
+
+	// Warmup serves the requests, to prime caches and such before the mux
+	// starts serving real traffic. It returns an error listing the requests
+	// that did not match a route or got an unexpected status. Handlers can
+	// recognise warmup requests with IsWarmup.
+	Warmup(ctx context.Context, requests []WarmupRequest) error
+
+	// Snapshot returns a canonical, stable text representation of the route
+	// table, for use in golden file tests. Each route is listed on a line of
+	// its own, sorted by pattern and then in evaluation order.
+	Snapshot() []byte
+
+	// SnapshotHash returns a hash of the Snapshot, so that config controllers
+	// can cheaply detect whether a reload changed the routing. The Admin mux
+	// also sets it as the HeaderRouteTableHash header of its route table
+	// responses.
+	SnapshotHash() string
+
+	// Shutdown gracefully shuts down the mux. New requests get a 503 response
+	// with a "Connection: close" header, while the requests being served are
+	// finished. Then the route shutdown functions (see WithShutdown) are
+	// called, handlers are closed (see RouteRegisterer), background jobs
+	// are stopped (see WithJob), buffered usage records are exported (see
+	// OptionUsageExport) and the servers started with Serve are shut down.
+	// It returns early with the context's error when the context is done
+	// first.
+	Shutdown(ctx context.Context) error
+
+	// Handler returns the handler to use for the given request and the
+	// pattern it was registered with, like http.ServeMux.Handler. The pattern
+	// identifies the route regardless of the wildcard values in the path, so
+	// it can be used as a key for metrics and logging. If the request cannot
+	// be matched, the not found handler (or the OptionFallthrough handler)
+	// and an empty pattern are returned.
 	Handler(r *http.Request) (h http.Handler, pattern string)
+
+	// WriteSitemap writes a sitemap of the routes of the mux (see Sitemap and
+	// WithSitemap).
+	WriteSitemap(w io.Writer, cfg Sitemap) error
+
+	// HandleSitemap serves the sitemap of the routes of the mux at the path.
+	// It is generated for every request, so it includes routes added later.
+	//   t.HandleSitemap("/sitemap.xml", treemux.Sitemap{BaseURL: "https://example.com"})
+	HandleSitemap(path string, cfg Sitemap, options ...RouteOption)
+
+	// Client returns a client for the mux's named routes (see WithName),
+	// served at the given base URL.
+	//   c := t.Client("https://api.example.com")
+	//   resp, err := c.Do(ctx, "city", []string{"france", "lille"}, nil)
+	Client(baseURL string) *Client
+
+	// GenerateRoutes writes Go source with constants for the mux's named
+	// routes to w, using the given package name.
+	GenerateRoutes(w io.Writer, pkg string) error
+
+	// ExportKubernetes writes the route table as a Kubernetes Ingress or
+	// HTTPRoute resource to w. Method restrictions are exported as HTTPRoute
+	// method matches and host routes get their own host rule or HTTPRoute.
+	ExportKubernetes(w io.Writer, cfg KubernetesExport) error
+
+	// ImportKubernetes registers routes for the Kubernetes HTTPRoute and
+	// Ingress resources in data, dispatching to the handlers of the named
+	// backends.
+	ImportKubernetes(data []byte, backends map[string]http.Handler) error
+
+	// ImportEnvoy registers routes for an Envoy route configuration,
+	// forwarding requests to the handlers of the named clusters.
+	ImportEnvoy(data []byte, clusters map[string]http.Handler) error
 }
 
 type treeMux struct {
-	trie     WildcardTrie
-	notFound http.HandlerFunc
-	debug    bool
+	matcher    Matcher
+	fastMiss   *missFilter
+	missCache  *missCache
+	endpoints  map[string]*endpoint
+	names      map[string]string
+	fragments  map[string]string
+	prefixes   map[string]*endpoint
+	hosts      WildcardTrie[*hostTable]
+	notFound   http.HandlerFunc
+	forbidden  http.HandlerFunc
+	notAllowed http.HandlerFunc
+	fallback   http.Handler
+	timeout    time.Duration
+	debug      bool
+	strict     bool
+
+	onClientGone  ClientGoneFunc
+	watchdog      time.Duration
+	watchdogStack bool
+	recover       bool
+	recovery      http.HandlerFunc
+	panicBudget   *PanicBudget
+	chaos         *ChaosInjector
+	notFoundStats *NotFoundStats
+	paramStats    *ParamStats
+	latency       *LatencyHistogram
+	sloTracker    *SLOTracker
+	admission     *admission
+	matchTrace    MatchTraceFunc
+	matchBudget   int
+	matrixParams  bool
+	httpsRedirect *httpsRedirect
+	wellKnown     map[string]*route
+	cors          *cors
+	policy        PolicyEvaluator
+	usage         *usageMeter
+	middleware    []func(http.Handler) http.Handler
+	routerTrace   *RouterTrace
+	logger        Logger
+	logf          logFunc
+	accessLog     bool
+
+	accessLogHeaders []string
+	notFoundAnalysis *NotFoundAnalysis
+
+	errorRenderers []errorRenderer
+	rateLimitStore RateLimitStore
+	quotaStore     QuotaStore
+	clock          Clock
+
+	draining   int32
+	stopping   chan struct{}
+	inFlight   inFlight
+	serversMux sync.Mutex
+	servers    []*http.Server
+
+	// routesMux guards the routes, so they can be added while serving.
+	routesMux sync.RWMutex
+	jobs      supervisor
 }
 
 func (t *treeMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	h, p := t.Handler(r)
-	if t.debug {
-		log.Printf("DEBUG: used route pattern '%s' for '%s'", p, r.URL.Path)
+	t.inFlight.add(1)
+	defer t.inFlight.add(-1)
+	if t.drained(w, r) {
+		return
+	}
+	if t.httpsRedirect != nil && t.httpsRedirect.redirect(w, r) {
+		return
+	}
+	if t.matrixParams {
+		r = stripMatrixParams(r)
+	}
+	if t.cors != nil && t.cors.serve(t, w, r) {
+		return
+	}
+	if trace := ContextRouterTrace(r.Context()).compose(t.routerTrace); trace != nil {
+		t.serveTraced(w, r, trace)
+		return
+	}
+	h, _, _ := t.lookupHandler(r)
+	t.wrap(h).ServeHTTP(w, r)
+}
+
+// lookupHandler is like Handler, but also takes care of tracing, debug logging
+// and statistics. It also returns how the request was matched.
+func (t *treeMux) lookupHandler(r *http.Request) (http.Handler, string, MatchInfo) {
+	var rt *route
+	var info MatchInfo
+	if t.matchTrace != nil {
+		start := t.clock.Now()
+		mt := &MatchTrace{}
+		rt, info = t.match(r, mt)
+		var p string
+		if rt != nil {
+			p = rt.pattern
+		}
+		t.matchTrace(r, p, *mt, t.clock.Now().Sub(start))
+	} else {
+		rt, info = t.match(r, nil)
+	}
+	if rt == nil {
+		t.logf(LogDebug, "no route matched", "path", r.URL.Path)
+		if t.fallback != nil {
+			return withMiss(t.fallback, info), "", info
+		}
+		if t.notFoundStats != nil {
+			t.notFoundStats.record(t.matcher.Prefix(r.URL.Path))
+		}
+		if t.notFoundAnalysis != nil {
+			t.notFoundAnalysis.analyze(withClock(r, t.clock))
+		}
+		return withMiss(t.notFound, info), "", info
+	}
+	rt.logf(LogDebug, "matched route", "pattern", rt.pattern, "path", r.URL.Path)
+	return rt.serve, rt.pattern, info
+}
+
+func (t *treeMux) Handle(path string, handler http.Handler, options ...RouteOption) {
+	path = t.expandFragments(path)
+	if t.strict {
+		if err := checkCatchAll(path); err != nil {
+			panic(err.Error())
+		}
+	}
+	if prefix, p, ok := parseCatchAll(normalisePattern(path)); ok {
+		t.handlePrefix(prefix+"/", handler, append([]RouteOption{withPathParams{[]pathParam{p}}}, options...)...)
+		return
+	}
+	pattern, params := parsePathParams(normalisePattern(path))
+	if err := checkPattern(pattern); err != nil {
+		panic(err.Error())
 	}
-	h.ServeHTTP(w, r)
+	if params != nil {
+		options = append([]RouteOption{withPathParams{params}}, options...)
+	}
+	rt := t.newRoute(pattern, handler, options)
+	t.register(t.endpoint(pattern), rt)
+}
+
+// endpoint returns the endpoint for the pattern, adding it when needed.
+func (t *treeMux) endpoint(pattern string) *endpoint {
+	t.routesMux.Lock()
+	defer t.routesMux.Unlock()
+	e, ok := t.endpoints[pattern]
+	if !ok {
+		e = &endpoint{pattern: pattern}
+		t.matcher.Add(pattern, e)
+		if t.fastMiss != nil {
+			t.fastMiss.add(pattern)
+		}
+		if t.missCache != nil {
+			t.missCache.reset()
+		}
+		t.endpoints[pattern] = e
+	}
+	return e
+}
+
+func (t *treeMux) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request), options ...RouteOption) {
+	t.Handle(pattern, http.HandlerFunc(handler), options...)
+}
+
+func (t *treeMux) Handler(r *http.Request) (http.Handler, string) {
+	if t.matrixParams {
+		r = stripMatrixParams(r)
+	}
+	rt, info := t.match(r, nil)
+	if rt != nil {
+		return rt.serve, rt.pattern
+	}
+	if t.fallback != nil {
+		return withMiss(t.fallback, info), ""
+	}
+	return withMiss(t.notFound, info), ""
 }
 
-func (t *treeMux) Handle(path string, handler http.Handler) {
-	t.trie.Add(path, handler)
+// newRoute creates a route with the options applied and its handler composed.
+func (t *treeMux) newRoute(pattern string, handler http.Handler, options []RouteOption) *route {
+	rt := &route{pattern: pattern, handler: handler, store: &Store{}, clock: t.clock}
+	for _, o := range options {
+		o.Apply(rt)
+	}
+	if t.strict {
+		if err := t.checkConflict(rt); err != nil {
+			panic(err)
+		}
+	}
+	rt.logf = t.routeLogger(rt)
+	rt.serve = t.compose(rt)
+	if rt.name != "" {
+		t.routesMux.Lock()
+		t.names[rt.name] = pattern
+		t.routesMux.Unlock()
+	}
+	return rt
 }
 
-func (t *treeMux) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
-	t.Handle(pattern, http.HandlerFunc(handler))
+func (t *treeMux) Routes() []Route {
+	var xs []Route
+	for _, rt := range t.routes() {
+		xs = append(xs, rt.info())
+	}
+	return xs
 }
 
-func (t treeMux) Handler(r *http.Request) (http.Handler, string) {
-	v, pattern := t.trie.Get(r.URL.Path)
-	if pattern == "" {
-		return t.notFound, pattern
+func (t *treeMux) Walk(fn func(pattern string, h http.Handler) bool) {
+	for _, rt := range t.routes() {
+		if !fn(rt.pattern, rt.handler) {
+			return
+		}
+	}
+}
+
+// routes returns all registered routes, sorted by pattern.
+func (t *treeMux) routes() []*route {
+	t.routesMux.RLock()
+	defer t.routesMux.RUnlock()
+	var es []*endpoint
+	for _, e := range t.endpoints {
+		es = append(es, e)
+	}
+	for _, e := range t.prefixes {
+		es = append(es, e)
+	}
+	if t.hosts != nil {
+		t.hosts.Walk(func(_ string, h *hostTable) bool {
+			if h != nil {
+				for _, e := range h.endpoints {
+					es = append(es, e)
+				}
+			}
+			return true
+		})
+	}
+	sort.Slice(es, func(i, j int) bool {
+		if es[i].pattern == es[j].pattern {
+			return es[i].host < es[j].host
+		}
+		return es[i].pattern < es[j].pattern
+	})
+	var rts []*route
+	for _, e := range es {
+		rts = append(rts, e.routes...)
+	}
+	return rts
+}
+
+// match returns the route for the request, or nil if there is none, and how
+// it was matched. The work done is recorded in mt, when not nil.
+func (t *treeMux) match(r *http.Request, mt *MatchTrace) (*route, MatchInfo) {
+	t.routesMux.RLock()
+	defer t.routesMux.RUnlock()
+	if rt, ok := t.wellKnown[r.URL.Path]; ok {
+		return rt, MatchInfo{Pattern: rt.pattern}
+	}
+	// a host route that rejected the request explains the miss best
+	miss := MatchInfo{Miss: MissPath}
+	if t.hosts != nil {
+		rt, e := t.matchHost(r, mt)
+		if rt != nil {
+			return rt, MatchInfo{Pattern: rt.pattern}
+		}
+		if e != nil {
+			miss = MatchInfo{Pattern: e.pattern, Miss: MissPredicate}
+		}
+	}
+	if t.fastMiss != nil && t.fastMiss.miss(r.URL.Path) {
+		return nil, miss
+	}
+	if t.missCache != nil && t.missCache.contains(r.URL.Path) {
+		return nil, miss
+	}
+	if t.matchBudget > 0 {
+		if mt == nil {
+			mt = &MatchTrace{}
+		}
+		mt.budget = t.matchBudget
+	}
+	v, _ := t.matcher.Trace(r.URL.Path, mt)
+	if mt != nil && mt.Exceeded {
+		t.logf(LogWarning, "match budget exceeded", "path", r.URL.Path, "steps", mt.Inspected)
+		return nil, MatchInfo{Miss: MissBudget}
+	}
+	e, _ := v.(*endpoint)
+	if e != nil {
+		if rt := e.lookup(r); rt != nil {
+			return rt, MatchInfo{Pattern: rt.pattern}
+		}
+	}
+	rt, found := t.matchPrefix(r)
+	if rt != nil {
+		return rt, MatchInfo{Pattern: rt.pattern}
+	}
+	if e != nil {
+		if allow := e.allowedMethods(r); len(allow) > 0 {
+			return t.methodNotAllowed(e, allow), MatchInfo{Pattern: e.pattern, Miss: MissMethod}
+		}
+	}
+	if e == nil && !found && t.missCache != nil {
+		t.missCache.add(r.URL.Path)
+	}
+	switch {
+	case miss.Miss != MissPath:
+	case e != nil:
+		miss = MatchInfo{Pattern: e.pattern, Miss: MissPredicate}
+	case found:
+		miss = MatchInfo{Miss: MissPredicate}
 	}
-	return v.(http.Handler), r.URL.Path
+	return nil, miss
 }
 
 // NewTreeMux creates a new tree-based request multiplexer. If a request path
 // cannot be matched, the standard `http.NotFound` will be used unless
-// OptionNotFound specifies a different one.
+// OptionNotFound specifies a different one. Likewise, requests rejected by a
+// route guard get a plain 403 response unless OptionForbidden is used. Use
+// OptionErrorRenderer to change the format of these responses.
 func NewTreeMux(options ...Option) TreeMux {
 	t := &treeMux{
-		trie:     newWildcardTrie("/"),
-		notFound: http.NotFound,
+		matcher:   newWildcardTrie("/"),
+		endpoints: make(map[string]*endpoint),
+		names:     make(map[string]string),
+		stopping:  make(chan struct{}),
+		clock:     realClock{},
+		logger:    StdLogger(nil),
 	}
 	for _, o := range options {
 		o.Apply(t)
 	}
+	if t.rateLimitStore == nil {
+		t.rateLimitStore = newMemoryStore(t.clock)
+	}
+	if t.quotaStore == nil {
+		t.quotaStore = newMemoryStore(t.clock)
+	}
+	if t.cors != nil {
+		t.cors.clock = t.clock
+	}
+	if t.notFound == nil {
+		t.notFound = t.errorHandler(http.StatusNotFound)
+	}
+	if t.forbidden == nil {
+		t.forbidden = t.errorHandler(http.StatusForbidden)
+	}
+	if t.notAllowed == nil {
+		t.notAllowed = t.errorHandler(http.StatusMethodNotAllowed)
+	}
+	if t.recover && t.recovery == nil {
+		t.recovery = t.errorHandler(http.StatusInternalServerError)
+	}
+	if t.admission != nil {
+		t.admission.tracker = t.sloTracker
+		t.admission.clock = t.clock
+	}
+	if t.sloTracker != nil {
+		t.sloTracker.clock = t.clock
+	}
+	t.logf = t.routeLogger(nil)
+	if t.usage != nil {
+		t.usage.logf = t.logf
+		t.usage.clock = t.clock
+	}
+	if t.accessLog {
+		t.notFound = t.accessLogger(t.notFound, nil).ServeHTTP
+	}
 	return t
 }
 
@@ -106,6 +759,61 @@ func OptionNotFound(handler http.HandlerFunc) Option {
 	return optionNotFound{handler}
 }
 
+type optionFallthrough struct {
+	value http.Handler
+}
+
+func (o optionFallthrough) Apply(mux *treeMux) {
+	mux.fallback = o.value
+}
+
+func (o optionFallthrough) private() {}
+
+// OptionFallthrough delegates requests that do not match a route to the next
+// handler, instead of responding with 404. This allows for moving routes from
+// another router (i.e. of a legacy framework) one by one. Delegated requests
+// do not count as not found (see OptionNotFoundStats). Responses by routes,
+// like method not allowed or missing files (see ServeFiles), are not
+// affected.
+func OptionFallthrough(next http.Handler) Option {
+	if next == nil {
+		panic("fallthrough handler cannot be nil")
+	}
+	return optionFallthrough{next}
+}
+
+type optionForbidden struct {
+	value http.HandlerFunc
+}
+
+func (o optionForbidden) Apply(mux *treeMux) {
+	mux.forbidden = o.value
+}
+
+func (o optionForbidden) private() {}
+
+// OptionForbidden sets the handler used when a route guard rejects a request.
+func OptionForbidden(handler http.HandlerFunc) Option {
+	return optionForbidden{handler}
+}
+
+type optionMethodNotAllowed struct {
+	value http.HandlerFunc
+}
+
+func (o optionMethodNotAllowed) Apply(mux *treeMux) {
+	mux.notAllowed = o.value
+}
+
+func (o optionMethodNotAllowed) private() {}
+
+// OptionMethodNotAllowed sets the handler used when a path matches, but none
+// of its routes accepts the request method (see WithMethods). The Allow header
+// is set before the handler is called.
+func OptionMethodNotAllowed(handler http.HandlerFunc) Option {
+	return optionMethodNotAllowed{handler}
+}
+
 type optionDebug struct {
 }
 
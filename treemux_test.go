@@ -5,6 +5,7 @@
 package treemux
 
 import (
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -64,3 +65,66 @@ func TestTreeMux_Handle(t *testing.T) {
 		})
 	}
 }
+
+func TestTreeMux_HandleWhileServing(t *testing.T) {
+	tr := NewTreeMux()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			tr.HandleFunc(fmt.Sprintf("/foo/%d", i), bodyHandler("foo"))
+			tr.Mount(fmt.Sprintf("/bar/%d", i), bodyHandler("bar"))
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, fmt.Sprintf("/foo/%d", i), nil))
+		tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, fmt.Sprintf("/bar/%d/x", i), nil))
+	}
+	<-done
+	w := httptest.NewRecorder()
+	tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/foo/99", nil))
+	if w.Body.String() != "foo" {
+		t.Errorf("expected foo, got %q", w.Body.String())
+	}
+}
+
+func TestTreeMux_Walk(t *testing.T) {
+	tr := NewTreeMux()
+	tr.HandleFunc("/foo/*", bodyHandler("foo"))
+	tr.HandleFunc("/bar", bodyHandler("bar"))
+	tr.Mount("/static", bodyHandler("static"))
+
+	var got []string
+	tr.Walk(func(pattern string, h http.Handler) bool {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		got = append(got, pattern+"="+w.Body.String())
+		return true
+	})
+	want := "[/bar=bar /foo/*=foo /static/**=static]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("expected %s, got %v", want, got)
+	}
+
+	n := 0
+	tr.Walk(func(string, http.Handler) bool {
+		n += 1
+		return false
+	})
+	if n != 1 {
+		t.Errorf("expected walk to stop after 1, got %d", n)
+	}
+}
+
+func TestTreeMux_Routes(t *testing.T) {
+	tr := NewTreeMux()
+	tr.HandleFunc("/orders/{id}/items/*", bodyHandler("items"), WithName("items"), WithMethods(http.MethodGet))
+	tr.Mount("/static", bodyHandler("static"))
+
+	got := fmt.Sprintf("%+v", tr.Routes())
+	want := "[{Pattern:/orders/*/items/* Host: Name:items Methods:[GET] Params:[id 1] Metadata:map[]} " +
+		"{Pattern:/static/** Host: Name: Methods:[] Params:[] Metadata:map[]}]"
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
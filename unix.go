@@ -0,0 +1,60 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// listenUnix listens on a unix domain socket. A stale socket file, one that
+// no process is listening on, is removed first. Paths starting with "@" are
+// abstract sockets (Linux only), which have no file and permissions.
+func listenUnix(path string, perm os.FileMode) (net.Listener, error) {
+	if strings.HasPrefix(path, "@") {
+		return net.Listen("unix", path)
+	}
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("'%s' exists and is not a socket", path)
+		}
+		if c, err := net.Dial("unix", path); err == nil {
+			_ = c.Close()
+			return nil, fmt.Errorf("socket '%s' is in use", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("could not remove stale socket: %w", err)
+		}
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, perm); err != nil {
+		_ = l.Close()
+		return nil, fmt.Errorf("could not set socket permissions: %w", err)
+	}
+	return l, nil
+}
+
+func (t *treeMux) ListenAndServeUnix(path string, perm os.FileMode) error {
+	l, err := listenUnix(path, perm)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	return t.Serve(l)
+}
+
+// IsUnixSocket is a Predicate that holds when the request was received on a
+// unix domain socket. Use it to guard routes that should only be exposed
+// locally, like debug endpoints.
+func IsUnixSocket(r *http.Request) bool {
+	addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	return ok && addr.Network() == "unix"
+}
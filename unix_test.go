@@ -0,0 +1,107 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestListenUnix(t *testing.T) {
+	dir := t.TempDir()
+
+	stale := filepath.Join(dir, "stale.sock")
+	l, err := net.Listen("unix", stale)
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = l.Close()
+
+	inUse := filepath.Join(dir, "in-use.sock")
+	l, err = net.Listen("unix", inUse)
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	defer l.Close()
+
+	regular := filepath.Join(dir, "regular")
+	if err := ioutil.WriteFile(regular, nil, 0o600); err != nil {
+		t.Fatalf("could not write file: %v", err)
+	}
+
+	cases := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{"new", filepath.Join(dir, "new.sock"), false},
+		{"stale", stale, false},
+		{"in use", inUse, true},
+		{"not a socket", regular, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			l, err := listenUnix(c.path, 0o660)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("expected error %v, got %v", c.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			defer l.Close()
+			fi, err := os.Stat(c.path)
+			if err != nil {
+				t.Fatalf("could not stat socket: %v", err)
+			}
+			if perm := fi.Mode().Perm(); perm != 0o660 {
+				t.Errorf("expected permissions %o, got %o", 0o660, perm)
+			}
+		})
+	}
+}
+
+func TestTreeMux_ListenAndServeUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mux.sock")
+	tr := NewTreeMux()
+	tr.HandleFunc("/debug", bodyHandler("debug"), WithGuard(IsUnixSocket))
+	go func() {
+		_ = tr.ListenAndServeUnix(path, 0o600)
+	}()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	var resp *http.Response
+	var err error
+	for i := 0; i < 50; i++ {
+		if resp, err = client.Get("http://local/debug"); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("could not get: %v", err)
+	}
+	defer resp.Body.Close()
+	if b, _ := ioutil.ReadAll(resp.Body); string(b) != "debug" {
+		t.Errorf("expected %q, got %q", "debug", b)
+	}
+
+	w := httptest.NewRecorder()
+	tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status %d over tcp, got %d", http.StatusForbidden, w.Code)
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// UsageRecord describes a handled request, for metering.
+type UsageRecord struct {
+	Time     time.Time
+	Tenant   string
+	Pattern  string
+	Method   string
+	Status   int
+	Bytes    int64
+	Duration time.Duration
+}
+
+// UsageExporter sends usage records to a metering or billing system.
+type UsageExporter interface {
+	Export(ctx context.Context, records []UsageRecord) error
+}
+
+// UsageExporterFunc is an adapter to allow the use of ordinary functions as
+// a UsageExporter.
+type UsageExporterFunc func(ctx context.Context, records []UsageRecord) error
+
+func (f UsageExporterFunc) Export(ctx context.Context, records []UsageRecord) error {
+	return f(ctx, records)
+}
+
+// UsageExport configures the export of usage records.
+type UsageExport struct {
+	// Exporter receives the records in batches. It must be set.
+	Exporter UsageExporter
+	// Tenant identifies the tenant of a request. Optional.
+	Tenant func(r *http.Request) string
+	// BatchSize is the number of records that triggers an export. Defaults
+	// to 100.
+	BatchSize int
+	// FlushInterval is the maximum time a record is buffered. Defaults to
+	// ten seconds.
+	FlushInterval time.Duration
+}
+
+// usageMeter buffers usage records and exports them in batches.
+type usageMeter struct {
+	cfg   UsageExport
+	logf  logFunc
+	clock Clock
+
+	mux     sync.Mutex
+	records []UsageRecord
+	timer   Timer
+
+	// exportMux keeps batches in order.
+	exportMux sync.Mutex
+}
+
+func newUsageMeter(cfg UsageExport) *usageMeter {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 10 * time.Second
+	}
+	return &usageMeter{cfg: cfg, clock: realClock{}}
+}
+
+func (m *usageMeter) add(rec UsageRecord) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.records = append(m.records, rec)
+	if len(m.records) >= m.cfg.BatchSize {
+		batch := m.take()
+		go m.export(context.Background(), batch)
+		return
+	}
+	if m.timer == nil {
+		m.timer = m.clock.AfterFunc(m.cfg.FlushInterval, func() {
+			_ = m.flush(context.Background())
+		})
+	}
+}
+
+// take removes the buffered records. The caller must hold the lock.
+func (m *usageMeter) take() []UsageRecord {
+	batch := m.records
+	m.records = nil
+	if m.timer != nil {
+		m.timer.Stop()
+		m.timer = nil
+	}
+	return batch
+}
+
+// flush exports the buffered records.
+func (m *usageMeter) flush(ctx context.Context) error {
+	m.mux.Lock()
+	batch := m.take()
+	m.mux.Unlock()
+	return m.export(ctx, batch)
+}
+
+func (m *usageMeter) export(ctx context.Context, batch []UsageRecord) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	m.exportMux.Lock()
+	defer m.exportMux.Unlock()
+	err := m.cfg.Exporter.Export(ctx, batch)
+	if err != nil {
+		m.logf(LogError, "usage export failed", "records", len(batch), "error", err)
+	}
+	return err
+}
+
+// meterUsage wraps the handler so that its requests are recorded.
+func meterUsage(h http.Handler, pattern string, m *usageMeter) http.Handler {
+	if m == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := m.clock.Now()
+		rw := newResponseWriter(w)
+		h.ServeHTTP(rw, r)
+		status := rw.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		var tenant string
+		if m.cfg.Tenant != nil {
+			tenant = m.cfg.Tenant(r)
+		}
+		m.add(UsageRecord{
+			Time:     start,
+			Tenant:   tenant,
+			Pattern:  pattern,
+			Method:   r.Method,
+			Status:   status,
+			Bytes:    rw.written,
+			Duration: m.clock.Now().Sub(start),
+		})
+	})
+}
+
+type optionUsageExport struct {
+	value UsageExport
+}
+
+func (o optionUsageExport) Apply(mux *treeMux) {
+	mux.usage = newUsageMeter(o.value)
+}
+
+func (o optionUsageExport) private() {}
+
+// OptionUsageExport makes the mux record the usage of every matched request
+// and export the records in batches. Batches are exported when full or after
+// the flush interval, and when the mux is shut down. Failed exports are
+// logged and their records dropped. It panics when no exporter is set.
+func OptionUsageExport(cfg UsageExport) Option {
+	if cfg.Exporter == nil {
+		panic("invalid usage export: no exporter")
+	}
+	return optionUsageExport{cfg}
+}
@@ -0,0 +1,100 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type usageCollector struct {
+	mux     sync.Mutex
+	batches [][]UsageRecord
+	done    chan struct{}
+}
+
+func (c *usageCollector) Export(_ context.Context, records []UsageRecord) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.batches = append(c.batches, records)
+	if c.done != nil {
+		c.done <- struct{}{}
+	}
+	return nil
+}
+
+func TestOptionUsageExport(t *testing.T) {
+	cases := []struct {
+		name     string
+		cfg      UsageExport
+		requests int
+		wait     bool
+		batches  []int
+	}{
+		{"batch size", UsageExport{BatchSize: 2, FlushInterval: time.Hour}, 4, true, []int{2, 2}},
+		{"flush interval", UsageExport{BatchSize: 10, FlushInterval: time.Millisecond}, 1, true, []int{1}},
+		{"shutdown", UsageExport{BatchSize: 10, FlushInterval: time.Hour}, 3, false, []int{3}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			col := &usageCollector{}
+			if c.wait {
+				col.done = make(chan struct{}, len(c.batches))
+			}
+			cfg := c.cfg
+			cfg.Exporter = col
+			cfg.Tenant = func(r *http.Request) string {
+				return r.Header.Get("X-Tenant")
+			}
+			tr := NewTreeMux(OptionUsageExport(cfg))
+			tr.HandleFunc("/items/*", bodyHandler("item"))
+			for i := 0; i < c.requests; i++ {
+				r := httptest.NewRequest(http.MethodPost, "/items/1", nil)
+				r.Header.Set("X-Tenant", "acme")
+				tr.ServeHTTP(httptest.NewRecorder(), r)
+			}
+			tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/unknown", nil))
+			if c.wait {
+				for range c.batches {
+					select {
+					case <-col.done:
+					case <-time.After(time.Second):
+						t.Fatal("timeout waiting for export")
+					}
+				}
+			} else if err := tr.Shutdown(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			col.mux.Lock()
+			defer col.mux.Unlock()
+			if len(col.batches) != len(c.batches) {
+				t.Fatalf("expected %d batches, got %d", len(c.batches), len(col.batches))
+			}
+			for i, b := range col.batches {
+				if len(b) != c.batches[i] {
+					t.Errorf("expected batch of %d, got %d", c.batches[i], len(b))
+				}
+				rec := b[0]
+				if rec.Tenant != "acme" || rec.Pattern != "/items/*" || rec.Method != http.MethodPost || rec.Status != http.StatusOK || rec.Bytes != 4 {
+					t.Errorf("unexpected record %+v", rec)
+				}
+			}
+		})
+	}
+}
+
+func TestOptionUsageExport_invalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic")
+		}
+	}()
+	OptionUsageExport(UsageExport{})
+}
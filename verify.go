@@ -0,0 +1,89 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+// MatcherDivergence describes a path that two matchers disagree on.
+type MatcherDivergence struct {
+	Path string
+	// Pattern and Trace are the result of the primary matcher.
+	Pattern string
+	Trace   MatchTrace
+	// CandidatePattern and CandidateTrace are the result of the candidate.
+	CandidatePattern string
+	CandidateTrace   MatchTrace
+}
+
+// VerifyingMatcher is a Matcher that runs a candidate matcher next to the
+// primary one, reporting the paths for which their results differ. The
+// primary result is always used. It can be used to verify a new matcher (or a
+// new version of one) on production traffic:
+//
+//	m := treemux.NewVerifyingMatcher(treemux.NewWildcardTrie[interface{}]("/"), treemux.NewDFAMatcher(),
+//		func(d treemux.MatcherDivergence) {
+//			log.Printf("matchers diverge on %s: %q != %q", d.Path, d.Pattern, d.CandidatePattern)
+//		})
+//	t := treemux.NewTreeMux(treemux.OptionMatcher(m))
+//
+// Every lookup is done twice, so this is meant to be used for a limited time.
+type VerifyingMatcher struct {
+	primary   Matcher
+	candidate Matcher
+	onDiverge func(MatcherDivergence)
+}
+
+// NewVerifyingMatcher creates a VerifyingMatcher. It panics when onDiverge is
+// nil.
+func NewVerifyingMatcher(primary, candidate Matcher, onDiverge func(MatcherDivergence)) *VerifyingMatcher {
+	if onDiverge == nil {
+		panic("divergence callback cannot be nil")
+	}
+	return &VerifyingMatcher{primary: primary, candidate: candidate, onDiverge: onDiverge}
+}
+
+func (m *VerifyingMatcher) Add(pattern string, v interface{}) {
+	m.primary.Add(pattern, v)
+	m.candidate.Add(pattern, v)
+}
+
+// Trace returns the result of the primary matcher. The candidate is not
+// consulted when the primary exceeded its match budget.
+func (m *VerifyingMatcher) Trace(path string, mt *MatchTrace) (interface{}, string) {
+	if mt == nil {
+		mt = &MatchTrace{}
+	}
+	v, pattern := m.primary.Trace(path, mt)
+	if mt.Exceeded {
+		return v, pattern
+	}
+	cmt := MatchTrace{}
+	if _, p := m.candidate.Trace(path, &cmt); p != pattern {
+		m.onDiverge(MatcherDivergence{
+			Path:             path,
+			Pattern:          pattern,
+			Trace:            *mt,
+			CandidatePattern: p,
+			CandidateTrace:   cmt,
+		})
+	}
+	return v, pattern
+}
+
+func (m *VerifyingMatcher) Prefix(path string) string {
+	return m.primary.Prefix(path)
+}
+
+// CompareMatchers matches the paths with both matchers and returns the
+// divergences, i.e. to replay a corpus of recorded paths. The matchers should
+// have the same patterns added.
+func CompareMatchers(primary, candidate Matcher, paths []string) []MatcherDivergence {
+	var ds []MatcherDivergence
+	m := NewVerifyingMatcher(primary, candidate, func(d MatcherDivergence) {
+		ds = append(ds, d)
+	})
+	for _, p := range paths {
+		m.Trace(p, nil)
+	}
+	return ds
+}
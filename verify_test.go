@@ -0,0 +1,69 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVerifyingMatcher(t *testing.T) {
+	var ds []MatcherDivergence
+	m := NewVerifyingMatcher(
+		newWildcardTrie("/"),
+		&caseInsensitiveMatcher{values: map[string]interface{}{}, patterns: map[string]string{}},
+		func(d MatcherDivergence) {
+			ds = append(ds, d)
+		})
+	tr := NewTreeMux(OptionMatcher(m))
+	tr.HandleFunc("/foo/bar", bodyHandler("foo"))
+	tr.HandleFunc("/foo/*", bodyHandler("wildcard"))
+
+	cases := []struct {
+		path      string
+		want      string
+		candidate string
+		diverges  bool
+	}{
+		{"/foo/bar", "foo", "", false},
+		{"/foo/baz", "wildcard", "", true},
+		{"/FOO/bar", "404 page not found\n", "/foo/bar", true},
+		{"/qux", "404 page not found\n", "", false},
+	}
+	for _, c := range cases {
+		t.Run(c.path, func(t *testing.T) {
+			ds = nil
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+			if got := w.Body.String(); got != c.want {
+				t.Errorf("expected %q, got %q", c.want, got)
+			}
+			if len(ds) > 0 != c.diverges {
+				t.Fatalf("expected divergence %v, got %v", c.diverges, ds)
+			}
+			if c.diverges && (ds[0].Path != c.path || ds[0].CandidatePattern != c.candidate || ds[0].Trace.Inspected == 0) {
+				t.Errorf("unexpected divergence %+v", ds[0])
+			}
+		})
+	}
+}
+
+func TestCompareMatchers(t *testing.T) {
+	trie, dfa := newWildcardTrie("/"), NewDFAMatcher()
+	for i, p := range []string{"/foo/bar", "/foo/*", "/*/baz"} {
+		trie.Add(p, i)
+		dfa.Add(p, i)
+	}
+	if ds := CompareMatchers(trie, dfa, []string{"/foo/bar", "/foo/qux", "/x/baz", "/nope"}); len(ds) != 0 {
+		t.Errorf("expected no divergences, got %v", ds)
+	}
+	other := newWildcardTrie("/")
+	other.Add("/foo/bar", 0)
+	ds := CompareMatchers(trie, other, []string{"/foo/bar", "/foo/qux", "/x/baz"})
+	if len(ds) != 2 || ds[0].Path != "/foo/qux" || ds[1].Pattern != "/*/baz" {
+		t.Errorf("unexpected divergences %v", ds)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+)
+
+// View is a read-only view of a mux, exposing only the routes that pass its
+// filter. It shares the route table of the mux, so routes added to the mux
+// later are visible too (if they pass the filter).
+//
+// Requests for a route that does not pass the filter are not found, even when
+// a less specific route would have matched them.
+type View struct {
+	t      *treeMux
+	filter func(Route) bool
+}
+
+func (t *treeMux) View(filter func(Route) bool) *View {
+	if filter == nil {
+		panic("view filter cannot be nil")
+	}
+	return &View{t: t, filter: filter}
+}
+
+func (v *View) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !v.visible(r) {
+		v.t.wrap(v.t.notFound).ServeHTTP(w, r)
+		return
+	}
+	v.t.ServeHTTP(w, r)
+}
+
+// Handler is like TreeMux.Handler, but only returns routes passing the filter.
+func (v *View) Handler(r *http.Request) (http.Handler, string) {
+	if !v.visible(r) {
+		return v.t.notFound, ""
+	}
+	return v.t.Handler(r)
+}
+
+// Walk is like TreeMux.Walk, but only visits routes passing the filter.
+func (v *View) Walk(fn func(pattern string, h http.Handler) bool) {
+	for _, rt := range v.t.routes() {
+		if v.filter(rt.info()) && !fn(rt.pattern, rt.handler) {
+			return
+		}
+	}
+}
+
+// visible reports whether the request matches a route passing the filter.
+// Unmatched requests are left to the mux.
+func (v *View) visible(r *http.Request) bool {
+	if v.t.matrixParams {
+		r = stripMatrixParams(r)
+	}
+	rt, _ := v.t.match(r, nil)
+	if rt == nil {
+		return true
+	}
+	if rt.handler != nil {
+		return v.filter(rt.info())
+	}
+	// Synthetic routes, like method not allowed responses, are visible when
+	// any route on their pattern is.
+	v.t.routesMux.RLock()
+	defer v.t.routesMux.RUnlock()
+	if e := v.t.endpoints[rt.pattern]; e != nil {
+		for _, x := range e.routes {
+			if v.filter(x.info()) {
+				return true
+			}
+		}
+	}
+	return false
+}
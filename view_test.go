@@ -0,0 +1,64 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTreeMux_View(t *testing.T) {
+	tr := NewTreeMux()
+	tr.HandleFunc("/foo", bodyHandler("foo"))
+	tr.HandleFunc("/admin", bodyHandler("admin"), WithMetadata("internal", true))
+	tr.HandleFunc("/bar", bodyHandler("bar"), WithMethods(http.MethodPost))
+	tr.HandleFunc("/qux", bodyHandler("qux"), WithMethods(http.MethodPost), WithMetadata("internal", true))
+	v := tr.View(func(r Route) bool {
+		return r.Metadata["internal"] == nil
+	})
+	tr.HandleFunc("/later", bodyHandler("later"))
+
+	cases := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"visible", http.MethodGet, "/foo", http.StatusOK, "foo"},
+		{"added later", http.MethodGet, "/later", http.StatusOK, "later"},
+		{"hidden", http.MethodGet, "/admin", http.StatusNotFound, "404 page not found\n"},
+		{"not found", http.MethodGet, "/baz", http.StatusNotFound, "404 page not found\n"},
+		{"method not allowed", http.MethodGet, "/bar", http.StatusMethodNotAllowed, "405 method not allowed\n"},
+		{"hidden method not allowed", http.MethodGet, "/qux", http.StatusNotFound, "404 page not found\n"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest(c.method, c.path, nil)
+			w := httptest.NewRecorder()
+			v.ServeHTTP(w, r)
+			if w.Code != c.wantStatus {
+				t.Errorf("expected %d, got %d", c.wantStatus, w.Code)
+			}
+			if got := w.Body.String(); got != c.wantBody {
+				t.Errorf("expected %q, got %q", c.wantBody, got)
+			}
+			if _, pattern := v.Handler(r); (pattern != "") != (c.wantStatus != http.StatusNotFound) {
+				t.Errorf("unexpected pattern %q", pattern)
+			}
+		})
+	}
+
+	var got []string
+	v.Walk(func(pattern string, _ http.Handler) bool {
+		got = append(got, pattern)
+		return true
+	})
+	if want := "[/bar /foo /later]"; fmt.Sprint(got) != want {
+		t.Errorf("expected %s, got %v", want, got)
+	}
+}
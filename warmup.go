@@ -0,0 +1,115 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// WarmupRequest describes a request made by Warmup. The route is given by
+// path, or by name and params (see WithName).
+type WarmupRequest struct {
+	// Method defaults to GET.
+	Method string
+	Path   string
+	Name   string
+	Params []string
+	Header http.Header
+	Body   []byte
+	// Status is the expected response status. When zero, any status below
+	// 500 will do.
+	Status int
+}
+
+// warmupWriter is a response writer that only keeps the status.
+type warmupWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *warmupWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *warmupWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return len(b), nil
+}
+
+func (w *warmupWriter) WriteHeader(status int) {
+	if w.status == 0 && !informational(status) {
+		w.status = status
+	}
+}
+
+// IsWarmup reports whether the request was made by Warmup. Handlers can use
+// it to skip side effects.
+func IsWarmup(r *http.Request) bool {
+	v, _ := r.Context().Value(warmupKey).(bool)
+	return v
+}
+
+func (t *treeMux) warmup(ctx context.Context, wr WarmupRequest) error {
+	path := wr.Path
+	if wr.Name != "" {
+		pattern, ok := t.names[wr.Name]
+		if !ok {
+			return fmt.Errorf("unknown route name '%s'", wr.Name)
+		}
+		var err error
+		if path, err = expand(pattern, wr.Params); err != nil {
+			return err
+		}
+	}
+	method := wr.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	r, err := http.NewRequestWithContext(context.WithValue(ctx, warmupKey, true), method, path, bytes.NewReader(wr.Body))
+	if err != nil {
+		return err
+	}
+	r.RemoteAddr = "127.0.0.1:0"
+	r.Host = "localhost"
+	for k, vs := range wr.Header {
+		r.Header[k] = vs
+	}
+
+	if _, pattern := t.Handler(r); pattern == "" {
+		return fmt.Errorf("%s %s: no route", method, path)
+	}
+	w := &warmupWriter{header: make(http.Header)}
+	t.ServeHTTP(w, r)
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if (wr.Status != 0 && status != wr.Status) || (wr.Status == 0 && status >= 500) {
+		return fmt.Errorf("%s %s: status %d", method, path, status)
+	}
+	return nil
+}
+
+func (t *treeMux) Warmup(ctx context.Context, requests []WarmupRequest) error {
+	var failures []string
+	for _, wr := range requests {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := t.warmup(ctx, wr); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("warmup failed: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
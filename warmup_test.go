@@ -0,0 +1,84 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestTreeMux_Warmup(t *testing.T) {
+	var seen []string
+	tr := NewTreeMux()
+	tr.HandleFunc("/countries/*", func(w http.ResponseWriter, r *http.Request) {
+		if !IsWarmup(r) {
+			t.Errorf("expected warmup request")
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		seen = append(seen, r.Method+" "+r.URL.Path+" "+r.Header.Get("X-Foo")+" "+string(b))
+	}, WithName("country"))
+	tr.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	tr.HandleFunc("/teapot", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	cases := []struct {
+		name     string
+		requests []WarmupRequest
+		wantErr  string
+		wantSeen []string
+	}{
+		{
+			name: "ok",
+			requests: []WarmupRequest{
+				{Path: "/countries/france"},
+				{Method: http.MethodPost, Name: "country", Params: []string{"belgium"}, Header: http.Header{"X-Foo": {"bar"}}, Body: []byte("hi")},
+				{Path: "/teapot", Status: http.StatusTeapot},
+			},
+			wantSeen: []string{"GET /countries/france  ", "POST /countries/belgium bar hi"},
+		},
+		{
+			name: "failures",
+			requests: []WarmupRequest{
+				{Path: "/fail"},
+				{Path: "/nope"},
+				{Path: "/teapot", Status: http.StatusOK},
+				{Name: "city"},
+				{Path: "/countries/spain"},
+			},
+			wantErr:  "warmup failed: GET /fail: status 500; GET /nope: no route; GET /teapot: status 418; unknown route name 'city'",
+			wantSeen: []string{"GET /countries/spain  "},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			seen = nil
+			err := tr.Warmup(context.Background(), c.requests)
+			if c.wantErr == "" && err != nil || c.wantErr != "" && (err == nil || err.Error() != c.wantErr) {
+				t.Errorf("expected error %q, got %v", c.wantErr, err)
+			}
+			if len(seen) != len(c.wantSeen) {
+				t.Fatalf("expected %v, got %v", c.wantSeen, seen)
+			}
+			for i := range seen {
+				if seen[i] != c.wantSeen[i] {
+					t.Errorf("expected %q, got %q", c.wantSeen[i], seen[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTreeMux_Warmup_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := NewTreeMux().Warmup(ctx, []WarmupRequest{{Path: "/"}}); err != context.Canceled {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+}
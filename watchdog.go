@@ -0,0 +1,89 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"runtime"
+	"time"
+)
+
+type optionWatchdog struct {
+	threshold time.Duration
+	stack     bool
+}
+
+func (o optionWatchdog) Apply(mux *treeMux) {
+	mux.watchdog = o.threshold
+	mux.watchdogStack = o.stack
+}
+
+func (o optionWatchdog) private() {}
+
+// OptionWatchdog logs a warning for every request that is still being handled
+// after the threshold has passed. When stack is set, the stacks of all
+// goroutines are logged with it. Routes can override the threshold with
+// WithWatchdog; streaming routes are only watched when they do.
+func OptionWatchdog(threshold time.Duration, stack bool) Option {
+	return optionWatchdog{threshold, stack}
+}
+
+type withWatchdog struct {
+	value time.Duration
+}
+
+func (o withWatchdog) Apply(rt *route) {
+	rt.watchdog = o.value
+	rt.hasWatchdog = true
+}
+
+func (o withWatchdog) private() {}
+
+// WithWatchdog sets the slow request threshold for the route, overriding the
+// mux default. A zero duration disables the watchdog for the route.
+func WithWatchdog(threshold time.Duration) RouteOption {
+	return withWatchdog{threshold}
+}
+
+// routeWatchdog returns the slow request threshold that applies to the route.
+func (t *treeMux) routeWatchdog(rt *route) time.Duration {
+	if rt.hasWatchdog {
+		return rt.watchdog
+	}
+	if rt.streaming {
+		return 0
+	}
+	return t.watchdog
+}
+
+// watch wraps the handler so that a warning is logged when it runs longer than
+// the threshold.
+func watch(h http.Handler, pattern string, threshold time.Duration, stack bool, clock Clock, logf logFunc) http.Handler {
+	if threshold <= 0 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timer := clock.AfterFunc(threshold, func() {
+			if stack {
+				logf(LogWarning, "request still running", "pattern", pattern, "path", r.URL.Path, "elapsed", threshold, "goroutines", string(dumpStacks()))
+				return
+			}
+			logf(LogWarning, "request still running", "pattern", pattern, "path", r.URL.Path, "elapsed", threshold)
+		})
+		defer timer.Stop()
+		h.ServeHTTP(w, r)
+	})
+}
+
+func dumpStacks() []byte {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
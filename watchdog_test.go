@@ -0,0 +1,74 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOptionWatchdog(t *testing.T) {
+	cases := []struct {
+		name      string
+		path      string
+		wantLog   string
+		wantStack bool
+	}{
+		{"mux default", "/default", "WARNING: request still running pattern=/default path=/default elapsed=10ms", true},
+		{"route override", "/override", "", false},
+		{"streaming", "/streaming", "", false},
+		{"watched streaming", "/watched", "WARNING: request still running pattern=/watched path=/watched elapsed=5ms", true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			logs := &syncLog{logged: make(chan string, 1)}
+			release := make(chan struct{})
+			wait := func(w http.ResponseWriter, r *http.Request) {
+				<-release
+			}
+
+			tr := NewTreeMux(OptionWatchdog(10*time.Millisecond, true), OptionLogger(logs))
+			tr.HandleFunc("/default", wait)
+			tr.HandleFunc("/override", wait, WithWatchdog(time.Minute))
+			tr.HandleFunc("/streaming", wait, Streaming())
+			tr.HandleFunc("/watched", wait, Streaming(), WithWatchdog(5*time.Millisecond))
+
+			r := httptest.NewRequest(http.MethodGet, c.path, nil)
+			if c.wantLog == "" {
+				// the request finishes right away, before any watchdog
+				// would fire
+				close(release)
+				tr.ServeHTTP(httptest.NewRecorder(), r)
+				if got := logs.String(); got != "" {
+					t.Errorf("expected no log, got %q", got)
+				}
+				return
+			}
+
+			done := make(chan struct{})
+			go func() {
+				tr.ServeHTTP(httptest.NewRecorder(), r)
+				close(done)
+			}()
+			var got string
+			select {
+			case got = <-logs.logged:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("expected watchdog warning")
+			}
+			close(release)
+			<-done
+			if !strings.Contains(got, c.wantLog) {
+				t.Errorf("expected log to contain %q, got %q", c.wantLog, got)
+			}
+			if hasStack := strings.Contains(got, "goroutines="); hasStack != c.wantStack {
+				t.Errorf("expected stack dump %v, got %v", c.wantStack, hasStack)
+			}
+		})
+	}
+}
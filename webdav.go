@@ -0,0 +1,18 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"strings"
+)
+
+func (t *treeMux) WebDAV(path string, handler http.Handler, options ...RouteOption) {
+	prefix := strings.TrimSuffix(strings.TrimSuffix(normalisePattern(path), "**"), "/")
+	if prefix == "" {
+		prefix = "/"
+	}
+	t.handlePrefix(prefix, handler, options...)
+}
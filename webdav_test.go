@@ -0,0 +1,49 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTreeMux_WebDAV(t *testing.T) {
+	dav := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Method + " " + r.URL.Path + " " + r.Header.Get("Depth")))
+	})
+	cases := []struct {
+		name   string
+		mount  string
+		method string
+		path   string
+		depth  string
+		want   string
+	}{
+		{"propfind root", "/dav/**", "PROPFIND", "/dav", "1", "PROPFIND /dav 1"},
+		{"propfind root slash", "/dav/**", "PROPFIND", "/dav/", "0", "PROPFIND /dav/ 0"},
+		{"mkcol", "/dav/**", "MKCOL", "/dav/a/b", "", "MKCOL /dav/a/b "},
+		{"get", "/dav", http.MethodGet, "/dav/a.txt", "", "GET /dav/a.txt "},
+		{"regular route", "/dav", http.MethodGet, "/dav/regular", "", "regular"},
+		{"outside", "/dav/", "PROPFIND", "/other", "1", "404 page not found\n"},
+		{"root", "/**", "LOCK", "/a", "", "LOCK /a "},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tr := NewTreeMux()
+			tr.WebDAV(c.mount, dav)
+			tr.HandleFunc("/dav/regular", bodyHandler("regular"))
+			r := httptest.NewRequest(c.method, c.path, nil)
+			if c.depth != "" {
+				r.Header.Set("Depth", c.depth)
+			}
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, r)
+			if got := w.Body.String(); got != c.want {
+				t.Errorf("expected %q, got %q", c.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+)
+
+// WebhookDispatcher dispatches verified webhook requests to handlers by event
+// type.
+type WebhookDispatcher struct {
+	eventType func(provider string, r *http.Request) string
+	handlers  map[string]http.Handler
+}
+
+// NewWebhookDispatcher creates a dispatcher that uses the given function to
+// determine the event type of a request.
+func NewWebhookDispatcher(eventType func(provider string, r *http.Request) string) *WebhookDispatcher {
+	return &WebhookDispatcher{eventType: eventType, handlers: make(map[string]http.Handler)}
+}
+
+// EventHeader returns an event type function that reads the event type from
+// the given request header (i.e. "X-GitHub-Event").
+func EventHeader(key string) func(string, *http.Request) string {
+	return func(_ string, r *http.Request) string {
+		return r.Header.Get(key)
+	}
+}
+
+// On registers a handler for an event type of a provider. An empty provider
+// matches events from all providers that do not have a more specific handler.
+func (d *WebhookDispatcher) On(provider, event string, h http.Handler) {
+	d.handlers[provider+"\x00"+event] = h
+}
+
+// OnFunc registers a handler function. See On for more details.
+func (d *WebhookDispatcher) OnFunc(provider, event string, h func(http.ResponseWriter, *http.Request)) {
+	d.On(provider, event, http.HandlerFunc(h))
+}
+
+func (d *WebhookDispatcher) handler(provider string, r *http.Request) http.Handler {
+	event := d.eventType(provider, r)
+	if h, ok := d.handlers[provider+"\x00"+event]; ok {
+		return h
+	}
+	return d.handlers["\x00"+event]
+}
+
+// webhook verifies and dispatches webhook requests. The provider is taken from
+// the last path parameter in the pattern.
+type webhook struct {
+	param      string
+	verifiers  map[string]RequestVerifier
+	dispatcher *WebhookDispatcher
+	notFound   http.Handler
+	fail       errorFunc
+}
+
+func (wh webhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	provider := PathParam(r, wh.param)
+	v, ok := wh.verifiers[provider]
+	if !ok {
+		wh.notFound.ServeHTTP(w, r)
+		return
+	}
+	if err := v.Verify(r); err != nil {
+		wh.fail(w, r, http.StatusUnauthorized)
+		return
+	}
+	h := wh.dispatcher.handler(provider, r)
+	if h == nil {
+		// acknowledge events nobody is interested in, to prevent retries
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	h.ServeHTTP(w, r)
+}
+
+func (t *treeMux) Webhook(path string, verifiers map[string]RequestVerifier, dispatcher *WebhookDispatcher, options ...RouteOption) {
+	_, params := parsePathParams(normalisePattern(t.expandFragments(path)))
+	if len(params) == 0 {
+		panic("webhook path needs a wildcard for the provider")
+	}
+	wh := webhook{
+		param:      params[len(params)-1].name,
+		verifiers:  verifiers,
+		dispatcher: dispatcher,
+		notFound:   t.notFound,
+		fail:       t.writeError,
+	}
+	t.Handle(path, wh, options...)
+}
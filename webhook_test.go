@@ -0,0 +1,58 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTreeMux_Webhook(t *testing.T) {
+	allow := RequestVerifierFunc(func(*http.Request) error { return nil })
+	deny := RequestVerifierFunc(func(*http.Request) error { return errors.New("nope") })
+
+	d := NewWebhookDispatcher(EventHeader("X-Event"))
+	d.On("github", "push", bodyHandler("github push"))
+	d.On("", "push", bodyHandler("any push"))
+
+	cases := []struct {
+		name     string
+		path     string
+		event    string
+		wantCode int
+		wantBody string
+	}{
+		{"provider specific", "/hooks/github", "push", 200, "github push"},
+		{"any provider", "/hooks/gitlab", "push", 200, "any push"},
+		{"unhandled event", "/hooks/github", "issue", 204, ""},
+		{"unknown provider", "/hooks/bitbucket", "push", 404, "404 page not found\n"},
+		{"verification failed", "/hooks/evil", "push", 401, "401 unauthorized\n"},
+	}
+	for _, c := range cases {
+		for _, pattern := range []string{"/hooks/*", "/hooks/{provider}", "/hooks/:provider"} {
+			t.Run(c.name+" "+pattern, func(t *testing.T) {
+				tr := NewTreeMux()
+				tr.Webhook(pattern, map[string]RequestVerifier{
+					"github": allow,
+					"gitlab": allow,
+					"evil":   deny,
+				}, d)
+
+				r := httptest.NewRequest(http.MethodPost, c.path, nil)
+				r.Header.Set("X-Event", c.event)
+				w := httptest.NewRecorder()
+				tr.ServeHTTP(w, r)
+				if w.Code != c.wantCode {
+					t.Errorf("expected %v, got %v", c.wantCode, w.Code)
+				}
+				if w.Body.String() != c.wantBody {
+					t.Errorf("expected %q, got %q", c.wantBody, w.Body.String())
+				}
+			})
+		}
+	}
+}
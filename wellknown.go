@@ -0,0 +1,104 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"time"
+)
+
+const defaultWellKnownMaxAge = 24 * time.Hour
+
+// WellKnownDocument is a document served at a well-known location, like
+// /.well-known/security.txt or /robots.txt.
+type WellKnownDocument struct {
+	// Content is the body of the document.
+	Content []byte
+	// ContentType defaults to the type for the file extension, or to JSON
+	// for documents without one, like apple-app-site-association.
+	ContentType string
+	// MaxAge is how long clients may cache the document, defaults to a day.
+	MaxAge time.Duration
+	// Redirect, when set, makes the location redirect to the given URL
+	// instead, like /.well-known/change-password should.
+	Redirect string
+}
+
+// contentType returns the content type of the document at the path.
+func (d WellKnownDocument) contentType(p string) string {
+	if d.ContentType != "" {
+		return d.ContentType
+	}
+	ext := path.Ext(p)
+	if ext == "" {
+		return "application/json"
+	}
+	if ct := mime.TypeByExtension(ext); ct != "" {
+		return ct
+	}
+	return http.DetectContentType(d.Content)
+}
+
+func (d WellKnownDocument) handler(p string) http.Handler {
+	if d.Redirect != "" {
+		return http.RedirectHandler(d.Redirect, http.StatusFound)
+	}
+	maxAge := d.MaxAge
+	if maxAge == 0 {
+		maxAge = defaultWellKnownMaxAge
+	}
+	contentType := d.contentType(p)
+	cacheControl := fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds()))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Cache-Control", cacheControl)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(d.Content))
+	})
+}
+
+func (t *treeMux) WellKnown(path string, doc WellKnownDocument, options ...RouteOption) {
+	pattern := normalisePattern(path)
+	rt := t.newRoute(pattern, doc.handler(pattern), options)
+	t.routesMux.Lock()
+	defer t.routesMux.Unlock()
+	if t.wellKnown == nil {
+		t.wellKnown = make(map[string]*route)
+	}
+	t.wellKnown[pattern] = rt
+}
+
+func (t *treeMux) WellKnownFS(fsys fs.FS, maxAge time.Duration, options ...RouteOption) error {
+	var docs []string
+	if _, err := fs.Stat(fsys, "robots.txt"); err == nil {
+		docs = append(docs, "robots.txt")
+	}
+	err := fs.WalkDir(fsys, ".well-known", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			docs = append(docs, p)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for _, p := range docs {
+		b, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		t.WellKnown(p, WellKnownDocument{Content: b, MaxAge: maxAge}, options...)
+	}
+	return nil
+}
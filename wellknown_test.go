@@ -0,0 +1,93 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestTreeMux_WellKnown(t *testing.T) {
+	tr := NewTreeMux()
+	// registered first, so it would win over an exact route in the trie
+	tr.HandleFunc("/*", bodyHandler("wildcard"))
+	tr.Mount("/.well-known", bodyHandler("mount"))
+	tr.WellKnown("/robots.txt", WellKnownDocument{Content: []byte("User-agent: *\n")})
+	tr.WellKnown("/.well-known/assetlinks.json", WellKnownDocument{Content: []byte("[]"), MaxAge: time.Hour})
+	tr.WellKnown("/.well-known/apple-app-site-association", WellKnownDocument{Content: []byte("{}")})
+	tr.WellKnown(".well-known/change-password", WellKnownDocument{Redirect: "/account/password"})
+
+	cases := []struct {
+		path         string
+		want         int
+		body         string
+		contentType  string
+		cacheControl string
+	}{
+		{"/robots.txt", 200, "User-agent: *\n", "text/plain; charset=utf-8", "public, max-age=86400"},
+		{"/.well-known/assetlinks.json", 200, "[]", "application/json", "public, max-age=3600"},
+		{"/.well-known/apple-app-site-association", 200, "{}", "application/json", "public, max-age=86400"},
+		{"/.well-known/change-password", 302, "", "", ""},
+		{"/.well-known/other", 200, "mount", "text/plain; charset=utf-8", ""},
+		{"/other", 200, "wildcard", "text/plain; charset=utf-8", ""},
+	}
+	for _, c := range cases {
+		t.Run(c.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+			if w.Code != c.want {
+				t.Errorf("expected %d, got %d", c.want, w.Code)
+			}
+			if c.body != "" && w.Body.String() != c.body {
+				t.Errorf("expected %q, got %q", c.body, w.Body.String())
+			}
+			if c.contentType != "" && w.Header().Get("Content-Type") != c.contentType {
+				t.Errorf("expected %q, got %q", c.contentType, w.Header().Get("Content-Type"))
+			}
+			if got := w.Header().Get("Cache-Control"); got != c.cacheControl {
+				t.Errorf("expected %q, got %q", c.cacheControl, got)
+			}
+		})
+	}
+}
+
+func TestTreeMux_WellKnownFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"robots.txt":                  {Data: []byte("User-agent: *\n")},
+		".well-known/security.txt":    {Data: []byte("Contact: mailto:security@example.com\n")},
+		".well-known/nested/doc.json": {Data: []byte("{}")},
+		"index.html":                  {Data: []byte("<html></html>")},
+	}
+	tr := NewTreeMux()
+	if err := tr.WellKnownFS(fsys, time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cases := []struct {
+		path string
+		want int
+	}{
+		{"/robots.txt", 200},
+		{"/.well-known/security.txt", 200},
+		{"/.well-known/nested/doc.json", 200},
+		{"/index.html", 404},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+		if w.Code != c.want {
+			t.Errorf("%s: expected %d, got %d", c.path, c.want, w.Code)
+		}
+		if c.want == 200 && w.Header().Get("Cache-Control") != "public, max-age=60" {
+			t.Errorf("%s: expected caching headers, got %q", c.path, w.Header().Get("Cache-Control"))
+		}
+	}
+
+	if err := NewTreeMux().WellKnownFS(fstest.MapFS{}, 0); err != nil {
+		t.Errorf("expected empty file system to be fine, got %v", err)
+	}
+}
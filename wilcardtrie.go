@@ -5,26 +5,117 @@
 package treemux
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"strings"
+	"sync"
 )
 
-type WildcardTrie interface {
-	Get(s string) (interface{}, string)
-	Add(s string, v interface{})
+// WildcardTrie is a trie of values of type T, keyed by path patterns with
+// wildcards. A WildcardTrie[interface{}] is a Matcher.
+type WildcardTrie[T any] interface {
+	Add(pattern string, v T)
+	// TryAdd is like Add, but returns an error instead of panicking on an
+	// invalid pattern.
+	TryAdd(pattern string, v T) error
+	Get(s string) (T, string)
+	Trace(path string, mt *MatchTrace) (T, string)
+	Prefix(path string) string
+	CheckInvariants() error
+	// Walk calls fn for every node of the trie, depth-first in insertion
+	// order, until it returns false. Nodes that were not added themselves
+	// have the zero value.
+	Walk(fn func(pattern string, v T) bool)
 }
 
-type wildcardTrie struct {
+// MatchTrace describes the work done to match a path.
+type MatchTrace struct {
+	// Segments is the number of elements in the path.
+	Segments int
+	// Inspected is the number of trie nodes compared to a path element.
+	Inspected int
+	// Wildcards is the number of path elements matched by a wildcard,
+	// including matches that were abandoned later on.
+	Wildcards int
+	// Backtracks is the number of dead ends that were explored.
+	Backtracks int
+	// Exceeded is set when matching was aborted because it took more steps
+	// than allowed (see OptionMatchBudget).
+	Exceeded bool
+
+	budget int
+}
+
+type wildcardTrie[T any] struct {
 	separator string
 	key       string
 	pattern   string
-	value     interface{}
-	children  []wildcardTrie
+	value     T
+	children  []wildcardTrie[T]
+}
+
+// NewWildcardTrie creates an empty trie, with path elements separated by the
+// separator. The trie is safe for concurrent use.
+func NewWildcardTrie[T any](separator string) WildcardTrie[T] {
+	return &syncTrie[T]{trie: wildcardTrie[T]{separator: separator, key: ""}}
+}
+
+// syncTrie guards a trie with a read-write lock, so values can be added while
+// it is being read.
+type syncTrie[T any] struct {
+	mux  sync.RWMutex
+	trie wildcardTrie[T]
+}
+
+func (t *syncTrie[T]) Add(s string, v T) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.trie.Add(s, v)
 }
 
-func newWildcardTrie(separator string) WildcardTrie {
-	return &wildcardTrie{separator: separator, key: ""}
+func (t *syncTrie[T]) TryAdd(s string, v T) error {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	return t.trie.TryAdd(s, v)
+}
+
+func (t *syncTrie[T]) Get(s string) (T, string) {
+	return t.Trace(s, nil)
+}
+
+func (t *syncTrie[T]) Trace(s string, mt *MatchTrace) (T, string) {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	return t.trie.Trace(s, mt)
+}
+
+func (t *syncTrie[T]) Prefix(s string) string {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	return t.trie.Prefix(s)
+}
+
+func (t *syncTrie[T]) CheckInvariants() error {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	return t.trie.CheckInvariants()
+}
+
+func (t *syncTrie[T]) Walk(fn func(pattern string, v T) bool) {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	t.trie.Walk(fn)
+}
+
+func (t *syncTrie[T]) String() string {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	return t.trie.String()
+}
+
+func newWildcardTrie(separator string) WildcardTrie[interface{}] {
+	return NewWildcardTrie[interface{}](separator)
 }
 
 // Add breaks up a string using the specified separator and adds the data to the
@@ -39,7 +130,13 @@ func newWildcardTrie(separator string) WildcardTrie {
 // whatsoever at construction-time. One could even apply different wildcard
 // schemes for different purposes on the same trie.
 // See Get for more details on wildcard behaviour.
-func (t *wildcardTrie) Add(s string, v interface{}) {
+func (t *wildcardTrie[T]) Add(s string, v T) {
+	if err := t.TryAdd(s, v); err != nil {
+		panic(err.Error())
+	}
+}
+
+func (t *wildcardTrie[T]) TryAdd(s string, v T) error {
 	xs := strings.Split(s, t.separator)
 	idx := 0
 	if xs[0] == "" {
@@ -47,12 +144,20 @@ func (t *wildcardTrie) Add(s string, v interface{}) {
 		idx = 1
 	}
 	if len(xs) > 1 && xs[len(xs)-1] == "" {
-		panic("path cannot end with slash")
+		return errors.New("path cannot end with slash")
+	}
+	for _, x := range xs {
+		if strings.HasPrefix(x, constrainedPrefix) {
+			if _, err := compileConstraint(x); err != nil {
+				return err
+			}
+		}
 	}
 	t.grow(idx, xs, v)
+	return nil
 }
 
-func (t *wildcardTrie) grow(idx int, xs []string, v interface{}) {
+func (t *wildcardTrie[T]) grow(idx int, xs []string, v T) {
 	if len(xs) == idx {
 		t.value = v
 		return
@@ -64,7 +169,7 @@ func (t *wildcardTrie) grow(idx int, xs []string, v interface{}) {
 		}
 	}
 	if len(xs) > idx {
-		c := newTrie(t.separator, xs[idx], xs[:idx+1])
+		c := newTrie[T](t.separator, xs[idx], xs[:idx+1])
 		if len(xs) == idx+1 {
 			c.value = v
 		} else {
@@ -74,8 +179,9 @@ func (t *wildcardTrie) grow(idx int, xs []string, v interface{}) {
 	}
 }
 
-func newTrie(sep, key string, path []string) wildcardTrie {
-	return wildcardTrie{separator: sep, key: key, pattern: "/" + strings.Join(path, sep)}
+func newTrie[T any](sep, key string, path []string) wildcardTrie[T] {
+	pattern := "/" + strings.TrimPrefix(strings.Join(path, sep), sep)
+	return wildcardTrie[T]{separator: sep, key: key, pattern: pattern}
 }
 
 const wildcard = "*"
@@ -85,39 +191,141 @@ const wildcard = "*"
 //
 // Wildcard elements hold no special status over other elements. When, due to a
 // wildcard, a path has two valid end points, the one inserted earliest wins.
-func (t *wildcardTrie) Get(s string) (interface{}, string) {
+func (t *wildcardTrie[T]) Get(s string) (T, string) {
+	return t.Trace(s, nil)
+}
+
+// Trace is like Get, but also records the work done in mt (when not nil).
+func (t *wildcardTrie[T]) Trace(s string, mt *MatchTrace) (T, string) {
 	// TODO(hvl): input validation
 	xs := strings.Split(s, t.separator)
+	if mt != nil {
+		mt.Segments = len(xs)
+	}
 	if xs[0] == "" {
-		return t.get(0, xs, wildcard)
+		return t.get(0, xs, wildcard, mt)
 	}
 	for _, c := range t.children {
-		if v, pattern := c.get(0, xs, wildcard); pattern != "" {
+		if v, pattern := c.get(0, xs, wildcard, mt); pattern != "" {
 			return v, pattern
 		}
 	}
-	return nil, ""
+	var zero T
+	return zero, ""
 }
 
-func (t *wildcardTrie) get(idx int, xs []string, wildcard string) (interface{}, string) {
-	if xs[idx] != t.key && t.key != wildcard {
+func (t *wildcardTrie[T]) get(idx int, xs []string, wildcard string, mt *MatchTrace) (T, string) {
+	var zero T
+	if mt.Step() {
+		return zero, ""
+	}
+	if xs[idx] != t.key && t.key != wildcard && !matchConstraint(t.key, xs[idx]) {
 		if t.key == "" && len(t.children) == 0 {
 			return t.value, t.pattern
 		}
-		return nil, ""
+		return zero, ""
+	}
+	if mt != nil && xs[idx] != t.key {
+		mt.Wildcards += 1
 	}
 	if len(xs)-idx == 1 {
 		return t.value, t.pattern
 	}
 	for _, c := range t.children {
-		if v, pattern := c.get(idx+1, xs, wildcard); pattern != "" {
+		if v, pattern := c.get(idx+1, xs, wildcard, mt); pattern != "" {
 			return v, pattern
 		}
 	}
-	return nil, ""
+	if mt != nil {
+		mt.Backtracks += 1
+	}
+	return zero, ""
+}
+
+// Prefix returns the pattern of the deepest node that matches the start of the
+// path, or an empty string when not even the root matches. Like with Get,
+// wildcards are taken into account.
+func (t *wildcardTrie[T]) Prefix(s string) string {
+	xs := strings.Split(s, t.separator)
+	if xs[0] != "" {
+		xs = append([]string{""}, xs...)
+	}
+	pattern, _ := t.prefix(0, xs, wildcard)
+	return pattern
+}
+
+func (t *wildcardTrie[T]) prefix(idx int, xs []string, wildcard string) (string, int) {
+	if xs[idx] != t.key && t.key != wildcard && !matchConstraint(t.key, xs[idx]) {
+		return "", -1
+	}
+	pattern, depth := t.pattern, idx
+	if len(xs)-idx == 1 {
+		return pattern, depth
+	}
+	for _, c := range t.children {
+		if p, d := c.prefix(idx+1, xs, wildcard); d > depth {
+			pattern, depth = p, d
+		}
+	}
+	return pattern, depth
+}
+
+func (t *wildcardTrie[T]) Walk(fn func(pattern string, v T) bool) {
+	t.walk(fn)
+}
+
+func (t *wildcardTrie[T]) walk(fn func(pattern string, v T) bool) bool {
+	if t.pattern != "" && !fn(t.pattern, t.value) {
+		return false
+	}
+	for i := range t.children {
+		if !t.children[i].walk(fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckInvariants verifies the structure of the trie: all nodes use the same
+// separator, keys do not contain it, siblings have distinct keys and patterns
+// are consistent with the keys of their ancestors. It returns an error
+// describing the first violation found.
+func (t *wildcardTrie[T]) CheckInvariants() error {
+	if t.key != "" || t.pattern != "" {
+		return fmt.Errorf("root has key %q and pattern %q", t.key, t.pattern)
+	}
+	return t.checkInvariants(t.separator)
+}
+
+func (t *wildcardTrie[T]) checkInvariants(sep string) error {
+	keys := make(map[string]bool, len(t.children))
+	for i := range t.children {
+		c := &t.children[i]
+		if c.separator != sep {
+			return fmt.Errorf("%s: separator %q, expected %q", c.pattern, c.separator, sep)
+		}
+		if strings.Contains(c.key, sep) {
+			return fmt.Errorf("%s: key %q contains separator", c.pattern, c.key)
+		}
+		if keys[c.key] {
+			return fmt.Errorf("%s: duplicate key %q", t.pattern, c.key)
+		}
+		keys[c.key] = true
+		want := t.pattern + sep + c.key
+		if t.pattern == "" {
+			want = "/" + c.key
+		}
+		if c.pattern != want {
+			return fmt.Errorf("%s: pattern inconsistent with ancestry, expected %s", c.pattern, want)
+		}
+		if err := c.checkInvariants(sep); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (t *wildcardTrie) equals(other wildcardTrie) bool {
+func (t *wildcardTrie[T]) equals(other wildcardTrie[T]) bool {
 	if t.separator != other.separator {
 		return false
 	}
@@ -141,7 +349,7 @@ func (t *wildcardTrie) equals(other wildcardTrie) bool {
 	return true
 }
 
-func (t wildcardTrie) String() string {
+func (t wildcardTrie[T]) String() string {
 	b := &strings.Builder{}
 	b.WriteString("WildcardTrie(")
 	b.WriteString(t.separator)
@@ -150,7 +358,7 @@ func (t wildcardTrie) String() string {
 	return b.String()
 }
 
-func (t *wildcardTrie) string(b *strings.Builder) {
+func (t *wildcardTrie[T]) string(b *strings.Builder) {
 	b.WriteString("{\"")
 	b.WriteString(t.pattern)
 	b.WriteString(fmt.Sprintf("\"=%v", t.value))
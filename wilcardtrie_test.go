@@ -6,82 +6,83 @@ package treemux
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 )
 
 func TestWildcardTrie_Equals(t *testing.T) {
 	cases := []struct {
 		name  string
-		left  wildcardTrie
-		right wildcardTrie
+		left  wildcardTrie[interface{}]
+		right wildcardTrie[interface{}]
 		want  bool
 	}{
 		{
 			"empty",
-			wildcardTrie{},
-			wildcardTrie{},
+			wildcardTrie[interface{}]{},
+			wildcardTrie[interface{}]{},
 			true,
 		},
 		{
 			"simple equals no value",
-			wildcardTrie{key: "foo"},
-			wildcardTrie{key: "foo"},
+			wildcardTrie[interface{}]{key: "foo"},
+			wildcardTrie[interface{}]{key: "foo"},
 			true,
 		},
 		{
 			"simple equals",
-			wildcardTrie{key: "foo", value: 1},
-			wildcardTrie{key: "foo", value: 1},
+			wildcardTrie[interface{}]{key: "foo", value: 1},
+			wildcardTrie[interface{}]{key: "foo", value: 1},
 			true,
 		},
 		{
 			"equals with children",
-			wildcardTrie{key: "foo", value: 1,
-				children: []wildcardTrie{{key: "bar", value: 1}}},
-			wildcardTrie{key: "foo", value: 1,
-				children: []wildcardTrie{{key: "bar", value: 1}}},
+			wildcardTrie[interface{}]{key: "foo", value: 1,
+				children: []wildcardTrie[interface{}]{{key: "bar", value: 1}}},
+			wildcardTrie[interface{}]{key: "foo", value: 1,
+				children: []wildcardTrie[interface{}]{{key: "bar", value: 1}}},
 			true,
 		},
 		{
 			"unequal key",
-			wildcardTrie{key: "foo"},
-			wildcardTrie{key: "moo"},
+			wildcardTrie[interface{}]{key: "foo"},
+			wildcardTrie[interface{}]{key: "moo"},
 			false,
 		},
 		{
 			"unequal value",
-			wildcardTrie{key: "foo", value: 1},
-			wildcardTrie{key: "foo", value: 2},
+			wildcardTrie[interface{}]{key: "foo", value: 1},
+			wildcardTrie[interface{}]{key: "foo", value: 2},
 			false,
 		},
 		{
 			"with and without value",
-			wildcardTrie{key: "foo"},
-			wildcardTrie{key: "foo", value: 1},
+			wildcardTrie[interface{}]{key: "foo"},
+			wildcardTrie[interface{}]{key: "foo", value: 1},
 			false,
 		},
 		{
 			"unequal child value",
-			wildcardTrie{key: "foo", value: 1,
-				children: []wildcardTrie{{key: "bar", value: 1}}},
-			wildcardTrie{key: "foo", value: 1,
-				children: []wildcardTrie{{key: "bar", value: 2}}},
+			wildcardTrie[interface{}]{key: "foo", value: 1,
+				children: []wildcardTrie[interface{}]{{key: "bar", value: 1}}},
+			wildcardTrie[interface{}]{key: "foo", value: 1,
+				children: []wildcardTrie[interface{}]{{key: "bar", value: 2}}},
 			false,
 		},
 		{
 			"no child value",
-			wildcardTrie{key: "foo", value: 1,
-				children: []wildcardTrie{{key: "bar", value: 1}}},
-			wildcardTrie{key: "foo", value: 1,
-				children: []wildcardTrie{{key: "bar"}}},
+			wildcardTrie[interface{}]{key: "foo", value: 1,
+				children: []wildcardTrie[interface{}]{{key: "bar", value: 1}}},
+			wildcardTrie[interface{}]{key: "foo", value: 1,
+				children: []wildcardTrie[interface{}]{{key: "bar"}}},
 			false,
 		},
 		{
 			"different number of children",
-			wildcardTrie{key: "foo", value: 1,
-				children: []wildcardTrie{{key: "bar", value: 1}}},
-			wildcardTrie{key: "foo", value: 1,
-				children: []wildcardTrie{{key: "bar", value: 1}, {key: "bla", value: 1}}},
+			wildcardTrie[interface{}]{key: "foo", value: 1,
+				children: []wildcardTrie[interface{}]{{key: "bar", value: 1}}},
+			wildcardTrie[interface{}]{key: "foo", value: 1,
+				children: []wildcardTrie[interface{}]{{key: "bar", value: 1}, {key: "bla", value: 1}}},
 			false,
 		},
 	}
@@ -98,19 +99,19 @@ func TestWildcardTrie_Equals(t *testing.T) {
 }
 
 func TestWildcardTrie_Get(t *testing.T) {
-	basicTrie := wildcardTrie{
+	basicTrie := wildcardTrie[interface{}]{
 		separator: "/",
 		value:     -1,
-		children: []wildcardTrie{
-			{"/", "moo", "/moo", 1, []wildcardTrie{{"/", "cow", "/moo/cow", 14, nil}}},
-			{"/", "foo", "/foo", 2, []wildcardTrie{
+		children: []wildcardTrie[interface{}]{
+			{"/", "moo", "/moo", 1, []wildcardTrie[interface{}]{{"/", "cow", "/moo/cow", 14, nil}}},
+			{"/", "foo", "/foo", 2, []wildcardTrie[interface{}]{
 				{"/", "bar", "/foo/bar", 3, nil},
 				{"/", "*", "/foo/*", 99, nil},
-				{"/", "bla", "/foo/bla", 5, []wildcardTrie{{"/", "*", "/foo/bla/*", 6, nil}}}}}},
+				{"/", "bla", "/foo/bla", 5, []wildcardTrie[interface{}]{{"/", "*", "/foo/bla/*", 6, nil}}}}}},
 	}
 	cases := []struct {
 		name        string
-		tr          wildcardTrie
+		tr          wildcardTrie[interface{}]
 		input       string
 		want        interface{}
 		wantPattern string
@@ -130,8 +131,8 @@ func TestWildcardTrie_Get(t *testing.T) {
 		{"unknown leaf", basicTrie, "moo/cowpie", nil, ""},
 		{
 			"unsupported partial wildcard",
-			wildcardTrie{
-				separator: "/", key: "", value: "", children: []wildcardTrie{
+			wildcardTrie[interface{}]{
+				separator: "/", key: "", value: "", children: []wildcardTrie[interface{}]{
 					{separator: "/", key: "foo*", value: 42},
 				}},
 			"foobar",
@@ -152,6 +153,34 @@ func TestWildcardTrie_Get(t *testing.T) {
 	}
 }
 
+func TestWildcardTrie_Prefix(t *testing.T) {
+	tr := newWildcardTrie("/")
+	tr.Add("/foo/bar/baz", 1)
+	tr.Add("/foo/*/qux", 2)
+	tr.Add("/moo", 3)
+
+	cases := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"exact", "/foo/bar/baz", "/foo/bar/baz"},
+		{"no leading separator", "foo/bar/baz", "/foo/bar/baz"},
+		{"partial", "/foo/bar", "/foo/bar"},
+		{"deeper than trie", "/moo/cow", "/moo"},
+		{"deepest via wildcard", "/foo/bar/qux/quux", "/foo/*/qux"},
+		{"wildcard", "/foo/zzz", "/foo/*"},
+		{"only root", "/bla", ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := tr.Prefix(c.input); got != c.want {
+				t.Errorf("expected %q, got %q", c.want, got)
+			}
+		})
+	}
+}
+
 func TestWildcardTrie_Add(t *testing.T) {
 	type args struct {
 		key   string
@@ -159,98 +188,106 @@ func TestWildcardTrie_Add(t *testing.T) {
 	}
 	cases := []struct {
 		name  string
-		tr    wildcardTrie
+		tr    wildcardTrie[interface{}]
 		args  args
-		want  *wildcardTrie
+		want  *wildcardTrie[interface{}]
 		panic string
 	}{
 		{
 			"add first node",
-			wildcardTrie{"/", "", "/", nil, nil},
+			wildcardTrie[interface{}]{"/", "", "/", nil, nil},
 			args{"foo", 1},
-			&wildcardTrie{"/", "", "/", nil, []wildcardTrie{{"/", "foo", "/foo", 1, nil}}},
+			&wildcardTrie[interface{}]{"/", "", "/", nil, []wildcardTrie[interface{}]{{"/", "foo", "/foo", 1, nil}}},
+			"",
+		},
+		{
+			"add with leading separator",
+			wildcardTrie[interface{}]{"/", "", "", nil, nil},
+			args{"/foo/bar", 1},
+			&wildcardTrie[interface{}]{"/", "", "", nil, []wildcardTrie[interface{}]{
+				{"/", "foo", "/foo", nil, []wildcardTrie[interface{}]{{"/", "bar", "/foo/bar", 1, nil}}}}},
 			"",
 		},
 		{
 			"add to existing node",
-			wildcardTrie{"/", "", "", nil, []wildcardTrie{{"/", "foo", "/foo", 1, nil}}},
+			wildcardTrie[interface{}]{"/", "", "", nil, []wildcardTrie[interface{}]{{"/", "foo", "/foo", 1, nil}}},
 			args{"foo/bar", 2},
-			&wildcardTrie{
-				"/", "", "", nil, []wildcardTrie{
-					{"/", "foo", "/foo", 1, []wildcardTrie{{"/", "bar", "/foo/bar", 2, nil}}}}},
+			&wildcardTrie[interface{}]{
+				"/", "", "", nil, []wildcardTrie[interface{}]{
+					{"/", "foo", "/foo", 1, []wildcardTrie[interface{}]{{"/", "bar", "/foo/bar", 2, nil}}}}},
 			"",
 		},
 		{
 			"add wildcard node to existing node",
-			wildcardTrie{
-				"/", "", "", nil, []wildcardTrie{
-					{"/", "foo", "/foo", 1, []wildcardTrie{{"/", "bar", "/foo/bar", 2, nil}}}}},
+			wildcardTrie[interface{}]{
+				"/", "", "", nil, []wildcardTrie[interface{}]{
+					{"/", "foo", "/foo", 1, []wildcardTrie[interface{}]{{"/", "bar", "/foo/bar", 2, nil}}}}},
 			args{"foo/*", 99},
-			&wildcardTrie{
-				"/", "", "", nil, []wildcardTrie{
-					{"/", "foo", "/foo", 1, []wildcardTrie{
+			&wildcardTrie[interface{}]{
+				"/", "", "", nil, []wildcardTrie[interface{}]{
+					{"/", "foo", "/foo", 1, []wildcardTrie[interface{}]{
 						{"/", "bar", "/foo/bar", 2, nil},
 						{"/", "*", "/foo/*", 99, nil}}}}},
 			"",
 		},
 		{
 			"add wildcard node to existing sub-node",
-			wildcardTrie{
-				"/", "", "", nil, []wildcardTrie{
-					{"/", "foo", "/foo", 1, []wildcardTrie{
+			wildcardTrie[interface{}]{
+				"/", "", "", nil, []wildcardTrie[interface{}]{
+					{"/", "foo", "/foo", 1, []wildcardTrie[interface{}]{
 						{"/", "bar", "/foo/bar", 2, nil},
 						{"/", "*", "/foo/*", 99, nil}}}}},
 			args{"foo/bla/*", 6},
-			&wildcardTrie{
-				"/", "", "", nil, []wildcardTrie{
-					{"/", "foo", "/foo", 1, []wildcardTrie{
+			&wildcardTrie[interface{}]{
+				"/", "", "", nil, []wildcardTrie[interface{}]{
+					{"/", "foo", "/foo", 1, []wildcardTrie[interface{}]{
 						{"/", "bar", "/foo/bar", 2, nil},
 						{"/", "*", "/foo/*", 99, nil},
-						{"/", "bla", "/foo/bla", nil, []wildcardTrie{
+						{"/", "bla", "/foo/bla", nil, []wildcardTrie[interface{}]{
 							{"/", "*", "/foo/bla/*", 6, nil}}}}}}},
 			"",
 		},
 		{
 			"set value on valueless existing sub-node",
-			wildcardTrie{
-				"/", "", "", nil, []wildcardTrie{
-					{"/", "foo", "/foo", 1, []wildcardTrie{
+			wildcardTrie[interface{}]{
+				"/", "", "", nil, []wildcardTrie[interface{}]{
+					{"/", "foo", "/foo", 1, []wildcardTrie[interface{}]{
 						{"/", "bar", "/foo/bar", 2, nil},
 						{"/", "*", "/foo/*", 99, nil},
-						{"/", "bla", "/foo/bla", nil, []wildcardTrie{
+						{"/", "bla", "/foo/bla", nil, []wildcardTrie[interface{}]{
 							{"/", "*", "/foo/bla/*", 6, nil}}}}}}},
 			args{"foo/bla", 5},
-			&wildcardTrie{
-				"/", "", "", nil, []wildcardTrie{
-					{"/", "foo", "/foo", 1, []wildcardTrie{
+			&wildcardTrie[interface{}]{
+				"/", "", "", nil, []wildcardTrie[interface{}]{
+					{"/", "foo", "/foo", 1, []wildcardTrie[interface{}]{
 						{"/", "bar", "/foo/bar", 2, nil},
 						{"/", "*", "/foo/*", 99, nil},
-						{"/", "bla", "/foo/bla", 5, []wildcardTrie{
+						{"/", "bla", "/foo/bla", 5, []wildcardTrie[interface{}]{
 							{"/", "*", "/foo/bla/*", 6, nil}}}}}}},
 			"",
 		},
 		{
 			"update value on existing sub-node",
-			wildcardTrie{
-				"/", "", "", nil, []wildcardTrie{
-					{"/", "foo", "/foo", 1, []wildcardTrie{
+			wildcardTrie[interface{}]{
+				"/", "", "", nil, []wildcardTrie[interface{}]{
+					{"/", "foo", "/foo", 1, []wildcardTrie[interface{}]{
 						{"/", "bar", "/foo/bar", 2, nil},
 						{"/", "*", "/foo/*", 99, nil},
-						{"/", "bla", "/foo/bla", 5, []wildcardTrie{
+						{"/", "bla", "/foo/bla", 5, []wildcardTrie[interface{}]{
 							{"/", "*", "/foo/bla/*", 6, nil}}}}}}},
 			args{"/foo/bar", 666},
-			&wildcardTrie{
-				"/", "", "", nil, []wildcardTrie{
-					{"/", "foo", "/foo", 1, []wildcardTrie{
+			&wildcardTrie[interface{}]{
+				"/", "", "", nil, []wildcardTrie[interface{}]{
+					{"/", "foo", "/foo", 1, []wildcardTrie[interface{}]{
 						{"/", "bar", "/foo/bar", 666, nil},
 						{"/", "*", "/foo/*", 99, nil},
-						{"/", "bla", "/foo/bla", 5, []wildcardTrie{
+						{"/", "bla", "/foo/bla", 5, []wildcardTrie[interface{}]{
 							{"/", "*", "/foo/bla/*", 6, nil}}}}}}},
 			"",
 		},
 		{
 			"! end in slash",
-			wildcardTrie{separator: "/", key: ""},
+			wildcardTrie[interface{}]{separator: "/", key: ""},
 			args{"foo/bar/", 1},
 			nil,
 			"path cannot end with slash",
@@ -272,3 +309,178 @@ func TestWildcardTrie_Add(t *testing.T) {
 		})
 	}
 }
+
+func TestWildcardTrie_CheckInvariants(t *testing.T) {
+	valid := newWildcardTrie("/")
+	valid.Add("/foo/bar", 1)
+	valid.Add("/foo/*/baz", 2)
+	valid.Add("qux", 3)
+
+	cases := []struct {
+		name string
+		trie WildcardTrie[interface{}]
+		want bool
+	}{
+		{"empty", newWildcardTrie("/"), true},
+		{"valid", valid, true},
+		{
+			"root with key",
+			&wildcardTrie[interface{}]{separator: "/", key: "foo"},
+			false,
+		},
+		{
+			"separator mismatch",
+			&wildcardTrie[interface{}]{separator: "/", children: []wildcardTrie[interface{}]{{separator: ".", key: "foo", pattern: "/foo"}}},
+			false,
+		},
+		{
+			"key with separator",
+			&wildcardTrie[interface{}]{separator: "/", children: []wildcardTrie[interface{}]{{separator: "/", key: "foo/bar", pattern: "/foo/bar"}}},
+			false,
+		},
+		{
+			"duplicate keys",
+			&wildcardTrie[interface{}]{separator: "/", children: []wildcardTrie[interface{}]{
+				{separator: "/", key: "foo", pattern: "/foo"},
+				{separator: "/", key: "foo", pattern: "/foo"},
+			}},
+			false,
+		},
+		{
+			"inconsistent pattern",
+			&wildcardTrie[interface{}]{separator: "/", children: []wildcardTrie[interface{}]{
+				{separator: "/", key: "foo", pattern: "/foo", children: []wildcardTrie[interface{}]{
+					{separator: "/", key: "bar", pattern: "/baz/bar"},
+				}},
+			}},
+			false,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if err := c.trie.CheckInvariants(); (err == nil) != c.want {
+				t.Errorf("expected valid %v, got %v", c.want, err)
+			}
+		})
+	}
+}
+
+// FuzzWildcardTrie adds and gets a newline-separated sequence of patterns
+// (prefixed with "+") and paths, checking the invariants after each step and
+// comparing results to the DFAMatcher.
+func FuzzWildcardTrie(f *testing.F) {
+	f.Add("+/foo/bar\n+/foo/*\n/foo/bar\n/foo/baz\n/bar")
+	f.Add("+/*/*/a\n+/x/*/b\n+/x/y/a\n/x/y/a\n/x/y/b\n/x")
+	f.Add("+a/b\n+.\n/a/b\na")
+	f.Fuzz(func(t *testing.T, ops string) {
+		trie := newWildcardTrie("/")
+		dfa := NewDFAMatcher()
+		for i, op := range strings.Split(ops, "\n") {
+			if strings.Contains(op, "//") || strings.HasSuffix(op, "/") {
+				// empty path elements are not supported
+				continue
+			}
+			if strings.HasPrefix(op, "+") {
+				p := op[1:]
+				if p == "" {
+					continue
+				}
+				trie.Add(p, i)
+				dfa.Add(p, i)
+				if err := trie.CheckInvariants(); err != nil {
+					t.Fatalf("after adding %q: %s", p, err)
+				}
+				if _, pattern := trie.Get(p); pattern == "" {
+					t.Fatalf("added %q, but got no match", p)
+				}
+				continue
+			}
+			v1, p1 := trie.Get(op)
+			v2, p2 := dfa.Trace(op, nil)
+			if v1 != v2 || p1 != p2 {
+				t.Fatalf("%q: trie returned %v %q, dfa %v %q", op, v1, p1, v2, p2)
+			}
+		}
+	})
+}
+
+func TestNewWildcardTrie(t *testing.T) {
+	tr := NewWildcardTrie[int]("/")
+	tr.Add("/foo/*", 1)
+	tr.Add("/foo/bar/baz", 2)
+
+	cases := []struct {
+		path    string
+		want    int
+		pattern string
+	}{
+		{"/foo/bar", 1, "/foo/*"},
+		{"/foo/bar/baz", 2, "/foo/bar/baz"},
+		{"/bar", 0, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.path, func(t *testing.T) {
+			v, pattern := tr.Get(c.path)
+			if v != c.want || pattern != c.pattern {
+				t.Errorf("expected %d %q, got %d %q", c.want, c.pattern, v, pattern)
+			}
+		})
+	}
+}
+
+func TestNewWildcardTrie_concurrent(t *testing.T) {
+	tr := NewWildcardTrie[int]("/")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			tr.Add(fmt.Sprintf("/foo/%d", i), i)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		tr.Get(fmt.Sprintf("/foo/%d", i))
+	}
+	<-done
+	if v, _ := tr.Get("/foo/99"); v != 99 {
+		t.Errorf("expected 99, got %d", v)
+	}
+}
+
+func TestWildcardTrie_Walk(t *testing.T) {
+	tr := NewWildcardTrie[int]("/")
+	tr.Add("/foo/bar", 1)
+	tr.Add("/foo/*", 2)
+	tr.Add("/baz", 3)
+
+	var got []string
+	tr.Walk(func(pattern string, v int) bool {
+		got = append(got, fmt.Sprintf("%s=%d", pattern, v))
+		return true
+	})
+	want := "[/foo=0 /foo/bar=1 /foo/*=2 /baz=3]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("expected %s, got %v", want, got)
+	}
+
+	got = nil
+	tr.Walk(func(pattern string, _ int) bool {
+		got = append(got, pattern)
+		return len(got) < 2
+	})
+	if len(got) != 2 {
+		t.Errorf("expected walk to stop after 2, got %v", got)
+	}
+}
+
+func TestWildcardTrie_TryAdd(t *testing.T) {
+	tr := NewWildcardTrie[int]("/")
+	if err := tr.TryAdd("/foo/bar", 1); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if err := tr.TryAdd("/foo/", 2); err == nil {
+		t.Errorf("expected error")
+	}
+	if v, p := tr.Get("/foo/bar"); v != 1 || p != "/foo/bar" {
+		t.Errorf("expected 1 /foo/bar, got %v %s", v, p)
+	}
+}
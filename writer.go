@@ -0,0 +1,89 @@
+// Copyright 2022 Hayo van Loon. All rights reserved.
+// Use of this source code is governed by an Apache
+// license that can be found in the LICENSE file.
+
+package treemux
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"sync/atomic"
+)
+
+// responseWriter records what a handler does with the response. It keeps
+// supporting flushing and hijacking when the underlying writer does.
+// Informational responses, like early hints, are passed on without being
+// recorded.
+type responseWriter struct {
+	http.ResponseWriter
+	status  int
+	written int64
+	started int32
+
+	// beforeHeader is called right before the header is written.
+	beforeHeader func(status int)
+}
+
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	return &responseWriter{ResponseWriter: w}
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.hasStarted() {
+		return
+	}
+	if informational(status) {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.status = status
+	atomic.StoreInt32(&w.started, 1)
+	if w.beforeHeader != nil {
+		w.beforeHeader(status)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(bs []byte) (int, error) {
+	if !w.hasStarted() {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(bs)
+	w.written += int64(n)
+	return n, err
+}
+
+// hasStarted reports whether the handler has started writing the response. It
+// is safe to call from other goroutines.
+func (w *responseWriter) hasStarted() bool {
+	return atomic.LoadInt32(&w.started) == 1
+}
+
+// Status returns the response status code, or zero if nothing was written.
+func (w *responseWriter) Status() int {
+	return w.status
+}
+
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if !w.hasStarted() {
+			w.WriteHeader(http.StatusOK)
+		}
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijacking not supported")
+	}
+	return h.Hijack()
+}
+
+// Unwrap returns the underlying writer, for use by http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}